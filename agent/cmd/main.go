@@ -6,6 +6,7 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/yusing/godoxy/agent/pkg/agent"
+	"github.com/yusing/godoxy/agent/pkg/discovery"
 	"github.com/yusing/godoxy/agent/pkg/env"
 	"github.com/yusing/godoxy/agent/pkg/server"
 	"github.com/yusing/godoxy/internal/metrics/systeminfo"
@@ -23,8 +24,14 @@ func main() {
 	}
 	zerolog.TimeFieldFormat = writer.TimeFormat
 	log.Logger = zerolog.New(writer).Level(zerolog.InfoLevel).With().Timestamp().Logger()
+	caPEM, srvPEM := env.AgentCACert, env.AgentSSLCert
+	if rotatedCA, rotatedSrv, ok := server.LoadRotatedCerts(); ok {
+		log.Info().Msgf("using rotated certificates from %s", env.AgentCertsFile)
+		caPEM, srvPEM = rotatedCA, rotatedSrv
+	}
+
 	ca := &agent.PEMPair{}
-	err := ca.Load(env.AgentCACert)
+	err := ca.Load(caPEM)
 	if err != nil {
 		log.Fatal().Err(err).Msg("init CA error")
 	}
@@ -34,7 +41,7 @@ func main() {
 	}
 
 	srv := &agent.PEMPair{}
-	srv.Load(env.AgentSSLCert)
+	err = srv.Load(srvPEM)
 	if err != nil {
 		log.Fatal().Err(err).Msg("init SSL error")
 	}
@@ -45,13 +52,19 @@ func main() {
 
 	log.Info().Msgf("GoDoxy Agent version %s", version.Get())
 	log.Info().Msgf("Agent name: %s", env.AgentName)
-	log.Info().Msgf("Agent port: %d", env.AgentPort)
+	if env.AgentTunnelURL != "" {
+		log.Info().Msgf("Agent tunnel: %s", env.AgentTunnelURL)
+	} else {
+		log.Info().Msgf("Agent port: %d", env.AgentPort)
+	}
 	log.Info().Msgf("Agent runtime: %s", env.Runtime)
 
 	log.Info().Msg(`
 Tips:
 1. To change the agent name, you can set the AGENT_NAME environment variable.
 2. To change the agent port, you can set the AGENT_PORT environment variable.
+3. To connect to GoDoxy from behind NAT, set AGENT_TUNNEL_URL to wss://<godoxy>/api/v1/agent/tunnel.
+4. To discover routes on the agent instead of GoDoxy, set AGENT_DISCOVERY=true, then AGENT_SOCKET_PROXY=false to stop exposing the socket.
 `)
 
 	t := task.RootTask("agent", false)
@@ -61,7 +74,23 @@ Tips:
 		Port:       env.AgentPort,
 	}
 
-	server.StartAgentServer(t, opts)
+	if env.AgentDiscovery {
+		log.Info().Msgf("Route discovery enabled, route files: %s", env.AgentRoutesDir)
+		opts.Discovery = discovery.New("unix://"+env.DockerSocket, env.AgentRoutesDir)
+		opts.Discovery.Start(t)
+	}
+	if !env.AgentSocketProxy {
+		log.Info().Msgf("%s socket proxy disabled", strutils.Title(string(env.Runtime)))
+	}
+	if env.AgentExec {
+		log.Warn().Msg("Exec enabled, GoDoxy can run commands on this host")
+	}
+
+	if env.AgentTunnelURL != "" {
+		server.StartTunnelClient(t, opts)
+	} else {
+		server.StartAgentServer(t, opts)
+	}
 
 	if socketproxy.ListenAddr != "" {
 		runtime := strutils.Title(string(env.Runtime))
@@ -38,11 +38,17 @@ func AddAgent(agent *AgentConfig) {
 	agentPool.Store(agent.Addr, agent)
 }
 
+// RemoveAgent removes the agent from the pool and closes its tunnel,
+// so a tunnelled agent reconnects to the agent that replaces it.
 func RemoveAgent(agent *AgentConfig) {
 	agentPool.Delete(agent.Addr)
+	agent.CloseTunnel()
 }
 
 func RemoveAllAgents() {
+	for _, agent := range agentPool.Range {
+		agent.CloseTunnel()
+	}
 	agentPool.Clear()
 }
 
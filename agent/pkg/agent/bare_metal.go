@@ -10,6 +10,9 @@ var (
 	AGENT_PORT="{{.Port}}" \
 	AGENT_CA_CERT="{{.CACert}}" \
 	AGENT_SSL_CERT="{{.SSLCert}}" \
+	{{ if .TunnelURL -}}
+	AGENT_TUNNEL_URL="{{.TunnelURL}}" \
+	{{ end -}}
 	{{ if eq .ContainerRuntime "nerdctl" -}}
 	DOCKER_SOCKET="/var/run/containerd/containerd.sock" \
 	RUNTIME="nerdctl" \
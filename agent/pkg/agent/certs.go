@@ -0,0 +1,176 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// EndpointCerts replaces the certificates of the agent, see RotateCertsRequest.
+const EndpointCerts = "/certs"
+
+var ErrAgentRevoked = errors.New("agent certificate revoked")
+
+// RotateCertsRequest is sent to the agent to replace its CA and server certificate.
+//
+// Client certificates issued by the previous CA are still accepted for GracePeriod.
+type RotateCertsRequest struct {
+	CA          string        `json:"ca"`     // PEMPair.String() of the new CA
+	Server      string        `json:"server"` // PEMPair.String() of the new server certificate
+	GracePeriod time.Duration `json:"grace_period"`
+}
+
+type agentCerts struct {
+	ca     *x509.Certificate
+	client tls.Certificate
+	roots  *x509.CertPool
+}
+
+func parseCertPEM(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("invalid certificate PEM")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// setCerts replaces the CA and client certificate used to connect to the agent.
+func (cfg *AgentConfig) setCerts(caPEM, crt, key []byte) error {
+	clientCert, err := tls.X509KeyPair(crt, key)
+	if err != nil {
+		return err
+	}
+	ca, err := parseCertPEM(caPEM)
+	if err != nil {
+		return fmt.Errorf("invalid ca certificate: %w", err)
+	}
+
+	cfg.certs.Store(&agentCerts{ca: ca, client: clientCert, roots: poolOf(ca)})
+	return nil
+}
+
+func (cfg *AgentConfig) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return &cfg.certs.Load().client, nil
+}
+
+func (cfg *AgentConfig) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("agent did not present a certificate")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         cfg.certs.Load().roots,
+		DNSName:       CertsDNSName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+		return err
+	}
+	cfg.serverCert.Store(cs.PeerCertificates[0])
+	return nil
+}
+
+// CAFingerprint returns the hex encoded SHA-256 fingerprint of the current CA of the agent.
+func (cfg *AgentConfig) CAFingerprint() string {
+	certs := cfg.certs.Load()
+	if certs == nil {
+		return ""
+	}
+	sum := sha256.Sum256(certs.ca.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// CertsExpiry returns the earliest expiry of the CA, the client certificate
+// and the last seen server certificate of the agent.
+func (cfg *AgentConfig) CertsExpiry() time.Time {
+	certs := cfg.certs.Load()
+	if certs == nil {
+		return time.Time{}
+	}
+	expiry := certs.ca.NotAfter
+	if leaf := certs.client.Leaf; leaf != nil && leaf.NotAfter.Before(expiry) {
+		expiry = leaf.NotAfter
+	}
+	if srv := cfg.serverCert.Load(); srv != nil && srv.NotAfter.Before(expiry) {
+		expiry = srv.NotAfter
+	}
+	return expiry
+}
+
+// Revoke stops all connections to the agent.
+func (cfg *AgentConfig) Revoke() {
+	cfg.revoked.Store(true)
+	cfg.CloseTunnel()
+	if cfg.httpClient != nil {
+		cfg.httpClient.CloseIdleConnections()
+	}
+}
+
+// IsRevoked returns whether the agent has been revoked.
+func (cfg *AgentConfig) IsRevoked() bool {
+	return cfg.revoked.Load()
+}
+
+// RotateCerts issues a new set of certificates expiring at notAfter and installs them on the agent.
+//
+// The new server certificate is trusted before the agent switches to it, and the previous client
+// certificate stays valid on the agent for gracePeriod. The returned PEMs should be saved by the caller.
+func (cfg *AgentConfig) RotateCerts(ctx context.Context, notAfter time.Time, gracePeriod time.Duration) (ca, client *PEMPair, err error) {
+	ca, srv, client, err := NewAgentWithExpiry(notAfter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	old := cfg.certs.Load()
+	newCA, err := parseCertPEM(ca.Cert)
+	if err != nil {
+		return nil, nil, err
+	}
+	// trust both the current and the new server certificate until the agent switched,
+	// it is kept on failure since the agent may have switched without us seeing the response
+	cfg.certs.Store(&agentCerts{ca: old.ca, client: old.client, roots: poolOf(old.ca, newCA)})
+
+	body, err := sonic.Marshal(RotateCertsRequest{
+		CA:          ca.String(),
+		Server:      srv.String(),
+		GracePeriod: gracePeriod,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := cfg.Do(ctx, http.MethodPost, EndpointCerts, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("agent rejected certificates: HTTP %d", resp.StatusCode)
+	}
+
+	if err := cfg.setCerts(ca.Cert, client.Cert, client.Key); err != nil {
+		return nil, nil, err
+	}
+	// reconnect with the new client certificate
+	cfg.httpClient.CloseIdleConnections()
+	return ca, client, nil
+}
+
+func poolOf(certs ...*x509.Certificate) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return pool
+}
@@ -11,8 +11,11 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/hashicorp/yamux"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/valyala/fasthttp"
@@ -25,21 +28,38 @@ type AgentConfig struct {
 	Name    string           `json:"name"`
 	Version version.Version  `json:"version" swaggertype:"string"`
 	Runtime ContainerRuntime `json:"runtime"`
-
+	Tunnel  bool             `json:"tunnel"` // the agent dials the main instance instead, Addr is the agent name
+
+	infoMu                    sync.RWMutex // guards Version and Runtime, updated when a tunnel reconnects
+	tunnel                    atomic.Pointer[yamux.Session]
+	pendingHandshakes         atomic.Int32 // tunnel websockets in the handshake
+	certs                     atomic.Pointer[agentCerts]
+	serverCert                atomic.Pointer[x509.Certificate] // last seen server certificate
+	revoked                   atomic.Bool
+	status                    atomic.Pointer[AgentStatus] // last heartbeat status
 	httpClient                *http.Client
 	fasthttpClientHealthCheck *fasthttp.Client
 	tlsConfig                 tls.Config
 	l                         zerolog.Logger
 } // @name Agent
 
+type agentInfo struct {
+	name    string
+	version version.Version
+	runtime ContainerRuntime
+}
+
 const (
-	EndpointVersion    = "/version"
-	EndpointName       = "/name"
-	EndpointRuntime    = "/runtime"
-	EndpointProxyHTTP  = "/proxy/http"
-	EndpointHealth     = "/health"
-	EndpointLogs       = "/logs"
-	EndpointSystemInfo = "/system_info"
+	EndpointVersion     = "/version"
+	EndpointName        = "/name"
+	EndpointRuntime     = "/runtime"
+	EndpointProxyHTTP   = "/proxy/http"
+	EndpointProxyStream = "/proxy/stream"
+	EndpointHealth      = "/health"
+	EndpointLogs        = "/logs"
+	EndpointSystemInfo  = "/system_info"
+	EndpointRoutes      = "/routes"
+	EndpointExec        = "/exec"
 
 	AgentHost = CertsDNSName
 
@@ -79,6 +99,14 @@ func (cfg *AgentConfig) FakeDockerHost() string {
 }
 
 func (cfg *AgentConfig) Parse(addr string) error {
+	if name, ok := parseTunnelAddr(addr); ok {
+		if name == "" {
+			return errors.New("missing agent name in tunnel address")
+		}
+		cfg.Addr = name
+		cfg.Tunnel = true
+		return nil
+	}
 	cfg.Addr = addr
 	return nil
 }
@@ -86,22 +114,17 @@ func (cfg *AgentConfig) Parse(addr string) error {
 var serverVersion = version.Get()
 
 func (cfg *AgentConfig) StartWithCerts(ctx context.Context, ca, crt, key []byte) error {
-	clientCert, err := tls.X509KeyPair(crt, key)
-	if err != nil {
+	if err := cfg.setCerts(ca, crt, key); err != nil {
 		return err
 	}
 
-	// create tls config
-	caCertPool := x509.NewCertPool()
-	ok := caCertPool.AppendCertsFromPEM(ca)
-	if !ok {
-		return errors.New("invalid ca certificate")
-	}
-
 	cfg.tlsConfig = tls.Config{
-		Certificates: []tls.Certificate{clientCert},
-		RootCAs:      caCertPool,
-		ServerName:   CertsDNSName,
+		// the server certificate is verified in verifyConnection against the current CA,
+		// which changes when the certificates are rotated
+		InsecureSkipVerify:   true, //nolint:gosec
+		VerifyConnection:     cfg.verifyConnection,
+		GetClientCertificate: cfg.getClientCertificate,
+		ServerName:           CertsDNSName,
 	}
 
 	// create transport and http client
@@ -110,57 +133,101 @@ func (cfg *AgentConfig) StartWithCerts(ctx context.Context, ca, crt, key []byte)
 
 	cfg.fasthttpClientHealthCheck = cfg.NewFastHTTPHealthCheckClient()
 
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
+	if cfg.Tunnel {
+		// agent info is fetched once the agent connected
+		cfg.Name = cfg.Addr
+		if cfg.Runtime == "" {
+			cfg.Runtime = ContainerRuntimeDocker
+		}
+		cfg.l = log.With().Str("agent", cfg.Name).Logger()
+		log.Info().Msgf("agent %q waiting for tunnel", cfg.Name)
+		return nil
+	}
 
-	// get agent name
-	name, _, err := cfg.fetchString(ctx, EndpointName)
+	info, err := cfg.fetchInfo(ctx, cfg.httpClient)
 	if err != nil {
 		return err
 	}
+	cfg.Name = info.name
+	cfg.l = log.With().Str("agent", cfg.Name).Logger()
+	cfg.setInfo(info)
 
-	cfg.Name = name
+	log.Info().Msgf("agent %q initialized", cfg.Name)
+	return nil
+}
 
-	cfg.l = log.With().Str("agent", cfg.Name).Logger()
+// fetchInfo fetches the name, version and runtime of the agent with client.
+func (cfg *AgentConfig) fetchInfo(ctx context.Context, client *http.Client) (*agentInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// get agent name
+	name, _, err := fetchString(ctx, client, EndpointName)
+	if err != nil {
+		return nil, err
+	}
 
 	// check agent version
-	agentVersion, _, err := cfg.fetchString(ctx, EndpointVersion)
+	agentVersion, _, err := fetchString(ctx, client, EndpointVersion)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	info := &agentInfo{name: name, version: version.Parse(agentVersion)}
+
 	// check agent runtime
-	runtime, status, err := cfg.fetchString(ctx, EndpointRuntime)
+	runtime, status, err := fetchString(ctx, client, EndpointRuntime)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	switch status {
 	case http.StatusOK:
 		switch runtime {
 		case "docker":
-			cfg.Runtime = ContainerRuntimeDocker
+			info.runtime = ContainerRuntimeDocker
 		// case "nerdctl":
-		// 	cfg.Runtime = ContainerRuntimeNerdctl
+		// 	info.runtime = ContainerRuntimeNerdctl
 		case "podman":
-			cfg.Runtime = ContainerRuntimePodman
+			info.runtime = ContainerRuntimePodman
 		default:
-			return fmt.Errorf("invalid agent runtime: %s", runtime)
+			return nil, fmt.Errorf("invalid agent runtime: %s", runtime)
 		}
 	case http.StatusNotFound:
 		// backward compatibility, old agent does not have runtime endpoint
-		cfg.Runtime = ContainerRuntimeDocker
+		info.runtime = ContainerRuntimeDocker
 	default:
-		return fmt.Errorf("failed to get agent runtime: HTTP %d %s", status, runtime)
+		return nil, fmt.Errorf("failed to get agent runtime: HTTP %d %s", status, runtime)
 	}
 
-	cfg.Version = version.Parse(agentVersion)
-
-	if serverVersion.IsNewerThanMajor(cfg.Version) {
-		log.Warn().Msgf("agent %s major version mismatch: server: %s, agent: %s", cfg.Name, serverVersion, cfg.Version)
+	if serverVersion.IsNewerThanMajor(info.version) {
+		log.Warn().Msgf("agent %s major version mismatch: server: %s, agent: %s", name, serverVersion, info.version)
 	}
+	return info, nil
+}
 
-	log.Info().Msgf("agent %q initialized", cfg.Name)
-	return nil
+// setInfo updates the version and runtime of the agent.
+func (cfg *AgentConfig) setInfo(info *agentInfo) {
+	cfg.infoMu.Lock()
+	defer cfg.infoMu.Unlock()
+
+	cfg.Version = info.version
+	cfg.Runtime = info.runtime
+}
+
+// GetVersion returns the version of the agent.
+func (cfg *AgentConfig) GetVersion() version.Version {
+	cfg.infoMu.RLock()
+	defer cfg.infoMu.RUnlock()
+
+	return cfg.Version
+}
+
+// GetRuntime returns the container runtime of the agent.
+func (cfg *AgentConfig) GetRuntime() ContainerRuntime {
+	cfg.infoMu.RLock()
+	defer cfg.infoMu.RUnlock()
+
+	return cfg.Runtime
 }
 
 func (cfg *AgentConfig) Start(ctx context.Context) error {
@@ -194,7 +261,7 @@ func (cfg *AgentConfig) NewFastHTTPHealthCheckClient() *fasthttp.Client {
 			if addr != AgentHost+":443" {
 				return nil, &net.AddrError{Err: "invalid address", Addr: addr}
 			}
-			return net.Dial("tcp", cfg.Addr)
+			return cfg.DialContext(context.Background())
 		},
 		TLSConfig:                     &cfg.tlsConfig,
 		ReadTimeout:                   5 * time.Second,
@@ -225,6 +292,12 @@ func (cfg *AgentConfig) Transport() *http.Transport {
 var dialer = &net.Dialer{Timeout: 5 * time.Second}
 
 func (cfg *AgentConfig) DialContext(ctx context.Context) (net.Conn, error) {
+	if cfg.revoked.Load() {
+		return nil, ErrAgentRevoked
+	}
+	if cfg.Tunnel {
+		return cfg.dialTunnel()
+	}
 	return dialer.DialContext(ctx, "tcp", cfg.Addr)
 }
 
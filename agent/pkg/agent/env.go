@@ -8,6 +8,7 @@ type (
 		CACert           string
 		SSLCert          string
 		ContainerRuntime ContainerRuntime
+		TunnelURL        string
 	}
 	AgentComposeConfig struct {
 		Image string
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+type (
+	// ExecRequest is a command to run on the host of GoDoxy or an agent,
+	// e.g. to start and stop services for the idlewatcher.
+	ExecRequest struct {
+		Command []string          `json:"command"`
+		Env     map[string]string `json:"env,omitempty"`
+		Dir     string            `json:"dir,omitempty"`
+		Timeout time.Duration     `json:"timeout,omitempty"` // default: DefaultExecTimeout
+	}
+	ExecResult struct {
+		ExitCode int    `json:"exit_code"`
+		Output   string `json:"output"` // combined output, truncated to MaxExecOutputSize bytes
+	}
+)
+
+const (
+	DefaultExecTimeout = time.Minute
+	MaxExecOutputSize  = 64 * 1024
+
+	// execWaitDelay is how long to wait for the output after the command exited,
+	// background processes started by the command may keep it open.
+	execWaitDelay = time.Second
+	// maxExecResponseSize bounds the JSON encoded result, escaping may grow the output.
+	maxExecResponseSize = 8 * MaxExecOutputSize
+)
+
+var ErrExecDisabled = errors.New("exec is not enabled on the agent, set AGENT_EXEC=true to enable")
+
+// Run runs the command on this host.
+//
+// A non-zero exit code is not an error, errors are returned only if the command could not be run or timed out.
+func (req *ExecRequest) Run(ctx context.Context) (*ExecResult, error) {
+	if len(req.Command) == 0 {
+		return nil, errors.New("empty command")
+	}
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = DefaultExecTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, req.Command[0], req.Command[1:]...)
+	cmd.Dir = req.Dir
+	cmd.WaitDelay = execWaitDelay
+	if len(req.Env) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range req.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+	}
+
+	output := &cappedBuffer{limit: MaxExecOutputSize}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("command timed out: %w", ctx.Err())
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil, errors.Is(err, exec.ErrWaitDelay):
+		return &ExecResult{Output: output.String()}, nil
+	case errors.As(err, &exitErr):
+		return &ExecResult{ExitCode: exitErr.ExitCode(), Output: output.String()}, nil
+	default:
+		return nil, err
+	}
+}
+
+// Exec runs the command on the agent host.
+func (cfg *AgentConfig) Exec(ctx context.Context, req *ExecRequest) (*ExecResult, error) {
+	body, err := sonic.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cfg.Do(ctx, http.MethodPost, EndpointExec, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrExecDisabled
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("HTTP %d %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	var result ExecResult
+	if err := sonic.ConfigDefault.NewDecoder(io.LimitReader(resp.Body, maxExecResponseSize)).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// cappedBuffer keeps the first limit bytes written to it and discards the rest.
+//
+// The buffer is not embedded so io.Copy cannot bypass the limit with bytes.Buffer.ReadFrom.
+type cappedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+func (b *cappedBuffer) String() string {
+	return b.buf.String()
+}
@@ -15,11 +15,15 @@ import (
 )
 
 func (cfg *AgentConfig) Do(ctx context.Context, method, endpoint string, body io.Reader) (*http.Response, error) {
+	return do(ctx, cfg.httpClient, method, endpoint, body)
+}
+
+func do(ctx context.Context, client *http.Client, method, endpoint string, body io.Reader) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, method, APIBaseURL+endpoint, body)
 	if err != nil {
 		return nil, err
 	}
-	return cfg.httpClient.Do(req)
+	return client.Do(req)
 }
 
 func (cfg *AgentConfig) Forward(req *http.Request, endpoint string) (*http.Response, error) {
@@ -72,7 +76,11 @@ func (cfg *AgentConfig) DoHealthCheck(timeout time.Duration, query string) (ret
 }
 
 func (cfg *AgentConfig) fetchString(ctx context.Context, endpoint string) (string, int, error) {
-	resp, err := cfg.Do(ctx, "GET", endpoint, nil)
+	return fetchString(ctx, cfg.httpClient, endpoint)
+}
+
+func fetchString(ctx context.Context, client *http.Client, endpoint string) (string, int, error) {
+	resp, err := do(ctx, client, "GET", endpoint, nil)
 	if err != nil {
 		return "", 0, err
 	}
@@ -90,10 +98,11 @@ func (cfg *AgentConfig) fetchString(ctx context.Context, endpoint string) (strin
 func (cfg *AgentConfig) Websocket(ctx context.Context, endpoint string) (*websocket.Conn, *http.Response, error) {
 	transport := cfg.Transport()
 	dialer := websocket.Dialer{
-		NetDialContext:    transport.DialContext,
-		NetDialTLSContext: transport.DialTLSContext,
+		NetDialContext:   transport.DialContext,
+		TLSClientConfig:  transport.TLSClientConfig,
+		HandshakeTimeout: 10 * time.Second,
 	}
-	return dialer.DialContext(ctx, APIBaseURL+endpoint, http.Header{
+	return dialer.DialContext(ctx, "wss://"+AgentHost+APIEndpointBase+endpoint, http.Header{
 		"Host": {AgentHost},
 	})
 }
@@ -147,6 +147,11 @@ func newSerialNumber() (*big.Int, error) {
 }
 
 func NewAgent() (ca, srv, client *PEMPair, err error) {
+	return NewAgentWithExpiry(time.Now().AddDate(1000, 0, 0)) // 1000 years
+}
+
+// NewAgentWithExpiry mints a CA, server and client certificate for an agent, all expiring at notAfter.
+func NewAgentWithExpiry(notAfter time.Time) (ca, srv, client *PEMPair, err error) {
 	caSerialNumber, err := newSerialNumber()
 	if err != nil {
 		return nil, nil, nil, err
@@ -159,7 +164,7 @@ func NewAgent() (ca, srv, client *PEMPair, err error) {
 			CommonName:   CertsDNSName,
 		},
 		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(1000, 0, 0), // 1000 years
+		NotAfter:              notAfter,
 		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 		BasicConstraintsValid: true,
 		IsCA:                  true,
@@ -200,7 +205,7 @@ func NewAgent() (ca, srv, client *PEMPair, err error) {
 		},
 		DNSNames:           []string{CertsDNSName},
 		NotBefore:          time.Now(),
-		NotAfter:           time.Now().AddDate(1000, 0, 0), // Add validity period
+		NotAfter:           notAfter,
 		KeyUsage:           x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		SignatureAlgorithm: x509.ECDSAWithSHA256,
@@ -232,7 +237,7 @@ func NewAgent() (ca, srv, client *PEMPair, err error) {
 		},
 		DNSNames:           []string{CertsDNSName},
 		NotBefore:          time.Now(),
-		NotAfter:           time.Now().AddDate(1000, 0, 0),
+		NotAfter:           notAfter,
 		KeyUsage:           x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
 		SignatureAlgorithm: x509.ECDSAWithSHA256,
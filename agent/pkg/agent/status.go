@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/yusing/goutils/version"
+)
+
+type (
+	AgentStatus struct {
+		Online      bool            `json:"online"`
+		LastSeen    time.Time       `json:"last_seen"`
+		RTT         time.Duration   `json:"rtt" swaggertype:"primitive,integer"`
+		Version     version.Version `json:"version" swaggertype:"string"`
+		VersionSkew VersionSkew     `json:"version_skew"`
+		Detail      string          `json:"detail,omitempty"`
+	} // @name AgentStatus
+
+	// VersionSkew is the version of the agent relative to the main instance.
+	VersionSkew string
+)
+
+const (
+	VersionSkewNone       VersionSkew = ""
+	VersionSkewOlder      VersionSkew = "older"
+	VersionSkewNewer      VersionSkew = "newer"
+	VersionSkewOlderMajor VersionSkew = "older_major"
+	VersionSkewNewerMajor VersionSkew = "newer_major"
+)
+
+func GetVersionSkew(agentVersion version.Version) VersionSkew {
+	switch {
+	case agentVersion.IsEqual(serverVersion):
+		return VersionSkewNone
+	case serverVersion.IsNewerThanMajor(agentVersion):
+		return VersionSkewOlderMajor
+	case agentVersion.IsNewerThanMajor(serverVersion):
+		return VersionSkewNewerMajor
+	case serverVersion.IsNewerThan(agentVersion):
+		return VersionSkewOlder
+	default:
+		return VersionSkewNewer
+	}
+}
+
+// Ping fetches the version of the agent and measures the round trip time.
+func (cfg *AgentConfig) Ping(ctx context.Context) (rtt time.Duration, agentVersion version.Version, err error) {
+	start := time.Now()
+	body, status, err := cfg.fetchString(ctx, EndpointVersion)
+	rtt = time.Since(start)
+	if err != nil {
+		return rtt, agentVersion, err
+	}
+	if status != http.StatusOK {
+		return rtt, agentVersion, fmt.Errorf("HTTP %d %s", status, body)
+	}
+	return rtt, version.Parse(body), nil
+}
+
+// Status returns the last heartbeat status of the agent, nil if not checked yet.
+func (cfg *AgentConfig) Status() *AgentStatus {
+	return cfg.status.Load()
+}
+
+func (cfg *AgentConfig) SetStatus(status *AgentStatus) {
+	cfg.status.Store(status)
+}
+
+// IsOffline returns whether the last heartbeats of the agent failed.
+func (cfg *AgentConfig) IsOffline() bool {
+	status := cfg.status.Load()
+	return status != nil && !status.Online
+}
+
+func (cfg *AgentConfig) MarshalJSON() ([]byte, error) {
+	cfg.infoMu.RLock()
+	defer cfg.infoMu.RUnlock()
+
+	type agentConfig AgentConfig
+	return sonic.Marshal(struct {
+		*agentConfig
+		Status *AgentStatus `json:"status"`
+	}{(*agentConfig)(cfg), cfg.Status()})
+}
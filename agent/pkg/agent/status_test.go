@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yusing/goutils/version"
+)
+
+func TestVersionSkew(t *testing.T) {
+	require.Equal(t, VersionSkewNone, GetVersionSkew(serverVersion))
+	require.Equal(t, VersionSkewNewer, GetVersionSkew(version.New(serverVersion.Generation, serverVersion.Major, serverVersion.Minor+1)))
+	require.Equal(t, VersionSkewNewerMajor, GetVersionSkew(version.New(serverVersion.Generation, serverVersion.Major+1, 0)))
+}
+
+func TestAgentStatusJSON(t *testing.T) {
+	cfg := &AgentConfig{Addr: "10.0.0.2:8890", Name: "nas"}
+	data, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"addr":"10.0.0.2:8890","name":"nas","version":"v0.0.0","runtime":"","tunnel":false,"status":null}`, string(data))
+
+	cfg.SetStatus(&AgentStatus{Online: true, RTT: 20 * time.Millisecond})
+	require.False(t, cfg.IsOffline())
+
+	var decoded struct {
+		Status map[string]any `json:"status"`
+	}
+	data, err = json.Marshal(cfg)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, true, decoded.Status["online"])
+	require.InDelta(t, float64(20*time.Millisecond), decoded.Status["rtt"], 0)
+}
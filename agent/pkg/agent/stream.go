@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// NewStreamConn wraps a stream relay websocket as a net.Conn to addr.
+//
+// For udp, each Read and Write is a single datagram.
+func NewStreamConn(conn *websocket.Conn, network, addr string) net.Conn {
+	return &streamConn{
+		Conn:   conn,
+		stream: &tunnelConn{conn: conn},
+		raddr:  streamAddr{network: network, addr: addr},
+	}
+}
+
+type streamConn struct {
+	*websocket.Conn
+	stream *tunnelConn
+	raddr  streamAddr
+}
+
+type streamAddr struct {
+	network, addr string
+}
+
+func (a streamAddr) Network() string { return a.network }
+func (a streamAddr) String() string  { return a.addr }
+
+func (c *streamConn) Read(p []byte) (int, error) {
+	if c.raddr.network == "tcp" {
+		return c.stream.Read(p)
+	}
+	for {
+		typ, data, err := c.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		if typ == websocket.BinaryMessage {
+			return copy(p, data), nil
+		}
+	}
+}
+
+func (c *streamConn) Write(p []byte) (int, error) {
+	return c.stream.Write(p)
+}
+
+func (c *streamConn) RemoteAddr() net.Addr {
+	return c.raddr
+}
+
+func (c *streamConn) SetDeadline(t time.Time) error {
+	return errors.Join(c.SetReadDeadline(t), c.SetWriteDeadline(t))
+}
+
+// DialStream dials a tcp or udp address from the agent, relayed through the mTLS channel to the agent.
+func (cfg *AgentConfig) DialStream(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "udp":
+	default:
+		return nil, &net.OpError{Op: "dial", Net: network, Err: net.UnknownNetworkError(network)}
+	}
+
+	query := url.Values{
+		"scheme": {network},
+		"host":   {addr},
+	}
+	conn, resp, err := cfg.Websocket(ctx, EndpointProxyStream+"?"+query.Encode())
+	if err != nil {
+		if resp != nil {
+			defer resp.Body.Close()
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			return nil, fmt.Errorf("agent failed to dial %s: HTTP %d %s", addr, resp.StatusCode, msg)
+		}
+		return nil, err
+	}
+	return NewStreamConn(conn, network, addr), nil
+}
@@ -0,0 +1,225 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/yamux"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// EndpointTunnel is the websocket endpoint on the main instance that agents in tunnel mode dial.
+	EndpointTunnel = "/api/v1/agent/tunnel"
+	// HeaderAgentName identifies the agent dialing the tunnel endpoint.
+	HeaderAgentName = "X-GoDoxy-Agent-Name"
+
+	TunnelAddrPrefix = "tunnel://"
+)
+
+// maxPendingTunnelHandshakes is the number of tunnel websockets of an agent
+// that can be in the handshake at the same time.
+const maxPendingTunnelHandshakes = 4
+
+// tunnelHandshakeTimeout is the time an agent has to pass the handshake after dialing the tunnel.
+var tunnelHandshakeTimeout = 10 * time.Second
+
+var (
+	ErrAgentNotConnected       = errors.New("agent is not connected")
+	ErrTooManyTunnelHandshakes = errors.New("too many pending tunnel handshakes")
+)
+
+// TunnelConfig returns the yamux config shared by both ends of an agent tunnel.
+func TunnelConfig() *yamux.Config {
+	cfg := yamux.DefaultConfig()
+	cfg.KeepAliveInterval = 15 * time.Second
+	cfg.ConnectionWriteTimeout = 15 * time.Second
+	cfg.LogOutput = io.Discard
+	return cfg
+}
+
+// NewTunnelConn adapts a websocket connection to a byte stream for yamux,
+// each write is sent as a single binary message.
+func NewTunnelConn(conn *websocket.Conn) io.ReadWriteCloser {
+	return &tunnelConn{conn: conn}
+}
+
+type tunnelConn struct {
+	conn   *websocket.Conn
+	reader io.Reader
+	wmu    sync.Mutex
+}
+
+func (c *tunnelConn) Read(p []byte) (int, error) {
+	for {
+		if c.reader == nil {
+			typ, r, err := c.conn.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			if typ != websocket.BinaryMessage {
+				continue
+			}
+			c.reader = r
+		}
+		n, err := c.reader.Read(p)
+		if errors.Is(err, io.EOF) {
+			c.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (c *tunnelConn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if err := c.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *tunnelConn) Close() error {
+	return c.conn.Close()
+}
+
+// ServeTunnel takes over the tunnel websocket of an agent in tunnel mode
+// and blocks until the tunnel is closed or ctx is done.
+//
+// The agent serves its API inside the tunnel with the same mTLS config as the direct mode,
+// so the tunnel only replaces the current one after the agent passed the handshake.
+func (cfg *AgentConfig) ServeTunnel(ctx context.Context, conn *websocket.Conn) error {
+	session, err := cfg.handshakeTunnel(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	if old := cfg.tunnel.Swap(session); old != nil {
+		old.Close()
+	}
+	defer cfg.tunnel.CompareAndSwap(session, nil)
+
+	log.Info().Str("agent", cfg.Name).Msg("agent tunnel connected")
+	select {
+	case <-session.CloseChan():
+	case <-ctx.Done():
+	}
+	log.Info().Str("agent", cfg.Name).Msg("agent tunnel disconnected")
+	return nil
+}
+
+// CanHandshakeTunnel returns whether a new tunnel websocket of the agent would be accepted,
+// i.e. there are less than maxPendingTunnelHandshakes pending handshakes.
+func (cfg *AgentConfig) CanHandshakeTunnel() bool {
+	return cfg.pendingHandshakes.Load() < maxPendingTunnelHandshakes
+}
+
+// handshakeTunnel verifies the agent inside the tunnel websocket within tunnelHandshakeTimeout
+// and returns the session on success, conn is closed on error.
+func (cfg *AgentConfig) handshakeTunnel(ctx context.Context, conn *websocket.Conn) (*yamux.Session, error) {
+	if cfg.pendingHandshakes.Add(1) > maxPendingTunnelHandshakes {
+		cfg.pendingHandshakes.Add(-1)
+		conn.Close()
+		return nil, ErrTooManyTunnelHandshakes
+	}
+	defer cfg.pendingHandshakes.Add(-1)
+
+	// the deadline also unblocks reads and writes of an unresponsive agent,
+	// it is cleared once the agent passed the handshake
+	deadline := time.Now().Add(tunnelHandshakeTimeout)
+	_ = conn.SetReadDeadline(deadline)
+	_ = conn.SetWriteDeadline(deadline)
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	session, err := yamux.Client(NewTunnelConn(conn), TunnelConfig())
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	client := cfg.newTunnelHTTPClient(session)
+	info, err := cfg.fetchInfo(ctx, client)
+	client.CloseIdleConnections()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	if info.name != cfg.Name {
+		session.Close()
+		return nil, fmt.Errorf("agent name mismatch: expected %q, got %q", cfg.Name, info.name)
+	}
+
+	_ = conn.SetReadDeadline(time.Time{})
+	_ = conn.SetWriteDeadline(time.Time{})
+	cfg.setInfo(info)
+	return session, nil
+}
+
+// WaitTunnel blocks until the tunnel of the agent is connected or ctx is done.
+func (cfg *AgentConfig) WaitTunnel(ctx context.Context) error {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for !cfg.IsConnected() {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ErrAgentNotConnected
+		}
+	}
+	return nil
+}
+
+// IsConnected returns whether the agent is reachable, always true for agents in direct mode.
+func (cfg *AgentConfig) IsConnected() bool {
+	if !cfg.Tunnel {
+		return true
+	}
+	tunnel := cfg.tunnel.Load()
+	return tunnel != nil && !tunnel.IsClosed()
+}
+
+// CloseTunnel closes the tunnel of the agent if connected.
+func (cfg *AgentConfig) CloseTunnel() {
+	if tunnel := cfg.tunnel.Swap(nil); tunnel != nil {
+		tunnel.Close()
+	}
+}
+
+// newTunnelHTTPClient returns a client of the agent API inside session, for verifying the agent
+// before the session is used by other requests.
+func (cfg *AgentConfig) newTunnelHTTPClient(session *yamux.Session) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return session.Open()
+			},
+			TLSClientConfig: &cfg.tlsConfig,
+		},
+	}
+}
+
+func (cfg *AgentConfig) dialTunnel() (net.Conn, error) {
+	tunnel := cfg.tunnel.Load()
+	if tunnel == nil || tunnel.IsClosed() {
+		return nil, ErrAgentNotConnected
+	}
+	return tunnel.Open()
+}
+
+func parseTunnelAddr(addr string) (name string, ok bool) {
+	return strings.CutPrefix(addr, TunnelAddrPrefix)
+}
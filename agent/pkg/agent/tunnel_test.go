@@ -0,0 +1,243 @@
+package agent
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/yamux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTunnelAddr(t *testing.T) {
+	var cfg AgentConfig
+	require.NoError(t, cfg.Parse("tunnel://nas"))
+	require.True(t, cfg.Tunnel)
+	require.Equal(t, "nas", cfg.Addr)
+
+	cfg = AgentConfig{}
+	require.NoError(t, cfg.Parse("10.0.0.1:8890"))
+	require.False(t, cfg.Tunnel)
+	require.Equal(t, "10.0.0.1:8890", cfg.Addr)
+
+	require.Error(t, cfg.Parse("tunnel://"))
+}
+
+// startTunnelAgent dials the tunnel endpoint at url and serves a fake agent API named name inside the tunnel.
+func startTunnelAgent(t *testing.T, url, name string, ca, srv *PEMPair) *yamux.Session {
+	t.Helper()
+
+	srvCert, err := srv.ToTLSCert()
+	require.NoError(t, err)
+	caPool := x509.NewCertPool()
+	require.True(t, caPool.AppendCertsFromPEM(ca.Cert))
+
+	header := make(http.Header)
+	header.Set(HeaderAgentName, "nas")
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http"), header)
+	require.NoError(t, err)
+
+	session, err := yamux.Server(NewTunnelConn(conn), TunnelConfig())
+	require.NoError(t, err)
+	t.Cleanup(func() { session.Close() })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(APIEndpointBase+EndpointName, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name))
+	})
+	mux.HandleFunc(APIEndpointBase+EndpointVersion, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("v0.20.0"))
+	})
+	mux.HandleFunc(APIEndpointBase+EndpointRuntime, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("podman"))
+	})
+	mux.HandleFunc(APIEndpointBase+EndpointHealth, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"healthy":true}`))
+	})
+	go http.Serve(tls.NewListener(session, &tls.Config{
+		Certificates: []tls.Certificate{*srvCert},
+		ClientCAs:    caPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}), mux)
+	return session
+}
+
+func newTunnelAgentConfig(t *testing.T, ca, client *PEMPair) *AgentConfig {
+	t.Helper()
+
+	cfg := &AgentConfig{}
+	require.NoError(t, cfg.Parse("tunnel://nas"))
+	require.NoError(t, cfg.StartWithCerts(t.Context(), ca.Cert, client.Cert, client.Key))
+	return cfg
+}
+
+func newTunnelServer(t *testing.T, cfg *AgentConfig) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HeaderAgentName) != cfg.Addr {
+			http.NotFound(w, r)
+			return
+		}
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		_ = cfg.ServeTunnel(r.Context(), conn)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTunnel(t *testing.T) {
+	ca, srv, client, err := NewAgent()
+	require.NoError(t, err)
+
+	cfg := newTunnelAgentConfig(t, ca, client)
+	require.Equal(t, "nas", cfg.Name)
+	require.False(t, cfg.IsConnected())
+
+	_, err = cfg.DialContext(t.Context())
+	require.ErrorIs(t, err, ErrAgentNotConnected)
+
+	server := newTunnelServer(t, cfg)
+	session := startTunnelAgent(t, server.URL, "nas", ca, srv)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, cfg.WaitTunnel(ctx))
+	require.True(t, cfg.IsConnected())
+	require.Equal(t, ContainerRuntimePodman, cfg.GetRuntime())
+	require.Equal(t, "v0.20.0", cfg.GetVersion().String())
+
+	name, status, err := cfg.fetchString(ctx, EndpointName)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "nas", name)
+
+	health, err := cfg.DoHealthCheck(time.Second, "")
+	require.NoError(t, err)
+	require.True(t, health.Healthy)
+
+	session.Close()
+	require.Eventually(t, func() bool { return !cfg.IsConnected() }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestTunnelRejectsUntrustedAgent(t *testing.T) {
+	ca, _, client, err := NewAgent()
+	require.NoError(t, err)
+	otherCA, otherSrv, _, err := NewAgent()
+	require.NoError(t, err)
+
+	cfg := newTunnelAgentConfig(t, ca, client)
+	server := newTunnelServer(t, cfg)
+	startTunnelAgent(t, server.URL, "nas", otherCA, otherSrv)
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	require.ErrorIs(t, cfg.WaitTunnel(ctx), ErrAgentNotConnected)
+}
+
+func TestTunnelKeepsVerifiedSession(t *testing.T) {
+	ca, srv, client, err := NewAgent()
+	require.NoError(t, err)
+	otherCA, otherSrv, _, err := NewAgent()
+	require.NoError(t, err)
+
+	cfg := newTunnelAgentConfig(t, ca, client)
+	server := newTunnelServer(t, cfg)
+	startTunnelAgent(t, server.URL, "nas", ca, srv)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, cfg.WaitTunnel(ctx))
+	verified := cfg.tunnel.Load()
+
+	// neither an untrusted agent nor an agent with another name replaces the verified tunnel
+	untrusted := startTunnelAgent(t, server.URL, "nas", otherCA, otherSrv)
+	renamed := startTunnelAgent(t, server.URL, "other", ca, srv)
+	require.Eventually(t, func() bool { return untrusted.IsClosed() && renamed.IsClosed() }, 10*time.Second, 10*time.Millisecond)
+
+	require.Same(t, verified, cfg.tunnel.Load())
+	require.True(t, cfg.IsConnected())
+	health, err := cfg.DoHealthCheck(time.Second, "")
+	require.NoError(t, err)
+	require.True(t, health.Healthy)
+}
+
+// dialSilentTunnel dials the tunnel endpoint at url without serving the agent API.
+func dialSilentTunnel(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+
+	header := make(http.Header)
+	header.Set(HeaderAgentName, "nas")
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http"), header)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestTunnelHandshakeTimeout(t *testing.T) {
+	ca, _, client, err := NewAgent()
+	require.NoError(t, err)
+
+	timeout := tunnelHandshakeTimeout
+	tunnelHandshakeTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { tunnelHandshakeTimeout = timeout })
+
+	cfg := newTunnelAgentConfig(t, ca, client)
+	errCh := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		errCh <- cfg.ServeTunnel(r.Context(), conn)
+	}))
+	t.Cleanup(server.Close)
+
+	dialSilentTunnel(t, server.URL)
+	select {
+	case err := <-errCh:
+		require.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("handshake did not time out")
+	}
+	require.False(t, cfg.IsConnected())
+	require.True(t, cfg.CanHandshakeTunnel())
+}
+
+func TestTunnelPendingHandshakesLimit(t *testing.T) {
+	ca, _, client, err := NewAgent()
+	require.NoError(t, err)
+
+	cfg := newTunnelAgentConfig(t, ca, client)
+	errCh := make(chan error, maxPendingTunnelHandshakes+1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		errCh <- cfg.ServeTunnel(r.Context(), conn)
+	}))
+	t.Cleanup(server.Close)
+
+	for range maxPendingTunnelHandshakes {
+		dialSilentTunnel(t, server.URL)
+	}
+	require.Eventually(t, func() bool { return !cfg.CanHandshakeTunnel() }, 5*time.Second, 10*time.Millisecond)
+
+	dialSilentTunnel(t, server.URL)
+	select {
+	case err := <-errCh:
+		require.ErrorIs(t, err, ErrTooManyTunnelHandshakes)
+	case <-time.After(5 * time.Second):
+		t.Fatal("handshake over the limit was not rejected")
+	}
+}
@@ -0,0 +1,80 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bytedance/sonic"
+	"github.com/yusing/godoxy/agent/pkg/agent"
+)
+
+// ErrDisabled is returned when the agent does not have route discovery enabled,
+// routes should be discovered through its docker socket instead.
+var ErrDisabled = errors.New("route discovery is not enabled on the agent")
+
+// Fetch returns the current route list of the agent.
+func Fetch(ctx context.Context, cfg *agent.AgentConfig) (*Update, error) {
+	resp, err := cfg.Do(ctx, http.MethodGet, agent.EndpointRoutes, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if isDisabledStatus(resp.StatusCode) {
+		return nil, ErrDisabled
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch routes: HTTP %d", resp.StatusCode)
+	}
+
+	var update Update
+	if err := sonic.ConfigDefault.NewDecoder(resp.Body).Decode(&update); err != nil {
+		return nil, err
+	}
+	return &update, nil
+}
+
+// Watch returns a channel receiving the route list of the agent whenever it changes.
+//
+// The channel is closed when the connection to the agent is lost or ctx is done.
+func Watch(ctx context.Context, cfg *agent.AgentConfig) (<-chan *Update, error) {
+	conn, resp, err := cfg.Websocket(ctx, agent.EndpointRoutes)
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+			if isDisabledStatus(resp.StatusCode) {
+				return nil, ErrDisabled
+			}
+		}
+		return nil, err
+	}
+
+	updates := make(chan *Update)
+	go func() {
+		defer close(updates)
+		defer conn.Close()
+		stop := context.AfterFunc(ctx, func() { conn.Close() })
+		defer stop()
+
+		for {
+			var update Update
+			if err := conn.ReadJSON(&update); err != nil {
+				return
+			}
+			select {
+			case updates <- &update:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return updates, nil
+}
+
+// isDisabledStatus returns whether the status means route discovery is not enabled on the agent,
+// older agents pass the request to the docker socket proxy which rejects it.
+func isDisabledStatus(status int) bool {
+	return status == http.StatusNotFound || status == http.StatusForbidden
+}
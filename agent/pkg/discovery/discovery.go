@@ -0,0 +1,222 @@
+// Package discovery discovers routes on the agent host from container labels and route files,
+// and pushes them to the main instance so it does not need the docker socket of the agent.
+package discovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/puzpuzpuz/xsync/v4"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/yusing/godoxy/internal/docker"
+	"github.com/yusing/godoxy/internal/types"
+	"github.com/yusing/godoxy/internal/watcher"
+	"github.com/yusing/godoxy/internal/watcher/events"
+	gperr "github.com/yusing/goutils/errs"
+	"github.com/yusing/goutils/task"
+)
+
+type (
+	// Route is a route discovered on the agent host.
+	Route struct {
+		Container *types.Container `json:"container,omitempty"` // nil for routes from route files
+		File      string           `json:"file,omitempty"`      // name of the route file
+		Config    types.LabelMap   `json:"config"`              // nil for container aliases without route labels
+	}
+	// Update is the full route list of the agent, along with the events that changed it.
+	Update struct {
+		Routes map[string]*Route `json:"routes"`
+		Events []events.Event    `json:"events,omitempty"`
+		Error  string            `json:"error,omitempty"`
+	}
+)
+
+const (
+	loadTimeout        = 5 * time.Second
+	eventFlushInterval = 300 * time.Millisecond
+)
+
+type Discovery struct {
+	dockerHost string
+	routesDir  string
+
+	mu      sync.Mutex // serializes reloads
+	current *Update
+	subs    *xsync.Map[chan *Update, struct{}]
+
+	l zerolog.Logger
+}
+
+// New returns a Discovery for containers of dockerHost and route files in routesDir.
+//
+// Route files are not loaded if routesDir is empty.
+func New(dockerHost, routesDir string) *Discovery {
+	return &Discovery{
+		dockerHost: dockerHost,
+		routesDir:  routesDir,
+		current:    &Update{Routes: make(map[string]*Route)},
+		subs:       xsync.NewMap[chan *Update, struct{}](),
+		l:          log.With().Str("type", "discovery").Logger(),
+	}
+}
+
+// Start loads the routes and reloads them on container and route file events.
+func (d *Discovery) Start(parent task.Parent) {
+	t := parent.Subtask("discovery", false)
+	d.reload(t.Context(), nil)
+
+	onError := func(err gperr.Error) {
+		gperr.LogError("discovery error", err, &d.l)
+	}
+	onFlush := func(events []events.Event) {
+		d.reload(t.Context(), events)
+	}
+
+	dockerQueue := events.NewEventQueue(t.Subtask("docker_events", false), eventFlushInterval, onFlush, onError)
+	dockerQueue.Start(watcher.NewDockerWatcher(d.dockerHost).Events(t.Context()))
+
+	if d.routesDir != "" {
+		if err := os.MkdirAll(d.routesDir, 0o755); err != nil {
+			d.l.Err(err).Msg("failed to create routes directory")
+			return
+		}
+		fileQueue := events.NewEventQueue(t.Subtask("file_events", false), eventFlushInterval, onFlush, onError)
+		fileQueue.Start(watcher.NewDirectoryWatcher(t, d.routesDir).Events(t.Context()))
+	}
+}
+
+// Current returns the last loaded route list.
+func (d *Discovery) Current() *Update {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.current
+}
+
+// Subscribe returns a channel receiving the route list whenever it changes.
+func (d *Discovery) Subscribe() (updates <-chan *Update, unsubscribe func()) {
+	ch := make(chan *Update, 1)
+	d.subs.Store(ch, struct{}{})
+	return ch, func() { d.subs.Delete(ch) }
+}
+
+func (d *Discovery) reload(ctx context.Context, events []events.Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	update := d.load(ctx)
+	update.Events = events
+	d.current = update
+
+	for ch := range d.subs.Range {
+		// drop the pending update, the new one supersedes it
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- update
+	}
+	d.l.Debug().Int("routes", len(update.Routes)).Int("events", len(events)).Msg("routes reloaded")
+}
+
+func (d *Discovery) load(ctx context.Context) *Update {
+	update := &Update{Routes: make(map[string]*Route)}
+	errs := gperr.NewBuilder("discovery errors")
+	errs.Add(d.loadContainers(ctx, update.Routes))
+	if d.routesDir != "" {
+		errs.Add(d.loadFiles(update.Routes))
+	}
+	if err := errs.Error(); err != nil {
+		update.Error = string(err.Plain())
+	}
+	return update
+}
+
+func (d *Discovery) loadContainers(ctx context.Context, routes map[string]*Route) gperr.Error {
+	ctx, cancel := context.WithTimeout(ctx, loadTimeout)
+	defer cancel()
+
+	containers, err := docker.ListContainers(ctx, d.dockerHost)
+	if err != nil {
+		return gperr.Wrap(err)
+	}
+
+	errs := gperr.NewBuilder("")
+	for _, c := range containers {
+		container := docker.FromDocker(&c, d.dockerHost)
+		if container.Errors != nil {
+			errs.Add(gperr.PrependSubject(container.ContainerName, container.Errors))
+			continue
+		}
+		if container.IsHostNetworkMode {
+			if err := docker.UpdatePorts(container); err != nil {
+				errs.Add(gperr.PrependSubject(container.ContainerName, err))
+				continue
+			}
+		}
+
+		configs, err := docker.RouteLabels(container)
+		if err != nil {
+			errs.Add(err.Subject(container.ContainerName))
+		}
+		for alias, cfg := range configs {
+			if conflict, ok := routes[alias]; ok {
+				errs.Addf("route with alias %s already exists, container %s, conflicting container %s",
+					alias, container.ContainerName, conflict.Container.ContainerName)
+				continue
+			}
+			routes[alias] = &Route{Container: container, Config: cfg}
+		}
+	}
+	return errs.Error()
+}
+
+func (d *Discovery) loadFiles(routes map[string]*Route) gperr.Error {
+	entries, err := os.ReadDir(d.routesDir)
+	if err != nil {
+		return gperr.Wrap(err)
+	}
+
+	errs := gperr.NewBuilder("")
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		switch filepath.Ext(name) {
+		case ".yml", ".yaml":
+		default:
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(d.routesDir, name))
+		if err != nil {
+			errs.Add(gperr.PrependSubject(name, err))
+			continue
+		}
+		var configs map[string]types.LabelMap
+		if err := yaml.Unmarshal(data, &configs); err != nil {
+			errs.Add(gperr.PrependSubject(name, err))
+			continue
+		}
+		for alias, cfg := range configs {
+			if strings.HasPrefix(alias, "x-") {
+				continue
+			}
+			if _, ok := routes[alias]; ok {
+				errs.Add(gperr.Errorf("route with alias %s already exists", alias).Subject(name))
+				continue
+			}
+			if cfg == nil {
+				cfg = make(types.LabelMap)
+			}
+			routes[alias] = &Route{File: name, Config: cfg}
+		}
+	}
+	return errs.Error()
+}
@@ -0,0 +1,78 @@
+package discovery
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/yusing/godoxy/internal/watcher/events"
+)
+
+func newTestDiscovery(t *testing.T) (*Discovery, string) {
+	t.Helper()
+	dir := t.TempDir()
+	// no docker daemon in tests, containers fail to load
+	return New("unix://"+filepath.Join(dir, "docker.sock"), dir), dir
+}
+
+func writeRouteFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+}
+
+func TestLoadRouteFiles(t *testing.T) {
+	d, dir := newTestDiscovery(t)
+	writeRouteFile(t, dir, "apps.yml", `
+x-common: &common
+  scheme: http
+app:
+  <<: *common
+  host: 10.0.0.2
+  port: 8080
+empty:
+`)
+	writeRouteFile(t, dir, "dup.yaml", "app:\n  port: 80\n")
+	writeRouteFile(t, dir, "notes.txt", "other:\n  port: 80\n")
+
+	d.reload(t.Context(), nil)
+	update := d.Current()
+
+	require.Len(t, update.Routes, 2)
+	app := update.Routes["app"]
+	require.Nil(t, app.Container)
+	require.Equal(t, "apps.yml", app.File)
+	require.Equal(t, "10.0.0.2", app.Config["host"])
+	require.NotNil(t, update.Routes["empty"].Config)
+	require.Contains(t, update.Error, "route with alias app already exists")
+}
+
+func TestPushRoutes(t *testing.T) {
+	d, dir := newTestDiscovery(t)
+	writeRouteFile(t, dir, "apps.yml", "app:\n  port: 8080\n")
+	d.reload(t.Context(), nil)
+
+	server := httptest.NewServer(d)
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var update Update
+	require.NoError(t, conn.ReadJSON(&update))
+	require.Contains(t, update.Routes, "app")
+
+	writeRouteFile(t, dir, "other.yml", "other:\n  port: 8081\n")
+	event := events.Event{Type: events.EventTypeFile, ActorName: "other.yml", Action: events.ActionFileCreated}
+	d.reload(t.Context(), []events.Event{event})
+
+	update = Update{}
+	require.NoError(t, conn.ReadJSON(&update))
+	require.Contains(t, update.Routes, "app")
+	require.Contains(t, update.Routes, "other")
+	require.Equal(t, []events.Event{event}, update.Events)
+}
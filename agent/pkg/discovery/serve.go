@@ -0,0 +1,72 @@
+package discovery
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gorilla/websocket"
+)
+
+const pingInterval = 30 * time.Second
+
+var upgrader = &websocket.Upgrader{
+	// no origin check needed for internal websocket
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// ServeHTTP writes the current route list, or pushes it on every change if the request is a websocket.
+func (d *Discovery) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := sonic.ConfigDefault.NewEncoder(w).Encode(d.Current()); err != nil {
+			d.l.Err(err).Msg("failed to write routes")
+		}
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	updates, unsubscribe := d.Subscribe()
+	defer unsubscribe()
+
+	// detect the main instance disconnecting
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	update := d.Current()
+	for {
+		if err := conn.WriteJSON(update); err != nil {
+			d.l.Debug().Err(err).Msg("failed to push routes")
+			return
+		}
+		update = nil
+		for update == nil {
+			select {
+			case <-closed:
+				return
+			case <-ping.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(pingInterval)); err != nil {
+					return
+				}
+			case update = <-updates:
+			}
+		}
+	}
+}
@@ -23,6 +23,12 @@ var (
 	AgentSkipClientCertCheck bool
 	AgentCACert              string
 	AgentSSLCert             string
+	AgentTunnelURL           string
+	AgentCertsFile           string
+	AgentDiscovery           bool
+	AgentRoutesDir           string
+	AgentSocketProxy         bool
+	AgentExec                bool
 	DockerSocket             string
 	Runtime                  agent.ContainerRuntime
 )
@@ -39,6 +45,12 @@ func Load() {
 
 	AgentCACert = env.GetEnvString("AGENT_CA_CERT", "")
 	AgentSSLCert = env.GetEnvString("AGENT_SSL_CERT", "")
+	AgentCertsFile = env.GetEnvString("AGENT_CERTS_FILE", "data/agent_certs.json") // rotated certificates, overrides AGENT_CA_CERT and AGENT_SSL_CERT
+	AgentTunnelURL = env.GetEnvString("AGENT_TUNNEL_URL", "")                      // wss://<godoxy>/api/v1/agent/tunnel, dial GoDoxy instead of listening on AGENT_PORT
+	AgentDiscovery = env.GetEnvBool("AGENT_DISCOVERY", false)                      // discover routes on the agent and push them to GoDoxy
+	AgentRoutesDir = env.GetEnvString("AGENT_ROUTES_DIR", "data/routes")           // route files discovered with AGENT_DISCOVERY
+	AgentSocketProxy = env.GetEnvBool("AGENT_SOCKET_PROXY", true)                  // expose the docker socket to GoDoxy
+	AgentExec = env.GetEnvBool("AGENT_EXEC", false)                                // run idlewatcher commands (exec and systemd) for GoDoxy
 	Runtime = agent.ContainerRuntime(env.GetEnvString("RUNTIME", "docker"))
 
 	switch Runtime {
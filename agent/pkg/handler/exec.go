@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/bytedance/sonic"
+	"github.com/yusing/godoxy/agent/pkg/agent"
+)
+
+const maxExecRequestSize = 64 * 1024
+
+// Exec runs a command for GoDoxy on the agent host, enabled with AGENT_EXEC.
+func Exec(w http.ResponseWriter, r *http.Request) {
+	var req agent.ExecRequest
+	if err := sonic.ConfigDefault.NewDecoder(io.LimitReader(r.Body, maxExecRequestSize)).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := req.Run(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	sonic.ConfigDefault.NewEncoder(w).Encode(result) //nolint:errcheck
+}
@@ -0,0 +1,66 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	"github.com/yusing/godoxy/agent/pkg/handler"
+)
+
+func TestExec(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedResult agent.ExecResult
+	}{
+		{
+			name:           "Success",
+			body:           `{"command":["sh","-c","echo $NAME"],"env":{"NAME":"app"}}`,
+			expectedStatus: http.StatusOK,
+			expectedResult: agent.ExecResult{Output: "app\n"},
+		},
+		{
+			name:           "ExitCode",
+			body:           `{"command":["sh","-c","exit 3"]}`,
+			expectedStatus: http.StatusOK,
+			expectedResult: agent.ExecResult{ExitCode: 3},
+		},
+		{
+			name:           "OutputTruncated",
+			body:           `{"command":["head","-c","100000","/dev/zero"]}`,
+			expectedStatus: http.StatusOK,
+			expectedResult: agent.ExecResult{Output: strings.Repeat("\x00", agent.MaxExecOutputSize)},
+		},
+		{
+			name:           "EmptyCommand",
+			body:           `{"command":[]}`,
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "InvalidBody",
+			body:           `{`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, agent.APIEndpointBase+agent.EndpointExec, strings.NewReader(tt.body))
+			recorder := httptest.NewRecorder()
+			handler.Exec(recorder, req)
+
+			require.Equal(t, tt.expectedStatus, recorder.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var result agent.ExecResult
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+				require.Equal(t, tt.expectedResult, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	ioutils "github.com/yusing/goutils/io"
+)
+
+const streamDialTimeout = 10 * time.Second
+
+// ProxyStream relays a tcp or udp connection between the websocket and the target on the agent network.
+func ProxyStream(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	scheme := query.Get("scheme")
+	host := query.Get("host")
+	switch scheme {
+	case "tcp", "udp":
+	default:
+		http.Error(w, fmt.Sprintf("invalid scheme: %q", scheme), http.StatusBadRequest)
+		return
+	}
+	if host == "" {
+		http.Error(w, "missing host", http.StatusBadRequest)
+		return
+	}
+
+	// dial before upgrading so the error can be reported with the response
+	dst, err := net.DialTimeout(scheme, host, streamDialTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer dst.Close()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	src := agent.NewStreamConn(conn, scheme, r.RemoteAddr)
+	defer src.Close()
+
+	pipe := ioutils.NewBidirectionalPipe(r.Context(), src, dst)
+	if err := pipe.Start(); err != nil {
+		log.Debug().Err(err).Str("scheme", scheme).Str("host", host).Msg("stream relay closed")
+	}
+}
@@ -0,0 +1,99 @@
+package handler_test
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	"github.com/yusing/godoxy/agent/pkg/handler"
+)
+
+func dialProxyStream(t *testing.T, scheme, host string) (net.Conn, *http.Response, error) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.ProxyStream))
+	t.Cleanup(server.Close)
+
+	query := url.Values{"scheme": {scheme}, "host": {host}}
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, resp, err
+	}
+	t.Cleanup(func() { conn.Close() })
+	return agent.NewStreamConn(conn, scheme, host), resp, nil
+}
+
+func TestProxyStreamTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	conn, _, err := dialProxyStream(t, "tcp", l.Addr().String())
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf))
+}
+
+func TestProxyStreamUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	conn, _, err := dialProxyStream(t, "udp", pc.LocalAddr().String())
+	require.NoError(t, err)
+
+	// datagram boundaries are preserved
+	for _, msg := range []string{"first", "second datagram"} {
+		_, err = conn.Write([]byte(msg))
+		require.NoError(t, err)
+		buf := make([]byte, 1024)
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+		require.Equal(t, msg, string(buf[:n]))
+	}
+}
+
+func TestProxyStreamDialError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	_, resp, err := dialProxyStream(t, "tcp", addr)
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+
+	_, resp, err = dialProxyStream(t, "unix", "/var/run/docker.sock")
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	"github.com/yusing/godoxy/agent/pkg/env"
+)
+
+// serverCerts holds the TLS config of the agent server, replaced when the certificates are rotated.
+type serverCerts struct {
+	config atomic.Pointer[tls.Config]
+
+	mu     sync.Mutex
+	ca     *x509.Certificate
+	prevCA *x509.Certificate // client certificates issued by it are accepted until the grace period ends
+	srv    tls.Certificate
+	gen    int
+}
+
+var certs serverCerts
+
+func (c *serverCerts) set(ca *x509.Certificate, srv tls.Certificate, gracePeriod time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.prevCA = nil
+	if c.ca != nil && gracePeriod > 0 {
+		c.prevCA = c.ca
+		gen := c.gen
+		time.AfterFunc(gracePeriod, func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.gen == gen {
+				c.prevCA = nil
+				c.update()
+				log.Info().Msg("grace period of the previous agent certificates ended")
+			}
+		})
+	}
+	c.ca, c.srv = ca, srv
+	c.update()
+}
+
+func (c *serverCerts) update() {
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(c.ca)
+	if c.prevCA != nil {
+		clientCAs.AddCert(c.prevCA)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{c.srv},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	if env.AgentSkipClientCertCheck {
+		tlsConfig.ClientAuth = tls.NoClientCert
+	}
+	c.config.Store(tlsConfig)
+}
+
+func (c *serverCerts) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return c.config.Load(), nil
+}
+
+// rotatedCerts is persisted to env.AgentCertsFile, it overrides AGENT_CA_CERT and AGENT_SSL_CERT.
+type rotatedCerts struct {
+	CA     string `json:"ca"`
+	Server string `json:"server"`
+}
+
+// LoadRotatedCerts returns the CA and server certificate saved by the last rotation if any.
+func LoadRotatedCerts() (ca, srv string, ok bool) {
+	data, err := os.ReadFile(env.AgentCertsFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warn().Err(err).Msg("failed to read rotated certificates")
+		}
+		return "", "", false
+	}
+	var saved rotatedCerts
+	if err := json.Unmarshal(data, &saved); err != nil {
+		log.Warn().Err(err).Msg("invalid rotated certificates")
+		return "", "", false
+	}
+	return saved.CA, saved.Server, true
+}
+
+func saveRotatedCerts(ca, srv string) error {
+	data, err := json.Marshal(rotatedCerts{CA: ca, Server: srv})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(env.AgentCertsFile), 0o700); err != nil {
+		return err
+	}
+	tmp := env.AgentCertsFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, env.AgentCertsFile)
+}
+
+// rotateCerts handles agent.EndpointCerts.
+func rotateCerts(w http.ResponseWriter, r *http.Request) {
+	var req agent.RotateCertsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var caPair, srvPair agent.PEMPair
+	if err := caPair.Load(req.CA); err != nil {
+		http.Error(w, "invalid CA: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := srvPair.Load(req.Server); err != nil {
+		http.Error(w, "invalid server certificate: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ca, err := caPair.ToTLSCert()
+	if err != nil {
+		http.Error(w, "invalid CA: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	srv, err := srvPair.ToTLSCert()
+	if err != nil {
+		http.Error(w, "invalid server certificate: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := saveRotatedCerts(req.CA, req.Server); err != nil {
+		log.Err(err).Msg("failed to save rotated certificates")
+		http.Error(w, "failed to save certificates", http.StatusInternalServerError)
+		return
+	}
+	certs.set(ca.Leaf, *srv, req.GracePeriod)
+	log.Info().Msgf("agent certificates rotated, previous certificates accepted for %s", req.GracePeriod)
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	"github.com/yusing/godoxy/agent/pkg/env"
+)
+
+func startTestAgent(t *testing.T) (*agent.AgentConfig, *agent.AgentConfig) {
+	t.Helper()
+
+	env.AgentCertsFile = filepath.Join(t.TempDir(), "agent_certs.json")
+
+	ca, srv, client, err := agent.NewAgent()
+	require.NoError(t, err)
+	caCert, err := ca.ToTLSCert()
+	require.NoError(t, err)
+	srvCert, err := srv.ToTLSCert()
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(newHandler(Options{}))
+	server.TLS = newTLSConfig(Options{CACert: caCert, ServerCert: srvCert})
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	newClient := func() *agent.AgentConfig {
+		cfg := new(agent.AgentConfig)
+		require.NoError(t, cfg.Parse(strings.TrimPrefix(server.URL, "https://")))
+		require.NoError(t, cfg.StartWithCerts(t.Context(), ca.Cert, client.Cert, client.Key))
+		return cfg
+	}
+	return newClient(), newClient()
+}
+
+func fetchName(cfg *agent.AgentConfig) error {
+	resp, err := cfg.Do(context.Background(), http.MethodGet, agent.EndpointName, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func TestRotateCerts(t *testing.T) {
+	cfg, stale := startTestAgent(t)
+	oldFingerprint := cfg.CAFingerprint()
+
+	notAfter := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	ca, client, err := cfg.RotateCerts(t.Context(), notAfter, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, ca)
+	require.NotNil(t, client)
+	require.NotEqual(t, oldFingerprint, cfg.CAFingerprint())
+
+	// new certificates are used and persisted
+	require.NoError(t, fetchName(cfg))
+	require.True(t, cfg.CertsExpiry().Equal(notAfter.UTC()), cfg.CertsExpiry())
+	savedCA, _, ok := LoadRotatedCerts()
+	require.True(t, ok)
+	require.Equal(t, ca.String(), savedCA)
+
+	// a client still holding the previous certificates is accepted during the grace period
+	staleTLS := stale.Transport().TLSClientConfig.Clone()
+	staleTLS.VerifyConnection = nil
+	staleClient := &http.Client{Transport: &http.Transport{TLSClientConfig: staleTLS, DialContext: stale.Transport().DialContext}}
+	resp, err := staleClient.Get(agent.APIBaseURL + agent.EndpointName)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// grace period ended
+	certs.set(certs.ca, certs.srv, 0)
+	staleClient.CloseIdleConnections()
+	_, err = staleClient.Get(agent.APIBaseURL + agent.EndpointName)
+	require.Error(t, err)
+}
+
+func TestRevokedAgent(t *testing.T) {
+	cfg, _ := startTestAgent(t)
+	require.NoError(t, fetchName(cfg))
+
+	cfg.Revoke()
+	require.True(t, cfg.IsRevoked())
+	require.ErrorIs(t, fetchName(cfg), agent.ErrAgentRevoked)
+}
@@ -2,12 +2,12 @@ package server
 
 import (
 	"crypto/tls"
-	"crypto/x509"
 	"fmt"
 	"net/http"
 
 	"github.com/rs/zerolog/log"
-	"github.com/yusing/godoxy/agent/pkg/env"
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	"github.com/yusing/godoxy/agent/pkg/discovery"
 	"github.com/yusing/godoxy/agent/pkg/handler"
 	"github.com/yusing/goutils/server"
 	"github.com/yusing/goutils/task"
@@ -16,28 +16,33 @@ import (
 type Options struct {
 	CACert, ServerCert *tls.Certificate
 	Port               int
+	Discovery          *discovery.Discovery // nil if route discovery is disabled
 }
 
 func StartAgentServer(parent task.Parent, opt Options) {
-	caCertPool := x509.NewCertPool()
-	caCertPool.AddCert(opt.CACert.Leaf)
-
-	// Configure TLS
-	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{*opt.ServerCert},
-		ClientCAs:    caCertPool,
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-	}
-
-	if env.AgentSkipClientCertCheck {
-		tlsConfig.ClientAuth = tls.NoClientCert
-	}
-
 	agentServer := &http.Server{
 		Addr:      fmt.Sprintf(":%d", opt.Port),
-		Handler:   handler.NewAgentHandler(),
-		TLSConfig: tlsConfig,
+		Handler:   newHandler(opt),
+		TLSConfig: newTLSConfig(opt),
 	}
 
 	server.Start(parent.Subtask("agent-server", false), agentServer, server.WithLogger(&log.Logger))
 }
+
+func newTLSConfig(opt Options) *tls.Config {
+	certs.set(opt.CACert.Leaf, *opt.ServerCert, 0)
+	return &tls.Config{GetConfigForClient: certs.getConfigForClient}
+}
+
+func newHandler(opt Options) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+agent.APIEndpointBase+agent.EndpointCerts, rotateCerts)
+	if opt.Discovery != nil {
+		mux.Handle("GET "+agent.APIEndpointBase+agent.EndpointRoutes, opt.Discovery)
+	} else {
+		// do not pass it to the docker socket proxy
+		mux.Handle("GET "+agent.APIEndpointBase+agent.EndpointRoutes, http.NotFoundHandler())
+	}
+	mux.Handle("/", handler.NewAgentHandler())
+	return mux
+}
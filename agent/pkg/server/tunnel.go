@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/yamux"
+	"github.com/rs/zerolog/log"
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	"github.com/yusing/godoxy/agent/pkg/env"
+	"github.com/yusing/goutils/task"
+)
+
+const (
+	tunnelMinBackoff = time.Second
+	tunnelMaxBackoff = 15 * time.Second
+)
+
+var tunnelDialer = &websocket.Dialer{
+	Proxy:            http.ProxyFromEnvironment,
+	HandshakeTimeout: 10 * time.Second,
+	ReadBufferSize:   32 * 1024,
+	WriteBufferSize:  32 * 1024,
+}
+
+// StartTunnelClient dials GoDoxy at AGENT_TUNNEL_URL and serves the agent API inside the tunnel,
+// reconnecting with exponential backoff until parent is done.
+func StartTunnelClient(parent task.Parent, opt Options) {
+	t := parent.Subtask("agent-tunnel", false)
+	tlsConfig := newTLSConfig(opt)
+	agentHandler := newHandler(opt)
+
+	go func() {
+		backoff := tunnelMinBackoff
+		for {
+			connectedAt := time.Now()
+			err := serveTunnel(t.Context(), agentHandler, tlsConfig)
+			if t.Context().Err() != nil {
+				return
+			}
+			// reset after a tunnel that stayed up for a while
+			if time.Since(connectedAt) > tunnelMaxBackoff {
+				backoff = tunnelMinBackoff
+			}
+			log.Warn().Err(err).Msgf("agent tunnel disconnected, reconnecting in %s", backoff)
+			select {
+			case <-t.Context().Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, tunnelMaxBackoff)
+		}
+	}()
+}
+
+func serveTunnel(ctx context.Context, agentHandler http.Handler, tlsConfig *tls.Config) error {
+	header := make(http.Header)
+	header.Set(agent.HeaderAgentName, env.AgentName)
+
+	conn, resp, err := tunnelDialer.DialContext(ctx, env.AgentTunnelURL, header)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("%w: HTTP %d", err, resp.StatusCode)
+		}
+		return err
+	}
+
+	session, err := yamux.Server(agent.NewTunnelConn(conn), agent.TunnelConfig())
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer session.Close()
+	stop := context.AfterFunc(ctx, func() { session.Close() })
+	defer stop()
+
+	log.Info().Msgf("agent tunnel connected to %s", env.AgentTunnelURL)
+
+	srv := &http.Server{Handler: agentHandler}
+	defer srv.Close()
+	// returns when the session is closed
+	return srv.Serve(tls.NewListener(session, tlsConfig))
+}
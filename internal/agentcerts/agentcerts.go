@@ -0,0 +1,74 @@
+package agentcerts
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	"github.com/yusing/godoxy/agent/pkg/certs"
+	"github.com/yusing/godoxy/internal/jsonstore"
+	gperr "github.com/yusing/goutils/errs"
+)
+
+type Revocation struct {
+	Agent     string    `json:"agent"`
+	RevokedAt time.Time `json:"revoked_at"`
+} // @name AgentCertRevocation
+
+const (
+	DefaultValidity    = 365 * 24 * time.Hour
+	DefaultGracePeriod = 24 * time.Hour
+)
+
+// CA fingerprint -> revocation, agents with a revoked CA are not loaded.
+var revocations = jsonstore.Store[*Revocation]("agent_cert_revocations")
+
+var ErrAlreadyRevoked = gperr.New("agent certificate already revoked")
+
+// IsRevoked returns whether the current certificates of the agent have been revoked.
+func IsRevoked(a *agent.AgentConfig) bool {
+	_, ok := revocations.Load(a.CAFingerprint())
+	return ok
+}
+
+// Revoke revokes the current certificates of the agent and disconnects it.
+//
+// Routes of the agent are removed on the next config reload, where the agent is skipped.
+func Revoke(a *agent.AgentConfig) gperr.Error {
+	fingerprint := a.CAFingerprint()
+	if fingerprint == "" {
+		return gperr.New("agent has no certificates").Subject(a.String())
+	}
+	if _, loaded := revocations.LoadOrStore(fingerprint, &Revocation{Agent: a.Name, RevokedAt: time.Now()}); loaded {
+		return ErrAlreadyRevoked.Subject(a.String())
+	}
+	a.Revoke()
+	agent.RemoveAgent(a)
+	return nil
+}
+
+// Rotate issues new certificates valid for validity, installs them on the agent and saves them.
+//
+// The agent keeps accepting the previous client certificate for gracePeriod.
+func Rotate(ctx context.Context, a *agent.AgentConfig, validity, gracePeriod time.Duration) gperr.Error {
+	filename, ok := certs.AgentCertsFilepath(a.Addr)
+	if !ok {
+		return gperr.New("invalid agent host").Subject(a.Addr)
+	}
+
+	ca, client, err := a.RotateCerts(ctx, time.Now().Add(validity), gracePeriod)
+	if err != nil {
+		return gperr.Wrap(err, "failed to rotate certificates").Subject(a.String())
+	}
+
+	zip, err := certs.ZipCert(ca.Cert, client.Cert, client.Key)
+	if err != nil {
+		return gperr.Wrap(err, "failed to zip certs")
+	}
+	if err := os.WriteFile(filename, zip, 0o600); err != nil {
+		return gperr.Wrap(err, "failed to write certs")
+	}
+	expiryNotified.Delete(a.Addr)
+	return nil
+}
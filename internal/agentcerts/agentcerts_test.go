@@ -0,0 +1,38 @@
+package agentcerts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	expect "github.com/yusing/goutils/testing"
+)
+
+func addTestAgent(t *testing.T, name string, notAfter time.Time) *agent.AgentConfig {
+	t.Helper()
+
+	ca, _, client, err := agent.NewAgentWithExpiry(notAfter)
+	expect.NoError(t, err)
+
+	cfg := new(agent.AgentConfig)
+	// tunnel agents do not connect on start
+	expect.NoError(t, cfg.Parse("tunnel://"+name))
+	expect.NoError(t, cfg.StartWithCerts(t.Context(), ca.Cert, client.Cert, client.Key))
+	agent.AddAgent(cfg)
+	t.Cleanup(func() { agent.RemoveAgent(cfg) })
+	return cfg
+}
+
+func TestRevoke(t *testing.T) {
+	a := addTestAgent(t, "compromised", time.Now().Add(time.Hour))
+	t.Cleanup(func() { revocations.Delete(a.CAFingerprint()) })
+
+	expect.False(t, IsRevoked(a))
+	expect.NoError(t, Revoke(a))
+	expect.True(t, IsRevoked(a))
+	expect.True(t, a.IsRevoked())
+
+	_, ok := agent.GetAgent(a.Addr)
+	expect.False(t, ok)
+	expect.ErrorIs(t, ErrAlreadyRevoked, Revoke(a))
+}
@@ -0,0 +1,80 @@
+package agentcerts
+
+import (
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+	"github.com/rs/zerolog"
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	"github.com/yusing/godoxy/internal/notif"
+	strutils "github.com/yusing/goutils/strings"
+	"github.com/yusing/goutils/task"
+)
+
+const (
+	expiryCheckInterval = 24 * time.Hour
+	expiryWarnBefore    = 30 * 24 * time.Hour
+)
+
+type expiryNotice struct {
+	expiry  time.Time
+	expired bool
+}
+
+var notify notif.NotifyFunc = notif.Notify
+
+// agent addr -> last notice, so each expiry is notified once before and once after it passed.
+var expiryNotified = xsync.NewMap[string, expiryNotice]()
+
+// ScheduleExpiryCheck notifies when certificates of agents are about to expire, once a day.
+func ScheduleExpiryCheck(parent task.Parent) {
+	task := parent.Subtask("agent-cert-expiry-check", true)
+	go func() {
+		defer task.Finish(nil)
+
+		ticker := time.NewTicker(expiryCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			checkExpiry(time.Now())
+			select {
+			case <-task.Context().Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func checkExpiry(now time.Time) {
+	for _, a := range agent.IterAgents() {
+		expiry := a.CertsExpiry()
+		if expiry.IsZero() || expiry.Sub(now) > expiryWarnBefore {
+			continue
+		}
+		notice := expiryNotice{expiry: expiry, expired: !expiry.After(now)}
+		if notified, ok := expiryNotified.Load(a.Addr); ok && notified.expiry.Equal(expiry) && notified.expired == notice.expired {
+			continue
+		}
+		expiryNotified.Store(a.Addr, notice)
+
+		body := notif.FieldsBody{
+			{Name: "Agent", Value: a.String()},
+			{Name: "Expires", Value: expiry.Format(time.RFC3339)},
+		}
+		title := "Agent certificate expiring"
+		level := zerolog.WarnLevel
+		if !notice.expired {
+			body.Add("Remaining", strutils.FormatDuration(expiry.Sub(now)))
+		} else {
+			title = "Agent certificate expired"
+			level = zerolog.ErrorLevel
+		}
+		notify(&notif.LogMessage{
+			Level: level,
+			Title: title,
+			Body:  body,
+			Color: notif.ColorError,
+		})
+	}
+}
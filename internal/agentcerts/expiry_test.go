@@ -0,0 +1,34 @@
+package agentcerts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yusing/godoxy/internal/notif"
+	expect "github.com/yusing/goutils/testing"
+)
+
+func TestCheckExpiry(t *testing.T) {
+	var notified []*notif.LogMessage
+	notify = func(msg *notif.LogMessage) { notified = append(notified, msg) }
+	t.Cleanup(func() { notify = notif.Notify })
+
+	now := time.Now()
+	expiring := addTestAgent(t, "expiring", now.Add(7*24*time.Hour))
+	addTestAgent(t, "valid", now.Add(90*24*time.Hour))
+
+	checkExpiry(now)
+	expect.Equal(t, len(notified), 1)
+	expect.Equal(t, notified[0].Level, zerolog.WarnLevel)
+	expect.Equal(t, notified[0].Body.(notif.FieldsBody)[0].Value, expiring.String())
+
+	// notified once per expiry
+	checkExpiry(now.Add(time.Hour))
+	expect.Equal(t, len(notified), 1)
+
+	checkExpiry(now.Add(8 * 24 * time.Hour))
+	expect.Equal(t, len(notified), 2)
+	expect.Equal(t, notified[1].Title, "Agent certificate expired")
+	expect.Equal(t, notified[1].Level, zerolog.ErrorLevel)
+}
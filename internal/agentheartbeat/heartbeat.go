@@ -0,0 +1,225 @@
+package agentheartbeat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	"github.com/yusing/godoxy/internal/notif"
+	strutils "github.com/yusing/goutils/strings"
+	"github.com/yusing/goutils/task"
+	"github.com/yusing/goutils/version"
+)
+
+type Config struct {
+	Interval time.Duration `json:"interval" validate:"omitempty,min=1s" swaggertype:"primitive,integer"`
+	Timeout  time.Duration `json:"timeout" validate:"omitempty,min=1s" swaggertype:"primitive,integer"`
+	// number of failed heartbeats retried before the agent is considered offline,
+	// 0 marks it offline on the first failure (default: 3)
+	Retries *int `json:"retries,omitempty" validate:"omitempty,gte=0"`
+	// mark routes of offline agents as "agent unreachable" instead of unhealthy
+	MarkRoutesUnreachable bool `json:"mark_routes_unreachable"`
+} // @name AgentHeartbeatConfig
+
+const (
+	DefaultInterval = 10 * time.Second
+	DefaultTimeout  = 5 * time.Second
+	DefaultRetries  = 3
+)
+
+type heartbeat struct {
+	*Config
+
+	// agent addr -> consecutive failures
+	failures map[string]int
+}
+
+var (
+	activeConfig atomic.Pointer[Config]
+
+	notify notif.NotifyFunc = notif.Notify
+	ping                    = (*agent.AgentConfig).Ping
+)
+
+// Start sends heartbeats to the agents in the pool until the parent task is done.
+func Start(parent task.Parent, cfg Config) {
+	if cfg.Interval == 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.Retries == nil {
+		retries := DefaultRetries
+		cfg.Retries = &retries
+	}
+	activeConfig.Store(&cfg)
+
+	hb := &heartbeat{Config: &cfg, failures: make(map[string]int)}
+	task := parent.Subtask("agent-heartbeat", true)
+	go func() {
+		defer task.Finish(nil)
+
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-task.Context().Done():
+				return
+			case <-ticker.C:
+				hb.round(task.Context())
+			}
+		}
+	}()
+}
+
+// RoutesUnreachable returns whether routes of the agent should be marked as "agent unreachable".
+func RoutesUnreachable(a *agent.AgentConfig) bool {
+	cfg := activeConfig.Load()
+	return cfg != nil && cfg.MarkRoutesUnreachable && a.IsOffline()
+}
+
+type pingResult struct {
+	agent   *agent.AgentConfig
+	rtt     time.Duration
+	version version.Version
+	err     error
+}
+
+// round pings all agents concurrently and notifies their online/offline transitions at once.
+func (hb *heartbeat) round(ctx context.Context) {
+	var (
+		mu      sync.Mutex
+		results []pingResult
+		wg      sync.WaitGroup
+	)
+	for _, a := range agent.IterAgents() {
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(ctx, hb.Timeout)
+			defer cancel()
+			rtt, agentVersion, err := ping(a, ctx)
+			mu.Lock()
+			results = append(results, pingResult{a, rtt, agentVersion, err})
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	var offline, online []*agent.AgentConfig
+	now := time.Now()
+	for _, res := range results {
+		switch hb.update(res, now) {
+		case transitionOffline:
+			offline = append(offline, res.agent)
+		case transitionOnline:
+			online = append(online, res.agent)
+		}
+	}
+	notifyTransitions(offline, online)
+}
+
+type transition int
+
+const (
+	transitionNone transition = iota
+	transitionOffline
+	transitionOnline
+)
+
+func (hb *heartbeat) update(res pingResult, now time.Time) transition {
+	a := res.agent
+	prev := a.Status()
+
+	if res.err == nil {
+		delete(hb.failures, a.Addr)
+		a.SetStatus(&agent.AgentStatus{
+			Online:      true,
+			LastSeen:    now,
+			RTT:         res.rtt,
+			Version:     res.version,
+			VersionSkew: agent.GetVersionSkew(res.version),
+		})
+		if prev != nil && !prev.Online {
+			log.Info().Str("agent", a.String()).Msg("agent is back online")
+			return transitionOnline
+		}
+		return transitionNone
+	}
+
+	failures := hb.failures[a.Addr] + 1
+	hb.failures[a.Addr] = failures
+
+	status := &agent.AgentStatus{Online: true, Version: a.GetVersion(), VersionSkew: agent.GetVersionSkew(a.GetVersion())}
+	if prev != nil {
+		*status = *prev
+	}
+	status.Detail = res.err.Error()
+	status.Online = status.Online && failures <= *hb.Retries
+	a.SetStatus(status)
+
+	if !status.Online && (prev == nil || prev.Online) {
+		log.Warn().Str("agent", a.String()).Err(res.err).Msg("agent went offline")
+		return transitionOffline
+	}
+	return transitionNone
+}
+
+func notifyTransitions(offline, online []*agent.AgentConfig) {
+	if len(offline) == 0 && len(online) == 0 {
+		return
+	}
+
+	var titles []string
+	body := make(notif.FieldsBody, 0, len(offline)+len(online))
+	if len(offline) > 0 {
+		titles = append(titles, fmt.Sprintf("❌ %s offline", numAgents(len(offline))))
+		for _, a := range offline {
+			status := a.Status()
+			value := "offline: " + status.Detail
+			if !status.LastSeen.IsZero() {
+				value += ", last seen " + strutils.FormatLastSeen(status.LastSeen)
+			}
+			body.Add(a.String(), value)
+		}
+	}
+	if len(online) > 0 {
+		titles = append(titles, fmt.Sprintf("✅ %s back online", numAgents(len(online))))
+		for _, a := range online {
+			status := a.Status()
+			value := fmt.Sprintf("online, RTT %d ms", status.RTT.Milliseconds())
+			if status.VersionSkew != agent.VersionSkewNone {
+				value += fmt.Sprintf(", version %s (%s)", status.Version, status.VersionSkew)
+			}
+			body.Add(a.String(), value)
+		}
+	}
+
+	level, color := zerolog.InfoLevel, notif.ColorSuccess
+	if len(offline) > 0 {
+		level, color = zerolog.WarnLevel, notif.ColorError
+	}
+	notify(&notif.LogMessage{
+		Level: level,
+		Title: strings.Join(titles, ", "),
+		Body:  body,
+		Color: color,
+	})
+}
+
+func numAgents(n int) string {
+	if n == 1 {
+		return "1 agent"
+	}
+	return fmt.Sprintf("%d agents", n)
+}
@@ -0,0 +1,103 @@
+package agentheartbeat
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	"github.com/yusing/godoxy/internal/notif"
+	"github.com/yusing/godoxy/internal/serialization"
+	expect "github.com/yusing/goutils/testing"
+	"github.com/yusing/goutils/version"
+)
+
+func addTestAgent(t *testing.T, name string) *agent.AgentConfig {
+	t.Helper()
+	a := &agent.AgentConfig{Addr: name + ":8890", Name: name}
+	agent.AddAgent(a)
+	t.Cleanup(func() { agent.RemoveAgent(a) })
+	return a
+}
+
+func TestHeartbeat(t *testing.T) {
+	down := make(map[*agent.AgentConfig]bool)
+	ping = func(a *agent.AgentConfig, _ context.Context) (time.Duration, version.Version, error) {
+		if down[a] {
+			return 0, version.Version{}, errors.New("connection refused")
+		}
+		return 20 * time.Millisecond, version.New(0, 1, 0), nil
+	}
+	var notified []*notif.LogMessage
+	notify = func(msg *notif.LogMessage) { notified = append(notified, msg) }
+	activeConfig.Store(&Config{MarkRoutesUnreachable: true})
+	t.Cleanup(func() {
+		ping = (*agent.AgentConfig).Ping
+		notify = notif.Notify
+		activeConfig.Store(nil)
+	})
+
+	nas := addTestAgent(t, "nas")
+	pi := addTestAgent(t, "pi")
+	retries := 1
+	hb := &heartbeat{Config: &Config{Timeout: time.Second, Retries: &retries}, failures: make(map[string]int)}
+
+	hb.round(t.Context())
+	expect.Equal(t, len(notified), 0)
+	expect.True(t, nas.Status().Online)
+	expect.Equal(t, nas.Status().RTT, 20*time.Millisecond)
+
+	down[nas], down[pi] = true, true
+	hb.round(t.Context())
+	expect.Equal(t, len(notified), 0)
+	expect.True(t, nas.Status().Online)
+	expect.Equal(t, nas.Status().Detail, "connection refused")
+	expect.False(t, RoutesUnreachable(nas))
+
+	// offline after retries, notified together
+	hb.round(t.Context())
+	expect.Equal(t, len(notified), 1)
+	expect.Equal(t, notified[0].Level, zerolog.WarnLevel)
+	expect.Equal(t, notified[0].Title, "❌ 2 agents offline")
+	expect.False(t, nas.Status().Online)
+	expect.False(t, nas.Status().LastSeen.IsZero())
+	expect.True(t, RoutesUnreachable(nas))
+
+	// not notified again while offline
+	hb.round(t.Context())
+	expect.Equal(t, len(notified), 1)
+
+	down[nas] = false
+	hb.round(t.Context())
+	expect.Equal(t, len(notified), 2)
+	expect.Equal(t, notified[1].Level, zerolog.InfoLevel)
+	expect.Equal(t, notified[1].Title, "✅ 1 agent back online")
+	expect.Equal(t, notified[1].Body.(notif.FieldsBody)[0].Name, nas.String())
+	expect.True(t, nas.Status().Online)
+	expect.False(t, RoutesUnreachable(nas))
+	expect.True(t, RoutesUnreachable(pi))
+}
+
+func TestHeartbeatZeroRetries(t *testing.T) {
+	ping = func(*agent.AgentConfig, context.Context) (time.Duration, version.Version, error) {
+		return 0, version.Version{}, errors.New("connection refused")
+	}
+	notify = func(*notif.LogMessage) {}
+	t.Cleanup(func() {
+		ping = (*agent.AgentConfig).Ping
+		notify = notif.Notify
+	})
+
+	var cfg Config
+	expect.NoError(t, serialization.UnmarshalValidateYAML([]byte("retries: 0"), &cfg))
+	expect.Equal(t, *cfg.Retries, 0)
+
+	nas := addTestAgent(t, "nas")
+	hb := &heartbeat{Config: &cfg, failures: make(map[string]int)}
+
+	// offline on the first failure
+	hb.round(t.Context())
+	expect.False(t, nas.Status().Online)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/yusing/godoxy/internal/auth"
+	"github.com/yusing/godoxy/internal/common"
+	"github.com/yusing/godoxy/internal/logging/accesslog"
+	"github.com/yusing/goutils/task"
+)
+
+var (
+	auditLogger     accesslog.AccessLogger
+	auditLoggerOnce sync.Once
+)
+
+// getAuditLogger returns the audit logger writing to common.AuditLogPath,
+// the file is rotated once it exceeds the retention size.
+func getAuditLogger() accesslog.AccessLogger {
+	auditLoggerOnce.Do(func() {
+		cfg := accesslog.DefaultAuditLoggerConfig()
+		cfg.Path = common.AuditLogPath
+		logger, err := accesslog.NewAccessLogger(task.RootTask("audit_log", true), cfg)
+		if err != nil {
+			log.Err(err).Msg("failed to open audit log, logging to stdout")
+			cfg.Path = ""
+			cfg.Stdout = true
+			logger, _ = accesslog.NewAccessLogger(task.RootTask("audit_log", true), cfg)
+		}
+		auditLogger = logger
+	})
+	return auditLogger
+}
+
+// AuditLog records who changed what, i.e. every request that is not read only.
+func AuditLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		event := &accesslog.AuditEvent{
+			IP:       c.ClientIP(),
+			Status:   c.Writer.Status(),
+			Duration: time.Since(start),
+		}
+		if user := auth.UserFromContext(c.Request.Context()); user != nil {
+			event.User = user.Username
+			event.Role = string(user.Role)
+			if user.IsAPIToken() {
+				event.TokenID = user.TokenID
+			}
+		}
+		getAuditLogger().LogAudit(c.Request, event)
+	}
+}
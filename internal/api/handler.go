@@ -17,6 +17,9 @@ import (
 	homepageApi "github.com/yusing/godoxy/internal/api/v1/homepage"
 	metricsApi "github.com/yusing/godoxy/internal/api/v1/metrics"
 	routeApi "github.com/yusing/godoxy/internal/api/v1/route"
+	signedurlApi "github.com/yusing/godoxy/internal/api/v1/signedurl"
+	tokensApi "github.com/yusing/godoxy/internal/api/v1/tokens"
+	usersApi "github.com/yusing/godoxy/internal/api/v1/users"
 	"github.com/yusing/godoxy/internal/auth"
 	"github.com/yusing/godoxy/internal/common"
 	apitypes "github.com/yusing/goutils/apitypes"
@@ -50,44 +53,58 @@ func NewHandler() *gin.Engine {
 	log.Debug().Msg("gin codec json.API: " + reflect.TypeOf(json.API).Name())
 
 	r.GET("/api/v1/version", apiV1.Version)
+	// agents are authenticated with mTLS inside the tunnel
+	r.GET("/api/v1/agent/tunnel", agentApi.Tunnel)
 
 	if auth.IsEnabled() {
 		v1Auth := r.Group("/api/v1/auth")
 		{
 			v1Auth.HEAD("/check", authApi.Check)
+			v1Auth.Any("/forward", authApi.Forward)
 			v1Auth.POST("/login", authApi.Login)
 			v1Auth.GET("/callback", authApi.Callback)
 			v1Auth.POST("/callback", authApi.Callback)
 			v1Auth.POST("/logout", authApi.Logout)
 			v1Auth.GET("/logout", authApi.Logout)
+			v1Auth.POST("/mfa/totp", authApi.MFATOTP)
+			v1Auth.POST("/mfa/webauthn/begin", authApi.MFAWebAuthnBegin)
+			v1Auth.POST("/mfa/webauthn/finish", authApi.MFAWebAuthnFinish)
 		}
 	}
 
 	v1 := r.Group("/api/v1")
 	if auth.IsEnabled() {
 		v1.Use(AuthMiddleware())
+		v1.Use(AuditLog())
+		v1.Use(RequireTokenScope())
 	}
 	if common.APISkipOriginCheck {
 		v1.Use(SkipOriginCheckMiddleware())
 	}
+
+	viewer := RequireRole(auth.RoleViewer)
+	operator := RequireRole(auth.RoleOperator)
+	admin := RequireRole(auth.RoleAdmin)
 	{
 		// enable cache for favicon
-		v1.GET("/favicon", apiV1.FavIcon)
-		v1.GET("/health", apiV1.Health)
-		v1.GET("/icons", apiV1.Icons)
-		v1.POST("/reload", apiV1.Reload)
-		v1.GET("/stats", apiV1.Stats)
+		v1.GET("/favicon", viewer, apiV1.FavIcon)
+		v1.GET("/health", viewer, apiV1.Health)
+		v1.GET("/icons", viewer, apiV1.Icons)
+		v1.POST("/reload", operator, apiV1.Reload)
+		v1.GET("/stats", viewer, apiV1.Stats)
 
-		route := v1.Group("/route")
+		route := v1.Group("/route", viewer)
 		{
 			route.GET("/list", routeApi.Routes)
 			route.GET("/:which", routeApi.Route)
 			route.GET("/providers", routeApi.Providers)
 			route.GET("/by_provider", routeApi.ByProvider)
 			route.POST("/playground", routeApi.Playground)
+			route.POST("/weights", operator, routeApi.SetWeights)
 		}
 
-		file := v1.Group("/file")
+		// config files may contain secrets
+		file := v1.Group("/file", admin)
 		{
 			file.GET("/list", fileApi.List)
 			file.GET("/content", fileApi.Get)
@@ -96,50 +113,83 @@ func NewHandler() *gin.Engine {
 			file.POST("/validate", fileApi.Validate)
 		}
 
-		homepage := v1.Group("/homepage")
+		homepage := v1.Group("/homepage", viewer)
 		{
 			homepage.GET("/categories", homepageApi.Categories)
 			homepage.GET("/items", homepageApi.Items)
-			homepage.POST("/set/item", homepageApi.SetItem)
-			homepage.POST("/set/items_batch", homepageApi.SetItemsBatch)
-			homepage.POST("/set/item_visible", homepageApi.SetItemVisible)
-			homepage.POST("/set/item_favorite", homepageApi.SetItemFavorite)
-			homepage.POST("/set/item_sort_order", homepageApi.SetItemSortOrder)
-			homepage.POST("/set/item_all_sort_order", homepageApi.SetItemAllSortOrder)
-			homepage.POST("/set/item_fav_sort_order", homepageApi.SetItemFavSortOrder)
-			homepage.POST("/set/category_order", homepageApi.SetCategoryOrder)
+			homepage.POST("/set/item", operator, homepageApi.SetItem)
+			homepage.POST("/set/items_batch", operator, homepageApi.SetItemsBatch)
+			homepage.POST("/set/item_visible", operator, homepageApi.SetItemVisible)
+			homepage.POST("/set/item_favorite", operator, homepageApi.SetItemFavorite)
+			homepage.POST("/set/item_sort_order", operator, homepageApi.SetItemSortOrder)
+			homepage.POST("/set/item_all_sort_order", operator, homepageApi.SetItemAllSortOrder)
+			homepage.POST("/set/item_fav_sort_order", operator, homepageApi.SetItemFavSortOrder)
+			homepage.POST("/set/category_order", operator, homepageApi.SetCategoryOrder)
 			homepage.POST("/item_click", homepageApi.ItemClick)
 		}
 
-		cert := v1.Group("/cert")
+		cert := v1.Group("/cert", viewer)
 		{
 			cert.GET("/info", certApi.Info)
-			cert.GET("/renew", certApi.Renew)
+			cert.GET("/renew", operator, certApi.Renew)
 		}
 
-		agent := v1.Group("/agent")
+		agent := v1.Group("/agent", viewer)
 		{
 			agent.GET("/list", agentApi.List)
-			agent.POST("/create", agentApi.Create)
-			agent.POST("/verify", agentApi.Verify)
+			agent.POST("/create", admin, agentApi.Create)
+			agent.POST("/verify", admin, agentApi.Verify)
+			agent.GET("/certs", agentApi.Certs)
+			agent.POST("/certs/rotate", admin, agentApi.RotateCerts)
+			agent.POST("/certs/revoke", admin, agentApi.RevokeCerts)
 		}
 
-		metrics := v1.Group("/metrics")
+		metrics := v1.Group("/metrics", viewer)
 		{
 			metrics.GET("/system_info", metricsApi.SystemInfo)
 			metrics.GET("/all_system_info", metricsApi.AllSystemInfo)
 			metrics.GET("/uptime", metricsApi.Uptime)
 		}
 
-		docker := v1.Group("/docker")
+		docker := v1.Group("/docker", viewer)
 		{
 			docker.GET("/container/:id", dockerApi.GetContainer)
 			docker.GET("/containers", dockerApi.Containers)
 			docker.GET("/info", dockerApi.Info)
 			docker.GET("/logs/:id", dockerApi.Logs)
-			docker.POST("/start", dockerApi.Start)
-			docker.POST("/stop", dockerApi.Stop)
-			docker.POST("/restart", dockerApi.Restart)
+			docker.POST("/start", operator, dockerApi.Start)
+			docker.POST("/stop", operator, dockerApi.Stop)
+			docker.POST("/restart", operator, dockerApi.Restart)
+		}
+
+		users := v1.Group("/users")
+		{
+			users.GET("/me", usersApi.Me)
+			users.GET("/mfa", usersApi.MFAStatus)
+			users.POST("/mfa/totp/enroll", usersApi.TOTPEnroll)
+			users.POST("/mfa/totp/confirm", usersApi.TOTPConfirm)
+			users.POST("/mfa/totp/disable", usersApi.TOTPDisable)
+			users.POST("/mfa/recovery_codes", usersApi.RegenerateRecoveryCodes)
+			users.POST("/mfa/webauthn/register/begin", usersApi.WebAuthnRegisterBegin)
+			users.POST("/mfa/webauthn/register/finish", usersApi.WebAuthnRegisterFinish)
+			users.POST("/mfa/webauthn/delete", usersApi.WebAuthnDelete)
+			users.GET("/list", admin, usersApi.List)
+			users.POST("/create", admin, usersApi.Create)
+			users.POST("/update", admin, usersApi.Update)
+			users.POST("/delete", admin, usersApi.Delete)
+		}
+
+		tokens := v1.Group("/tokens", viewer)
+		{
+			tokens.GET("/list", tokensApi.List)
+			tokens.POST("/create", tokensApi.Create)
+			tokens.POST("/revoke", tokensApi.Revoke)
+		}
+
+		signedURL := v1.Group("/signed_url", operator)
+		{
+			signedURL.POST("/create", signedurlApi.Create)
+			signedURL.POST("/revoke", signedurlApi.Revoke)
 		}
 	}
 
@@ -160,12 +210,19 @@ func NoCache() gin.HandlerFunc {
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		err := auth.GetDefaultAuth().CheckToken(c.Request)
+		var user *auth.UserInfo
+		var err error
+		if token, ok := auth.BearerToken(c.Request); ok {
+			user, err = auth.CheckAPIToken(token)
+		} else {
+			user, err = auth.GetDefaultAuth().CurrentUser(c.Request)
+		}
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, apitypes.Error("Unauthorized", err))
 			c.Abort()
 			return
 		}
+		c.Request = c.Request.WithContext(auth.WithUser(c.Request.Context(), user))
 		c.Next()
 	}
 }
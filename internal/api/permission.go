@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusing/godoxy/internal/auth"
+	apitypes "github.com/yusing/goutils/apitypes"
+)
+
+// RequireRole aborts the request with 403 unless the authenticated user has at least the given role.
+//
+// It is a no-op when authentication is disabled.
+func RequireRole(role auth.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.IsEnabled() {
+			c.Next()
+			return
+		}
+		user := auth.UserFromContext(c.Request.Context())
+		if user == nil || !user.Role.Allows(role) {
+			c.JSON(http.StatusForbidden, apitypes.Error("Forbidden: "+string(role)+" role required"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// tokenScopes maps the endpoints accessible with API tokens to the required scope.
+//
+// Endpoints not listed here are not accessible with API tokens.
+var tokenScopes = map[string]auth.TokenScope{
+	"/api/v1/reload": auth.ScopeReload,
+
+	"/api/v1/route/list":        auth.ScopeRoutesRead,
+	"/api/v1/route/:which":      auth.ScopeRoutesRead,
+	"/api/v1/route/providers":   auth.ScopeRoutesRead,
+	"/api/v1/route/by_provider": auth.ScopeRoutesRead,
+
+	"/api/v1/docker/container/:id": auth.ScopeDockerControl,
+	"/api/v1/docker/containers":    auth.ScopeDockerControl,
+	"/api/v1/docker/start":         auth.ScopeDockerControl,
+	"/api/v1/docker/stop":          auth.ScopeDockerControl,
+	"/api/v1/docker/restart":       auth.ScopeDockerControl,
+
+	"/api/v1/file/list":     auth.ScopeFileWrite,
+	"/api/v1/file/content":  auth.ScopeFileWrite,
+	"/api/v1/file/validate": auth.ScopeFileWrite,
+
+	"/api/v1/cert/info":  auth.ScopeCertRenew,
+	"/api/v1/cert/renew": auth.ScopeCertRenew,
+}
+
+// RequireTokenScope aborts requests authenticated by API tokens with 403
+// unless the token has the scope required by the endpoint.
+//
+// Requests authenticated by sessions are not affected.
+func RequireTokenScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := auth.UserFromContext(c.Request.Context())
+		if user == nil || !user.IsAPIToken() {
+			c.Next()
+			return
+		}
+		scope, ok := tokenScopes[c.FullPath()]
+		if !ok {
+			c.JSON(http.StatusForbidden, apitypes.Error("Forbidden: endpoint is not accessible with api tokens"))
+			c.Abort()
+			return
+		}
+		if !user.HasScope(scope) {
+			c.JSON(http.StatusForbidden, apitypes.Error("Forbidden: "+string(scope)+" scope required"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
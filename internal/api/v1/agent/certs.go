@@ -0,0 +1,134 @@
+package agentapi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	"github.com/yusing/godoxy/internal/agentcerts"
+	"github.com/yusing/godoxy/internal/config"
+	apitypes "github.com/yusing/goutils/apitypes"
+)
+
+type AgentCertsInfo struct {
+	Name          string    `json:"name"`
+	Addr          string    `json:"addr"`
+	CAFingerprint string    `json:"ca_fingerprint"`
+	ExpiresAt     time.Time `json:"expires_at"`
+} // @name AgentCertsInfo
+
+type RotateCertsRequest struct {
+	Name               string `json:"name" binding:"required"`
+	ValidityDays       int    `json:"validity_days" binding:"omitempty,min=1"`        // default 365
+	GracePeriodMinutes int    `json:"grace_period_minutes" binding:"omitempty,min=0"` // default 1440, the previous certificates are accepted by the agent meanwhile
+} // @name RotateAgentCertsRequest
+
+type RevokeCertsRequest struct {
+	Name string `json:"name" binding:"required"`
+} // @name RevokeAgentCertsRequest
+
+// @x-id				"certs"
+// @BasePath		/api/v1
+// @Summary		List agent certificates
+// @Description	List the CA fingerprint and the earliest certificate expiry of agents
+// @Tags			agent
+// @Produce		json
+// @Success		200	{array}		AgentCertsInfo
+// @Failure		403	{object}	apitypes.ErrorResponse
+// @Router			/agent/certs [get]
+func Certs(c *gin.Context) {
+	agents := agent.ListAgents()
+	infos := make([]AgentCertsInfo, 0, len(agents))
+	for _, a := range agents {
+		infos = append(infos, AgentCertsInfo{
+			Name:          a.Name,
+			Addr:          a.Addr,
+			CAFingerprint: a.CAFingerprint(),
+			ExpiresAt:     a.CertsExpiry(),
+		})
+	}
+	c.JSON(http.StatusOK, infos)
+}
+
+// @x-id				"rotateCerts"
+// @BasePath		/api/v1
+// @Summary		Rotate agent certificates
+// @Description	Issue new certificates for an agent and install them without downtime
+// @Tags			agent
+// @Accept			json
+// @Produce		json
+// @Param			request	body		RotateCertsRequest	true	"Request"
+// @Success		200		{object}	apitypes.SuccessResponse
+// @Failure		400		{object}	apitypes.ErrorResponse
+// @Failure		403		{object}	apitypes.ErrorResponse
+// @Failure		404		{object}	apitypes.ErrorResponse
+// @Failure		500		{object}	apitypes.ErrorResponse
+// @Router			/agent/certs/rotate [post]
+func RotateCerts(c *gin.Context) {
+	var request RotateCertsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
+		return
+	}
+
+	a, ok := agent.GetAgentByName(request.Name)
+	if !ok {
+		c.JSON(http.StatusNotFound, apitypes.Error("agent not found"))
+		return
+	}
+
+	validity := agentcerts.DefaultValidity
+	if request.ValidityDays > 0 {
+		validity = time.Duration(request.ValidityDays) * 24 * time.Hour
+	}
+	gracePeriod := agentcerts.DefaultGracePeriod
+	if request.GracePeriodMinutes > 0 {
+		gracePeriod = time.Duration(request.GracePeriodMinutes) * time.Minute
+	}
+
+	if err := agentcerts.Rotate(c.Request.Context(), a, validity, gracePeriod); err != nil {
+		c.Error(apitypes.InternalServerError(err, "failed to rotate agent certificates"))
+		return
+	}
+	c.JSON(http.StatusOK, apitypes.Success("agent certificates rotated"))
+}
+
+// @x-id				"revokeCerts"
+// @BasePath		/api/v1
+// @Summary		Revoke agent certificates
+// @Description	Revoke the certificates of a compromised agent, it is disconnected immediately and not loaded again until re-added
+// @Tags			agent
+// @Accept			json
+// @Produce		json
+// @Param			request	body		RevokeCertsRequest	true	"Request"
+// @Success		200		{object}	apitypes.SuccessResponse
+// @Failure		400		{object}	apitypes.ErrorResponse
+// @Failure		403		{object}	apitypes.ErrorResponse
+// @Failure		404		{object}	apitypes.ErrorResponse
+// @Failure		500		{object}	apitypes.ErrorResponse
+// @Router			/agent/certs/revoke [post]
+func RevokeCerts(c *gin.Context) {
+	var request RevokeCertsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
+		return
+	}
+
+	a, ok := agent.GetAgentByName(request.Name)
+	if !ok {
+		c.JSON(http.StatusNotFound, apitypes.Error("agent not found"))
+		return
+	}
+
+	if err := agentcerts.Revoke(a); err != nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("failed to revoke agent certificates", err))
+		return
+	}
+	// remove the routes of the agent
+	if err := config.Reload(); err != nil {
+		c.Error(apitypes.InternalServerError(err, "agent certificates revoked but failed to reload config"))
+		return
+	}
+	c.JSON(http.StatusOK, apitypes.Success("agent certificates revoked"))
+}
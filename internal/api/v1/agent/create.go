@@ -14,8 +14,9 @@ import (
 
 type NewAgentRequest struct {
 	Name             string                 `json:"name" binding:"required"`
-	Host             string                 `json:"host" binding:"required"`
-	Port             int                    `json:"port" binding:"required,min=1,max=65535"`
+	Host             string                 `json:"host" binding:"required_without=TunnelURL"`
+	Port             int                    `json:"port" binding:"required_without=TunnelURL,omitempty,min=1,max=65535"`
+	TunnelURL        string                 `json:"tunnel_url" binding:"omitempty,url"` // e.g. wss://godoxy.example.com/api/v1/agent/tunnel, the agent dials it instead of listening on port
 	Type             string                 `json:"type" binding:"required,oneof=docker system"`
 	Nightly          bool                   `json:"nightly" binding:"omitempty"`
 	ContainerRuntime agent.ContainerRuntime `json:"container_runtime" binding:"omitempty,oneof=docker podman" default:"docker"`
@@ -49,8 +50,11 @@ func Create(c *gin.Context) {
 		return
 	}
 
-	hostport := net.JoinHostPort(request.Host, strconv.Itoa(request.Port))
-	if _, ok := agent.GetAgent(hostport); ok {
+	addr := net.JoinHostPort(request.Host, strconv.Itoa(request.Port))
+	if request.TunnelURL != "" {
+		addr = request.Name
+	}
+	if _, ok := agent.GetAgent(addr); ok {
 		c.JSON(http.StatusConflict, apitypes.Error("agent already exists"))
 		return
 	}
@@ -74,6 +78,7 @@ func Create(c *gin.Context) {
 		CACert:           ca.String(),
 		SSLCert:          srv.String(),
 		ContainerRuntime: request.ContainerRuntime,
+		TunnelURL:        request.TunnelURL,
 	}
 	if request.Type == "docker" {
 		cfg = &agent.AgentComposeConfig{
@@ -15,7 +15,7 @@ import (
 // @x-id				"list"
 // @BasePath		/api/v1
 // @Summary		List agents
-// @Description	List agents
+// @Description	List agents with their heartbeat status
 // @Tags			agent,websocket
 // @Accept			json
 // @Produce		json
@@ -0,0 +1,56 @@
+package agentapi
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	apitypes "github.com/yusing/goutils/apitypes"
+	"github.com/yusing/goutils/http/httpheaders"
+)
+
+var tunnelUpgrader = websocket.Upgrader{
+	ReadBufferSize:  32 * 1024,
+	WriteBufferSize: 32 * 1024,
+}
+
+// @x-id				"tunnel"
+// @BasePath		/api/v1
+// @Summary		Agent tunnel
+// @Description	Websocket endpoint dialed by agents in tunnel mode, the agent is authenticated with mTLS inside the tunnel
+// @Tags			agent,websocket
+// @Param			X-GoDoxy-Agent-Name	header	string	true	"Agent name"
+// @Success		101
+// @Failure		400	{object}	apitypes.ErrorResponse
+// @Failure		404	{object}	apitypes.ErrorResponse
+// @Failure		429	{object}	apitypes.ErrorResponse
+// @Router			/agent/tunnel [get]
+func Tunnel(c *gin.Context) {
+	if !httpheaders.IsWebsocket(c.Request.Header) {
+		c.JSON(http.StatusBadRequest, apitypes.Error("websocket upgrade required"))
+		return
+	}
+
+	name := c.GetHeader(agent.HeaderAgentName)
+	a, ok := agent.GetAgent(name)
+	if !ok || !a.Tunnel {
+		c.JSON(http.StatusNotFound, apitypes.Error("agent not found"))
+		return
+	}
+
+	if !a.CanHandshakeTunnel() {
+		c.JSON(http.StatusTooManyRequests, apitypes.Error("too many pending tunnel handshakes"))
+		return
+	}
+
+	conn, err := tunnelUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// response is already written by the upgrader
+		return
+	}
+
+	if err := a.ServeTunnel(c.Request.Context(), conn); err != nil {
+		c.Error(apitypes.InternalServerError(err, "agent tunnel error"))
+	}
+}
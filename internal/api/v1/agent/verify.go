@@ -1,9 +1,11 @@
 package agentapi
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/yusing/godoxy/agent/pkg/agent"
@@ -15,7 +17,8 @@ import (
 )
 
 type VerifyNewAgentRequest struct {
-	Host             string                 `json:"host"`
+	Host             string                 `json:"host"` // agent name for agents in tunnel mode
+	Tunnel           bool                   `json:"tunnel"`
 	CA               PEMPairResponse        `json:"ca"`
 	Client           PEMPairResponse        `json:"client"`
 	ContainerRuntime agent.ContainerRuntime `json:"container_runtime"`
@@ -59,7 +62,7 @@ func Verify(c *gin.Context) {
 		return
 	}
 
-	nRoutesAdded, err := verifyNewAgent(request.Host, ca, client, request.ContainerRuntime)
+	nRoutesAdded, err := verifyNewAgent(request.Host, request.Tunnel, ca, client, request.ContainerRuntime)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
 		return
@@ -79,7 +82,10 @@ func Verify(c *gin.Context) {
 	c.JSON(http.StatusOK, apitypes.Success(fmt.Sprintf("Added %d routes", nRoutesAdded)))
 }
 
-func verifyNewAgent(host string, ca agent.PEMPair, client agent.PEMPair, containerRuntime agent.ContainerRuntime) (int, gperr.Error) {
+// tunnelVerifyTimeout is how long to wait for a new agent in tunnel mode to connect.
+const tunnelVerifyTimeout = 30 * time.Second
+
+func verifyNewAgent(host string, tunnel bool, ca agent.PEMPair, client agent.PEMPair, containerRuntime agent.ContainerRuntime) (int, gperr.Error) {
 	cfgState := config.ActiveState.Load()
 	for _, a := range cfgState.Value().Providers.Agents {
 		if a.Addr == host {
@@ -90,6 +96,7 @@ func verifyNewAgent(host string, ca agent.PEMPair, client agent.PEMPair, contain
 	var agentCfg agent.AgentConfig
 	agentCfg.Addr = host
 	agentCfg.Runtime = containerRuntime
+	agentCfg.Tunnel = tunnel
 
 	err := agentCfg.StartWithCerts(cfgState.Context(), ca.Cert, client.Cert, client.Key)
 	if err != nil {
@@ -103,6 +110,16 @@ func verifyNewAgent(host string, ca agent.PEMPair, client agent.PEMPair, contain
 
 	// agent must be added before loading routes
 	agent.AddAgent(&agentCfg)
+	if tunnel {
+		ctx, cancel := context.WithTimeout(cfgState.Context(), tunnelVerifyTimeout)
+		err = agentCfg.WaitTunnel(ctx)
+		cancel()
+		if err != nil {
+			cfgState.DeleteProvider(provider.String())
+			agent.RemoveAgent(&agentCfg)
+			return 0, gperr.Wrap(err, "agent did not connect")
+		}
+	}
 	err = provider.LoadRoutes()
 	if err != nil {
 		cfgState.DeleteProvider(provider.String())
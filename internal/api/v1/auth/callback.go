@@ -12,12 +12,16 @@ import (
 // @Description	Handles the callback from the provider after successful authentication
 // @Tags			auth
 // @Produce		plain
-// @Param		  body	body	auth.UserPassAuthCallbackRequest	true	"Userpass only"
+// @Param		  body	body	auth.UserPassAuthCallbackRequest	true	"Userpass and LDAP only"
 // @Success		200	{string}	string	"Userpass: OK"
 // @Success		302	{string}	string	"OIDC: Redirects to home page"
 // @Failure		400	{string}	string	"OIDC: invalid request (missing state cookie or oauth state)"
 // @Failure		400	{string}	string	"Userpass: invalid request / credentials"
+// @Failure		401	{object}	auth.MFARequiredResponse	"Userpass: second factor required"
+// @Failure		429	{string}	string	"Userpass: too many login attempts"
+// @Failure		403	{string}	string	"LDAP: user not allowed"
 // @Failure		500	{string}	string	"Internal server error"
+// @Failure		502	{string}	string	"LDAP: server unavailable"
 // @Router			/auth/callback [post]
 func Callback(c *gin.Context) {
 	auth.GetDefaultAuth().PostAuthCallbackHandler(c.Writer, c.Request)
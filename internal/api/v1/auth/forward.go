@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yusing/godoxy/internal/auth"
+)
+
+// @x-id				"forward"
+// @Base			/api/v1
+// @Summary		Forward authentication
+// @Description	Auth endpoint for nginx auth_request and Traefik forwardAuth, honours the session cookies of GoDoxy
+// @Tags			auth
+// @Produce		plain
+// @Param			rd	query		string	false	"Login page to redirect to, overrides GODOXY_API_FORWARD_AUTH_LOGIN_URL"
+// @Success		200	{string}	string	"OK, with Remote-User and Remote-Groups headers"
+// @Failure		302	{string}	string	"Redirects to login page (Traefik)"
+// @Failure		401	{string}	string	"Unauthorized, login page in Location header (nginx)"
+// @Router			/auth/forward [get]
+func Forward(c *gin.Context) {
+	auth.ForwardAuthHandler(c.Writer, c.Request)
+}
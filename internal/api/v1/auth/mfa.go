@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusing/godoxy/internal/auth"
+)
+
+func userPassAuth(c *gin.Context) (*auth.UserPassAuth, bool) {
+	p, ok := auth.GetDefaultAuth().(*auth.UserPassAuth)
+	if !ok {
+		c.String(http.StatusNotFound, "second factors are only available for password login")
+	}
+	return p, ok
+}
+
+// @x-id				"mfaTOTP"
+// @Base			/api/v1
+// @Summary		Second factor: TOTP
+// @Description	Completes the password login with a TOTP or recovery code
+// @Tags			auth
+// @Accept			json
+// @Produce		plain
+// @Param			body	body		auth.MFATOTPRequest	true	"TOTP or recovery code"
+// @Success		200		{string}	string				"OK"
+// @Failure		400		{string}	string				"invalid request / code"
+// @Failure		401		{string}	string				"password login required"
+// @Failure		429		{string}	string				"Too Many Requests"
+// @Router			/auth/mfa/totp [post]
+func MFATOTP(c *gin.Context) {
+	if p, ok := userPassAuth(c); ok {
+		p.MFATOTPHandler(c.Writer, c.Request)
+	}
+}
+
+// @x-id				"mfaWebAuthnBegin"
+// @Base			/api/v1
+// @Summary		Second factor: begin passkey
+// @Description	Returns the options for navigator.credentials.get()
+// @Tags			auth
+// @Produce		json
+// @Success		200	{object}	auth.WebAuthnRequestOptions
+// @Failure		400	{string}	string	"no passkey registered"
+// @Failure		401	{string}	string	"password login required"
+// @Router			/auth/mfa/webauthn/begin [post]
+func MFAWebAuthnBegin(c *gin.Context) {
+	if p, ok := userPassAuth(c); ok {
+		p.WebAuthnBeginHandler(c.Writer, c.Request)
+	}
+}
+
+// @x-id				"mfaWebAuthnFinish"
+// @Base			/api/v1
+// @Summary		Second factor: finish passkey
+// @Description	Completes the password login with the result of navigator.credentials.get()
+// @Tags			auth
+// @Accept			json
+// @Produce		plain
+// @Param			body	body		auth.WebAuthnAssertion	true	"Passkey assertion"
+// @Success		200		{string}	string					"OK"
+// @Failure		400		{string}	string					"invalid request / passkey"
+// @Failure		401		{string}	string					"password login required"
+// @Failure		429		{string}	string					"Too Many Requests"
+// @Router			/auth/mfa/webauthn/finish [post]
+func MFAWebAuthnFinish(c *gin.Context) {
+	if p, ok := userPassAuth(c); ok {
+		p.WebAuthnFinishHandler(c.Writer, c.Request)
+	}
+}
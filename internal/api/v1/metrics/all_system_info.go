@@ -129,12 +129,11 @@ func AllSystemInfo(c *gin.Context) {
 
 		for _, a := range agent.IterAgents() {
 			totalAgents++
-			agentShallowCopy := *a
 
 			roundWg.Go(func() {
-				data, err := getAgentSystemInfoWithRetry(manager.Context(), &agentShallowCopy, queryEncoded)
+				data, err := getAgentSystemInfoWithRetry(manager.Context(), a, queryEncoded)
 				if err != nil {
-					errs.Add(gperr.Wrap(err, "Agent "+agentShallowCopy.Name))
+					errs.Add(gperr.Wrap(err, "Agent "+a.Name))
 					numErrs.Add(1)
 					return
 				}
@@ -142,7 +141,7 @@ func AllSystemInfo(c *gin.Context) {
 				case <-manager.Done():
 					return
 				case dataCh <- SystemInfoData{
-					AgentName:  agentShallowCopy.Name,
+					AgentName:  a.Name,
 					SystemInfo: data,
 				}:
 				}
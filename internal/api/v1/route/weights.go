@@ -0,0 +1,53 @@
+package routeApi
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusing/godoxy/internal/net/gphttp/loadbalancer"
+	"github.com/yusing/godoxy/internal/route/routes"
+	apitypes "github.com/yusing/goutils/apitypes"
+)
+
+type SetWeightsRequest struct {
+	Link    string         `json:"link" binding:"required"`
+	Weights map[string]int `json:"weights" binding:"required"` // server name or host:port => weight
+} //	@name	SetWeightsRequest
+
+// @x-id				"setWeights"
+// @BasePath		/api/v1
+// @Summary		Set load balancer weights
+// @Description	Set weights of load balanced servers without reloading
+// @Tags			route
+// @Accept			json
+// @Produce		json
+// @Param			request	body		SetWeightsRequest	true	"Request"
+// @Success		200		{object}	apitypes.SuccessResponse
+// @Failure		400		{object}	apitypes.ErrorResponse
+// @Failure		403		{object}	apitypes.ErrorResponse
+// @Failure		404		{object}	apitypes.ErrorResponse
+// @Router			/route/weights [post]
+func SetWeights(c *gin.Context) {
+	var request SetWeightsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
+		return
+	}
+
+	r, ok := routes.HTTP.Get(request.Link)
+	if !ok {
+		c.JSON(http.StatusNotFound, apitypes.Error("load balancer not found"))
+		return
+	}
+	lb, ok := r.HealthMonitor().(*loadbalancer.LoadBalancer)
+	if !ok {
+		c.JSON(http.StatusBadRequest, apitypes.Error("route is not a load balancer"))
+		return
+	}
+
+	if err := lb.SetWeights(request.Weights); err != nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("failed to set weights", err))
+		return
+	}
+	c.JSON(http.StatusOK, apitypes.Success("weights updated"))
+}
@@ -0,0 +1,54 @@
+package signedurlapi
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusing/godoxy/internal/auth"
+	apitypes "github.com/yusing/goutils/apitypes"
+)
+
+type CreateSignedURLRequest struct {
+	URL        string    `json:"url" binding:"required,url"`
+	PathPrefix string    `json:"path_prefix"` // optional, defaults to the path of url
+	ExpiresAt  time.Time `json:"expires_at" binding:"required"`
+	MaxUses    int       `json:"max_uses" binding:"min=0"` // optional, unlimited if omitted
+} //	@name	CreateSignedURLRequest
+
+// @x-id				"create"
+// @BasePath		/api/v1
+// @Summary		Create a signed URL
+// @Description	Create an HMAC signed URL that allows requests to the same host under the path prefix
+// @Description	without other authentication, until it expires, is revoked or reaches max uses.
+// @Tags			signed_url
+// @Accept			json
+// @Produce		json
+// @Param			request	body		CreateSignedURLRequest	true	"Request"
+// @Success		200		{object}	auth.SignedURLInfo
+// @Failure		400		{object}	apitypes.ErrorResponse
+// @Failure		403		{object}	apitypes.ErrorResponse
+// @Router			/signed_url/create [post]
+func Create(c *gin.Context) {
+	var request CreateSignedURLRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
+		return
+	}
+
+	var createdBy string
+	if user := auth.UserFromContext(c.Request.Context()); user != nil {
+		createdBy = user.Username
+	}
+
+	info, err := auth.CreateSignedURL(request.URL, request.PathPrefix, request.ExpiresAt, request.MaxUses, createdBy)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, info)
+	case errors.Is(err, auth.ErrSignedURLDisabled):
+		c.JSON(http.StatusBadRequest, apitypes.Error("signed URLs are not available", err))
+	default:
+		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
+	}
+}
@@ -0,0 +1,44 @@
+package signedurlapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusing/godoxy/internal/auth"
+	apitypes "github.com/yusing/goutils/apitypes"
+)
+
+type RevokeSignedURLRequest struct {
+	URL string `json:"url" binding:"required,url"`
+} //	@name	RevokeSignedURLRequest
+
+// @x-id				"revoke"
+// @BasePath		/api/v1
+// @Summary		Revoke a signed URL
+// @Description	Revoke a signed URL until it expires
+// @Tags			signed_url
+// @Accept			json
+// @Produce		json
+// @Param			request	body		RevokeSignedURLRequest	true	"Request"
+// @Success		200		{object}	auth.SignedURLInfo
+// @Failure		400		{object}	apitypes.ErrorResponse
+// @Failure		403		{object}	apitypes.ErrorResponse
+// @Router			/signed_url/revoke [post]
+func Revoke(c *gin.Context) {
+	var request RevokeSignedURLRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
+		return
+	}
+
+	info, err := auth.RevokeSignedURL(request.URL)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, info)
+	case errors.Is(err, auth.ErrSignedURLDisabled):
+		c.JSON(http.StatusBadRequest, apitypes.Error("signed URLs are not available", err))
+	default:
+		c.JSON(http.StatusBadRequest, apitypes.Error("not a valid signed URL", err))
+	}
+}
@@ -0,0 +1,59 @@
+package tokensapi
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusing/godoxy/internal/auth"
+	apitypes "github.com/yusing/goutils/apitypes"
+)
+
+type CreateTokenRequest struct {
+	Name      string            `json:"name" binding:"required"`
+	Scopes    []auth.TokenScope `json:"scopes" binding:"required,min=1,dive,oneof=routes:read docker:control file:write cert:renew reload"`
+	ExpiresAt time.Time         `json:"expires_at"` // optional, never expires if omitted
+} //	@name	CreateTokenRequest
+
+type CreateTokenResponse struct {
+	Token string             `json:"token"` // shown only once
+	Info  *auth.APITokenInfo `json:"info"`
+} //	@name	CreateTokenResponse
+
+// @x-id				"create"
+// @BasePath		/api/v1
+// @Summary		Create an API token
+// @Description	Create an API token for the current user, to be sent as "Authorization: Bearer <token>".
+// @Description	The token acts with the role of the current user, restricted to the given scopes.
+// @Tags			tokens
+// @Accept			json
+// @Produce		json
+// @Param			request	body		CreateTokenRequest	true	"Request"
+// @Success		200		{object}	CreateTokenResponse
+// @Failure		400		{object}	apitypes.ErrorResponse
+// @Failure		403		{object}	apitypes.ErrorResponse
+// @Router			/tokens/create [post]
+func Create(c *gin.Context) {
+	var request CreateTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
+		return
+	}
+
+	user := auth.UserFromContext(c.Request.Context())
+	if user == nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("authentication is disabled"))
+		return
+	}
+
+	token, info, err := auth.CreateAPIToken(user, request.Name, request.Scopes, request.ExpiresAt)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, CreateTokenResponse{Token: token, Info: info})
+	case errors.Is(err, auth.ErrInvalidScope), errors.Is(err, auth.ErrAPITokenExpired):
+		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
+	default:
+		c.Error(apitypes.InternalServerError(err, "failed to create token"))
+	}
+}
@@ -0,0 +1,27 @@
+package tokensapi
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusing/godoxy/internal/auth"
+)
+
+// @x-id				"list"
+// @BasePath		/api/v1
+// @Summary		List API tokens
+// @Description	List API tokens of the current user, admins see all tokens
+// @Tags			tokens
+// @Produce		json
+// @Success		200	{array}		auth.APITokenInfo
+// @Failure		403	{object}	apitypes.ErrorResponse
+// @Router			/tokens/list [get]
+func List(c *gin.Context) {
+	user := auth.UserFromContext(c.Request.Context())
+	// user is nil when authentication is disabled
+	if user == nil || user.Role.Allows(auth.RoleAdmin) {
+		c.JSON(http.StatusOK, auth.ListAPITokens(""))
+		return
+	}
+	c.JSON(http.StatusOK, auth.ListAPITokens(user.Username))
+}
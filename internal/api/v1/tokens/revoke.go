@@ -0,0 +1,49 @@
+package tokensapi
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusing/godoxy/internal/auth"
+	apitypes "github.com/yusing/goutils/apitypes"
+)
+
+type RevokeTokenRequest struct {
+	ID string `json:"id" binding:"required"`
+} //	@name	RevokeTokenRequest
+
+// @x-id				"revoke"
+// @BasePath		/api/v1
+// @Summary		Revoke an API token
+// @Description	Revoke an API token of the current user, admins can revoke any token
+// @Tags			tokens
+// @Accept			json
+// @Produce		json
+// @Param			request	body		RevokeTokenRequest	true	"Request"
+// @Success		200		{object}	apitypes.SuccessResponse
+// @Failure		400		{object}	apitypes.ErrorResponse
+// @Failure		403		{object}	apitypes.ErrorResponse
+// @Failure		404		{object}	apitypes.ErrorResponse
+// @Router			/tokens/revoke [post]
+func Revoke(c *gin.Context) {
+	var request RevokeTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
+		return
+	}
+
+	token, ok := auth.GetAPIToken(request.ID)
+	if !ok {
+		c.JSON(http.StatusNotFound, apitypes.Error("token not found"))
+		return
+	}
+	user := auth.UserFromContext(c.Request.Context())
+	if user != nil && !user.Role.Allows(auth.RoleAdmin) && token.Username != user.Username {
+		// do not reveal tokens of other users
+		c.JSON(http.StatusNotFound, apitypes.Error("token not found"))
+		return
+	}
+
+	auth.RevokeAPIToken(request.ID)
+	c.JSON(http.StatusOK, apitypes.Success("token revoked"))
+}
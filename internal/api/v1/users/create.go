@@ -0,0 +1,55 @@
+package usersapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusing/godoxy/internal/auth"
+	apitypes "github.com/yusing/goutils/apitypes"
+)
+
+type CreateUserRequest struct {
+	Username string    `json:"username" binding:"required"`
+	Password string    `json:"password" binding:"required,min=8"`
+	Role     auth.Role `json:"role" binding:"required,oneof=viewer operator admin"`
+} //	@name	CreateUserRequest
+
+// @x-id				"create"
+// @BasePath		/api/v1
+// @Summary		Create a user
+// @Description	Create a user, the users file is created if it does not exist
+// @Tags			users
+// @Accept			json
+// @Produce		json
+// @Param			request	body		CreateUserRequest	true	"Request"
+// @Success		200		{object}	apitypes.SuccessResponse
+// @Failure		400		{object}	apitypes.ErrorResponse
+// @Failure		403		{object}	apitypes.ErrorResponse
+// @Failure		404		{object}	apitypes.ErrorResponse
+// @Failure		409		{object}	apitypes.ErrorResponse
+// @Failure		500		{object}	apitypes.ErrorResponse
+// @Router			/users/create [post]
+func Create(c *gin.Context) {
+	var request CreateUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
+		return
+	}
+
+	store := auth.GetUserStore()
+	if store == nil {
+		c.JSON(http.StatusNotFound, apitypes.Error("authentication is disabled"))
+		return
+	}
+
+	err := store.Add(request.Username, request.Password, request.Role)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, apitypes.Success("user created"))
+	case errors.Is(err, auth.ErrUserAlreadyExists):
+		c.JSON(http.StatusConflict, apitypes.Error("user already exists"))
+	default:
+		c.Error(apitypes.InternalServerError(err, "failed to create user"))
+	}
+}
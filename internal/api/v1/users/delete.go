@@ -0,0 +1,54 @@
+package usersapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusing/godoxy/internal/auth"
+	apitypes "github.com/yusing/goutils/apitypes"
+)
+
+type DeleteUserRequest struct {
+	Username string `json:"username" binding:"required"`
+} //	@name	DeleteUserRequest
+
+// @x-id				"delete"
+// @BasePath		/api/v1
+// @Summary		Delete a user
+// @Description	Delete a user, the last admin cannot be deleted
+// @Tags			users
+// @Accept			json
+// @Produce		json
+// @Param			request	body		DeleteUserRequest	true	"Request"
+// @Success		200		{object}	apitypes.SuccessResponse
+// @Failure		400		{object}	apitypes.ErrorResponse
+// @Failure		403		{object}	apitypes.ErrorResponse
+// @Failure		404		{object}	apitypes.ErrorResponse
+// @Failure		500		{object}	apitypes.ErrorResponse
+// @Router			/users/delete [post]
+func Delete(c *gin.Context) {
+	var request DeleteUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
+		return
+	}
+
+	store := auth.GetUserStore()
+	if store == nil {
+		c.JSON(http.StatusNotFound, apitypes.Error("authentication is disabled"))
+		return
+	}
+
+	err := store.Delete(request.Username)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, apitypes.Success("user deleted"))
+	case errors.Is(err, auth.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, apitypes.Error("user not found"))
+	case errors.Is(err, auth.ErrLastAdmin):
+		c.JSON(http.StatusBadRequest, apitypes.Error("cannot delete the last admin"))
+	default:
+		c.Error(apitypes.InternalServerError(err, "failed to delete user"))
+	}
+}
@@ -0,0 +1,28 @@
+package usersapi
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusing/godoxy/internal/auth"
+	apitypes "github.com/yusing/goutils/apitypes"
+)
+
+// @x-id				"list"
+// @BasePath		/api/v1
+// @Summary		List users
+// @Description	List users and their roles
+// @Tags			users
+// @Produce		json
+// @Success		200	{array}		auth.UserInfo
+// @Failure		403	{object}	apitypes.ErrorResponse
+// @Failure		404	{object}	apitypes.ErrorResponse
+// @Router			/users/list [get]
+func List(c *gin.Context) {
+	store := auth.GetUserStore()
+	if store == nil {
+		c.JSON(http.StatusNotFound, apitypes.Error("authentication is disabled"))
+		return
+	}
+	c.JSON(http.StatusOK, store.List())
+}
@@ -0,0 +1,28 @@
+package usersapi
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusing/godoxy/internal/auth"
+	apitypes "github.com/yusing/goutils/apitypes"
+)
+
+// @x-id				"me"
+// @BasePath		/api/v1
+// @Summary		Get current user
+// @Description	Get the username, groups and role of the current user
+// @Tags			users
+// @Produce		json
+// @Success		200	{object}	auth.UserInfo
+// @Failure		403	{object}	apitypes.ErrorResponse
+// @Failure		404	{object}	apitypes.ErrorResponse
+// @Router			/users/me [get]
+func Me(c *gin.Context) {
+	user := auth.UserFromContext(c.Request.Context())
+	if user == nil {
+		c.JSON(http.StatusNotFound, apitypes.Error("authentication is disabled"))
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,67 @@
+package usersapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusing/godoxy/internal/auth"
+	apitypes "github.com/yusing/goutils/apitypes"
+)
+
+// currentUser returns the user store and the username of the current user,
+// second factors are only available to users in the store, i.e. not to OIDC users.
+func currentUser(c *gin.Context) (*auth.UserStore, string, bool) {
+	store := auth.GetUserStore()
+	user := auth.UserFromContext(c.Request.Context())
+	if store == nil || user == nil {
+		c.JSON(http.StatusNotFound, apitypes.Error("authentication is disabled"))
+		return nil, "", false
+	}
+	if _, ok := store.Get(user.Username); !ok {
+		c.JSON(http.StatusNotFound, apitypes.Error("second factors are only available for password login"))
+		return nil, "", false
+	}
+	return store, user.Username, true
+}
+
+// handleMFAError responds with the status code matching err.
+func handleMFAError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidPassword),
+		errors.Is(err, auth.ErrInvalidMFACode),
+		errors.Is(err, auth.ErrMFANotEnabled),
+		errors.Is(err, auth.ErrTOTPAlreadyEnabled),
+		errors.Is(err, auth.ErrNoPendingEnrollment),
+		errors.Is(err, auth.ErrInvalidWebAuthnResponse),
+		errors.Is(err, auth.ErrWebAuthnChallenge):
+		c.JSON(http.StatusBadRequest, apitypes.Error("failed to "+action, err))
+	case errors.Is(err, auth.ErrUserNotFound), errors.Is(err, auth.ErrUnknownCredential):
+		c.JSON(http.StatusNotFound, apitypes.Error("failed to "+action, err))
+	default:
+		c.Error(apitypes.InternalServerError(err, "failed to "+action))
+	}
+}
+
+// @x-id				"mfaStatus"
+// @BasePath		/api/v1
+// @Summary		Get second factors
+// @Description	Get the second factors enrolled by the current user
+// @Tags			users
+// @Produce		json
+// @Success		200	{object}	auth.MFAStatus
+// @Failure		403	{object}	apitypes.ErrorResponse
+// @Failure		404	{object}	apitypes.ErrorResponse
+// @Router			/users/mfa [get]
+func MFAStatus(c *gin.Context) {
+	store, username, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	status, err := store.MFAStatus(username)
+	if err != nil {
+		handleMFAError(c, err, "get second factors")
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
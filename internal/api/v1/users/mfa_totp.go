@@ -0,0 +1,141 @@
+package usersapi
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	apitypes "github.com/yusing/goutils/apitypes"
+)
+
+type TOTPEnrollResponse struct {
+	Secret string `json:"secret"` // base32 secret for manual entry
+	URI    string `json:"uri"`    // otpauth:// URI for QR codes
+} //	@name	TOTPEnrollResponse
+
+type TOTPConfirmRequest struct {
+	Code string `json:"code" binding:"required"`
+} //	@name	TOTPConfirmRequest
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes,omitempty"` // shown only once, empty if unchanged
+} //	@name	RecoveryCodesResponse
+
+type PasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+} //	@name	PasswordRequest
+
+// @x-id				"totpEnroll"
+// @BasePath		/api/v1
+// @Summary		Begin TOTP enrolment
+// @Description	Generate a TOTP secret for the current user, enabled once confirmed
+// @Tags			users
+// @Produce		json
+// @Success		200	{object}	TOTPEnrollResponse
+// @Failure		400	{object}	apitypes.ErrorResponse
+// @Failure		403	{object}	apitypes.ErrorResponse
+// @Failure		404	{object}	apitypes.ErrorResponse
+// @Router			/users/mfa/totp/enroll [post]
+func TOTPEnroll(c *gin.Context) {
+	store, username, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	secret, uri, err := store.BeginTOTPEnrollment(username)
+	if err != nil {
+		handleMFAError(c, err, "enroll totp")
+		return
+	}
+	c.JSON(http.StatusOK, TOTPEnrollResponse{Secret: secret, URI: uri})
+}
+
+// @x-id				"totpConfirm"
+// @BasePath		/api/v1
+// @Summary		Confirm TOTP enrolment
+// @Description	Enable TOTP with a code from the authenticator app, recovery codes are returned on first enrolment
+// @Tags			users
+// @Accept			json
+// @Produce		json
+// @Param			request	body		TOTPConfirmRequest	true	"Request"
+// @Success		200		{object}	RecoveryCodesResponse
+// @Failure		400		{object}	apitypes.ErrorResponse
+// @Failure		403		{object}	apitypes.ErrorResponse
+// @Failure		404		{object}	apitypes.ErrorResponse
+// @Router			/users/mfa/totp/confirm [post]
+func TOTPConfirm(c *gin.Context) {
+	var request TOTPConfirmRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
+		return
+	}
+	store, username, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	codes, err := store.ConfirmTOTPEnrollment(username, request.Code)
+	if err != nil {
+		handleMFAError(c, err, "confirm totp")
+		return
+	}
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @x-id				"totpDisable"
+// @BasePath		/api/v1
+// @Summary		Disable TOTP
+// @Description	Disable TOTP of the current user
+// @Tags			users
+// @Accept			json
+// @Produce		json
+// @Param			request	body		PasswordRequest	true	"Request"
+// @Success		200		{object}	apitypes.SuccessResponse
+// @Failure		400		{object}	apitypes.ErrorResponse
+// @Failure		403		{object}	apitypes.ErrorResponse
+// @Failure		404		{object}	apitypes.ErrorResponse
+// @Router			/users/mfa/totp/disable [post]
+func TOTPDisable(c *gin.Context) {
+	var request PasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
+		return
+	}
+	store, username, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if err := store.DisableTOTP(username, request.Password); err != nil {
+		handleMFAError(c, err, "disable totp")
+		return
+	}
+	c.JSON(http.StatusOK, apitypes.Success("totp disabled"))
+}
+
+// @x-id				"regenerateRecoveryCodes"
+// @BasePath		/api/v1
+// @Summary		Regenerate recovery codes
+// @Description	Replace the recovery codes of the current user
+// @Tags			users
+// @Accept			json
+// @Produce		json
+// @Param			request	body		PasswordRequest	true	"Request"
+// @Success		200		{object}	RecoveryCodesResponse
+// @Failure		400		{object}	apitypes.ErrorResponse
+// @Failure		403		{object}	apitypes.ErrorResponse
+// @Failure		404		{object}	apitypes.ErrorResponse
+// @Router			/users/mfa/recovery_codes [post]
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var request PasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
+		return
+	}
+	store, username, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	codes, err := store.RegenerateRecoveryCodes(username, request.Password)
+	if err != nil {
+		handleMFAError(c, err, "regenerate recovery codes")
+		return
+	}
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
@@ -0,0 +1,108 @@
+package usersapi
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusing/godoxy/internal/auth"
+	apitypes "github.com/yusing/goutils/apitypes"
+)
+
+type WebAuthnRegisterRequest struct {
+	Name       string                    `json:"name" binding:"required"`
+	Credential auth.WebAuthnRegistration `json:"credential" binding:"required"`
+} //	@name	WebAuthnRegisterRequest
+
+type WebAuthnRegisterResponse struct {
+	Credential    *auth.WebAuthnCredentialInfo `json:"credential"`
+	RecoveryCodes []string                     `json:"recovery_codes,omitempty"` // shown only once, on first enrolment
+} //	@name	WebAuthnRegisterResponse
+
+type WebAuthnDeleteRequest struct {
+	ID       string `json:"id" binding:"required"`
+	Password string `json:"password" binding:"required"`
+} //	@name	WebAuthnDeleteRequest
+
+// @x-id				"webauthnRegisterBegin"
+// @BasePath		/api/v1
+// @Summary		Begin passkey registration
+// @Description	Returns the options for navigator.credentials.create()
+// @Tags			users
+// @Produce		json
+// @Success		200	{object}	auth.WebAuthnCreationOptions
+// @Failure		403	{object}	apitypes.ErrorResponse
+// @Failure		404	{object}	apitypes.ErrorResponse
+// @Router			/users/mfa/webauthn/register/begin [post]
+func WebAuthnRegisterBegin(c *gin.Context) {
+	store, username, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	options, err := store.BeginWebAuthnRegistration(c.Request, username)
+	if err != nil {
+		handleMFAError(c, err, "begin passkey registration")
+		return
+	}
+	c.JSON(http.StatusOK, options)
+}
+
+// @x-id				"webauthnRegisterFinish"
+// @BasePath		/api/v1
+// @Summary		Finish passkey registration
+// @Description	Register the result of navigator.credentials.create(), recovery codes are returned on first enrolment
+// @Tags			users
+// @Accept			json
+// @Produce		json
+// @Param			request	body		WebAuthnRegisterRequest	true	"Request"
+// @Success		200		{object}	WebAuthnRegisterResponse
+// @Failure		400		{object}	apitypes.ErrorResponse
+// @Failure		403		{object}	apitypes.ErrorResponse
+// @Failure		404		{object}	apitypes.ErrorResponse
+// @Router			/users/mfa/webauthn/register/finish [post]
+func WebAuthnRegisterFinish(c *gin.Context) {
+	var request WebAuthnRegisterRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
+		return
+	}
+	store, username, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	info, codes, err := store.FinishWebAuthnRegistration(username, request.Name, &request.Credential)
+	if err != nil {
+		handleMFAError(c, err, "register passkey")
+		return
+	}
+	c.JSON(http.StatusOK, WebAuthnRegisterResponse{Credential: info, RecoveryCodes: codes})
+}
+
+// @x-id				"webauthnDelete"
+// @BasePath		/api/v1
+// @Summary		Delete a passkey
+// @Description	Delete a passkey of the current user
+// @Tags			users
+// @Accept			json
+// @Produce		json
+// @Param			request	body		WebAuthnDeleteRequest	true	"Request"
+// @Success		200		{object}	apitypes.SuccessResponse
+// @Failure		400		{object}	apitypes.ErrorResponse
+// @Failure		403		{object}	apitypes.ErrorResponse
+// @Failure		404		{object}	apitypes.ErrorResponse
+// @Router			/users/mfa/webauthn/delete [post]
+func WebAuthnDelete(c *gin.Context) {
+	var request WebAuthnDeleteRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
+		return
+	}
+	store, username, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if err := store.DeleteWebAuthnCredential(username, request.Password, request.ID); err != nil {
+		handleMFAError(c, err, "delete passkey")
+		return
+	}
+	c.JSON(http.StatusOK, apitypes.Success("passkey deleted"))
+}
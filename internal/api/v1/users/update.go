@@ -0,0 +1,60 @@
+package usersapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusing/godoxy/internal/auth"
+	apitypes "github.com/yusing/goutils/apitypes"
+)
+
+type UpdateUserRequest struct {
+	Username string    `json:"username" binding:"required"`
+	Password string    `json:"password" binding:"omitempty,min=8"`
+	Role     auth.Role `json:"role" binding:"omitempty,oneof=viewer operator admin"`
+	ResetMFA bool      `json:"reset_mfa"` // remove all second factors, for users who lost them
+} //	@name	UpdateUserRequest
+
+// @x-id				"update"
+// @BasePath		/api/v1
+// @Summary		Update a user
+// @Description	Update the password and/or role of a user, or reset their second factors, empty fields are left unchanged
+// @Tags			users
+// @Accept			json
+// @Produce		json
+// @Param			request	body		UpdateUserRequest	true	"Request"
+// @Success		200		{object}	apitypes.SuccessResponse
+// @Failure		400		{object}	apitypes.ErrorResponse
+// @Failure		403		{object}	apitypes.ErrorResponse
+// @Failure		404		{object}	apitypes.ErrorResponse
+// @Failure		500		{object}	apitypes.ErrorResponse
+// @Router			/users/update [post]
+func Update(c *gin.Context) {
+	var request UpdateUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, apitypes.Error("invalid request", err))
+		return
+	}
+
+	store := auth.GetUserStore()
+	if store == nil {
+		c.JSON(http.StatusNotFound, apitypes.Error("authentication is disabled"))
+		return
+	}
+
+	err := store.Update(request.Username, request.Password, request.Role)
+	if err == nil && request.ResetMFA {
+		err = store.ResetMFA(request.Username)
+	}
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, apitypes.Success("user updated"))
+	case errors.Is(err, auth.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, apitypes.Error("user not found"))
+	case errors.Is(err, auth.ErrLastAdmin):
+		c.JSON(http.StatusBadRequest, apitypes.Error("cannot remove the last admin"))
+	default:
+		c.Error(apitypes.InternalServerError(err, "failed to update user"))
+	}
+}
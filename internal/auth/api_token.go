@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+	"github.com/yusing/godoxy/internal/jsonstore"
+	gperr "github.com/yusing/goutils/errs"
+	strutils "github.com/yusing/goutils/strings"
+)
+
+type (
+	// TokenScope is a permission granted to an API token.
+	TokenScope string
+
+	// APIToken is a long lived token for automation, only the hash of the secret is stored.
+	APIToken struct {
+		ID         string       `json:"id"`
+		Name       string       `json:"name"`
+		Username   string       `json:"username"`
+		Role       Role         `json:"role"`   // role of the creator when the token was created
+		Origin     UserOrigin   `json:"origin"` // auth backend of the creator, local if empty
+		Hash       string       `json:"hash"`   // sha256 of the secret
+		Scopes     []TokenScope `json:"scopes"`
+		CreatedAt  time.Time    `json:"created_at"`
+		ExpiresAt  time.Time    `json:"expires_at,omitzero"`   // zero means never
+		LastUsedAt time.Time    `json:"last_used_at,omitzero"` // zero means never used
+	}
+
+	// APITokenInfo is an API token without its hash.
+	APITokenInfo struct {
+		ID         string       `json:"id"`
+		Name       string       `json:"name"`
+		Username   string       `json:"username"`
+		Scopes     []TokenScope `json:"scopes"`
+		CreatedAt  time.Time    `json:"created_at"`
+		ExpiresAt  time.Time    `json:"expires_at,omitzero"`
+		LastUsedAt time.Time    `json:"last_used_at,omitzero"`
+	} //	@name	APITokenInfo
+)
+
+const (
+	ScopeRoutesRead    TokenScope = "routes:read"    // list and inspect routes
+	ScopeDockerControl TokenScope = "docker:control" // list, start, stop and restart containers
+	ScopeFileWrite     TokenScope = "file:write"     // read, write and validate config files
+	ScopeCertRenew     TokenScope = "cert:renew"     // inspect and renew certificates
+	ScopeReload        TokenScope = "reload"         // reload the config
+)
+
+// AllTokenScopes is the list of all valid token scopes.
+var AllTokenScopes = []TokenScope{ScopeRoutesRead, ScopeDockerControl, ScopeFileWrite, ScopeCertRenew, ScopeReload}
+
+const (
+	apiTokenPrefix    = "gdx_"
+	apiTokenIDLen     = 8  // bytes
+	apiTokenSecretLen = 32 // bytes
+)
+
+var (
+	ErrInvalidAPIToken = gperr.New("invalid api token")
+	ErrAPITokenExpired = gperr.New("api token expired")
+	ErrInvalidScope    = gperr.New("invalid token scope")
+)
+
+var apiTokens = jsonstore.Store[*APIToken]("api_tokens")
+
+// IsValid returns whether the scope is one of AllTokenScopes.
+func (s TokenScope) IsValid() bool {
+	return slices.Contains(AllTokenScopes, s)
+}
+
+// HasScope returns whether the user is allowed to access endpoints that require the scope.
+//
+// Users authenticated by session are not restricted by scopes,
+// while API token users must have the scope granted.
+func (u *UserInfo) HasScope(scope TokenScope) bool {
+	if !u.IsAPIToken() {
+		return true
+	}
+	return scope != "" && slices.Contains(u.Scopes, scope)
+}
+
+// IsAPIToken returns whether the user is authenticated by an API token.
+func (u *UserInfo) IsAPIToken() bool {
+	return u.TokenID != ""
+}
+
+// BearerToken returns the token from the "Authorization: Bearer" header.
+func BearerToken(r *http.Request) (token string, ok bool) {
+	token, ok = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// CreateAPIToken creates an API token for the user and returns the plain text token.
+//
+// The plain text token is not stored and cannot be retrieved later.
+func CreateAPIToken(user *UserInfo, name string, scopes []TokenScope, expiresAt time.Time) (string, *APITokenInfo, error) {
+	if len(scopes) == 0 {
+		return "", nil, ErrInvalidScope.Subject("no scopes")
+	}
+	for _, scope := range scopes {
+		if !scope.IsValid() {
+			return "", nil, ErrInvalidScope.Subject(string(scope))
+		}
+	}
+	if !expiresAt.IsZero() && expiresAt.Before(time.Now()) {
+		return "", nil, ErrAPITokenExpired.Subject(name)
+	}
+
+	id := randomHex(apiTokenIDLen)
+	secret := randomHex(apiTokenSecretLen)
+	token := &APIToken{
+		ID:        id,
+		Name:      name,
+		Username:  user.Username,
+		Role:      user.Role,
+		Origin:    user.Origin,
+		Hash:      hashAPITokenSecret(secret),
+		Scopes:    slices.Clone(scopes),
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+	apiTokens.Store(id, token)
+	return apiTokenPrefix + id + "_" + secret, token.Info(), nil
+}
+
+// ListAPITokens returns the API tokens of the user, or all tokens if username is empty.
+func ListAPITokens(username string) []*APITokenInfo {
+	tokens := make([]*APITokenInfo, 0, apiTokens.Size())
+	for _, token := range apiTokens.Range {
+		if username == "" || token.Username == username {
+			tokens = append(tokens, token.Info())
+		}
+	}
+	slices.SortFunc(tokens, func(a, b *APITokenInfo) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return tokens
+}
+
+// GetAPIToken returns the API token with the given id.
+func GetAPIToken(id string) (*APITokenInfo, bool) {
+	token, ok := apiTokens.Load(id)
+	if !ok {
+		return nil, false
+	}
+	return token.Info(), true
+}
+
+// RevokeAPIToken deletes the API token with the given id.
+func RevokeAPIToken(id string) bool {
+	_, ok := apiTokens.LoadAndDelete(id)
+	return ok
+}
+
+// revokeLocalAPITokens deletes the API tokens of the local user,
+// tokens of OIDC and LDAP users with the same name are kept.
+func revokeLocalAPITokens(username string) {
+	for id, token := range apiTokens.Range {
+		if token.Username == username && token.Origin != UserOriginOIDC && token.Origin != UserOriginLDAP {
+			apiTokens.Delete(id)
+		}
+	}
+}
+
+// CheckAPIToken validates the plain text token and returns the user it acts as.
+//
+// The token acts with the lower of the creator's role at creation and the current role.
+// Tokens of local users are rejected once the creator has been removed from the user store,
+// tokens of OIDC and LDAP users once the backend is disabled.
+func CheckAPIToken(plain string) (*UserInfo, error) {
+	rest, ok := strings.CutPrefix(plain, apiTokenPrefix)
+	if !ok {
+		return nil, ErrInvalidAPIToken
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok {
+		return nil, ErrInvalidAPIToken
+	}
+	token, ok := apiTokens.Load(id)
+	if !ok {
+		return nil, ErrInvalidAPIToken
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPITokenSecret(secret)), []byte(token.Hash)) != 1 {
+		return nil, ErrInvalidAPIToken
+	}
+	if !token.ExpiresAt.IsZero() && time.Now().After(token.ExpiresAt) {
+		return nil, ErrAPITokenExpired.Subjectf("expired on %s", strutils.FormatTime(token.ExpiresAt))
+	}
+
+	role := token.Role
+	switch token.Origin {
+	case UserOriginOIDC, UserOriginLDAP:
+		// OIDC and LDAP users are not in the user store
+		if !token.Origin.IsEnabled() {
+			return nil, ErrUserNotAllowed.Subject(token.Username)
+		}
+	default:
+		if userStore == nil {
+			return nil, ErrUserNotAllowed.Subject(token.Username)
+		}
+		user, ok := userStore.Get(token.Username)
+		if !ok {
+			return nil, ErrUserNotAllowed.Subject(token.Username)
+		}
+		if !user.Role.Allows(role) {
+			role = user.Role
+		}
+	}
+
+	// tokens are never mutated in place, store a copy to avoid races with readers,
+	// and skip the update if the token was revoked meanwhile
+	apiTokens.Compute(id, func(old *APIToken, loaded bool) (*APIToken, xsync.ComputeOp) {
+		if !loaded {
+			return old, xsync.CancelOp
+		}
+		used := *old
+		used.LastUsedAt = time.Now()
+		return &used, xsync.UpdateOp
+	})
+
+	return &UserInfo{
+		Username: token.Username,
+		Role:     role,
+		Origin:   token.Origin,
+		Scopes:   slices.Clone(token.Scopes),
+		TokenID:  token.ID,
+	}, nil
+}
+
+// Info returns the token without its hash.
+func (token *APIToken) Info() *APITokenInfo {
+	return &APITokenInfo{
+		ID:         token.ID,
+		Name:       token.Name,
+		Username:   token.Username,
+		Scopes:     slices.Clone(token.Scopes),
+		CreatedAt:  token.CreatedAt,
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+	}
+}
+
+func hashAPITokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yusing/godoxy/internal/common"
+	expect "github.com/yusing/goutils/testing"
+)
+
+func TestAPIToken(t *testing.T) {
+	store, err := LoadUserStore(filepath.Join(t.TempDir(), "users.yml"))
+	expect.NoError(t, err)
+	expect.NoError(t, store.Add("ci", "password", RoleOperator))
+	userStore = store
+	t.Cleanup(func() {
+		userStore = nil
+		apiTokens.Clear()
+	})
+
+	creator := &UserInfo{Username: "ci", Role: RoleOperator}
+	token, info, err := CreateAPIToken(creator, "pipeline", []TokenScope{ScopeReload, ScopeDockerControl}, time.Time{})
+	expect.NoError(t, err)
+	expect.Equal(t, info.Username, "ci")
+	expect.True(t, info.LastUsedAt.IsZero())
+
+	stored, ok := apiTokens.Load(info.ID)
+	expect.True(t, ok)
+	expect.False(t, stored.Hash == token) // only the hash is stored
+
+	user, err := CheckAPIToken(token)
+	expect.NoError(t, err)
+	expect.Equal(t, user.Username, "ci")
+	expect.Equal(t, user.Role, RoleOperator)
+	expect.True(t, user.IsAPIToken())
+	expect.True(t, user.HasScope(ScopeReload))
+	expect.False(t, user.HasScope(ScopeFileWrite))
+
+	info, ok = GetAPIToken(info.ID)
+	expect.True(t, ok)
+	expect.False(t, info.LastUsedAt.IsZero())
+
+	_, err = CheckAPIToken(token[:len(token)-1] + "x")
+	expect.ErrorIs(t, ErrInvalidAPIToken, err)
+	_, err = CheckAPIToken("not a token")
+	expect.ErrorIs(t, ErrInvalidAPIToken, err)
+
+	// role is capped by the current role of the creator
+	expect.NoError(t, store.Update("ci", "", RoleViewer))
+	user, err = CheckAPIToken(token)
+	expect.NoError(t, err)
+	expect.Equal(t, user.Role, RoleViewer)
+
+	expect.True(t, RevokeAPIToken(info.ID))
+	_, err = CheckAPIToken(token)
+	expect.ErrorIs(t, ErrInvalidAPIToken, err)
+}
+
+func TestAPITokenDeletedUser(t *testing.T) {
+	store, err := LoadUserStore(filepath.Join(t.TempDir(), "users.yml"))
+	expect.NoError(t, err)
+	expect.NoError(t, store.Add("ci", "password", RoleOperator))
+	userStore = store
+	t.Cleanup(func() {
+		userStore = nil
+		apiTokens.Clear()
+	})
+
+	token, info, err := CreateAPIToken(&UserInfo{Username: "ci", Role: RoleOperator, Origin: UserOriginLocal}, "pipeline", []TokenScope{ScopeReload}, time.Time{})
+	expect.NoError(t, err)
+	_, err = CheckAPIToken(token)
+	expect.NoError(t, err)
+
+	// tokens are revoked with the user
+	expect.NoError(t, store.Delete("ci"))
+	_, ok := GetAPIToken(info.ID)
+	expect.False(t, ok)
+	_, err = CheckAPIToken(token)
+	expect.ErrorIs(t, ErrInvalidAPIToken, err)
+
+	// and not restored by adding a user with the same name
+	expect.NoError(t, store.Add("ci", "password", RoleOperator))
+	_, err = CheckAPIToken(token)
+	expect.ErrorIs(t, ErrInvalidAPIToken, err)
+}
+
+func TestAPITokenOrigin(t *testing.T) {
+	store, err := LoadUserStore(filepath.Join(t.TempDir(), "users.yml"))
+	expect.NoError(t, err)
+	userStore = store
+	common.OIDCIssuerURL = "https://auth.example.com"
+	t.Cleanup(func() {
+		userStore = nil
+		common.OIDCIssuerURL = ""
+		apiTokens.Clear()
+	})
+
+	// local users missing from the user store are rejected even with OIDC enabled
+	localToken, _, err := CreateAPIToken(&UserInfo{Username: "ghost", Role: RoleAdmin, Origin: UserOriginLocal}, "local", []TokenScope{ScopeReload}, time.Time{})
+	expect.NoError(t, err)
+	_, err = CheckAPIToken(localToken)
+	expect.ErrorIs(t, ErrUserNotAllowed, err)
+
+	oidcToken, _, err := CreateAPIToken(&UserInfo{Username: "alice", Role: RoleOperator, Origin: UserOriginOIDC}, "oidc", []TokenScope{ScopeReload}, time.Time{})
+	expect.NoError(t, err)
+	user, err := CheckAPIToken(oidcToken)
+	expect.NoError(t, err)
+	expect.Equal(t, user.Role, RoleOperator)
+	expect.Equal(t, user.Origin, UserOriginOIDC)
+
+	// rejected once OIDC is disabled
+	common.OIDCIssuerURL = ""
+	_, err = CheckAPIToken(oidcToken)
+	expect.ErrorIs(t, ErrUserNotAllowed, err)
+}
+
+func TestAPITokenExpired(t *testing.T) {
+	t.Cleanup(apiTokens.Clear)
+
+	creator := &UserInfo{Username: "ci", Role: RoleAdmin}
+	_, _, err := CreateAPIToken(creator, "expired", []TokenScope{ScopeReload}, time.Now().Add(-time.Hour))
+	expect.ErrorIs(t, ErrAPITokenExpired, err)
+
+	token, info, err := CreateAPIToken(creator, "short", []TokenScope{ScopeReload}, time.Now().Add(time.Hour))
+	expect.NoError(t, err)
+	stored, _ := apiTokens.Load(info.ID)
+	stored.ExpiresAt = time.Now().Add(-time.Second)
+
+	_, err = CheckAPIToken(token)
+	expect.ErrorIs(t, ErrAPITokenExpired, err)
+}
+
+func TestAPITokenInvalidScope(t *testing.T) {
+	creator := &UserInfo{Username: "ci", Role: RoleAdmin}
+	_, _, err := CreateAPIToken(creator, "none", nil, time.Time{})
+	expect.ErrorIs(t, ErrInvalidScope, err)
+	_, _, err = CreateAPIToken(creator, "invalid", []TokenScope{"everything"}, time.Time{})
+	expect.ErrorIs(t, ErrInvalidScope, err)
+}
+
+func TestBearerToken(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	_, ok := BearerToken(r)
+	expect.False(t, ok)
+
+	r.Header.Set("Authorization", "Bearer gdx_abc_def")
+	token, ok := BearerToken(r)
+	expect.True(t, ok)
+	expect.Equal(t, token, "gdx_abc_def")
+
+	r.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	_, ok = BearerToken(r)
+	expect.False(t, ok)
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/yusing/godoxy/internal/common"
@@ -14,12 +15,20 @@ func Initialize() error {
 		return nil
 	}
 
-	var err error
-	// Initialize OIDC if configured.
-	if common.OIDCIssuerURL != "" {
+	users, err := LoadUserStore(common.UsersFilePath)
+	if err != nil {
+		return err
+	}
+	userStore = users
+
+	// Initialize OIDC or LDAP if configured.
+	switch {
+	case common.OIDCIssuerURL != "":
 		defaultAuth, err = NewOIDCProviderFromEnv()
-	} else {
-		defaultAuth, err = NewUserPassAuthFromEnv()
+	case IsLDAPEnabled():
+		defaultAuth, err = NewLDAPAuthFromEnv()
+	default:
+		defaultAuth = NewUserPassAuthFromEnv(users)
 	}
 
 	return err
@@ -37,9 +46,26 @@ func IsOIDCEnabled() bool {
 	return common.OIDCIssuerURL != ""
 }
 
-type nextHandler struct{}
+type (
+	nextHandler struct{}
+	userInfoKey struct{}
+)
+
+var (
+	nextHandlerContextKey = nextHandler{}
+	userInfoContextKey    = userInfoKey{}
+)
 
-var nextHandlerContextKey = nextHandler{}
+// WithUser returns a copy of ctx with the authenticated user.
+func WithUser(ctx context.Context, user *UserInfo) context.Context {
+	return context.WithValue(ctx, userInfoContextKey, user)
+}
+
+// UserFromContext returns the authenticated user stored by WithUser, or nil if none.
+func UserFromContext(ctx context.Context) *UserInfo {
+	user, _ := ctx.Value(userInfoContextKey).(*UserInfo)
+	return user
+}
 
 func ProceedNext(w http.ResponseWriter, r *http.Request) {
 	next, ok := r.Context().Value(nextHandlerContextKey).(http.HandlerFunc)
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+type claimsContextKey struct{}
+
+// WithClaims stores the verified claims in the request context.
+func WithClaims(r *http.Request, claims map[string]any) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), claimsContextKey{}, claims))
+}
+
+// ClaimsOf returns the claims stored by WithClaims, or nil if none.
+func ClaimsOf(r *http.Request) map[string]any {
+	claims, _ := r.Context().Value(claimsContextKey{}).(map[string]any)
+	return claims
+}
+
+// ClaimOf returns the formatted claim of the request,
+// nested claims can be accessed with dots, e.g. "realm_access.roles".
+func ClaimOf(r *http.Request, name string) (string, bool) {
+	claims := ClaimsOf(r)
+	if claims == nil {
+		return "", false
+	}
+	if v, ok := claims[name]; ok {
+		return FormatClaim(v), true
+	}
+	var cur any = claims
+	for part := range strings.SplitSeq(name, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if cur, ok = m[part]; !ok {
+			return "", false
+		}
+	}
+	return FormatClaim(cur), true
+}
+
+// FormatClaim formats a JWT claim as a header or variable value,
+// arrays are joined by "," and objects are JSON encoded.
+func FormatClaim(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case []any:
+		values := make([]string, len(v))
+		for i, elem := range v {
+			values[i] = FormatClaim(elem)
+		}
+		return strings.Join(values, ",")
+	default:
+		b, err := sonic.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/yusing/godoxy/internal/common"
+)
+
+// headers set on authenticated forward auth responses
+const (
+	HeaderRemoteUser   = "Remote-User"
+	HeaderRemoteGroups = "Remote-Groups"
+)
+
+// domains the login page may be on, other than the GoDoxy domain
+var forwardAuthDomains atomic.Pointer[[]string]
+
+// SetForwardAuthDomains sets the route domains (match_domains) the login page
+// passed in the `rd` query of the forward auth endpoint may be on.
+func SetForwardAuthDomains(domains []string) {
+	normalized := make([]string, len(domains))
+	for i, domain := range domains {
+		normalized[i] = "." + strings.TrimPrefix(domain, ".")
+	}
+	forwardAuthDomains.Store(&normalized)
+}
+
+// ForwardAuthHandler lets other reverse proxies delegate authentication to GoDoxy,
+// compatible with nginx `auth_request` and Traefik `forwardAuth`.
+//
+// The original request carries the session cookies, which are scoped to the parent domain,
+// so a login on the WebUI is honoured by every subdomain.
+//
+// Authenticated requests get 200 with Remote-User and Remote-Groups.
+// Otherwise Traefik gets a redirect to the login page, and nginx gets 401
+// with the login page in Location, since auth_request cannot pass redirects through:
+//
+//	auth_request_set $login $upstream_http_location;
+//	error_page 401 =302 $login;
+func ForwardAuthHandler(w http.ResponseWriter, r *http.Request) {
+	if defaultAuth == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	user, err := defaultAuth.CurrentUser(r)
+	if err == nil {
+		w.Header().Set(HeaderRemoteUser, user.Username)
+		w.Header().Set(HeaderRemoteGroups, strings.Join(user.Groups, ","))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	loginURL := forwardAuthLoginURL(r)
+	// nginx sets X-Original-URL, Traefik sets X-Forwarded-Uri
+	if loginURL == "" || r.Header.Get("X-Original-URL") != "" {
+		if loginURL != "" {
+			w.Header().Set("Location", loginURL)
+		}
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	http.Redirect(w, r, loginURL, http.StatusFound)
+}
+
+// forwardAuthLoginURL returns the login page with the original URL to return to after login,
+// or an empty string if no login page is configured.
+//
+// The login page in the `rd` query is ignored unless it is a relative path,
+// or on the GoDoxy domain, the cookie domain or a route domain, to prevent open redirects.
+func forwardAuthLoginURL(r *http.Request) string {
+	var loginURL *url.URL
+	if rd := r.URL.Query().Get("rd"); rd != "" {
+		if u, err := url.Parse(rd); err == nil && isAllowedLoginURL(r, rd, u) {
+			loginURL = u
+		}
+	}
+	if loginURL == nil {
+		if common.APIForwardAuthLoginURL == "" {
+			return ""
+		}
+		u, err := url.Parse(common.APIForwardAuthLoginURL)
+		if err != nil {
+			return ""
+		}
+		loginURL = u
+	}
+	if original := forwardAuthOriginalURL(r); original != "" {
+		q := loginURL.Query()
+		q.Set("redirect", original)
+		loginURL.RawQuery = q.Encode()
+	}
+	return loginURL.String()
+}
+
+func isAllowedLoginURL(r *http.Request, raw string, u *url.URL) bool {
+	// browsers treat backslashes as slashes, e.g. "/\evil.com"
+	if strings.ContainsRune(raw, '\\') {
+		return false
+	}
+	if u.Scheme == "" && u.Host == "" {
+		return strings.HasPrefix(u.Path, "/")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		return false
+	}
+	reqHost := requestHost(r)
+	if h, _, err := net.SplitHostPort(reqHost); err == nil {
+		reqHost = h
+	}
+	if host == reqHost || isSubdomainOf(host, cookieDomain(r)) {
+		return true
+	}
+	if defaultLogin, err := url.Parse(common.APIForwardAuthLoginURL); err == nil && defaultLogin.Hostname() == host {
+		return true
+	}
+	if domains := forwardAuthDomains.Load(); domains != nil {
+		for _, domain := range *domains {
+			if isSubdomainOf(host, domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isSubdomainOf reports whether host is the domain, or under it. domain starts with a dot.
+func isSubdomainOf(host, domain string) bool {
+	if domain == "" {
+		return false
+	}
+	return host == domain[1:] || strings.HasSuffix(host, domain)
+}
+
+// forwardAuthOriginalURL returns the URL requested by the client before it reached the other proxy.
+func forwardAuthOriginalURL(r *http.Request) string {
+	if original := r.Header.Get("X-Original-URL"); original != "" {
+		u, err := url.Parse(original)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ""
+		}
+		return u.String()
+	}
+
+	host := r.Header.Get("X-Forwarded-Host")
+	if host == "" {
+		return ""
+	}
+	scheme := r.Header.Get("X-Forwarded-Proto")
+	if scheme != "http" {
+		scheme = "https"
+	}
+	uri := r.Header.Get("X-Forwarded-Uri")
+	if !strings.HasPrefix(uri, "/") {
+		uri = "/" + uri
+	}
+	return scheme + "://" + host + uri
+}
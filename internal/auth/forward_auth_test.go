@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	expect "github.com/yusing/goutils/testing"
+)
+
+func TestForwardAuthHandler(t *testing.T) {
+	userpass := newMockUserPassAuth()
+	defaultAuth = userpass
+	t.Cleanup(func() { defaultAuth = nil })
+
+	token, err := userpass.NewToken("username")
+	expect.NoError(t, err)
+
+	const login = "https://godoxy.example.com/login"
+
+	t.Run("authenticated", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/forward", nil)
+		req.Header.Set("Cookie", userpass.TokenCookieName()+"="+token)
+		w := httptest.NewRecorder()
+		ForwardAuthHandler(w, req)
+		expect.Equal(t, w.Code, http.StatusOK)
+		expect.Equal(t, w.Header().Get(HeaderRemoteUser), "username")
+	})
+
+	t.Run("traefik redirect", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/forward?rd="+url.QueryEscape(login), nil)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "app.example.com")
+		req.Header.Set("X-Forwarded-Uri", "/path?q=1")
+		w := httptest.NewRecorder()
+		ForwardAuthHandler(w, req)
+		expect.Equal(t, w.Code, http.StatusFound)
+		expect.Equal(t, w.Header().Get("Location"), login+"?redirect="+url.QueryEscape("https://app.example.com/path?q=1"))
+	})
+
+	t.Run("nginx unauthorized", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/forward?rd="+url.QueryEscape(login), nil)
+		req.Header.Set("X-Original-URL", "https://app.example.com/")
+		req.Header.Set("Cookie", userpass.TokenCookieName()+"=invalid")
+		w := httptest.NewRecorder()
+		ForwardAuthHandler(w, req)
+		expect.Equal(t, w.Code, http.StatusUnauthorized)
+		expect.Equal(t, w.Header().Get("Location"), login+"?redirect="+url.QueryEscape("https://app.example.com/"))
+	})
+
+	t.Run("login page", func(t *testing.T) {
+		SetForwardAuthDomains([]string{"my.app"})
+		t.Cleanup(func() { SetForwardAuthDomains(nil) })
+
+		tests := []struct {
+			rd      string
+			allowed bool
+		}{
+			{"/login", true},
+			{"https://godoxy.example.com/login", true}, // request host
+			{"https://auth.example.com/login", true},   // cookie domain
+			{"https://auth.my.app/login", true},        // route domain
+			{"https://my.app/login", true},
+			{"https://evil.com/login", false},
+			{"https://example.com.evil.com/login", false},
+			{"https://evilmy.app/login", false},
+			{"//evil.com/login", false},
+			{"/\\evil.com/login", false},
+			{"javascript:alert(1)", false},
+		}
+		for _, tt := range tests {
+			req := httptest.NewRequest(http.MethodGet, "https://godoxy.example.com/api/v1/auth/forward?rd="+url.QueryEscape(tt.rd), nil)
+			w := httptest.NewRecorder()
+			ForwardAuthHandler(w, req)
+			if tt.allowed {
+				expect.Equal(t, w.Code, http.StatusFound, tt.rd)
+				expect.Equal(t, w.Header().Get("Location"), tt.rd)
+			} else {
+				expect.Equal(t, w.Code, http.StatusUnauthorized, tt.rd)
+				expect.Equal(t, w.Header().Get("Location"), "", tt.rd)
+			}
+		}
+	})
+
+	t.Run("no login page", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/forward", nil)
+		w := httptest.NewRecorder()
+		ForwardAuthHandler(w, req)
+		expect.Equal(t, w.Code, http.StatusUnauthorized)
+		expect.Equal(t, w.Header().Get("Location"), "")
+	})
+}
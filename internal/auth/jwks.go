@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/puzpuzpuz/xsync/v4"
+	gperr "github.com/yusing/goutils/errs"
+)
+
+type (
+	// JWKS is a JSON Web Key Set fetched from a URL,
+	// refreshed periodically and when a token has an unknown key ID.
+	JWKS struct {
+		url string
+
+		mu          sync.Mutex
+		keys        map[string]any // by kid
+		allKeys     []jwt.VerificationKey
+		fetchedAt   time.Time
+		lastAttempt time.Time
+	}
+
+	jsonWebKey struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		Crv string `json:"crv"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+)
+
+const (
+	jwksRefreshInterval = time.Hour
+	jwksMinRefreshGap   = time.Minute // for unknown key IDs, which may be forged
+	jwksFetchTimeout    = 5 * time.Second
+	jwksMaxSize         = 1 << 20
+)
+
+var (
+	ErrUnknownJWK    = gperr.New("unknown key ID")
+	ErrInvalidJWK    = gperr.New("invalid JSON web key")
+	ErrInvalidPEMKey = gperr.New("invalid PEM public key")
+)
+
+// JWKS are shared by URL, so that routes of the same issuer fetch the keys once.
+var jwksByURL = xsync.NewMap[string, *JWKS]()
+
+// GetJWKS returns the shared key set of the URL.
+func GetJWKS(url string) *JWKS {
+	jwks, _ := jwksByURL.LoadOrCompute(url, func() (*JWKS, bool) {
+		return &JWKS{url: url}, false
+	})
+	return jwks
+}
+
+// Keyfunc returns the verification key of the token by its key ID,
+// or all keys if the token has none.
+func (j *JWKS) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	_, known := j.keys[kid]
+	stale := now.Sub(j.fetchedAt) > jwksRefreshInterval
+	if (stale || (kid != "" && !known)) && now.Sub(j.lastAttempt) > jwksMinRefreshGap {
+		j.lastAttempt = now
+		if err := j.refresh(); err != nil {
+			// keep using the previous keys if any
+			if len(j.allKeys) == 0 {
+				return nil, err
+			}
+		}
+	}
+
+	if kid == "" {
+		if len(j.allKeys) == 0 {
+			return nil, ErrUnknownJWK
+		}
+		return jwt.VerificationKeySet{Keys: j.allKeys}, nil
+	}
+	key, ok := j.keys[kid]
+	if !ok {
+		return nil, ErrUnknownJWK.Subject(kid)
+	}
+	return key, nil
+}
+
+func (j *JWKS) refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := sonic.ConfigDefault.NewDecoder(io.LimitReader(resp.Body, jwksMaxSize)).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+	keys := make(map[string]any, len(set.Keys))
+	allKeys := make([]jwt.VerificationKey, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue // unsupported key types are skipped
+		}
+		keys[jwk.Kid] = key
+		allKeys = append(allKeys, key)
+	}
+	j.keys = keys
+	j.allKeys = allKeys
+	j.fetchedAt = time.Now()
+	return nil
+}
+
+func (jwk *jsonWebKey) publicKey() (any, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, ErrInvalidJWK.With(err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, ErrInvalidJWK.Subject("e")
+		}
+		exp := 0
+		for _, b := range e {
+			exp = exp<<8 | int(b)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exp}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrInvalidJWK.Subjectf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, ErrInvalidJWK.With(err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, ErrInvalidJWK.With(err)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, ErrInvalidJWK.Subject("invalid coordinates")
+		}
+		uncompressed := make([]byte, 0, 1+2*size)
+		uncompressed = append(uncompressed, 4)
+		uncompressed = append(uncompressed, x...)
+		uncompressed = append(uncompressed, y...)
+		key, err := ecdsa.ParseUncompressedPublicKey(curve, uncompressed)
+		if err != nil {
+			return nil, ErrInvalidJWK.With(err)
+		}
+		return key, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, ErrInvalidJWK.Subjectf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, ErrInvalidJWK.Subject("x")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, ErrInvalidJWK.Subjectf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+// ParsePublicKey parses a PEM encoded public key or certificate,
+// or reads it from the file if s starts with "file://".
+func ParsePublicKey(s string) (any, error) {
+	data := []byte(s)
+	if path, ok := strings.CutPrefix(s, "file://"); ok {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPEMKey
+	}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, ErrInvalidPEMKey.With(err)
+		}
+		return cert.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, ErrInvalidPEMKey.With(err)
+		}
+		return key, nil
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, ErrInvalidPEMKey.With(err)
+		}
+		return key, nil
+	default:
+		return nil, ErrInvalidPEMKey.Subjectf("unexpected PEM block %q", block.Type)
+	}
+}
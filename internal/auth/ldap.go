@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/puzpuzpuz/xsync/v4"
+	"github.com/yusing/godoxy/internal/common"
+	"github.com/yusing/godoxy/internal/utils"
+	gperr "github.com/yusing/goutils/errs"
+	httputils "github.com/yusing/goutils/http"
+)
+
+type (
+	// LDAPAuth authenticates users with their LDAP / Active Directory credentials,
+	// the login session is a JWT like UserPassAuth.
+	LDAPAuth struct {
+		client        *LDAPClient
+		secret        []byte
+		tokenTTL      time.Duration
+		allowedUsers  []string
+		allowedGroups []string
+		limiter       *loginLimiter
+	}
+	LDAPClaims struct {
+		Username string   `json:"username"`
+		Groups   []string `json:"groups"`
+		jwt.RegisteredClaims
+	}
+
+	ldapCachedUser struct {
+		user   *LDAPUser
+		expiry time.Time
+	}
+)
+
+var _ Provider = (*LDAPAuth)(nil)
+
+const (
+	ldapTokenIssuer = "godoxy-ldap"
+
+	// basic auth credentials are sent on every request,
+	// cache the successful ones to avoid an LDAP round trip for each
+	ldapBasicAuthCacheTTL  = time.Minute
+	ldapBasicAuthCacheSize = 1024
+	ldapBasicAuthBurst     = 20
+)
+
+var (
+	ErrMissingBasicAuth     = gperr.New("missing basic auth credentials")
+	ErrTooManyLoginAttempts = gperr.New("too many login attempts")
+	ErrLDAPNoAllowList      = gperr.New("allowed users or allowed groups is required for LDAP")
+)
+
+var (
+	ldapBasicAuthCache   = xsync.NewMap[[sha256.Size]byte, *ldapCachedUser]()
+	ldapBasicAuthLimiter = func() *loginLimiter {
+		l := newLoginLimiter(common.APILoginMaxFailures, common.APILoginLockoutDuration)
+		l.rateBurst = ldapBasicAuthBurst
+		return l
+	}()
+)
+
+func NewLDAPAuth(client *LDAPClient, secret []byte, tokenTTL time.Duration, allowedUsers, allowedGroups []string) *LDAPAuth {
+	return &LDAPAuth{
+		client:        client,
+		secret:        secret,
+		tokenTTL:      tokenTTL,
+		allowedUsers:  allowedUsers,
+		allowedGroups: allowedGroups,
+		limiter:       newLoginLimiter(common.APILoginMaxFailures, common.APILoginLockoutDuration),
+	}
+}
+
+func NewLDAPAuthFromEnv() (*LDAPAuth, error) {
+	client, err := GetLDAPClient()
+	if err != nil {
+		return nil, err
+	}
+	if len(common.LDAPAllowedUsers) == 0 && len(common.LDAPAllowedGroups) == 0 {
+		return nil, ErrLDAPNoAllowList
+	}
+	return NewLDAPAuth(
+		client,
+		common.APIJWTSecret,
+		common.APIJWTTokenTTL,
+		common.LDAPAllowedUsers,
+		common.LDAPAllowedGroups,
+	), nil
+}
+
+func (auth *LDAPAuth) TokenCookieName() string {
+	return "godoxy_token"
+}
+
+func (auth *LDAPAuth) NewToken(user *LDAPUser) (token string, err error) {
+	claim := &LDAPClaims{
+		Username: user.Username,
+		Groups:   user.Groups,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    ldapTokenIssuer,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(auth.tokenTTL)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS512, claim).SignedString(auth.secret)
+}
+
+func (auth *LDAPAuth) CheckToken(r *http.Request) error {
+	_, err := auth.CurrentUser(r)
+	return err
+}
+
+func (auth *LDAPAuth) CurrentUser(r *http.Request) (*UserInfo, error) {
+	jwtCookie, err := r.Cookie(auth.TokenCookieName())
+	if err != nil {
+		return nil, ErrMissingSessionToken
+	}
+	var claims LDAPClaims
+	token, err := jwt.ParseWithClaims(jwtCookie.Value, &claims, func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return auth.secret, nil
+	}, jwt.WithIssuer(ldapTokenIssuer), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, ErrInvalidSessionToken
+	}
+	// the allowed users and groups may have changed after the token was issued
+	user := &LDAPUser{Username: claims.Username, Groups: claims.Groups}
+	if !user.IsAllowed(auth.allowedUsers, auth.allowedGroups) {
+		return nil, ErrUserNotAllowed.Subject(claims.Username)
+	}
+	return user.info(), nil
+}
+
+// PostAuthCallbackHandler checks the credentials against LDAP, and issues the session token.
+func (auth *LDAPAuth) PostAuthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	var creds UserPassAuthCallbackRequest
+	if err := sonic.ConfigDefault.NewDecoder(r.Body).Decode(&creds); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	ip := clientIP(r)
+	if retryAfter, ok := auth.limiter.allow(ip, creds.User); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+		http.Error(w, "too many login attempts, try again later", http.StatusTooManyRequests)
+		return
+	}
+	user, err := auth.client.Authenticate(creds.User, creds.Pass)
+	if err != nil {
+		httputils.LogError(r).Msg(fmt.Sprintf("LDAP login failed for %q: %v", creds.User, err))
+		if !isLDAPCredentialError(err) {
+			http.Error(w, "LDAP server unavailable", http.StatusBadGateway)
+			return
+		}
+		auth.limiter.fail(ip, creds.User)
+		// NOTE: do not include the actual error here
+		http.Error(w, "invalid credentials", http.StatusBadRequest)
+		return
+	}
+	if !user.IsAllowed(auth.allowedUsers, auth.allowedGroups) {
+		http.Error(w, "user not allowed", http.StatusForbidden)
+		return
+	}
+	token, err := auth.NewToken(user)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		httputils.LogError(r).Msg(fmt.Sprintf("failed to generate token: %v", err))
+		return
+	}
+	auth.limiter.succeed(ip, creds.User)
+	SetTokenCookie(w, r, auth.TokenCookieName(), token, auth.tokenTTL)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (auth *LDAPAuth) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, "/login", http.StatusFound)
+}
+
+func (auth *LDAPAuth) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	ClearTokenCookie(w, r, auth.TokenCookieName())
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// IsAllowed returns whether the user is in allowedUsers or any of allowedGroups,
+// no user is allowed if both are empty.
+func (user *LDAPUser) IsAllowed(allowedUsers, allowedGroups []string) bool {
+	return slices.Contains(allowedUsers, user.Username) || len(utils.Intersect(user.Groups, allowedGroups)) > 0
+}
+
+// isLDAPCredentialError returns whether the login failed because of the credentials,
+// as opposed to the LDAP server being unreachable or misconfigured.
+func isLDAPCredentialError(err error) bool {
+	return errors.Is(err, ErrLDAPInvalidCredentials) || errors.Is(err, ErrLDAPUserNotFound) || errors.Is(err, ErrLDAPMultipleUsers)
+}
+
+func (user *LDAPUser) info() *UserInfo {
+	// roles only apply to the API, mapped from ldap_group_roles of the users file
+	role := RoleViewer
+	if userStore != nil {
+		role = userStore.RoleForLDAPGroups(user.Groups)
+	}
+	return &UserInfo{
+		Username: user.Username,
+		Groups:   user.Groups,
+		Role:     role,
+		Origin:   UserOriginLDAP,
+	}
+}
+
+// LDAPBasicAuth authenticates the basic auth credentials of the request against LDAP,
+// for the ldap_auth middleware and the require_basic_auth rule command.
+//
+// Failed attempts are rate limited and locked out the same way as logins.
+func LDAPBasicAuth(r *http.Request) (*LDAPUser, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, ErrMissingBasicAuth
+	}
+	client, err := GetLDAPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	key := sha256.Sum256([]byte(username + "\x00" + password))
+	now := time.Now()
+	if cached, ok := ldapBasicAuthCache.Load(key); ok && now.Before(cached.expiry) {
+		return cached.user, nil
+	}
+
+	ip := clientIP(r)
+	if _, ok := ldapBasicAuthLimiter.allow(ip, username); !ok {
+		return nil, ErrTooManyLoginAttempts
+	}
+	user, err := client.Authenticate(username, password)
+	if err != nil {
+		if isLDAPCredentialError(err) {
+			ldapBasicAuthLimiter.fail(ip, username)
+		}
+		return nil, err
+	}
+	ldapBasicAuthLimiter.succeed(ip, username)
+
+	if ldapBasicAuthCache.Size() >= ldapBasicAuthCacheSize {
+		ldapBasicAuthCache.Range(func(k [sha256.Size]byte, v *ldapCachedUser) bool {
+			if now.After(v.expiry) {
+				ldapBasicAuthCache.Delete(k)
+			}
+			return true
+		})
+	}
+	ldapBasicAuthCache.Store(key, &ldapCachedUser{user: user, expiry: now.Add(ldapBasicAuthCacheTTL)})
+	return user, nil
+}
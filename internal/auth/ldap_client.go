@@ -0,0 +1,349 @@
+package auth
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/yusing/godoxy/internal/common"
+	gperr "github.com/yusing/goutils/errs"
+)
+
+type (
+	LDAPConfig struct {
+		URL                string // ldap://host:389 or ldaps://host:636
+		StartTLS           bool
+		InsecureSkipVerify bool
+		BindDN             string // empty for anonymous search
+		BindPassword       string
+		BaseDN             string
+		UserFilter         string // {username} is replaced with the escaped username
+		GroupAttribute     string // attribute of the user listing its groups, e.g. memberOf
+		GroupBaseDN        string // defaults to BaseDN
+		GroupFilter        string // {dn} and {username} are replaced, overrides GroupAttribute if set
+		PoolSize           int
+		Timeout            time.Duration
+
+		TLSConfig *tls.Config // overrides InsecureSkipVerify
+	}
+
+	// LDAPClient authenticates users against an LDAP directory,
+	// with a pool of connections bound as the search account.
+	LDAPClient struct {
+		cfg  LDAPConfig
+		url  string
+		tls  *tls.Config
+		pool chan *ldap.Conn
+	}
+
+	// LDAPUser is an authenticated LDAP user.
+	LDAPUser struct {
+		DN       string
+		Username string
+		Groups   []string // group names (CN)
+	}
+)
+
+const (
+	ldapDefaultTimeout = 5 * time.Second
+	ldapMaxEntries     = 1000
+)
+
+var (
+	ErrLDAPNotConfigured      = gperr.New("LDAP not configured")
+	ErrLDAPInvalidCredentials = gperr.New("invalid credentials")
+	ErrLDAPUserNotFound       = gperr.New("user not found")
+	ErrLDAPMultipleUsers      = gperr.New("username matches multiple entries")
+)
+
+var (
+	defaultLDAPClient     *LDAPClient
+	defaultLDAPClientErr  error
+	defaultLDAPClientOnce sync.Once
+)
+
+func IsLDAPEnabled() bool {
+	return common.LDAPURL != ""
+}
+
+// GetLDAPClient returns the LDAP client configured by environment variables,
+// shared by the LDAP auth provider, the ldap_auth middleware and the rules engine.
+func GetLDAPClient() (*LDAPClient, error) {
+	if !IsLDAPEnabled() {
+		return nil, ErrLDAPNotConfigured
+	}
+	defaultLDAPClientOnce.Do(func() {
+		defaultLDAPClient, defaultLDAPClientErr = NewLDAPClient(LDAPConfig{
+			URL:                common.LDAPURL,
+			StartTLS:           common.LDAPStartTLS,
+			InsecureSkipVerify: common.LDAPInsecureSkipVerify,
+			BindDN:             common.LDAPBindDN,
+			BindPassword:       common.LDAPBindPassword,
+			BaseDN:             common.LDAPBaseDN,
+			UserFilter:         common.LDAPUserFilter,
+			GroupAttribute:     common.LDAPGroupAttribute,
+			GroupBaseDN:        common.LDAPGroupBaseDN,
+			GroupFilter:        common.LDAPGroupFilter,
+			PoolSize:           common.LDAPPoolSize,
+		})
+	})
+	return defaultLDAPClient, defaultLDAPClientErr
+}
+
+func NewLDAPClient(cfg LDAPConfig) (*LDAPClient, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, gperr.Wrap(err, "invalid LDAP URL")
+	}
+	switch u.Scheme {
+	case "ldap":
+	case "ldaps":
+		if cfg.StartTLS {
+			return nil, gperr.New("StartTLS cannot be used with ldaps://")
+		}
+	default:
+		return nil, gperr.Errorf("invalid LDAP URL scheme %q, expect ldap:// or ldaps://", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, gperr.New("missing host in LDAP URL")
+	}
+	if cfg.BaseDN == "" {
+		return nil, gperr.New("LDAP base DN is required")
+	}
+	if !strings.Contains(cfg.UserFilter, "{username}") {
+		return nil, gperr.New("LDAP user filter must contain {username}")
+	}
+	if _, err := ldap.CompileFilter(cfg.UserFilter); err != nil {
+		return nil, gperr.Wrap(err, "invalid LDAP user filter")
+	}
+	if cfg.GroupFilter != "" {
+		if _, err := ldap.CompileFilter(cfg.GroupFilter); err != nil {
+			return nil, gperr.Wrap(err, "invalid LDAP group filter")
+		}
+	}
+
+	client := &LDAPClient{cfg: cfg, url: cfg.URL}
+	client.tls = cfg.TLSConfig
+	if client.tls != nil && client.tls.ServerName == "" {
+		client.tls = client.tls.Clone()
+		client.tls.ServerName = u.Hostname()
+	}
+	if client.tls == nil {
+		client.tls = &tls.Config{
+			ServerName:         u.Hostname(),
+			InsecureSkipVerify: cfg.InsecureSkipVerify, //nolint:gosec
+			MinVersion:         tls.VersionTLS12,
+		}
+	}
+	if client.cfg.Timeout <= 0 {
+		client.cfg.Timeout = ldapDefaultTimeout
+	}
+	client.pool = make(chan *ldap.Conn, max(cfg.PoolSize, 1))
+	return client, nil
+}
+
+// Authenticate verifies the password of the user and looks up the groups of the user.
+func (c *LDAPClient) Authenticate(username, password string) (*LDAPUser, error) {
+	// an empty password is an unauthenticated bind which always succeeds (RFC 4513 section 5.1.2)
+	if username == "" || password == "" {
+		return nil, ErrLDAPInvalidCredentials
+	}
+
+	var user *LDAPUser
+	err := c.withConn(func(conn *ldap.Conn) error {
+		var err error
+		user, err = c.authenticate(conn, username, password)
+		return err
+	})
+	return user, err
+}
+
+func (c *LDAPClient) authenticate(conn *ldap.Conn, username, password string) (*LDAPUser, error) {
+	attrs := []string{"1.1"} // no attributes (RFC 4511 section 4.5.1.8)
+	if c.cfg.GroupFilter == "" && c.cfg.GroupAttribute != "" {
+		attrs = []string{c.cfg.GroupAttribute}
+	}
+	filter := strings.ReplaceAll(c.cfg.UserFilter, "{username}", ldap.EscapeFilter(username))
+	entries, err := c.search(conn, c.cfg.BaseDN, filter, attrs)
+	if err != nil {
+		return nil, err
+	}
+	switch len(entries) {
+	case 0:
+		return nil, ErrLDAPUserNotFound.Subject(username)
+	case 1:
+	default:
+		return nil, ErrLDAPMultipleUsers.Subject(username)
+	}
+	entry := entries[0]
+
+	// verify the password, then bind back to the search account
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			// the connection is still usable
+			if err := c.bind(conn); err != nil {
+				return nil, err
+			}
+			return nil, ErrLDAPInvalidCredentials.Subject(username)
+		}
+		return nil, err
+	}
+	if err := c.bind(conn); err != nil {
+		return nil, err
+	}
+
+	user := &LDAPUser{DN: entry.DN, Username: username}
+	if c.cfg.GroupFilter == "" {
+		for _, groupDN := range entry.GetEqualFoldAttributeValues(c.cfg.GroupAttribute) {
+			user.Groups = append(user.Groups, ldapGroupName(groupDN))
+		}
+		return user, nil
+	}
+
+	groupBaseDN := c.cfg.GroupBaseDN
+	if groupBaseDN == "" {
+		groupBaseDN = c.cfg.BaseDN
+	}
+	filter = strings.NewReplacer(
+		"{dn}", ldap.EscapeFilter(entry.DN),
+		"{username}", ldap.EscapeFilter(username),
+	).Replace(c.cfg.GroupFilter)
+	groups, err := c.search(conn, groupBaseDN, filter, []string{"cn"})
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		if cn := group.GetEqualFoldAttributeValue("cn"); cn != "" {
+			user.Groups = append(user.Groups, cn)
+		} else {
+			user.Groups = append(user.Groups, ldapGroupName(group.DN))
+		}
+	}
+	return user, nil
+}
+
+// search searches the whole subtree of baseDN, referrals are not followed.
+func (c *LDAPClient) search(conn *ldap.Conn, baseDN, filter string, attrs []string) ([]*ldap.Entry, error) {
+	result, err := conn.Search(ldap.NewSearchRequest(
+		baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		ldapMaxEntries, int(c.cfg.Timeout/time.Second), false,
+		filter, attrs, nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return result.Entries, nil
+}
+
+// bind binds the connection as the search account.
+func (c *LDAPClient) bind(conn *ldap.Conn) error {
+	if c.cfg.BindDN == "" {
+		return conn.UnauthenticatedBind("")
+	}
+	return conn.Bind(c.cfg.BindDN, c.cfg.BindPassword)
+}
+
+// withConn runs fn with a pooled connection,
+// and retries once with a new connection if the pooled one was closed by the server.
+func (c *LDAPClient) withConn(fn func(conn *ldap.Conn) error) error {
+	conn, pooled, err := c.get()
+	if err != nil {
+		return err
+	}
+	err = fn(conn)
+	if err != nil && pooled && isLDAPConnError(err) {
+		_ = conn.Close()
+		conn, err = c.dial()
+		if err != nil {
+			return err
+		}
+		err = fn(conn)
+	}
+	c.put(conn, err)
+	return err
+}
+
+func (c *LDAPClient) get() (conn *ldap.Conn, pooled bool, err error) {
+	select {
+	case conn := <-c.pool:
+		return conn, true, nil
+	default:
+		conn, err := c.dial()
+		return conn, false, err
+	}
+}
+
+// put returns the connection to the pool, unless the connection may be in an unknown state after err.
+func (c *LDAPClient) put(conn *ldap.Conn, err error) {
+	if conn.IsClosing() || (err != nil && !isLDAPCredentialError(err)) {
+		_ = conn.Close()
+		return
+	}
+	select {
+	case c.pool <- conn:
+	default:
+		_ = conn.Close()
+	}
+}
+
+// Close closes the pooled connections.
+func (c *LDAPClient) Close() {
+	for {
+		select {
+		case conn := <-c.pool:
+			_ = conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+func (c *LDAPClient) dial() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(c.url,
+		ldap.DialWithDialer(&net.Dialer{Timeout: c.cfg.Timeout}),
+		ldap.DialWithTLSConfig(c.tls),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to LDAP server: %w", err)
+	}
+	conn.SetTimeout(c.cfg.Timeout)
+
+	if c.cfg.StartTLS {
+		if err := conn.StartTLS(c.tls); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("LDAP StartTLS failed: %w", err)
+		}
+	}
+	if err := c.bind(conn); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("LDAP bind as %q failed: %w", c.cfg.BindDN, err)
+	}
+	return conn, nil
+}
+
+// isLDAPConnError returns whether the error is an I/O error, e.g. the connection was closed by the server.
+func isLDAPConnError(err error) bool {
+	return ldap.IsErrorWithCode(err, ldap.ErrorNetwork)
+}
+
+// ldapGroupName returns the CN of the group DN, or the DN itself if it has no CN.
+func ldapGroupName(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 {
+		return dn
+	}
+	for _, attr := range parsed.RDNs[0].Attributes {
+		if strings.EqualFold(attr.Type, "cn") {
+			return attr.Value
+		}
+	}
+	return dn
+}
@@ -0,0 +1,378 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	expect "github.com/yusing/goutils/testing"
+)
+
+const testLDAPOIDStartTLS = "1.3.6.1.4.1.1466.20037"
+
+// testLDAPServer is a minimal in-process LDAP server
+// supporting simple bind, search and StartTLS.
+type testLDAPServer struct {
+	ln      net.Listener
+	entries map[string]map[string][]string // by DN, attribute names are lower case
+	tls     *tls.Config
+	ldaps   bool
+	conns   atomic.Int32
+}
+
+func newTestLDAPServer(t *testing.T, ldaps bool) (*testLDAPServer, *x509.CertPool) {
+	t.Helper()
+	tlsConfig, pool := newTestLDAPCert(t)
+	s := &testLDAPServer{
+		tls:   tlsConfig,
+		ldaps: ldaps,
+		entries: map[string]map[string][]string{
+			"uid=alice,ou=users,dc=example,dc=com": {
+				"objectclass":  {"person"},
+				"uid":          {"alice"},
+				"userpassword": {"alice-password"},
+				"memberof":     {"cn=admins,ou=groups,dc=example,dc=com", "cn=users,ou=groups,dc=example,dc=com"},
+			},
+			"uid=bob,ou=users,dc=example,dc=com": {
+				"objectclass":  {"person"},
+				"uid":          {"bob"},
+				"userpassword": {"bob-password"},
+				"memberof":     {"cn=users,ou=groups,dc=example,dc=com"},
+			},
+			"cn=admins,ou=groups,dc=example,dc=com": {
+				"objectclass": {"groupOfNames"},
+				"cn":          {"admins"},
+				"member":      {"uid=alice,ou=users,dc=example,dc=com"},
+			},
+			"cn=users,ou=groups,dc=example,dc=com": {
+				"objectclass": {"groupOfNames"},
+				"cn":          {"users"},
+				"member":      {"uid=alice,ou=users,dc=example,dc=com", "uid=bob,ou=users,dc=example,dc=com"},
+			},
+			"cn=service,dc=example,dc=com": {
+				"userpassword": {"service-password"},
+			},
+		},
+	}
+	var err error
+	if ldaps {
+		s.ln, err = tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	} else {
+		s.ln, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	expect.NoError(t, err)
+	t.Cleanup(func() { s.ln.Close() })
+	go s.serve()
+	return s, pool
+}
+
+func newTestLDAPCert(t *testing.T) (*tls.Config, *x509.CertPool) {
+	t.Helper()
+	key := expect.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der := expect.Must(x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key))
+	cert := expect.Must(x509.ParseCertificate(der))
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS12,
+	}, pool
+}
+
+func (s *testLDAPServer) URL() string {
+	if s.ldaps {
+		return "ldaps://" + s.ln.Addr().String()
+	}
+	return "ldap://" + s.ln.Addr().String()
+}
+
+func (s *testLDAPServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.conns.Add(1)
+		go s.handle(conn)
+	}
+}
+
+func (s *testLDAPServer) handle(conn net.Conn) {
+	defer func() { conn.Close() }()
+	for {
+		msg, err := ber.ReadPacket(conn)
+		if err != nil || len(msg.Children) < 2 {
+			return
+		}
+		id, op := msg.Children[0].Value, msg.Children[1]
+		reply := func(ops ...*ber.Packet) {
+			for _, op := range ops {
+				msg := ber.NewSequence("")
+				msg.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+				msg.AppendChild(op)
+				_, _ = conn.Write(msg.Bytes())
+			}
+		}
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn, password := op.Children[1].Data.String(), op.Children[2].Data.String()
+			code := uint16(ldap.LDAPResultInvalidCredentials)
+			if dn == "" && password == "" {
+				code = ldap.LDAPResultSuccess
+			} else if entry, ok := s.entries[dn]; ok && len(entry["userpassword"]) > 0 && entry["userpassword"][0] == password {
+				code = ldap.LDAPResultSuccess
+			}
+			reply(testLDAPResult(ldap.ApplicationBindResponse, code))
+		case ldap.ApplicationSearchRequest:
+			base := op.Children[0].Data.String()
+			var ops []*ber.Packet
+			for dn, entry := range s.entries {
+				if !strings.HasSuffix(dn, base) || !testLDAPMatch(op.Children[6], entry) {
+					continue
+				}
+				attrList := ber.NewSequence("")
+				for _, attr := range op.Children[7].Children {
+					name := strings.ToLower(attr.Data.String())
+					if values, ok := entry[name]; ok {
+						vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+						for _, v := range values {
+							vals.AppendChild(testLDAPString(v))
+						}
+						attr := ber.NewSequence("")
+						attr.AppendChild(testLDAPString(name))
+						attr.AppendChild(vals)
+						attrList.AppendChild(attr)
+					}
+				}
+				result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+				result.AppendChild(testLDAPString(dn))
+				result.AppendChild(attrList)
+				ops = append(ops, result)
+			}
+			ops = append(ops, testLDAPResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+			reply(ops...)
+		case ldap.ApplicationExtendedRequest:
+			if op.Children[0].Data.String() != testLDAPOIDStartTLS {
+				reply(testLDAPResult(ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError))
+				continue
+			}
+			reply(testLDAPResult(ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess))
+			conn = tls.Server(conn, s.tls)
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func testLDAPString(s string) *ber.Packet {
+	return ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, s, "")
+}
+
+func testLDAPResult(tag ber.Tag, code uint16) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	result.AppendChild(testLDAPString(""))
+	result.AppendChild(testLDAPString(""))
+	return result
+}
+
+func testLDAPMatch(filter *ber.Packet, entry map[string][]string) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !testLDAPMatch(child, entry) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if testLDAPMatch(child, entry) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !testLDAPMatch(filter.Children[0], entry)
+	case ldap.FilterEqualityMatch:
+		for _, v := range entry[strings.ToLower(filter.Children[0].Data.String())] {
+			if strings.EqualFold(v, filter.Children[1].Data.String()) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		return len(entry[strings.ToLower(filter.Data.String())]) > 0
+	}
+	return false
+}
+
+func newTestLDAPClient(t *testing.T, s *testLDAPServer, pool *x509.CertPool, modify func(cfg *LDAPConfig)) *LDAPClient {
+	t.Helper()
+	cfg := LDAPConfig{
+		URL:            s.URL(),
+		BindDN:         "cn=service,dc=example,dc=com",
+		BindPassword:   "service-password",
+		BaseDN:         "ou=users,dc=example,dc=com",
+		UserFilter:     "(&(objectClass=person)(uid={username}))",
+		GroupAttribute: "memberOf",
+		PoolSize:       2,
+		TLSConfig:      &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+	}
+	if modify != nil {
+		modify(&cfg)
+	}
+	client, err := NewLDAPClient(cfg)
+	expect.NoError(t, err)
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestLDAPInvalidFilter(t *testing.T) {
+	for _, filter := range []string{"(uid={username}", "(&)", "(=alice)", "(uid=\\zz{username})"} {
+		_, err := NewLDAPClient(LDAPConfig{URL: "ldap://localhost", BaseDN: "dc=example,dc=com", UserFilter: filter})
+		expect.HasError(t, err, filter)
+	}
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	s, pool := newTestLDAPServer(t, false)
+	client := newTestLDAPClient(t, s, pool, nil)
+
+	user, err := client.Authenticate("alice", "alice-password")
+	expect.NoError(t, err)
+	expect.Equal(t, user.DN, "uid=alice,ou=users,dc=example,dc=com")
+	expect.Equal(t, user.Groups, []string{"admins", "users"})
+
+	_, err = client.Authenticate("alice", "wrong-password")
+	expect.ErrorIs(t, ErrLDAPInvalidCredentials, err)
+	_, err = client.Authenticate("alice", "")
+	expect.ErrorIs(t, ErrLDAPInvalidCredentials, err)
+	_, err = client.Authenticate("nobody", "password")
+	expect.ErrorIs(t, ErrLDAPUserNotFound, err)
+	// wildcards in the username must be escaped
+	_, err = client.Authenticate("*", "alice-password")
+	expect.ErrorIs(t, ErrLDAPUserNotFound, err)
+
+	// the connection is reused and bound back to the service account after failures
+	user, err = client.Authenticate("bob", "bob-password")
+	expect.NoError(t, err)
+	expect.Equal(t, user.Groups, []string{"users"})
+	expect.Equal(t, s.conns.Load(), int32(1))
+}
+
+func TestLDAPGroupFilter(t *testing.T) {
+	s, pool := newTestLDAPServer(t, false)
+	client := newTestLDAPClient(t, s, pool, func(cfg *LDAPConfig) {
+		cfg.GroupBaseDN = "ou=groups,dc=example,dc=com"
+		cfg.GroupFilter = "(&(objectClass=groupOfNames)(member={dn}))"
+	})
+
+	user, err := client.Authenticate("bob", "bob-password")
+	expect.NoError(t, err)
+	expect.Equal(t, user.Groups, []string{"users"})
+}
+
+func TestLDAPTLS(t *testing.T) {
+	t.Run("StartTLS", func(t *testing.T) {
+		s, pool := newTestLDAPServer(t, false)
+		client := newTestLDAPClient(t, s, pool, func(cfg *LDAPConfig) {
+			cfg.StartTLS = true
+		})
+		_, err := client.Authenticate("alice", "alice-password")
+		expect.NoError(t, err)
+	})
+
+	t.Run("LDAPS", func(t *testing.T) {
+		s, pool := newTestLDAPServer(t, true)
+		client := newTestLDAPClient(t, s, pool, nil)
+		_, err := client.Authenticate("alice", "alice-password")
+		expect.NoError(t, err)
+	})
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		s, _ := newTestLDAPServer(t, true)
+		client := newTestLDAPClient(t, s, x509.NewCertPool(), nil)
+		_, err := client.Authenticate("alice", "alice-password")
+		expect.HasError(t, err)
+	})
+}
+
+func TestLDAPAuthLogin(t *testing.T) {
+	s, pool := newTestLDAPServer(t, false)
+	client := newTestLDAPClient(t, s, pool, nil)
+	auth := NewLDAPAuth(client, []byte("abcdefghijklmnopqrstuvwxyz"), time.Hour, nil, []string{"admins"})
+
+	login := func(username, password string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(UserPassAuthCallbackRequest{User: username, Pass: password})
+		req := httptest.NewRequest(http.MethodPost, "/auth/callback", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		auth.PostAuthCallbackHandler(w, req)
+		return w
+	}
+
+	w := login("alice", "alice-password")
+	expect.Equal(t, w.Code, http.StatusOK)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range w.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	user, err := auth.CurrentUser(req)
+	expect.NoError(t, err)
+	expect.Equal(t, user.Username, "alice")
+	expect.Equal(t, user.Groups, []string{"admins", "users"})
+	expect.Equal(t, user.Role, RoleViewer)
+
+	// roles are mapped from ldap_group_roles only
+	userStore = &UserStore{config: UsersConfig{
+		OIDCGroupRoles:  map[string]Role{"users": RoleAdmin},
+		LDAPGroupRoles:  map[string]Role{"admins": RoleOperator},
+		LDAPDefaultRole: RoleViewer,
+	}}
+	t.Cleanup(func() { userStore = nil })
+	user, err = auth.CurrentUser(req)
+	expect.NoError(t, err)
+	expect.Equal(t, user.Role, RoleOperator)
+
+	// not in allowed groups
+	w = login("bob", "bob-password")
+	expect.Equal(t, w.Code, http.StatusForbidden)
+
+	w = login("alice", "wrong-password")
+	expect.Equal(t, w.Code, http.StatusBadRequest)
+}
+
+func TestLDAPUserIsAllowed(t *testing.T) {
+	user := &LDAPUser{Username: "alice", Groups: []string{"admins"}}
+	expect.False(t, user.IsAllowed(nil, nil))
+	expect.True(t, user.IsAllowed([]string{"alice"}, nil))
+	expect.True(t, user.IsAllowed(nil, []string{"admins"}))
+	expect.False(t, user.IsAllowed([]string{"bob"}, []string{"users"}))
+}
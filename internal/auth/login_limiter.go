@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+	"golang.org/x/time/rate"
+)
+
+type (
+	// loginLimiter rate limits login attempts by client IP,
+	// and locks out users and client IPs after consecutive failures.
+	loginLimiter struct {
+		maxFailures int
+		lockout     time.Duration
+		rateLimit   rate.Limit // per client IP
+		rateBurst   int
+
+		attempts *xsync.Map[string, *loginAttempts] // by "user:<username>" or "ip:<ip>"
+	}
+	loginAttempts struct {
+		mu          sync.Mutex
+		limiter     *rate.Limiter // nil for users
+		failures    int
+		lastSeen    time.Time
+		lockedUntil time.Time
+	}
+)
+
+const (
+	loginRateLimit   = rate.Limit(1) // per second
+	loginRateBurst   = 5
+	ipFailuresFactor = 4 // a client IP is locked out after ipFailuresFactor * maxFailures
+	loginPruneSize   = 1024
+)
+
+func newLoginLimiter(maxFailures int, lockout time.Duration) *loginLimiter {
+	return &loginLimiter{
+		maxFailures: max(maxFailures, 1),
+		lockout:     lockout,
+		rateLimit:   loginRateLimit,
+		rateBurst:   loginRateBurst,
+		attempts:    xsync.NewMap[string, *loginAttempts](),
+	}
+}
+
+func (l *loginLimiter) get(key string, withLimiter bool) *loginAttempts {
+	a, _ := l.attempts.LoadOrCompute(key, func() (*loginAttempts, bool) {
+		a := &loginAttempts{}
+		if withLimiter {
+			a.limiter = rate.NewLimiter(l.rateLimit, l.rateBurst)
+		}
+		return a, false
+	})
+	return a
+}
+
+// allow returns whether a login attempt for username from ip is allowed,
+// or how long to wait before retrying.
+func (l *loginLimiter) allow(ip, username string) (retryAfter time.Duration, ok bool) {
+	now := time.Now()
+	byIP := l.get("ip:"+ip, true)
+	byIP.mu.Lock()
+	byIP.lastSeen = now
+	if wait := byIP.lockedUntil.Sub(now); wait > 0 {
+		byIP.mu.Unlock()
+		return wait, false
+	}
+	if !byIP.limiter.AllowN(now, 1) {
+		byIP.mu.Unlock()
+		return time.Second, false
+	}
+	byIP.mu.Unlock()
+
+	if username == "" {
+		return 0, true
+	}
+	byUser := l.get("user:"+username, false)
+	byUser.mu.Lock()
+	defer byUser.mu.Unlock()
+	if wait := byUser.lockedUntil.Sub(now); wait > 0 {
+		return wait, false
+	}
+	return 0, true
+}
+
+// fail records a failed attempt, and locks out the user and/or ip
+// once they reach the maximum number of failures.
+func (l *loginLimiter) fail(ip, username string) {
+	now := time.Now()
+	l.failKey("ip:"+ip, true, l.maxFailures*ipFailuresFactor, now)
+	if username != "" {
+		l.failKey("user:"+username, false, l.maxFailures, now)
+	}
+	if l.attempts.Size() > loginPruneSize {
+		l.prune(now)
+	}
+}
+
+func (l *loginLimiter) failKey(key string, withLimiter bool, maxFailures int, now time.Time) {
+	a := l.get(key, withLimiter)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// failures are forgotten after a lockout period without failures
+	if now.Sub(a.lastSeen) > l.lockout {
+		a.failures = 0
+	}
+	a.lastSeen = now
+	a.failures++
+	if a.failures >= maxFailures {
+		a.failures = 0
+		a.lockedUntil = now.Add(l.lockout)
+	}
+}
+
+// succeed resets the failures of the user and ip.
+func (l *loginLimiter) succeed(ip, username string) {
+	l.attempts.Delete("user:" + username)
+	if a, ok := l.attempts.Load("ip:" + ip); ok {
+		a.mu.Lock()
+		a.failures = 0
+		a.mu.Unlock()
+	}
+}
+
+// prune drops entries that are neither locked nor seen within the lockout period.
+func (l *loginLimiter) prune(now time.Time) {
+	l.attempts.Range(func(key string, a *loginAttempts) bool {
+		a.mu.Lock()
+		stale := now.After(a.lockedUntil) && now.Sub(a.lastSeen) > l.lockout
+		a.mu.Unlock()
+		if stale {
+			l.attempts.Delete(key)
+		}
+		return true
+	})
+}
+
+// clientIP returns the IP of the client, the frontend passes it in X-Real-IP or X-Forwarded-For.
+func clientIP(r *http.Request) string {
+	if IsFrontend(r) {
+		if ip := r.Header.Get("X-Real-IP"); ip != "" {
+			return ip
+		}
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			first, _, _ := strings.Cut(xff, ",")
+			if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	return requestRemoteIP(r)
+}
@@ -0,0 +1,301 @@
+package auth
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+	gperr "github.com/yusing/goutils/errs"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type (
+	MFAMethod string
+
+	// MFAStatus is the second factor enrolment of a user.
+	MFAStatus struct {
+		TOTP          bool                      `json:"totp"`
+		RecoveryCodes int                       `json:"recovery_codes"` // number of unused recovery codes
+		WebAuthn      []*WebAuthnCredentialInfo `json:"webauthn"`
+	} //	@name	MFAStatus
+
+	pendingTOTP struct {
+		secret string
+		expiry time.Time
+	}
+)
+
+const (
+	MFAMethodTOTP     MFAMethod = "totp"
+	MFAMethodWebAuthn MFAMethod = "webauthn"
+)
+
+const totpEnrollmentTimeout = 10 * time.Minute
+
+var (
+	ErrMFANotEnabled       = gperr.New("second factor not enabled")
+	ErrTOTPAlreadyEnabled  = gperr.New("totp already enabled")
+	ErrNoPendingEnrollment = gperr.New("no pending totp enrolment")
+	ErrInvalidMFACode      = gperr.New("invalid code")
+)
+
+// secrets generated by BeginTOTPEnrollment by username, waiting for confirmation
+var pendingTOTPs = xsync.NewMap[string, *pendingTOTP]()
+
+// MFAMethods returns the enrolled second factors of the user.
+func (user *User) MFAMethods() []MFAMethod {
+	var methods []MFAMethod
+	if user.TOTPSecret != "" {
+		methods = append(methods, MFAMethodTOTP)
+	}
+	if len(user.WebAuthn) > 0 {
+		methods = append(methods, MFAMethodWebAuthn)
+	}
+	return methods
+}
+
+// HasMFA returns whether the user has any second factor enrolled.
+func (user *User) HasMFA() bool {
+	return user.TOTPSecret != "" || len(user.WebAuthn) > 0
+}
+
+// modify applies fn to a copy of the user and saves the result if fn succeeds.
+func (s *UserStore) modify(username string, fn func(user *User) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user := s.get(username)
+	if user == nil {
+		return ErrUserNotFound.Subject(username)
+	}
+	updated := *user
+	if err := fn(&updated); err != nil {
+		return err
+	}
+	*user = updated
+	return s.save()
+}
+
+// CheckPassword checks the plain text password of the user.
+func (s *UserStore) CheckPassword(username, password string) error {
+	user, ok := s.Get(username)
+	if !ok {
+		return ErrInvalidUsername.Subject(username)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return ErrInvalidPassword.With(err)
+	}
+	return nil
+}
+
+// MFAStatus returns the second factor enrolment of the user.
+func (s *UserStore) MFAStatus(username string) (*MFAStatus, error) {
+	user, ok := s.Get(username)
+	if !ok {
+		return nil, ErrUserNotFound.Subject(username)
+	}
+	status := &MFAStatus{
+		TOTP:          user.TOTPSecret != "",
+		RecoveryCodes: len(user.RecoveryCodes),
+		WebAuthn:      make([]*WebAuthnCredentialInfo, len(user.WebAuthn)),
+	}
+	for i, cred := range user.WebAuthn {
+		status.WebAuthn[i] = cred.Info()
+	}
+	return status, nil
+}
+
+// BeginTOTPEnrollment generates a TOTP secret for the user,
+// which is enabled once confirmed by ConfirmTOTPEnrollment.
+func (s *UserStore) BeginTOTPEnrollment(username string) (secret, uri string, err error) {
+	user, ok := s.Get(username)
+	if !ok {
+		return "", "", ErrUserNotFound.Subject(username)
+	}
+	if user.TOTPSecret != "" {
+		return "", "", ErrTOTPAlreadyEnabled
+	}
+	secret = newTOTPSecret()
+	pendingTOTPs.Store(username, &pendingTOTP{secret: secret, expiry: time.Now().Add(totpEnrollmentTimeout)})
+	return secret, totpURI(username, secret), nil
+}
+
+// ConfirmTOTPEnrollment enables TOTP with the pending secret if code is valid.
+//
+// Recovery codes are returned in plain text if the user had none, they cannot be retrieved later.
+func (s *UserStore) ConfirmTOTPEnrollment(username, code string) (recoveryCodes []string, err error) {
+	pending, ok := pendingTOTPs.Load(username)
+	if !ok || time.Now().After(pending.expiry) {
+		return nil, ErrNoPendingEnrollment
+	}
+	if !checkTOTP(username, pending.secret, code) {
+		return nil, ErrInvalidMFACode
+	}
+	err = s.modify(username, func(user *User) error {
+		if user.TOTPSecret != "" {
+			return ErrTOTPAlreadyEnabled
+		}
+		user.TOTPSecret = pending.secret
+		if len(user.RecoveryCodes) == 0 {
+			var hashes []string
+			recoveryCodes, hashes = newRecoveryCodes()
+			user.RecoveryCodes = hashes
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	pendingTOTPs.Delete(username)
+	return recoveryCodes, nil
+}
+
+// DisableTOTP disables TOTP after checking the password,
+// recovery codes are removed when no second factor is left.
+func (s *UserStore) DisableTOTP(username, password string) error {
+	if err := s.CheckPassword(username, password); err != nil {
+		return err
+	}
+	return s.modify(username, func(user *User) error {
+		if user.TOTPSecret == "" {
+			return ErrMFANotEnabled
+		}
+		user.TOTPSecret = ""
+		if !user.HasMFA() {
+			user.RecoveryCodes = nil
+		}
+		return nil
+	})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user after checking the password.
+func (s *UserStore) RegenerateRecoveryCodes(username, password string) (recoveryCodes []string, err error) {
+	if err := s.CheckPassword(username, password); err != nil {
+		return nil, err
+	}
+	err = s.modify(username, func(user *User) error {
+		if !user.HasMFA() {
+			return ErrMFANotEnabled
+		}
+		var hashes []string
+		recoveryCodes, hashes = newRecoveryCodes()
+		user.RecoveryCodes = hashes
+		return nil
+	})
+	return recoveryCodes, err
+}
+
+// BeginWebAuthnRegistration returns the options for navigator.credentials.create().
+func (s *UserStore) BeginWebAuthnRegistration(r *http.Request, username string) (*WebAuthnCreationOptions, error) {
+	user, ok := s.Get(username)
+	if !ok {
+		return nil, ErrUserNotFound.Subject(username)
+	}
+	return newWebAuthnCreationOptions(r, &user)
+}
+
+// FinishWebAuthnRegistration verifies and saves the new passkey.
+//
+// Recovery codes are returned in plain text if the user had none, they cannot be retrieved later.
+func (s *UserStore) FinishWebAuthnRegistration(username, name string, reg *WebAuthnRegistration) (info *WebAuthnCredentialInfo, recoveryCodes []string, err error) {
+	cred, err := finishWebAuthnRegistration(username, name, reg)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = s.modify(username, func(user *User) error {
+		if slices.ContainsFunc(user.WebAuthn, func(c *WebAuthnCredential) bool { return c.ID == cred.ID }) {
+			return ErrInvalidWebAuthnResponse.Subject("credential already registered")
+		}
+		user.WebAuthn = append(slices.Clone(user.WebAuthn), cred)
+		if len(user.RecoveryCodes) == 0 {
+			var hashes []string
+			recoveryCodes, hashes = newRecoveryCodes()
+			user.RecoveryCodes = hashes
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return cred.Info(), recoveryCodes, nil
+}
+
+// DeleteWebAuthnCredential removes the passkey of the user after checking the password,
+// recovery codes are removed when no second factor is left.
+func (s *UserStore) DeleteWebAuthnCredential(username, password, id string) error {
+	if err := s.CheckPassword(username, password); err != nil {
+		return err
+	}
+	return s.modify(username, func(user *User) error {
+		i := slices.IndexFunc(user.WebAuthn, func(c *WebAuthnCredential) bool { return c.ID == id })
+		if i == -1 {
+			return ErrUnknownCredential.Subject(id)
+		}
+		user.WebAuthn = slices.Delete(slices.Clone(user.WebAuthn), i, i+1)
+		if !user.HasMFA() {
+			user.RecoveryCodes = nil
+		}
+		return nil
+	})
+}
+
+// ResetMFA removes all second factors of the user, for admins to recover locked out users.
+func (s *UserStore) ResetMFA(username string) error {
+	return s.modify(username, func(user *User) error {
+		user.TOTPSecret = ""
+		user.RecoveryCodes = nil
+		user.WebAuthn = nil
+		return nil
+	})
+}
+
+// verifyTOTP checks the TOTP code, or consumes the recovery code if code is empty.
+func (s *UserStore) verifyTOTP(username, code, recoveryCode string) error {
+	user, ok := s.Get(username)
+	if !ok {
+		return ErrUserNotFound.Subject(username)
+	}
+	if code != "" {
+		if user.TOTPSecret == "" {
+			return ErrMFANotEnabled
+		}
+		if !checkTOTP(username, user.TOTPSecret, code) {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+	hash := hashRecoveryCode(recoveryCode)
+	return s.modify(username, func(user *User) error {
+		i := slices.Index(user.RecoveryCodes, hash)
+		if i == -1 {
+			return ErrInvalidMFACode
+		}
+		user.RecoveryCodes = slices.Delete(slices.Clone(user.RecoveryCodes), i, i+1)
+		return nil
+	})
+}
+
+// verifyWebAuthn checks the passkey assertion and updates the signature counter.
+func (s *UserStore) verifyWebAuthn(username string, assertion *WebAuthnAssertion) error {
+	user, ok := s.Get(username)
+	if !ok {
+		return ErrUserNotFound.Subject(username)
+	}
+	credID, signCount, err := verifyWebAuthnAssertion(&user, assertion)
+	if err != nil {
+		return err
+	}
+	return s.modify(username, func(user *User) error {
+		user.WebAuthn = slices.Clone(user.WebAuthn)
+		for i, c := range user.WebAuthn {
+			if c.ID == credID {
+				updated := *c
+				updated.SignCount = signCount
+				updated.LastUsedAt = time.Now()
+				user.WebAuthn[i] = &updated
+				return nil
+			}
+		}
+		return ErrUnknownCredential.Subject(credID)
+	})
+}
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/golang-jwt/jwt/v5"
+	httputils "github.com/yusing/goutils/http"
+)
+
+type (
+	// MFARequiredResponse is returned by the login callback with status 401
+	// when a second factor is required.
+	MFARequiredResponse struct {
+		Error   string      `json:"error"`
+		Methods []MFAMethod `json:"methods"`
+	} //	@name	MFARequiredResponse
+
+	MFATOTPRequest struct {
+		Code         string `json:"code"`          // TOTP code
+		RecoveryCode string `json:"recovery_code"` // used if code is empty
+	} //	@name	MFATOTPRequest
+
+	// mfaClaims identifies a user who passed the password check
+	// but has not yet passed the second factor.
+	mfaClaims struct {
+		Username string `json:"username"`
+		jwt.RegisteredClaims
+	}
+)
+
+const (
+	mfaCookieName = "godoxy_mfa"
+	mfaTokenTTL   = 5 * time.Minute
+)
+
+// mfaSecret derives the key of the pending MFA token from the session secret,
+// so that a pending token can never be used as a session token.
+func (auth *UserPassAuth) mfaSecret() []byte {
+	mac := hmac.New(sha256.New, auth.secret)
+	mac.Write([]byte("godoxy-mfa"))
+	return mac.Sum(nil)
+}
+
+// allowAttempt writes 429 and returns false if the login attempt is rate limited or locked out.
+func (auth *UserPassAuth) allowAttempt(w http.ResponseWriter, ip, username string) bool {
+	retryAfter, ok := auth.limiter.allow(ip, username)
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+		http.Error(w, "too many login attempts, try again later", http.StatusTooManyRequests)
+	}
+	return ok
+}
+
+// requireMFA issues the pending MFA token and responds with the enrolled methods.
+func (auth *UserPassAuth) requireMFA(w http.ResponseWriter, r *http.Request, user *User) {
+	claims := &mfaClaims{
+		Username: user.Username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaTokenTTL)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString(auth.mfaSecret())
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		httputils.LogError(r).Msg(fmt.Sprintf("failed to generate mfa token: %v", err))
+		return
+	}
+	SetTokenCookie(w, r, mfaCookieName, token, mfaTokenTTL)
+	writeJSON(w, http.StatusUnauthorized, &MFARequiredResponse{
+		Error:   "second factor required",
+		Methods: user.MFAMethods(),
+	})
+}
+
+// pendingMFAUser returns the username of the pending MFA token.
+func (auth *UserPassAuth) pendingMFAUser(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(mfaCookieName)
+	if err != nil {
+		return "", ErrMissingSessionToken
+	}
+	var claims mfaClaims
+	token, err := jwt.ParseWithClaims(cookie.Value, &claims, func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return auth.mfaSecret(), nil
+	}, jwt.WithExpirationRequired())
+	if err != nil {
+		return "", err
+	}
+	if !token.Valid {
+		return "", ErrInvalidSessionToken
+	}
+	return claims.Username, nil
+}
+
+// completeLogin issues the session token.
+func (auth *UserPassAuth) completeLogin(w http.ResponseWriter, r *http.Request, ip, username string) {
+	token, err := auth.NewToken(username)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		httputils.LogError(r).Msg(fmt.Sprintf("failed to generate token: %v", err))
+		return
+	}
+	auth.limiter.succeed(ip, username)
+	ClearTokenCookie(w, r, mfaCookieName)
+	SetTokenCookie(w, r, auth.TokenCookieName(), token, auth.tokenTTL)
+	w.WriteHeader(http.StatusOK)
+}
+
+// MFATOTPHandler completes the login with a TOTP or recovery code.
+func (auth *UserPassAuth) MFATOTPHandler(w http.ResponseWriter, r *http.Request) {
+	username, err := auth.pendingMFAUser(r)
+	if err != nil {
+		http.Error(w, "login required", http.StatusUnauthorized)
+		return
+	}
+	var req MFATOTPRequest
+	if err := sonic.ConfigDefault.NewDecoder(r.Body).Decode(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	ip := clientIP(r)
+	if !auth.allowAttempt(w, ip, username) {
+		return
+	}
+	if err := auth.users.verifyTOTP(username, req.Code, req.RecoveryCode); err != nil {
+		auth.limiter.fail(ip, username)
+		http.Error(w, "invalid code", http.StatusBadRequest)
+		return
+	}
+	auth.completeLogin(w, r, ip, username)
+}
+
+// WebAuthnBeginHandler responds with the options for navigator.credentials.get().
+func (auth *UserPassAuth) WebAuthnBeginHandler(w http.ResponseWriter, r *http.Request) {
+	username, err := auth.pendingMFAUser(r)
+	if err != nil {
+		http.Error(w, "login required", http.StatusUnauthorized)
+		return
+	}
+	user, ok := auth.users.Get(username)
+	if !ok || len(user.WebAuthn) == 0 {
+		http.Error(w, "no passkey registered", http.StatusBadRequest)
+		return
+	}
+	options, err := newWebAuthnRequestOptions(r, &user)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		httputils.LogError(r).Msg(fmt.Sprintf("failed to begin passkey login: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, options)
+}
+
+// WebAuthnFinishHandler completes the login with a passkey assertion.
+func (auth *UserPassAuth) WebAuthnFinishHandler(w http.ResponseWriter, r *http.Request) {
+	username, err := auth.pendingMFAUser(r)
+	if err != nil {
+		http.Error(w, "login required", http.StatusUnauthorized)
+		return
+	}
+	var assertion WebAuthnAssertion
+	if err := sonic.ConfigDefault.NewDecoder(r.Body).Decode(&assertion); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	ip := clientIP(r)
+	if !auth.allowAttempt(w, ip, username) {
+		return
+	}
+	if err := auth.users.verifyWebAuthn(username, &assertion); err != nil {
+		auth.limiter.fail(ip, username)
+		httputils.LogError(r).Msg(fmt.Sprintf("passkey verification failed for %s: %v", username, err))
+		http.Error(w, "invalid passkey", http.StatusBadRequest)
+		return
+	}
+	auth.completeLogin(w, r, ip, username)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = sonic.ConfigDefault.NewEncoder(w).Encode(v)
+}
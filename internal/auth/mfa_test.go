@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/go-webauthn/webauthn/protocol"
+	expect "github.com/yusing/goutils/testing"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 test vectors, truncated to 6 digits
+	key := []byte("12345678901234567890")
+	expect.Equal(t, totpCode(key, 59/totpPeriod), "287082")
+	expect.Equal(t, totpCode(key, 1111111109/totpPeriod), "081804")
+	expect.Equal(t, totpCode(key, 2000000000/totpPeriod), "279037")
+
+	secret := b32NoPadding.EncodeToString(key)
+	expect.Equal(t, validateTOTP(secret, "287082", time.Unix(59, 0)), int64(1))
+	expect.Equal(t, validateTOTP(secret, "287082", time.Unix(59+totpPeriod, 0)), int64(1)) // skew
+	expect.Equal(t, validateTOTP(secret, "287082", time.Unix(59+3*totpPeriod, 0)), int64(-1))
+	expect.Equal(t, validateTOTP(secret, "000000", time.Unix(59, 0)), int64(-1))
+}
+
+func newMFATestAuth(t *testing.T) (*UserPassAuth, *UserStore) {
+	t.Helper()
+	store, err := LoadUserStore(filepath.Join(t.TempDir(), "users.yml"))
+	expect.NoError(t, err)
+	expect.NoError(t, store.Add("alice", "password", RoleViewer))
+	t.Cleanup(func() {
+		totpLastStep.Clear()
+		pendingTOTPs.Clear()
+	})
+	auth := NewUserPassAuth(store, []byte("abcdefghijklmnopqrstuvwxyz"), time.Hour)
+	auth.limiter.rateBurst = 100 // all requests come from the same ip
+	return auth, store
+}
+
+func findCookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name && c.MaxAge >= 0 {
+			return c
+		}
+	}
+	return nil
+}
+
+func postJSON(t *testing.T, handler http.HandlerFunc, body any, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "https://godoxy.example.com/", bytes.NewReader(expect.Must(json.Marshal(body))))
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func loginPassword(t *testing.T, auth *UserPassAuth) *http.Cookie {
+	t.Helper()
+	w := postJSON(t, auth.PostAuthCallbackHandler, UserPassAuthCallbackRequest{User: "alice", Pass: "password"})
+	expect.Equal(t, w.Code, http.StatusUnauthorized)
+	expect.Nil(t, findCookie(w, auth.TokenCookieName()))
+	mfaCookie := findCookie(w, mfaCookieName)
+	expect.NotNil(t, mfaCookie)
+	return mfaCookie
+}
+
+func TestTOTPLogin(t *testing.T) {
+	auth, store := newMFATestAuth(t)
+
+	secret, uri, err := store.BeginTOTPEnrollment("alice")
+	expect.NoError(t, err)
+	expect.True(t, bytes.HasPrefix([]byte(uri), []byte("otpauth://totp/GoDoxy:alice?")))
+
+	key := expect.Must(decodeTOTPSecret(secret))
+	step := time.Now().Unix() / totpPeriod
+	_, err = store.ConfirmTOTPEnrollment("alice", "000000")
+	expect.ErrorIs(t, ErrInvalidMFACode, err)
+	recoveryCodes, err := store.ConfirmTOTPEnrollment("alice", totpCode(key, step))
+	expect.NoError(t, err)
+	expect.Equal(t, len(recoveryCodes), recoveryCodeCount)
+
+	mfaCookie := loginPassword(t, auth)
+
+	// the pending token is not a session token
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: auth.TokenCookieName(), Value: mfaCookie.Value})
+	_, err = auth.CurrentUser(req)
+	expect.HasError(t, err)
+
+	// code used for enrolment cannot be replayed
+	w := postJSON(t, auth.MFATOTPHandler, MFATOTPRequest{Code: totpCode(key, step)}, mfaCookie)
+	expect.Equal(t, w.Code, http.StatusBadRequest)
+
+	w = postJSON(t, auth.MFATOTPHandler, MFATOTPRequest{Code: totpCode(key, step+1)}, mfaCookie)
+	expect.Equal(t, w.Code, http.StatusOK)
+	expect.NotNil(t, findCookie(w, auth.TokenCookieName()))
+
+	// no pending token
+	w = postJSON(t, auth.MFATOTPHandler, MFATOTPRequest{RecoveryCode: recoveryCodes[0]})
+	expect.Equal(t, w.Code, http.StatusUnauthorized)
+
+	// recovery codes can be used once
+	mfaCookie = loginPassword(t, auth)
+	w = postJSON(t, auth.MFATOTPHandler, MFATOTPRequest{RecoveryCode: recoveryCodes[0]}, mfaCookie)
+	expect.Equal(t, w.Code, http.StatusOK)
+	w = postJSON(t, auth.MFATOTPHandler, MFATOTPRequest{RecoveryCode: recoveryCodes[0]}, mfaCookie)
+	expect.Equal(t, w.Code, http.StatusBadRequest)
+
+	status, err := store.MFAStatus("alice")
+	expect.NoError(t, err)
+	expect.True(t, status.TOTP)
+	expect.Equal(t, status.RecoveryCodes, recoveryCodeCount-1)
+
+	expect.ErrorIs(t, ErrInvalidPassword, store.DisableTOTP("alice", "wrong"))
+	expect.NoError(t, store.DisableTOTP("alice", "password"))
+	w = postJSON(t, auth.PostAuthCallbackHandler, UserPassAuthCallbackRequest{User: "alice", Pass: "password"})
+	expect.Equal(t, w.Code, http.StatusOK)
+}
+
+func TestLoginLockout(t *testing.T) {
+	auth, _ := newMFATestAuth(t)
+	auth.limiter = newLoginLimiter(3, time.Minute)
+
+	for range 3 {
+		w := postJSON(t, auth.PostAuthCallbackHandler, UserPassAuthCallbackRequest{User: "alice", Pass: "wrong"})
+		expect.Equal(t, w.Code, http.StatusBadRequest)
+	}
+	// locked out even with the correct password
+	w := postJSON(t, auth.PostAuthCallbackHandler, UserPassAuthCallbackRequest{User: "alice", Pass: "password"})
+	expect.Equal(t, w.Code, http.StatusTooManyRequests)
+	expect.True(t, w.Header().Get("Retry-After") != "")
+}
+
+func TestLoginLimiter(t *testing.T) {
+	l := newLoginLimiter(2, time.Minute)
+
+	// rate limit by ip
+	for range loginRateBurst {
+		_, ok := l.allow("10.0.0.1", "")
+		expect.True(t, ok)
+	}
+	_, ok := l.allow("10.0.0.1", "")
+	expect.False(t, ok)
+
+	// lockout by user from any ip
+	l.fail("10.0.0.2", "bob")
+	l.succeed("10.0.0.2", "bob")
+	l.fail("10.0.0.2", "bob")
+	_, ok = l.allow("10.0.0.3", "bob")
+	expect.True(t, ok)
+	l.fail("10.0.0.2", "bob")
+	retryAfter, ok := l.allow("10.0.0.3", "bob")
+	expect.False(t, ok)
+	expect.True(t, retryAfter > 0 && retryAfter <= time.Minute)
+	_, ok = l.allow("10.0.0.3", "carol")
+	expect.True(t, ok)
+}
+
+func TestWebAuthnLogin(t *testing.T) {
+	auth, store := newMFATestAuth(t)
+	const rpID = "godoxy.example.com"
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	clientData := func(ceremony protocol.CeremonyType, challenge protocol.URLEncodedBase64) []byte {
+		return expect.Must(json.Marshal(protocol.CollectedClientData{Type: ceremony, Challenge: challenge.String(), Origin: "https://" + rpID}))
+	}
+
+	key := expect.Must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+	coseKey := expect.Must(cbor.Marshal(map[int]any{
+		1: 2, 3: -7, -1: 1, // EC2, ES256, P-256
+		-2: key.X.FillBytes(make([]byte, 32)),
+		-3: key.Y.FillBytes(make([]byte, 32)),
+	}))
+	credID := []byte("credential-id")
+
+	// registration
+	req := httptest.NewRequest(http.MethodPost, "https://"+rpID+"/", nil)
+	creation, err := store.BeginWebAuthnRegistration(req, "alice")
+	expect.NoError(t, err)
+	expect.Equal(t, creation.RelyingParty.ID, rpID)
+
+	flags := byte(protocol.FlagUserPresent | protocol.FlagAttestedCredentialData)
+	authData := slices.Concat(rpIDHash[:], []byte{flags}, make([]byte, 4), make([]byte, 16),
+		binary.BigEndian.AppendUint16(nil, uint16(len(credID))), credID, coseKey)
+	reg := &WebAuthnRegistration{
+		PublicKeyCredential: protocol.PublicKeyCredential{
+			Credential: protocol.Credential{ID: b64url.EncodeToString(credID), Type: "public-key"},
+			RawID:      credID,
+		},
+		AttestationResponse: protocol.AuthenticatorAttestationResponse{
+			AuthenticatorResponse: protocol.AuthenticatorResponse{ClientDataJSON: clientData(protocol.CreateCeremony, creation.Challenge)},
+			AttestationObject: expect.Must(cbor.Marshal(map[string]any{
+				"fmt": "none", "attStmt": map[string]any{}, "authData": authData,
+			})),
+		},
+	}
+	info, recoveryCodes, err := store.FinishWebAuthnRegistration("alice", "laptop", reg)
+	expect.NoError(t, err)
+	expect.Equal(t, info.Name, "laptop")
+	expect.Equal(t, len(recoveryCodes), recoveryCodeCount)
+
+	// challenge is consumed
+	_, _, err = store.FinishWebAuthnRegistration("alice", "laptop", reg)
+	expect.ErrorIs(t, ErrWebAuthnChallenge, err)
+
+	// login
+	mfaCookie := loginPassword(t, auth)
+	w := postJSON(t, auth.WebAuthnBeginHandler, nil, mfaCookie)
+	expect.Equal(t, w.Code, http.StatusOK)
+	var options WebAuthnRequestOptions
+	expect.NoError(t, json.Unmarshal(w.Body.Bytes(), &options))
+	expect.Equal(t, options.AllowedCredentials[0].CredentialID.String(), reg.ID)
+
+	assert := func(challenge protocol.URLEncodedBase64, counter uint32) *WebAuthnAssertion {
+		clientData := clientData(protocol.AssertCeremony, challenge)
+		authData := slices.Concat(rpIDHash[:], []byte{byte(protocol.FlagUserPresent)}, binary.BigEndian.AppendUint32(nil, counter))
+		clientDataHash := sha256.Sum256(clientData)
+		digest := sha256.Sum256(slices.Concat(authData, clientDataHash[:]))
+		return &WebAuthnAssertion{
+			PublicKeyCredential: reg.PublicKeyCredential,
+			AssertionResponse: protocol.AuthenticatorAssertionResponse{
+				AuthenticatorResponse: protocol.AuthenticatorResponse{ClientDataJSON: clientData},
+				AuthenticatorData:     authData,
+				Signature:             expect.Must(ecdsa.SignASN1(rand.Reader, key, digest[:])),
+			},
+		}
+	}
+
+	w = postJSON(t, auth.WebAuthnFinishHandler, assert(options.Challenge, 1), mfaCookie)
+	expect.Equal(t, w.Code, http.StatusOK)
+	expect.NotNil(t, findCookie(w, auth.TokenCookieName()))
+
+	// replayed challenge
+	w = postJSON(t, auth.WebAuthnFinishHandler, assert(options.Challenge, 2), mfaCookie)
+	expect.Equal(t, w.Code, http.StatusBadRequest)
+
+	// signature counter must increase
+	mfaCookie = loginPassword(t, auth)
+	w = postJSON(t, auth.WebAuthnBeginHandler, nil, mfaCookie)
+	expect.NoError(t, json.Unmarshal(w.Body.Bytes(), &options))
+	w = postJSON(t, auth.WebAuthnFinishHandler, assert(options.Challenge, 1), mfaCookie)
+	expect.Equal(t, w.Code, http.StatusBadRequest)
+
+	expect.ErrorIs(t, ErrInvalidPassword, store.DeleteWebAuthnCredential("alice", "wrong", reg.ID))
+	expect.NoError(t, store.DeleteWebAuthnCredential("alice", "password", reg.ID))
+	status, err := store.MFAStatus("alice")
+	expect.NoError(t, err)
+	expect.Equal(t, len(status.WebAuthn), 0)
+	expect.Equal(t, status.RecoveryCodes, 0)
+}
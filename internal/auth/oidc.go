@@ -25,6 +25,8 @@ import (
 
 type (
 	OIDCProvider struct {
+		issuerURL     string
+		cookieDomain  string // overrides the cookie domain derived from the request host
 		oauthConfig   *oauth2.Config
 		oidcProvider  *oidc.Provider
 		oidcVerifier  *oidc.IDTokenVerifier
@@ -57,7 +59,12 @@ func (auth *OIDCProvider) getAppScopedCookieName(baseName string) string {
 	// This prevents conflicts when multiple apps use different client IDs
 	if auth.oauthConfig.ClientID != "" {
 		// Create a hash of the client ID to keep cookie names short
-		hash := sha256.Sum256([]byte(auth.oauthConfig.ClientID))
+		// Issuers other than the global one are included, as client IDs are only unique per issuer
+		scope := auth.oauthConfig.ClientID
+		if auth.issuerURL != "" && auth.issuerURL != common.OIDCIssuerURL {
+			scope = auth.issuerURL + " " + scope
+		}
+		hash := sha256.Sum256([]byte(scope))
 		clientHash := base64.URLEncoding.EncodeToString(hash[:])[:8]
 		return fmt.Sprintf("%s_%s", baseName, clientHash)
 	}
@@ -92,11 +99,9 @@ func NewOIDCProvider(issuerURL, clientID, clientSecret string, allowedUsers, all
 		return nil, errors.New("oidc.allowed_users or oidc.allowed_groups are both empty")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	provider, err := oidc.NewProvider(ctx, issuerURL)
+	provider, err := discoverOIDCIssuer(issuerURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize OIDC provider: %w", err)
+		return nil, err
 	}
 
 	endSessionURL, err := url.Parse(provider.EndSessionEndpoint())
@@ -109,6 +114,7 @@ func NewOIDCProvider(issuerURL, clientID, clientSecret string, allowedUsers, all
 	}
 
 	return &OIDCProvider{
+		issuerURL: issuerURL,
 		oauthConfig: &oauth2.Config{
 			ClientID:     clientID,
 			ClientSecret: clientSecret,
@@ -159,6 +165,8 @@ func NewOIDCProviderWithCustomClient(baseProvider *OIDCProvider, clientID, clien
 	}
 
 	return &OIDCProvider{
+		issuerURL:     baseProvider.issuerURL,
+		cookieDomain:  baseProvider.cookieDomain,
 		oauthConfig:   oauthConfig,
 		oidcProvider:  baseProvider.oidcProvider,
 		oidcVerifier:  oidcVerifier,
@@ -180,6 +188,29 @@ func (auth *OIDCProvider) SetScopes(scopes []string) {
 	auth.oauthConfig.Scopes = scopes
 }
 
+// SetCookieDomain sets the domain of the cookies, e.g. ".example.com" to share the login across subdomains.
+//
+// If empty, the domain is derived from the request host.
+func (auth *OIDCProvider) SetCookieDomain(domain string) {
+	auth.cookieDomain = domain
+}
+
+func (auth *OIDCProvider) setCookie(w http.ResponseWriter, r *http.Request, name, value string, ttl time.Duration) {
+	if auth.cookieDomain != "" {
+		setTokenCookie(w, auth.cookieDomain, name, value, ttl)
+	} else {
+		SetTokenCookie(w, r, name, value, ttl)
+	}
+}
+
+func (auth *OIDCProvider) deleteCookie(w http.ResponseWriter, r *http.Request, name string) {
+	if auth.cookieDomain != "" {
+		clearTokenCookie(w, auth.cookieDomain, name)
+	} else {
+		ClearTokenCookie(w, r, name)
+	}
+}
+
 func (auth *OIDCProvider) SetOnUnknownPathHandler(handler http.HandlerFunc) {
 	auth.onUnknownPathHandler = handler
 }
@@ -256,7 +287,7 @@ func (auth *OIDCProvider) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	state := generateState()
-	SetTokenCookie(w, r, auth.getAppScopedCookieName(CookieOauthState), state, 300*time.Second)
+	auth.setCookie(w, r, auth.getAppScopedCookieName(CookieOauthState), state, 300*time.Second)
 	// redirect user to Idp
 	url := auth.oauthConfig.AuthCodeURL(state, optRedirectPostAuth(r))
 	http.Redirect(w, r, url, http.StatusFound)
@@ -287,25 +318,52 @@ func (auth *OIDCProvider) checkAllowed(user string, groups []string) bool {
 }
 
 func (auth *OIDCProvider) CheckToken(r *http.Request) error {
+	_, err := auth.CurrentUser(r)
+	return err
+}
+
+func (auth *OIDCProvider) CurrentUser(r *http.Request) (*UserInfo, error) {
+	user, _, err := auth.CurrentUserClaims(r)
+	return user, err
+}
+
+// CurrentUserClaims returns the user and all claims of the ID token of the request.
+func (auth *OIDCProvider) CurrentUserClaims(r *http.Request) (*UserInfo, map[string]any, error) {
 	tokenCookie, err := r.Cookie(auth.getAppScopedCookieName(CookieOauthToken))
 	if err != nil {
-		return ErrMissingOAuthToken
+		return nil, nil, ErrMissingOAuthToken
 	}
 
 	idToken, err := auth.oidcVerifier.Verify(r.Context(), tokenCookie.Value)
 	if err != nil {
-		return fmt.Errorf("%w: %w", ErrInvalidOAuthToken, err)
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidOAuthToken, err)
 	}
 
 	claims, err := parseClaims(idToken)
 	if err != nil {
-		return fmt.Errorf("%w: %w", ErrInvalidOAuthToken, err)
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidOAuthToken, err)
 	}
 
 	if !auth.checkAllowed(claims.Username, claims.Groups) {
-		return ErrUserNotAllowed
+		return nil, nil, ErrUserNotAllowed
+	}
+
+	var allClaims map[string]any
+	if err := idToken.Claims(&allClaims); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidOAuthToken, err)
+	}
+
+	// roles only apply to the API, route level OIDC has no user store
+	role := RoleAdmin
+	if userStore != nil {
+		role = userStore.RoleForGroups(claims.Groups)
 	}
-	return nil
+	return &UserInfo{
+		Username: claims.Username,
+		Groups:   claims.Groups,
+		Role:     role,
+		Origin:   UserOriginOIDC,
+	}, allClaims, nil
 }
 
 func (auth *OIDCProvider) PostAuthCallbackHandler(w http.ResponseWriter, r *http.Request) {
@@ -387,12 +445,12 @@ func (auth *OIDCProvider) LogoutHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (auth *OIDCProvider) setIDTokenCookie(w http.ResponseWriter, r *http.Request, jwt string, ttl time.Duration) {
-	SetTokenCookie(w, r, auth.getAppScopedCookieName(CookieOauthToken), jwt, ttl)
+	auth.setCookie(w, r, auth.getAppScopedCookieName(CookieOauthToken), jwt, ttl)
 }
 
 func (auth *OIDCProvider) clearCookie(w http.ResponseWriter, r *http.Request) {
-	ClearTokenCookie(w, r, auth.getAppScopedCookieName(CookieOauthToken))
-	ClearTokenCookie(w, r, auth.getAppScopedCookieName(CookieOauthSessionToken))
+	auth.deleteCookie(w, r, auth.getAppScopedCookieName(CookieOauthToken))
+	auth.deleteCookie(w, r, auth.getAppScopedCookieName(CookieOauthSessionToken))
 }
 
 // handleTestCallback handles OIDC callback in test environment.
@@ -409,7 +467,7 @@ func (auth *OIDCProvider) handleTestCallback(w http.ResponseWriter, r *http.Requ
 	}
 
 	// Create test JWT token
-	SetTokenCookie(w, r, auth.getAppScopedCookieName(CookieOauthToken), "test", time.Hour)
+	auth.setCookie(w, r, auth.getAppScopedCookieName(CookieOauthToken), "test", time.Hour)
 
 	http.Redirect(w, r, "/", http.StatusFound)
 }
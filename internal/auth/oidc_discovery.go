@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+// discovered providers by issuer URL.
+//
+// Verifiers created from the same provider share its JWKS key set,
+// so keys are fetched and cached once per issuer no matter how many
+// clients (routes) use it.
+var (
+	oidcIssuers   = xsync.NewMap[string, *oidc.Provider]()
+	oidcIssuersMu sync.Mutex
+)
+
+const oidcDiscoveryTimeout = 5 * time.Second
+
+// discoverOIDCIssuer returns the cached provider of the issuer,
+// performing OIDC discovery on first use.
+//
+// Failed discoveries are not cached and will be retried on next call.
+func discoverOIDCIssuer(issuerURL string) (*oidc.Provider, error) {
+	if provider, ok := oidcIssuers.Load(issuerURL); ok {
+		return provider, nil
+	}
+
+	oidcIssuersMu.Lock()
+	defer oidcIssuersMu.Unlock()
+	if provider, ok := oidcIssuers.Load(issuerURL); ok {
+		return provider, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oidcDiscoveryTimeout)
+	defer cancel()
+	provider, err := oidc.NewProvider(ctx, issuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize OIDC provider: %w", err)
+	}
+	oidcIssuers.Store(issuerURL, provider)
+	return provider, nil
+}
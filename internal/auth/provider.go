@@ -4,6 +4,7 @@ import "net/http"
 
 type Provider interface {
 	CheckToken(r *http.Request) error
+	CurrentUser(r *http.Request) (*UserInfo, error)
 	LoginHandler(w http.ResponseWriter, r *http.Request)
 	PostAuthCallbackHandler(w http.ResponseWriter, r *http.Request)
 	LogoutHandler(w http.ResponseWriter, r *http.Request)
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	gperr "github.com/yusing/goutils/errs"
+)
+
+type (
+	Role string
+
+	// UserOrigin is the auth backend a user comes from.
+	UserOrigin string
+
+	// UserInfo is the identity of an authenticated request.
+	UserInfo struct {
+		Username string     `json:"username"`
+		Groups   []string   `json:"groups,omitempty"`
+		Role     Role       `json:"role"`
+		Origin   UserOrigin `json:"origin,omitempty"`
+
+		// set only when authenticated by an API token
+		Scopes  []TokenScope `json:"scopes,omitempty"`
+		TokenID string       `json:"token_id,omitempty"`
+	} //	@name	UserInfo
+)
+
+const (
+	RoleViewer   Role = "viewer"   // read only
+	RoleOperator Role = "operator" // viewer, plus reload, container control and homepage changes
+	RoleAdmin    Role = "admin"    // full access, including config files, agents and users
+)
+
+const (
+	UserOriginLocal UserOrigin = "local" // users file
+	UserOriginOIDC  UserOrigin = "oidc"
+	UserOriginLDAP  UserOrigin = "ldap"
+)
+
+var ErrInvalidRole = gperr.New("invalid role")
+
+func (r Role) level() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleOperator:
+		return 2
+	case RoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+// IsValid returns whether the role is one of viewer, operator and admin.
+func (r Role) IsValid() bool {
+	return r.level() > 0
+}
+
+// Allows returns whether the role has at least the permissions of the required role.
+func (r Role) Allows(required Role) bool {
+	return r.level() >= required.level()
+}
+
+// IsEnabled returns whether the auth backend is configured.
+func (o UserOrigin) IsEnabled() bool {
+	switch o {
+	case UserOriginOIDC:
+		return IsOIDCEnabled()
+	case UserOriginLDAP:
+		return IsLDAPEnabled()
+	}
+	return true
+}
@@ -0,0 +1,375 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+	"github.com/yusing/godoxy/internal/common"
+	"github.com/yusing/godoxy/internal/jsonstore"
+	gperr "github.com/yusing/goutils/errs"
+)
+
+type (
+	// SignedURLInfo describes a minted signed URL.
+	SignedURLInfo struct {
+		ID         string    `json:"id"`
+		URL        string    `json:"url"`
+		PathPrefix string    `json:"path_prefix"`
+		CreatedBy  string    `json:"created_by,omitempty"`
+		ExpiresAt  time.Time `json:"expires_at"`
+		MaxUses    int       `json:"max_uses,omitempty"` // zero means unlimited
+	} //	@name	SignedURLInfo
+
+	signedURLUsage struct {
+		Uses      int       `json:"uses"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	signedURLKey struct{}
+)
+
+// query parameters of signed URLs
+const (
+	SignedURLParamID        = "gdx_id"
+	SignedURLParamExpires   = "gdx_expires"
+	SignedURLParamPrefix    = "gdx_prefix"
+	SignedURLParamMaxUses   = "gdx_uses"
+	SignedURLParamSignature = "gdx_sig"
+)
+
+const signedURLIDLen = 8 // bytes
+
+// signedURLCookieName is the cookie set after the first valid use of a signed URL without a usage limit,
+// so subresources of the shared page are allowed.
+const (
+	signedURLCookieName = "godoxy_signed_url"
+	signedURLCookieTTL  = 10 * time.Minute
+)
+
+var (
+	ErrSignedURLDisabled     = gperr.New("signed URLs require API_JWT_SECRET")
+	ErrMissingSignature      = gperr.New("missing signature")
+	ErrInvalidSignature      = gperr.New("invalid signature")
+	ErrSignedURLExpired      = gperr.New("signed URL expired")
+	ErrSignedURLRevoked      = gperr.New("signed URL revoked")
+	ErrSignedURLUsedUp       = gperr.New("signed URL has reached its usage limit")
+	ErrSignedURLPathMismatch = gperr.New("path is not allowed by the signed URL")
+)
+
+var (
+	// id -> expiry, pruned once expired since expired URLs are rejected anyway
+	signedURLRevocations = jsonstore.Store[time.Time]("signed_url_revocations")
+	// id -> usage of signed URLs with a usage limit
+	signedURLUsages = jsonstore.Store[*signedURLUsage]("signed_url_usages")
+)
+
+// CreateSignedURL signs rawURL so that requests to the same host under pathPrefix
+// are allowed without other authentication until expiresAt.
+//
+// pathPrefix defaults to the path of rawURL, maxUses of zero means unlimited.
+func CreateSignedURL(rawURL, pathPrefix string, expiresAt time.Time, maxUses int, createdBy string) (*SignedURLInfo, error) {
+	key := signedURLKeyFromSecret()
+	if key == nil {
+		return nil, ErrSignedURLDisabled
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, gperr.New("url must be absolute").Subject(rawURL)
+	}
+	if !expiresAt.After(time.Now()) {
+		return nil, ErrSignedURLExpired.Subject("expires_at is in the past")
+	}
+	if maxUses < 0 {
+		return nil, gperr.New("max_uses must not be negative")
+	}
+	if pathPrefix == "" {
+		pathPrefix = u.Path
+	}
+	pathPrefix = cleanPath(pathPrefix)
+	if !hasPathPrefix(cleanPath(u.Path), pathPrefix) {
+		return nil, ErrSignedURLPathMismatch.Subject(u.Path)
+	}
+
+	pruneSignedURLs()
+
+	id := randomHex(signedURLIDLen)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	uses := strconv.Itoa(maxUses)
+
+	query := u.Query()
+	query.Set(SignedURLParamID, id)
+	query.Set(SignedURLParamExpires, expires)
+	query.Set(SignedURLParamPrefix, pathPrefix)
+	if maxUses > 0 {
+		query.Set(SignedURLParamMaxUses, uses)
+	} else {
+		query.Del(SignedURLParamMaxUses)
+	}
+	query.Set(SignedURLParamSignature, signURL(key, id, hostname(u.Host), pathPrefix, expires, uses))
+	u.RawQuery = query.Encode()
+
+	return &SignedURLInfo{
+		ID:         id,
+		URL:        u.String(),
+		PathPrefix: pathPrefix,
+		CreatedBy:  createdBy,
+		ExpiresAt:  time.Unix(expiresAt.Unix(), 0),
+		MaxUses:    maxUses,
+	}, nil
+}
+
+// RevokeSignedURL adds the signed URL to the revocation list until it expires.
+func RevokeSignedURL(rawURL string) (*SignedURLInfo, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	info, err := parseSignedURL(u, hostname(u.Host))
+	if err != nil {
+		return nil, err
+	}
+	signedURLRevocations.Store(info.ID, info.ExpiresAt)
+	signedURLUsages.Delete(info.ID)
+	return info, nil
+}
+
+// CheckSignedURL validates the signed URL of the request and counts a use.
+//
+// On success the signed URL parameters are removed before the request is proxied.
+// For signed URLs without a usage limit, a short-lived cookie scoped to the path prefix is set,
+// so subresources of the shared page are allowed. Signed URLs with a usage limit get no cookie,
+// as it would allow requests beyond max_uses.
+//
+// The result is remembered in the request, so later checks of the same request,
+// e.g. by middleware bypass rules and require_auth, do not count another use.
+func CheckSignedURL(w http.ResponseWriter, r *http.Request) error {
+	if id, ok := r.Context().Value(signedURLKey{}).(string); ok && id != "" {
+		return nil
+	}
+
+	if !r.URL.Query().Has(SignedURLParamSignature) {
+		id, err := checkSignedURLCookie(r)
+		if err != nil {
+			return err
+		}
+		*r = *r.WithContext(context.WithValue(r.Context(), signedURLKey{}, id))
+		return nil
+	}
+
+	info, err := parseSignedURL(r.URL, hostname(r.Host))
+	if err != nil {
+		return err
+	}
+	if time.Now().After(info.ExpiresAt) {
+		return ErrSignedURLExpired
+	}
+	if !hasPathPrefix(cleanPath(r.URL.Path), info.PathPrefix) {
+		return ErrSignedURLPathMismatch.Subject(r.URL.Path)
+	}
+	if _, ok := signedURLRevocations.Load(info.ID); ok {
+		return ErrSignedURLRevoked
+	}
+
+	if info.MaxUses > 0 {
+		usedUp := false
+		signedURLUsages.Compute(info.ID, func(old *signedURLUsage, loaded bool) (*signedURLUsage, xsync.ComputeOp) {
+			usage := signedURLUsage{ExpiresAt: info.ExpiresAt}
+			if loaded {
+				usage = *old
+			}
+			if usage.Uses >= info.MaxUses {
+				usedUp = true
+				return old, xsync.CancelOp
+			}
+			usage.Uses++
+			return &usage, xsync.UpdateOp
+		})
+		if usedUp {
+			return ErrSignedURLUsedUp
+		}
+	}
+
+	if info.MaxUses == 0 {
+		setSignedURLCookie(w, r, info)
+	}
+	stripSignedURLParams(r.URL)
+	*r = *r.WithContext(context.WithValue(r.Context(), signedURLKey{}, info.ID))
+	return nil
+}
+
+// setSignedURLCookie sets the cookie of the signed URL, expiring no later than the signed URL.
+func setSignedURLCookie(w http.ResponseWriter, r *http.Request, info *SignedURLInfo) {
+	expiresAt := time.Now().Add(signedURLCookieTTL)
+	if info.ExpiresAt.Before(expiresAt) {
+		expiresAt = info.ExpiresAt
+	}
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	prefix := base64.RawURLEncoding.EncodeToString([]byte(info.PathPrefix))
+	sig := signURLCookie(signedURLKeyFromSecret(), info.ID, hostname(r.Host), info.PathPrefix, expires)
+	http.SetCookie(w, &http.Cookie{
+		Name:     signedURLCookieName,
+		Value:    strings.Join([]string{info.ID, expires, prefix, sig}, "."),
+		Path:     info.PathPrefix,
+		Expires:  expiresAt,
+		MaxAge:   int(time.Until(expiresAt).Seconds()),
+		HttpOnly: true,
+		Secure:   common.APIJWTSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// checkSignedURLCookie returns the signed URL id of a valid cookie of the request.
+func checkSignedURLCookie(r *http.Request) (string, error) {
+	key := signedURLKeyFromSecret()
+	var err error = ErrMissingSignature
+	// cookies of signed URLs with different path prefixes share the same name
+	for _, cookie := range r.CookiesNamed(signedURLCookieName) {
+		parts := strings.Split(cookie.Value, ".")
+		if len(parts) != 4 {
+			continue
+		}
+		id, expires, sig := parts[0], parts[1], parts[3]
+		prefix, decodeErr := base64.RawURLEncoding.DecodeString(parts[2])
+		if key == nil || decodeErr != nil || !hmac.Equal([]byte(sig), []byte(signURLCookie(key, id, hostname(r.Host), string(prefix), expires))) {
+			err = ErrInvalidSignature
+			continue
+		}
+		expiresUnix, parseErr := strconv.ParseInt(expires, 10, 64)
+		_, revoked := signedURLRevocations.Load(id)
+		switch {
+		case parseErr != nil:
+			err = ErrInvalidSignature
+		case time.Now().After(time.Unix(expiresUnix, 0)):
+			err = ErrSignedURLExpired
+		case !hasPathPrefix(cleanPath(r.URL.Path), string(prefix)):
+			err = ErrSignedURLPathMismatch.Subject(r.URL.Path)
+		case revoked:
+			err = ErrSignedURLRevoked
+		default:
+			return id, nil
+		}
+	}
+	return "", err
+}
+
+// stripSignedURLParams removes the signed URL parameters so they are not sent upstream.
+func stripSignedURLParams(u *url.URL) {
+	query := u.Query()
+	for _, param := range []string{SignedURLParamID, SignedURLParamExpires, SignedURLParamPrefix, SignedURLParamMaxUses, SignedURLParamSignature} {
+		query.Del(param)
+	}
+	u.RawQuery = query.Encode()
+}
+
+// parseSignedURL returns the signed URL info if the signature of u is valid for the host.
+func parseSignedURL(u *url.URL, host string) (*SignedURLInfo, error) {
+	query := u.Query()
+	sig := query.Get(SignedURLParamSignature)
+	if sig == "" {
+		return nil, ErrMissingSignature
+	}
+	key := signedURLKeyFromSecret()
+	if key == nil {
+		return nil, ErrSignedURLDisabled
+	}
+
+	id := query.Get(SignedURLParamID)
+	expires := query.Get(SignedURLParamExpires)
+	prefix := query.Get(SignedURLParamPrefix)
+	uses := query.Get(SignedURLParamMaxUses)
+	if uses == "" {
+		uses = "0"
+	}
+	if !hmac.Equal([]byte(sig), []byte(signURL(key, id, host, prefix, expires, uses))) {
+		return nil, ErrInvalidSignature
+	}
+
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	maxUses, err := strconv.Atoi(uses)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	return &SignedURLInfo{
+		ID:         id,
+		URL:        u.String(),
+		PathPrefix: prefix,
+		ExpiresAt:  time.Unix(expiresUnix, 0),
+		MaxUses:    maxUses,
+	}, nil
+}
+
+// pruneSignedURLs removes revocations and usages of expired signed URLs.
+func pruneSignedURLs() {
+	now := time.Now()
+	for id, expiresAt := range signedURLRevocations.Range {
+		if now.After(expiresAt) {
+			signedURLRevocations.Delete(id)
+		}
+	}
+	for id, usage := range signedURLUsages.Range {
+		if now.After(usage.ExpiresAt) {
+			signedURLUsages.Delete(id)
+		}
+	}
+}
+
+func signURL(key []byte, id, host, prefix, expires, maxUses string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.Join([]string{id, strings.ToLower(host), prefix, expires, maxUses}, "\n")))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signURLCookie(key []byte, id, host, prefix, expires string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.Join([]string{"cookie", id, strings.ToLower(host), prefix, expires}, "\n")))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signedURLKeyFromSecret derives the signing key from API_JWT_SECRET,
+// so signed URLs cannot be used as session tokens and vice versa.
+func signedURLKeyFromSecret() []byte {
+	if common.APIJWTSecret == nil {
+		return nil
+	}
+	mac := hmac.New(sha256.New, common.APIJWTSecret)
+	mac.Write([]byte("godoxy-signed-url"))
+	return mac.Sum(nil)
+}
+
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	return path.Clean("/" + p)
+}
+
+// hasPathPrefix returns whether p is prefix or under the directory prefix.
+func hasPathPrefix(p, prefix string) bool {
+	if prefix == "/" || p == prefix {
+		return true
+	}
+	return strings.HasPrefix(p, strings.TrimSuffix(prefix, "/")+"/")
+}
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yusing/godoxy/internal/common"
+	expect "github.com/yusing/goutils/testing"
+)
+
+func TestSignedURL(t *testing.T) {
+	common.APIJWTSecret = []byte("test-secret")
+	t.Cleanup(func() {
+		common.APIJWTSecret = nil
+		signedURLRevocations.Clear()
+		signedURLUsages.Clear()
+	})
+
+	info, err := CreateSignedURL("https://files.example.com/share/report.pdf", "/share", time.Now().Add(time.Hour), 2, "alice")
+	expect.NoError(t, err)
+	expect.Equal(t, info.PathPrefix, "/share")
+	expect.Equal(t, info.CreatedBy, "alice")
+
+	check := func(rawURL string) error {
+		return CheckSignedURL(httptest.NewRecorder(), httptest.NewRequest("GET", rawURL, nil))
+	}
+
+	// the same request is only counted once
+	r := httptest.NewRequest("GET", info.URL, nil)
+	expect.NoError(t, CheckSignedURL(httptest.NewRecorder(), r))
+	expect.NoError(t, CheckSignedURL(httptest.NewRecorder(), r))
+
+	expect.NoError(t, check(info.URL))
+	expect.ErrorIs(t, ErrSignedURLUsedUp, check(info.URL))
+
+	// tampering
+	expect.ErrorIs(t, ErrMissingSignature, check("https://files.example.com/share/report.pdf"))
+	expect.ErrorIs(t, ErrInvalidSignature, check(strings.Replace(info.URL, "gdx_uses=2", "gdx_uses=100", 1)))
+
+	// other hosts are not allowed
+	r = httptest.NewRequest("GET", info.URL, nil)
+	r.Host = "other.example.com"
+	expect.ErrorIs(t, ErrInvalidSignature, CheckSignedURL(httptest.NewRecorder(), r))
+}
+
+func TestSignedURLPathPrefix(t *testing.T) {
+	common.APIJWTSecret = []byte("test-secret")
+	t.Cleanup(func() {
+		common.APIJWTSecret = nil
+		signedURLRevocations.Clear()
+		signedURLUsages.Clear()
+	})
+
+	info, err := CreateSignedURL("https://files.example.com/share/", "", time.Now().Add(time.Hour), 0, "")
+	expect.NoError(t, err)
+
+	r := httptest.NewRequest("GET", info.URL, nil)
+	r.URL.Path = "/share/sub/file.txt"
+	expect.NoError(t, CheckSignedURL(httptest.NewRecorder(), r))
+
+	for _, path := range []string{"/shared", "/share/../secret", "/"} {
+		r = httptest.NewRequest("GET", info.URL, nil)
+		r.URL.Path = path
+		expect.ErrorIs(t, ErrSignedURLPathMismatch, CheckSignedURL(httptest.NewRecorder(), r))
+	}
+
+	_, err = CreateSignedURL("https://files.example.com/other", "/share", time.Now().Add(time.Hour), 0, "")
+	expect.ErrorIs(t, ErrSignedURLPathMismatch, err)
+}
+
+func TestSignedURLRevokeAndExpiry(t *testing.T) {
+	common.APIJWTSecret = []byte("test-secret")
+	t.Cleanup(func() {
+		common.APIJWTSecret = nil
+		signedURLRevocations.Clear()
+		signedURLUsages.Clear()
+	})
+
+	info, err := CreateSignedURL("https://files.example.com/a", "", time.Now().Add(time.Hour), 0, "")
+	expect.NoError(t, err)
+	expect.NoError(t, CheckSignedURL(httptest.NewRecorder(), httptest.NewRequest("GET", info.URL, nil)))
+
+	revoked, err := RevokeSignedURL(info.URL)
+	expect.NoError(t, err)
+	expect.Equal(t, revoked.ID, info.ID)
+	expect.ErrorIs(t, ErrSignedURLRevoked, CheckSignedURL(httptest.NewRecorder(), httptest.NewRequest("GET", info.URL, nil)))
+
+	_, err = RevokeSignedURL("https://files.example.com/a?gdx_id=x&gdx_sig=y")
+	expect.ErrorIs(t, ErrInvalidSignature, err)
+
+	_, err = CreateSignedURL("https://files.example.com/a", "", time.Now().Add(-time.Second), 0, "")
+	expect.ErrorIs(t, ErrSignedURLExpired, err)
+
+	common.APIJWTSecret = nil
+	_, err = CreateSignedURL("https://files.example.com/a", "", time.Now().Add(time.Hour), 0, "")
+	expect.ErrorIs(t, ErrSignedURLDisabled, err)
+}
+
+func TestSignedURLCookie(t *testing.T) {
+	common.APIJWTSecret = []byte("test-secret")
+	t.Cleanup(func() {
+		common.APIJWTSecret = nil
+		signedURLRevocations.Clear()
+		signedURLUsages.Clear()
+	})
+
+	info, err := CreateSignedURL("https://files.example.com/share/index.html?lang=en", "/share", time.Now().Add(time.Hour), 0, "")
+	expect.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", info.URL, nil)
+	expect.NoError(t, CheckSignedURL(w, r))
+	// signed URL parameters are not sent upstream
+	expect.Equal(t, r.URL.RawQuery, "lang=en")
+
+	cookies := w.Result().Cookies()
+	expect.Equal(t, len(cookies), 1)
+	expect.Equal(t, cookies[0].Name, signedURLCookieName)
+	expect.Equal(t, cookies[0].Path, "/share")
+	expect.True(t, cookies[0].HttpOnly)
+	expect.True(t, cookies[0].MaxAge > 0 && cookies[0].MaxAge <= int(signedURLCookieTTL.Seconds()))
+
+	check := func(path string, cookie *http.Cookie) error {
+		r := httptest.NewRequest("GET", "https://files.example.com"+path, nil)
+		r.AddCookie(cookie)
+		return CheckSignedURL(httptest.NewRecorder(), r)
+	}
+
+	// subresources are allowed
+	expect.NoError(t, check("/share/style.css", cookies[0]))
+	expect.NoError(t, check("/share/app.js", cookies[0]))
+
+	expect.ErrorIs(t, ErrSignedURLPathMismatch, check("/secret", cookies[0]))
+
+	tampered := *cookies[0]
+	tampered.Value = strings.Replace(tampered.Value, ".", "x.", 1)
+	expect.ErrorIs(t, ErrInvalidSignature, check("/share/style.css", &tampered))
+
+	r = httptest.NewRequest("GET", "https://other.example.com/share/style.css", nil)
+	r.AddCookie(cookies[0])
+	expect.ErrorIs(t, ErrInvalidSignature, CheckSignedURL(httptest.NewRecorder(), r))
+
+	_, err = RevokeSignedURL(info.URL)
+	expect.NoError(t, err)
+	expect.ErrorIs(t, ErrSignedURLRevoked, check("/share/style.css", cookies[0]))
+}
+
+func TestSignedURLMaxUsesNoCookie(t *testing.T) {
+	common.APIJWTSecret = []byte("test-secret")
+	t.Cleanup(func() {
+		common.APIJWTSecret = nil
+		signedURLRevocations.Clear()
+		signedURLUsages.Clear()
+	})
+
+	const maxUses = 2
+	info, err := CreateSignedURL("https://files.example.com/share/index.html", "/share", time.Now().Add(time.Hour), maxUses, "")
+	expect.NoError(t, err)
+
+	var cookies []*http.Cookie
+	for range maxUses {
+		w := httptest.NewRecorder()
+		expect.NoError(t, CheckSignedURL(w, httptest.NewRequest("GET", info.URL, nil)))
+		cookies = append(cookies, w.Result().Cookies()...)
+	}
+	// no cookie to bypass the usage limit
+	expect.Equal(t, len(cookies), 0)
+
+	r := httptest.NewRequest("GET", "https://files.example.com/share/index.html", nil)
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+	expect.ErrorIs(t, ErrMissingSignature, CheckSignedURL(httptest.NewRecorder(), r))
+	expect.ErrorIs(t, ErrSignedURLUsedUp, CheckSignedURL(httptest.NewRecorder(), httptest.NewRequest("GET", info.URL, nil)))
+}
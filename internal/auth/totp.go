@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+// TOTP (RFC 6238) with the parameters supported by all authenticator apps:
+// HMAC-SHA1, 6 digits and 30 seconds period.
+
+const (
+	totpIssuer = "GoDoxy"
+	totpDigits = 6
+	totpPeriod = 30 // seconds
+	totpSkew   = 1  // accepted steps before and after the current one
+
+	recoveryCodeCount = 10
+)
+
+var b32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// last accepted step by username, a code is accepted only once
+var totpLastStep = xsync.NewMap[string, int64]()
+
+// newTOTPSecret returns a random base32 encoded 160 bit secret.
+func newTOTPSecret() string {
+	b := make([]byte, 20)
+	_, _ = rand.Read(b)
+	return b32NoPadding.EncodeToString(b)
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	return b32NoPadding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+}
+
+// totpURI returns the otpauth:// URI to be rendered as a QR code.
+func totpURI(username, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", totpIssuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + url.PathEscape(totpIssuer+":"+username) + "?" + q.Encode()
+}
+
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, code%1_000_000)
+}
+
+// validateTOTP returns the matched step if code is valid for secret at now, or -1 otherwise.
+func validateTOTP(secret, code string, now time.Time) int64 {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil || len(code) != totpDigits {
+		return -1
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step
+		}
+	}
+	return -1
+}
+
+// checkTOTP validates the code of the user and rejects replays of used codes.
+func checkTOTP(username, secret, code string) bool {
+	step := validateTOTP(secret, strings.TrimSpace(code), time.Now())
+	if step < 0 {
+		return false
+	}
+	accepted := false
+	totpLastStep.Compute(username, func(last int64, loaded bool) (int64, xsync.ComputeOp) {
+		if loaded && step <= last {
+			return last, xsync.CancelOp
+		}
+		accepted = true
+		return step, xsync.UpdateOp
+	})
+	return accepted
+}
+
+// newRecoveryCodes returns recovery codes in plain text and their hashes.
+func newRecoveryCodes() (codes, hashes []string) {
+	codes = make([]string, recoveryCodeCount)
+	hashes = make([]string, recoveryCodeCount)
+	for i := range codes {
+		code := randomHex(5)
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
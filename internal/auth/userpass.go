@@ -9,9 +9,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/yusing/godoxy/internal/common"
 	gperr "github.com/yusing/goutils/errs"
-	httputils "github.com/yusing/goutils/http"
 	strutils "github.com/yusing/goutils/strings"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -21,36 +19,32 @@ var (
 
 type (
 	UserPassAuth struct {
-		username string
-		pwdHash  []byte
+		users    *UserStore
 		secret   []byte
 		tokenTTL time.Duration
+		limiter  *loginLimiter
 	}
 	UserPassClaims struct {
 		Username string `json:"username"`
+		Version  int    `json:"ver,omitempty"` // User.TokenVersion when issued
 		jwt.RegisteredClaims
 	}
 )
 
 var _ Provider = (*UserPassAuth)(nil)
 
-func NewUserPassAuth(username, password string, secret []byte, tokenTTL time.Duration) (*UserPassAuth, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return nil, err
-	}
+func NewUserPassAuth(users *UserStore, secret []byte, tokenTTL time.Duration) *UserPassAuth {
 	return &UserPassAuth{
-		username: username,
-		pwdHash:  hash,
+		users:    users,
 		secret:   secret,
 		tokenTTL: tokenTTL,
-	}, nil
+		limiter:  newLoginLimiter(common.APILoginMaxFailures, common.APILoginLockoutDuration),
+	}
 }
 
-func NewUserPassAuthFromEnv() (*UserPassAuth, error) {
+func NewUserPassAuthFromEnv(users *UserStore) *UserPassAuth {
 	return NewUserPassAuth(
-		common.APIUser,
-		common.APIPassword,
+		users,
 		common.APIJWTSecret,
 		common.APIJWTTokenTTL,
 	)
@@ -60,9 +54,11 @@ func (auth *UserPassAuth) TokenCookieName() string {
 	return "godoxy_token"
 }
 
-func (auth *UserPassAuth) NewToken() (token string, err error) {
+func (auth *UserPassAuth) NewToken(username string) (token string, err error) {
+	user, _ := auth.users.Get(username)
 	claim := &UserPassClaims{
-		Username: auth.username,
+		Username: username,
+		Version:  user.TokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(auth.tokenTTL)),
 		},
@@ -76,9 +72,14 @@ func (auth *UserPassAuth) NewToken() (token string, err error) {
 }
 
 func (auth *UserPassAuth) CheckToken(r *http.Request) error {
+	_, err := auth.CurrentUser(r)
+	return err
+}
+
+func (auth *UserPassAuth) CurrentUser(r *http.Request) (*UserInfo, error) {
 	jwtCookie, err := r.Cookie(auth.TokenCookieName())
 	if err != nil {
-		return ErrMissingSessionToken
+		return nil, ErrMissingSessionToken
 	}
 	var claims UserPassClaims
 	token, err := jwt.ParseWithClaims(jwtCookie.Value, &claims, func(t *jwt.Token) (interface{}, error) {
@@ -88,18 +89,24 @@ func (auth *UserPassAuth) CheckToken(r *http.Request) error {
 		return auth.secret, nil
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	switch {
-	case !token.Valid:
-		return ErrInvalidSessionToken
-	case claims.Username != auth.username:
-		return ErrUserNotAllowed.Subject(claims.Username)
-	case claims.ExpiresAt.Before(time.Now()):
-		return gperr.Errorf("token expired on %s", strutils.FormatTime(claims.ExpiresAt.Time))
+	if !token.Valid {
+		return nil, ErrInvalidSessionToken
 	}
-
-	return nil
+	if claims.ExpiresAt.Before(time.Now()) {
+		return nil, gperr.Errorf("token expired on %s", strutils.FormatTime(claims.ExpiresAt.Time))
+	}
+	// the user may have been removed after the token was issued
+	user, ok := auth.users.Get(claims.Username)
+	if !ok {
+		return nil, ErrUserNotAllowed.Subject(claims.Username)
+	}
+	// the password may have been changed after the token was issued
+	if claims.Version != user.TokenVersion {
+		return nil, ErrInvalidSessionToken
+	}
+	return &UserInfo{Username: user.Username, Role: user.Role, Origin: UserOriginLocal}, nil
 }
 
 type UserPassAuthCallbackRequest struct {
@@ -107,6 +114,9 @@ type UserPassAuthCallbackRequest struct {
 	Pass string `json:"password"`
 }
 
+// PostAuthCallbackHandler checks the password, and issues the session token
+// unless the user has a second factor enrolled, in which case it responds
+// with 401 and MFARequiredResponse, and the login continues with the MFA handlers.
 func (auth *UserPassAuth) PostAuthCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	var creds UserPassAuthCallbackRequest
 	err := sonic.ConfigDefault.NewDecoder(r.Body).Decode(&creds)
@@ -114,19 +124,22 @@ func (auth *UserPassAuth) PostAuthCallbackHandler(w http.ResponseWriter, r *http
 		http.Error(w, "invalid request", http.StatusBadRequest)
 		return
 	}
+	ip := clientIP(r)
+	if !auth.allowAttempt(w, ip, creds.User) {
+		return
+	}
 	if err := auth.validatePassword(creds.User, creds.Pass); err != nil {
+		auth.limiter.fail(ip, creds.User)
 		// NOTE: do not include the actual error here
 		http.Error(w, "invalid credentials", http.StatusBadRequest)
 		return
 	}
-	token, err := auth.NewToken()
-	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		httputils.LogError(r).Msg(fmt.Sprintf("failed to generate token: %v", err))
+	user, _ := auth.users.Get(creds.User)
+	if user.HasMFA() {
+		auth.requireMFA(w, r, &user)
 		return
 	}
-	SetTokenCookie(w, r, auth.TokenCookieName(), token, auth.tokenTTL)
-	w.WriteHeader(http.StatusOK)
+	auth.completeLogin(w, r, ip, creds.User)
 }
 
 func (auth *UserPassAuth) LoginHandler(w http.ResponseWriter, r *http.Request) {
@@ -138,12 +151,6 @@ func (auth *UserPassAuth) LogoutHandler(w http.ResponseWriter, r *http.Request)
 	http.Redirect(w, r, "/", http.StatusFound)
 }
 
-func (auth *UserPassAuth) validatePassword(user, pass string) error {
-	if user != auth.username {
-		return ErrInvalidUsername.Subject(user)
-	}
-	if err := bcrypt.CompareHashAndPassword(auth.pwdHash, []byte(pass)); err != nil {
-		return ErrInvalidPassword.With(err).Subject(pass)
-	}
-	return nil
+func (auth *UserPassAuth) validatePassword(username, pass string) error {
+	return auth.users.CheckPassword(username, pass)
 }
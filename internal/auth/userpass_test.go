@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -13,12 +14,26 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+func newMockUserStore() *UserStore {
+	return &UserStore{
+		config: UsersConfig{
+			Users: []*User{
+				{
+					Username: "username",
+					Password: string(expect.Must(bcrypt.GenerateFromPassword([]byte("password"), bcrypt.DefaultCost))),
+					Role:     RoleAdmin,
+				},
+			},
+		},
+	}
+}
+
 func newMockUserPassAuth() *UserPassAuth {
 	return &UserPassAuth{
-		username: "username",
-		pwdHash:  expect.Must(bcrypt.GenerateFromPassword([]byte("password"), bcrypt.DefaultCost)),
+		users:    newMockUserStore(),
 		secret:   []byte("abcdefghijklmnopqrstuvwxyz"),
 		tokenTTL: time.Hour,
+		limiter:  newLoginLimiter(5, time.Minute),
 	}
 }
 
@@ -34,7 +49,9 @@ func TestUserPassValidateCredentials(t *testing.T) {
 
 func TestUserPassCheckToken(t *testing.T) {
 	auth := newMockUserPassAuth()
-	token, err := auth.NewToken()
+	token, err := auth.NewToken("username")
+	expect.NoError(t, err)
+	removedUserToken, err := auth.NewToken("removed-user")
 	expect.NoError(t, err)
 	tests := []struct {
 		token   string
@@ -44,6 +61,10 @@ func TestUserPassCheckToken(t *testing.T) {
 			token:   token,
 			wantErr: false,
 		},
+		{
+			token:   removedUserToken,
+			wantErr: true,
+		},
 		{
 			token:   "invalid-token",
 			wantErr: true,
@@ -67,6 +88,20 @@ func TestUserPassCheckToken(t *testing.T) {
 	}
 }
 
+func TestUserPassPasswordChangeRevokesToken(t *testing.T) {
+	auth := newMockUserPassAuth()
+	auth.users.path = filepath.Join(t.TempDir(), "users.yml")
+	token, err := auth.NewToken("username")
+	expect.NoError(t, err)
+
+	req := &http.Request{Header: http.Header{}}
+	req.Header.Set("Cookie", auth.TokenCookieName()+"="+token)
+	expect.NoError(t, auth.CheckToken(req))
+
+	expect.NoError(t, auth.users.Update("username", "new-password", ""))
+	expect.ErrorIs(t, ErrInvalidSessionToken, auth.CheckToken(req))
+}
+
 func TestUserPassLoginCallbackHandler(t *testing.T) {
 	type cred struct {
 		User string `json:"username"`
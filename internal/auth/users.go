@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/goccy/go-yaml"
+	"github.com/yusing/godoxy/internal/common"
+	"github.com/yusing/godoxy/internal/serialization"
+	gperr "github.com/yusing/goutils/errs"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type (
+	// UserStore is a file-backed store of users with bcrypt hashed passwords,
+	// and the mapping of OIDC and LDAP groups to roles.
+	UserStore struct {
+		path string
+
+		mu     sync.RWMutex
+		config UsersConfig
+	}
+	UsersConfig struct {
+		Users           []*User         `json:"users"`
+		OIDCGroupRoles  map[string]Role `json:"oidc_group_roles"`  // OIDC group -> role
+		OIDCDefaultRole Role            `json:"oidc_default_role"` // role of OIDC users without a mapped group (default: viewer)
+		LDAPGroupRoles  map[string]Role `json:"ldap_group_roles"`  // LDAP group (CN) -> role
+		LDAPDefaultRole Role            `json:"ldap_default_role"` // role of LDAP users without a mapped group (default: viewer)
+	}
+	User struct {
+		Username string `json:"username" validate:"required"`
+		Password string `json:"password" validate:"required"` // bcrypt hash
+		Role     Role   `json:"role" validate:"required,oneof=viewer operator admin"`
+		// incremented on password change to invalidate issued session tokens
+		TokenVersion int `json:"token_version,omitempty"`
+
+		// second factors, managed through the API
+		TOTPSecret    string                `json:"totp_secret,omitempty"`    // base32
+		RecoveryCodes []string              `json:"recovery_codes,omitempty"` // sha256 hashes of unused recovery codes
+		WebAuthn      []*WebAuthnCredential `json:"webauthn,omitempty"`
+	}
+)
+
+var (
+	ErrUserNotFound      = gperr.New("user not found")
+	ErrUserAlreadyExists = gperr.New("user already exists")
+	ErrLastAdmin         = gperr.New("cannot remove the last admin")
+)
+
+var userStore *UserStore
+
+// GetUserStore returns the user store initialized by Initialize.
+func GetUserStore() *UserStore {
+	return userStore
+}
+
+// LoadUserStore loads the users file at path.
+//
+// If the file does not exist, the store contains only the user
+// from API_USER and API_PASSWORD with the admin role.
+func LoadUserStore(path string) (*UserStore, error) {
+	store := &UserStore{path: path}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		hash, err := bcrypt.GenerateFromPassword([]byte(common.APIPassword), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		store.config.Users = []*User{{Username: common.APIUser, Password: string(hash), Role: RoleAdmin}}
+	case err != nil:
+		return nil, err
+	default:
+		if err := serialization.UnmarshalValidateYAML(data, &store.config); err != nil {
+			return nil, gperr.PrependSubject(path, err)
+		}
+		if err := store.config.validate(); err != nil {
+			return nil, gperr.PrependSubject(path, err)
+		}
+	}
+	if store.config.OIDCDefaultRole == "" {
+		store.config.OIDCDefaultRole = RoleViewer
+	}
+	if store.config.LDAPDefaultRole == "" {
+		store.config.LDAPDefaultRole = RoleViewer
+	}
+	return store, nil
+}
+
+func (cfg *UsersConfig) validate() gperr.Error {
+	errs := gperr.NewBuilder("invalid users config")
+	seen := make(map[string]struct{}, len(cfg.Users))
+	for _, user := range cfg.Users {
+		if _, ok := seen[user.Username]; ok {
+			errs.Add(ErrUserAlreadyExists.Subject(user.Username))
+		}
+		seen[user.Username] = struct{}{}
+		if _, err := bcrypt.Cost([]byte(user.Password)); err != nil {
+			errs.Add(gperr.Errorf("password of %s is not a bcrypt hash", user.Username))
+		}
+		if user.TOTPSecret != "" {
+			if _, err := decodeTOTPSecret(user.TOTPSecret); err != nil {
+				errs.Add(gperr.Errorf("totp secret of %s is not base32 encoded", user.Username))
+			}
+		}
+	}
+	for group, role := range cfg.OIDCGroupRoles {
+		if !role.IsValid() {
+			errs.Add(ErrInvalidRole.Subject(group))
+		}
+	}
+	if cfg.OIDCDefaultRole != "" && !cfg.OIDCDefaultRole.IsValid() {
+		errs.Add(ErrInvalidRole.Subject("oidc_default_role"))
+	}
+	for group, role := range cfg.LDAPGroupRoles {
+		if !role.IsValid() {
+			errs.Add(ErrInvalidRole.Subject(group))
+		}
+	}
+	if cfg.LDAPDefaultRole != "" && !cfg.LDAPDefaultRole.IsValid() {
+		errs.Add(ErrInvalidRole.Subject("ldap_default_role"))
+	}
+	return errs.Error()
+}
+
+// Get returns a copy of the user with the given username.
+func (s *UserStore) Get(username string) (User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user := s.get(username)
+	if user == nil {
+		return User{}, false
+	}
+	return *user, true
+}
+
+func (s *UserStore) get(username string) *User {
+	for _, user := range s.config.Users {
+		if user.Username == username {
+			return user
+		}
+	}
+	return nil
+}
+
+// List returns all users without password hashes.
+func (s *UserStore) List() []UserInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := make([]UserInfo, len(s.config.Users))
+	for i, user := range s.config.Users {
+		users[i] = UserInfo{Username: user.Username, Role: user.Role}
+	}
+	return users
+}
+
+// RoleForGroups returns the highest role mapped from the OIDC groups,
+// or the default role (viewer unless configured) if none is mapped.
+func (s *UserStore) RoleForGroups(groups []string) Role {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return roleForGroups(s.config.OIDCGroupRoles, s.config.OIDCDefaultRole, groups)
+}
+
+// RoleForLDAPGroups returns the highest role mapped from the LDAP groups,
+// or the default role (viewer unless configured) if none is mapped.
+func (s *UserStore) RoleForLDAPGroups(groups []string) Role {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return roleForGroups(s.config.LDAPGroupRoles, s.config.LDAPDefaultRole, groups)
+}
+
+func roleForGroups(groupRoles map[string]Role, role Role, groups []string) Role {
+	for _, group := range groups {
+		if mapped, ok := groupRoles[group]; ok && !role.Allows(mapped) {
+			role = mapped
+		}
+	}
+	return role
+}
+
+// Add adds a new user with the plain text password.
+func (s *UserStore) Add(username, password string, role Role) error {
+	if !role.IsValid() {
+		return ErrInvalidRole.Subject(string(role))
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.get(username) != nil {
+		return ErrUserAlreadyExists.Subject(username)
+	}
+	s.config.Users = append(s.config.Users, &User{Username: username, Password: string(hash), Role: role})
+	return s.save()
+}
+
+// Update updates the password and/or role of the user,
+// empty values are left unchanged.
+//
+// Changing the password invalidates the session tokens issued to the user.
+func (s *UserStore) Update(username, password string, role Role) error {
+	if role != "" && !role.IsValid() {
+		return ErrInvalidRole.Subject(string(role))
+	}
+	var hash []byte
+	if password != "" {
+		var err error
+		hash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user := s.get(username)
+	if user == nil {
+		return ErrUserNotFound.Subject(username)
+	}
+	if role != "" && role != RoleAdmin && user.Role == RoleAdmin && s.countAdmins() == 1 {
+		return ErrLastAdmin
+	}
+	updated := *user
+	if hash != nil {
+		updated.Password = string(hash)
+		updated.TokenVersion++
+	}
+	if role != "" {
+		updated.Role = role
+	}
+	*user = updated
+	return s.save()
+}
+
+// Delete deletes the user and revokes the user's API tokens.
+func (s *UserStore) Delete(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.config.Users, func(user *User) bool {
+		return user.Username == username
+	})
+	if i == -1 {
+		return ErrUserNotFound.Subject(username)
+	}
+	if s.config.Users[i].Role == RoleAdmin && s.countAdmins() == 1 {
+		return ErrLastAdmin
+	}
+	s.config.Users = slices.Delete(s.config.Users, i, i+1)
+	revokeLocalAPITokens(username)
+	return s.save()
+}
+
+func (s *UserStore) countAdmins() int {
+	n := 0
+	for _, user := range s.config.Users {
+		if user.Role == RoleAdmin {
+			n++
+		}
+	}
+	return n
+}
+
+// save writes the users to the users file, must be called with the lock held.
+//
+// When the file is first created, the env user is kept so the admin is not locked out.
+func (s *UserStore) save() error {
+	data, err := yaml.Marshal(&s.config)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0o600)
+}
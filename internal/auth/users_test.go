@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	expect "github.com/yusing/goutils/testing"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserStoreFromEnv(t *testing.T) {
+	store, err := LoadUserStore(filepath.Join(t.TempDir(), "users.yml"))
+	expect.NoError(t, err)
+
+	users := store.List()
+	expect.Equal(t, len(users), 1)
+	expect.Equal(t, users[0].Role, RoleAdmin)
+}
+
+func TestUserStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.yml")
+	hash := string(expect.Must(bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)))
+	expect.NoError(t, os.WriteFile(path, []byte(`
+users:
+  - username: alice
+    password: `+hash+`
+    role: admin
+  - username: bob
+    password: `+hash+`
+    role: viewer
+oidc_group_roles:
+  ops: operator
+  admins: admin
+`), 0o600))
+
+	store, err := LoadUserStore(path)
+	expect.NoError(t, err)
+
+	bob, ok := store.Get("bob")
+	expect.True(t, ok)
+	expect.Equal(t, bob.Role, RoleViewer)
+
+	expect.NoError(t, store.Add("carol", "secret", RoleOperator))
+	expect.ErrorIs(t, ErrUserAlreadyExists, store.Add("carol", "secret", RoleOperator))
+	expect.NoError(t, store.Update("bob", "", RoleOperator))
+	expect.ErrorIs(t, ErrLastAdmin, store.Delete("alice"))
+	expect.ErrorIs(t, ErrLastAdmin, store.Update("alice", "", RoleViewer))
+	expect.ErrorIs(t, ErrUserNotFound, store.Delete("dave"))
+
+	// changes are persisted
+	reloaded, err := LoadUserStore(path)
+	expect.NoError(t, err)
+	carol, ok := reloaded.Get("carol")
+	expect.True(t, ok)
+	expect.Equal(t, carol.Role, RoleOperator)
+	expect.NoError(t, bcrypt.CompareHashAndPassword([]byte(carol.Password), []byte("secret")))
+	bob, _ = reloaded.Get("bob")
+	expect.Equal(t, bob.Role, RoleOperator)
+
+	expect.Equal(t, reloaded.RoleForGroups([]string{"ops"}), RoleOperator)
+	expect.Equal(t, reloaded.RoleForGroups([]string{"ops", "admins"}), RoleAdmin)
+	expect.Equal(t, reloaded.RoleForGroups([]string{"others"}), RoleViewer)
+}
+
+func TestUserStoreDefaultRole(t *testing.T) {
+	store, err := LoadUserStore(filepath.Join(t.TempDir(), "users.yml"))
+	expect.NoError(t, err)
+	// OIDC users without a mapped group are never admins
+	expect.Equal(t, store.RoleForGroups([]string{"admins"}), RoleViewer)
+}
+
+func TestUserStoreInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.yml")
+	expect.NoError(t, os.WriteFile(path, []byte(`
+users:
+  - username: alice
+    password: plaintext
+    role: admin
+`), 0o600))
+	_, err := LoadUserStore(path)
+	expect.HasError(t, err)
+}
+
+func TestRoleAllows(t *testing.T) {
+	expect.True(t, RoleAdmin.Allows(RoleOperator))
+	expect.True(t, RoleOperator.Allows(RoleOperator))
+	expect.False(t, RoleViewer.Allows(RoleOperator))
+	expect.False(t, Role("guest").Allows(RoleViewer))
+}
@@ -79,11 +79,19 @@ func cookieDomain(r *http.Request) string {
 }
 
 func SetTokenCookie(w http.ResponseWriter, r *http.Request, name, value string, ttl time.Duration) {
+	setTokenCookie(w, cookieDomain(r), name, value, ttl)
+}
+
+func ClearTokenCookie(w http.ResponseWriter, r *http.Request, name string) {
+	clearTokenCookie(w, cookieDomain(r), name)
+}
+
+func setTokenCookie(w http.ResponseWriter, domain, name, value string, ttl time.Duration) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     name,
 		Value:    value,
 		MaxAge:   int(ttl.Seconds()),
-		Domain:   cookieDomain(r),
+		Domain:   domain,
 		HttpOnly: true,
 		Secure:   common.APIJWTSecure,
 		SameSite: http.SameSiteLaxMode,
@@ -91,12 +99,12 @@ func SetTokenCookie(w http.ResponseWriter, r *http.Request, name, value string,
 	})
 }
 
-func ClearTokenCookie(w http.ResponseWriter, r *http.Request, name string) {
+func clearTokenCookie(w http.ResponseWriter, domain, name string) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     name,
 		Value:    "",
 		MaxAge:   -1,
-		Domain:   cookieDomain(r),
+		Domain:   domain,
 		HttpOnly: true,
 		Secure:   common.APIJWTSecure,
 		SameSite: http.SameSiteLaxMode,
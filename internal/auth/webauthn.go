@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/puzpuzpuz/xsync/v4"
+	gperr "github.com/yusing/goutils/errs"
+)
+
+// WebAuthn (https://www.w3.org/TR/webauthn-2) relying party for passkeys as a second factor.
+//
+// Only "none" attestation is requested, the relying party id is the request hostname.
+
+type (
+	// WebAuthnCredential is a registered passkey.
+	WebAuthnCredential struct {
+		ID             string    `json:"id"`         // base64url credential id
+		Name           string    `json:"name"`       // user given name
+		PublicKey      string    `json:"public_key"` // base64url COSE key
+		SignCount      uint32    `json:"sign_count"`
+		BackupEligible bool      `json:"backup_eligible,omitempty"`
+		CreatedAt      time.Time `json:"created_at"`
+		LastUsedAt     time.Time `json:"last_used_at,omitzero"`
+	}
+
+	// WebAuthnCredentialInfo is a registered passkey without its public key.
+	WebAuthnCredentialInfo struct {
+		ID         string    `json:"id"`
+		Name       string    `json:"name"`
+		CreatedAt  time.Time `json:"created_at"`
+		LastUsedAt time.Time `json:"last_used_at,omitzero"`
+	} //	@name	WebAuthnCredentialInfo
+
+	// WebAuthnCreationOptions is passed to navigator.credentials.create() as publicKey.
+	WebAuthnCreationOptions = protocol.PublicKeyCredentialCreationOptions
+	// WebAuthnRequestOptions is passed to navigator.credentials.get() as publicKey.
+	WebAuthnRequestOptions = protocol.PublicKeyCredentialRequestOptions
+	// WebAuthnRegistration is the PublicKeyCredential returned by navigator.credentials.create().
+	WebAuthnRegistration = protocol.CredentialCreationResponse
+	// WebAuthnAssertion is the PublicKeyCredential returned by navigator.credentials.get().
+	WebAuthnAssertion = protocol.CredentialAssertionResponse
+
+	// webAuthnUser adapts User to webauthn.User.
+	webAuthnUser struct {
+		*User
+	}
+
+	webAuthnCeremony struct {
+		username string
+		ceremony protocol.CeremonyType
+		rp       *webauthn.WebAuthn
+		session  *webauthn.SessionData
+	}
+)
+
+const (
+	webAuthnTimeout = 5 * time.Minute
+	webAuthnRPName  = "GoDoxy"
+)
+
+var (
+	ErrInvalidWebAuthnResponse = gperr.New("invalid webauthn response")
+	ErrWebAuthnChallenge       = gperr.New("invalid or expired webauthn challenge")
+	ErrUnknownCredential       = gperr.New("unknown webauthn credential")
+)
+
+var b64url = base64.RawURLEncoding
+
+// pending ceremonies by base64url challenge
+var webAuthnCeremonies = xsync.NewMap[string, *webAuthnCeremony]()
+
+// webAuthnUserHandle returns the opaque user handle of the username.
+func webAuthnUserHandle(username string) []byte {
+	sum := sha256.Sum256([]byte("godoxy-webauthn:" + username))
+	return sum[:16]
+}
+
+func (u webAuthnUser) WebAuthnID() []byte {
+	return webAuthnUserHandle(u.Username)
+}
+
+func (u webAuthnUser) WebAuthnName() string {
+	return u.Username
+}
+
+func (u webAuthnUser) WebAuthnDisplayName() string {
+	return u.Username
+}
+
+func (u webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	creds := make([]webauthn.Credential, len(u.WebAuthn))
+	for i, cred := range u.WebAuthn {
+		creds[i] = webauthn.Credential{
+			ID:            decodeB64URL(cred.ID),
+			PublicKey:     decodeB64URL(cred.PublicKey),
+			Flags:         webauthn.CredentialFlags{BackupEligible: cred.BackupEligible},
+			Authenticator: webauthn.Authenticator{SignCount: cred.SignCount},
+		}
+	}
+	return creds
+}
+
+// newWebAuthn returns the relying party of the request, identified by the request hostname.
+func newWebAuthn(r *http.Request) (*webauthn.WebAuthn, error) {
+	host := requestHost(r)
+	rpID := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		rpID = h
+	}
+	origins := []string{"https://" + host}
+	if rpID == "localhost" {
+		origins = append(origins, "http://"+host)
+	}
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: webAuthnTimeout, TimeoutUVD: webAuthnTimeout}
+	return webauthn.New(&webauthn.Config{
+		RPID:                  rpID,
+		RPDisplayName:         webAuthnRPName,
+		RPOrigins:             origins,
+		AttestationPreference: protocol.PreferNoAttestation,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationPreferred,
+		},
+		Timeouts: webauthn.TimeoutsConfig{Login: timeout, Registration: timeout},
+	})
+}
+
+func storeWebAuthnCeremony(username string, ceremony protocol.CeremonyType, rp *webauthn.WebAuthn, session *webauthn.SessionData) {
+	now := time.Now()
+	// drop expired ceremonies
+	webAuthnCeremonies.Range(func(k string, c *webAuthnCeremony) bool {
+		if now.After(c.session.Expires) {
+			webAuthnCeremonies.Delete(k)
+		}
+		return true
+	})
+	webAuthnCeremonies.Store(session.Challenge, &webAuthnCeremony{
+		username: username,
+		ceremony: ceremony,
+		rp:       rp,
+		session:  session,
+	})
+}
+
+// takeWebAuthnCeremony consumes the pending ceremony of the challenge.
+func takeWebAuthnCeremony(challenge, username string, ceremony protocol.CeremonyType) (*webAuthnCeremony, error) {
+	c, ok := webAuthnCeremonies.LoadAndDelete(challenge)
+	if !ok || c.username != username || c.ceremony != ceremony || time.Now().After(c.session.Expires) {
+		return nil, ErrWebAuthnChallenge
+	}
+	return c, nil
+}
+
+func newWebAuthnCreationOptions(r *http.Request, user *User) (*WebAuthnCreationOptions, error) {
+	rp, err := newWebAuthn(r)
+	if err != nil {
+		return nil, err
+	}
+	wu := webAuthnUser{user}
+	creation, session, err := rp.BeginRegistration(wu,
+		webauthn.WithExclusions(webauthn.Credentials(wu.WebAuthnCredentials()).CredentialDescriptors()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	storeWebAuthnCeremony(user.Username, protocol.CreateCeremony, rp, session)
+	return &creation.Response, nil
+}
+
+func newWebAuthnRequestOptions(r *http.Request, user *User) (*WebAuthnRequestOptions, error) {
+	rp, err := newWebAuthn(r)
+	if err != nil {
+		return nil, err
+	}
+	assertion, session, err := rp.BeginLogin(webAuthnUser{user})
+	if err != nil {
+		return nil, err
+	}
+	storeWebAuthnCeremony(user.Username, protocol.AssertCeremony, rp, session)
+	return &assertion.Response, nil
+}
+
+// finishWebAuthnRegistration verifies the registration response and returns the new credential.
+func finishWebAuthnRegistration(username, name string, reg *WebAuthnRegistration) (*WebAuthnCredential, error) {
+	parsed, err := reg.Parse()
+	if err != nil {
+		return nil, ErrInvalidWebAuthnResponse.With(err)
+	}
+	c, err := takeWebAuthnCeremony(parsed.Response.CollectedClientData.Challenge, username, protocol.CreateCeremony)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := c.rp.CreateCredential(webAuthnUser{&User{Username: username}}, *c.session, parsed)
+	if err != nil {
+		return nil, ErrInvalidWebAuthnResponse.With(err)
+	}
+	return &WebAuthnCredential{
+		ID:             b64url.EncodeToString(cred.ID),
+		Name:           name,
+		PublicKey:      b64url.EncodeToString(cred.PublicKey),
+		SignCount:      cred.Authenticator.SignCount,
+		BackupEligible: cred.Flags.BackupEligible,
+		CreatedAt:      time.Now(),
+	}, nil
+}
+
+// verifyWebAuthnAssertion verifies the assertion against the user's credentials
+// and returns the used credential id and the new signature counter.
+func verifyWebAuthnAssertion(user *User, assertion *WebAuthnAssertion) (credID string, signCount uint32, err error) {
+	parsed, err := assertion.Parse()
+	if err != nil {
+		return "", 0, ErrInvalidWebAuthnResponse.With(err)
+	}
+	credID = b64url.EncodeToString(parsed.RawID)
+	if !slices.ContainsFunc(user.WebAuthn, func(c *WebAuthnCredential) bool { return c.ID == credID }) {
+		return "", 0, ErrUnknownCredential
+	}
+	c, err := takeWebAuthnCeremony(parsed.Response.CollectedClientData.Challenge, user.Username, protocol.AssertCeremony)
+	if err != nil {
+		return "", 0, err
+	}
+	cred, err := c.rp.ValidateLogin(webAuthnUser{user}, *c.session, parsed)
+	if err != nil {
+		return "", 0, ErrInvalidWebAuthnResponse.With(err)
+	}
+	// a counter that does not increase indicates a cloned authenticator
+	if cred.Authenticator.CloneWarning {
+		return "", 0, ErrInvalidWebAuthnResponse.Subject("signature counter did not increase")
+	}
+	return credID, cred.Authenticator.SignCount, nil
+}
+
+// decodeB64URL returns the decoded bytes, or nil if s is not valid base64url.
+func decodeB64URL(s string) []byte {
+	b, _ := b64url.DecodeString(s)
+	return b
+}
+
+// Info returns the credential without its public key.
+func (cred *WebAuthnCredential) Info() *WebAuthnCredentialInfo {
+	return &WebAuthnCredentialInfo{
+		ID:         cred.ID,
+		Name:       cred.Name,
+		CreatedAt:  cred.CreatedAt,
+		LastUsedAt: cred.LastUsedAt,
+	}
+}
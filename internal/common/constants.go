@@ -14,9 +14,11 @@ const (
 	ConfigFileName        = "config.yml"
 	ConfigExampleFileName = "config.example.yml"
 	ConfigPath            = ConfigBasePath + "/" + ConfigFileName
+	UsersFilePath         = ConfigBasePath + "/users.yml"
 
 	DataDir           = "data"
 	IconListCachePath = DataDir + "/.icon_list_cache.json"
+	AuditLogPath      = DataDir + "/audit.log"
 
 	NamespaceHomepageOverrides = ".homepage"
 	NamespaceIconCache         = ".icon_cache"
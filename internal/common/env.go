@@ -23,6 +23,8 @@ var (
 	ProxyHTTPSPort,
 	ProxyHTTPSURL = env.GetAddrEnv("HTTPS_ADDR", ":443", "https")
 
+	ProxyHTTP3Enabled = env.GetEnvBool("HTTP3_ENABLED", false) // listen on HTTPS_ADDR/udp, advertised for routes with h3 in alpn
+
 	APIHTTPAddr,
 	APIHTTPHost,
 	APIHTTPPort,
@@ -34,6 +36,11 @@ var (
 	APIUser        = env.GetEnvString("API_USER", "admin")
 	APIPassword    = env.GetEnvString("API_PASSWORD", "password")
 
+	APILoginMaxFailures     = env.GetEnvInt("API_LOGIN_MAX_FAILURES", 5) // consecutive failures before the user is locked out
+	APILoginLockoutDuration = env.GetEnvDuation("API_LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+
+	APIForwardAuthLoginURL = env.GetEnvString("API_FORWARD_AUTH_LOGIN_URL", "") // login page to redirect to from the forward auth endpoint
+
 	APISkipOriginCheck = env.GetEnvBool("API_SKIP_ORIGIN_CHECK", false) // skip this in UI Demo
 
 	DebugDisableAuth = env.GetEnvBool("DEBUG_DISABLE_AUTH", false)
@@ -46,6 +53,21 @@ var (
 	OIDCAllowedUsers  = env.GetEnvCommaSep("OIDC_ALLOWED_USERS", "")
 	OIDCAllowedGroups = env.GetEnvCommaSep("OIDC_ALLOWED_GROUPS", "")
 
+	// LDAP Configuration.
+	LDAPURL                = env.GetEnvString("LDAP_URL", "") // ldap://host:389 or ldaps://host:636
+	LDAPStartTLS           = env.GetEnvBool("LDAP_START_TLS", false)
+	LDAPInsecureSkipVerify = env.GetEnvBool("LDAP_INSECURE_SKIP_VERIFY", false)
+	LDAPBindDN             = env.GetEnvString("LDAP_BIND_DN", "")
+	LDAPBindPassword       = env.GetEnvString("LDAP_BIND_PASSWORD", "")
+	LDAPBaseDN             = env.GetEnvString("LDAP_BASE_DN", "")
+	LDAPUserFilter         = env.GetEnvString("LDAP_USER_FILTER", "(uid={username})")
+	LDAPGroupAttribute     = env.GetEnvString("LDAP_GROUP_ATTRIBUTE", "memberOf")
+	LDAPGroupBaseDN        = env.GetEnvString("LDAP_GROUP_BASE_DN", "")
+	LDAPGroupFilter        = env.GetEnvString("LDAP_GROUP_FILTER", "") // e.g. (member={dn}), overrides LDAP_GROUP_ATTRIBUTE
+	LDAPAllowedUsers       = env.GetEnvCommaSep("LDAP_ALLOWED_USERS", "")
+	LDAPAllowedGroups      = env.GetEnvCommaSep("LDAP_ALLOWED_GROUPS", "")
+	LDAPPoolSize           = env.GetEnvInt("LDAP_POOL_SIZE", 4)
+
 	// metrics configuration
 	MetricsDisableCPU     = env.GetEnvBool("METRICS_DISABLE_CPU", false)
 	MetricsDisableMemory  = env.GetEnvBool("METRICS_DISABLE_MEMORY", false)
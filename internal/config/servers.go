@@ -0,0 +1,166 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+
+	"github.com/quic-go/quic-go/http3"
+	"github.com/rs/zerolog/log"
+	"github.com/yusing/godoxy/internal/acl"
+	"github.com/yusing/godoxy/internal/common"
+	config "github.com/yusing/godoxy/internal/config/types"
+	"github.com/yusing/godoxy/internal/entrypoint"
+	entrypointTypes "github.com/yusing/godoxy/internal/entrypoint/types"
+	netutils "github.com/yusing/godoxy/internal/net"
+	nettypes "github.com/yusing/godoxy/internal/net/types"
+	gperr "github.com/yusing/goutils/errs"
+	"github.com/yusing/goutils/server"
+)
+
+type entrypointServer struct {
+	name          string
+	entrypoint    *entrypoint.Entrypoint
+	httpAddr      string
+	httpsAddr     string
+	tls           *entrypointTypes.TLSConfig
+	proxyProtocol bool
+	trusted       []*nettypes.CIDR
+	acl           *acl.Config
+}
+
+func StartProxyServers() {
+	cfg := GetState()
+
+	// https of the default entrypoint is skipped when autocert is not configured,
+	// certificates are loaded lazily on handshakes so they can be obtained later
+	httpsAddr := common.ProxyHTTPSAddr
+	if certProvider := cfg.AutoCertProvider(); certProvider == nil {
+		httpsAddr = ""
+	} else if _, err := certProvider.GetCert(nil); err != nil {
+		log.Warn().Err(err).Msg("no certificate available yet, https handshakes will fail until one is obtained")
+	}
+
+	if err := startEntrypointServer(cfg, entrypointServer{
+		name:          "proxy",
+		entrypoint:    cfg.GetEntrypoint(),
+		httpAddr:      common.ProxyHTTPAddr,
+		httpsAddr:     httpsAddr,
+		proxyProtocol: cfg.Value().Entrypoint.SupportProxyProtocol,
+		trusted:       cfg.Value().Entrypoint.ProxyProtocolTrustedProxies,
+		acl:           cfg.Value().ACL,
+	}); err != nil {
+		logNotifyError("start proxy server", err)
+	}
+
+	for name, epCfg := range cfg.Value().Entrypoints {
+		ep := cfg.GetNamedEntrypoint(name)
+		if ep == nil { // failed to initialize
+			continue
+		}
+		acl := epCfg.ACL
+		if acl == nil {
+			acl = cfg.Value().ACL
+		}
+		if err := startEntrypointServer(cfg, entrypointServer{
+			name:          "entrypoint." + name,
+			entrypoint:    ep,
+			httpAddr:      epCfg.HTTPAddr,
+			httpsAddr:     epCfg.HTTPSAddr,
+			tls:           epCfg.TLS,
+			proxyProtocol: epCfg.SupportProxyProtocol,
+			trusted:       epCfg.ProxyProtocolTrustedProxies,
+			acl:           acl,
+		}); err != nil {
+			logNotifyError("start entrypoint "+name, err)
+		}
+	}
+}
+
+func startEntrypointServer(cfg config.State, s entrypointServer) error {
+	logger := log.With().Str("server", s.name).Logger()
+	taskName := func(proto string) string {
+		return "server." + s.name + "." + proto
+	}
+	opts := []server.ServerStartOption{
+		server.WithProxyProtocolSupport(s.proxyProtocol),
+		server.WithACL(s.acl),
+		server.WithLogger(&logger),
+	}
+
+	if s.httpAddr != "" {
+		server.Start(cfg.Task().Subtask(taskName("http"), true), &http.Server{
+			Addr:    s.httpAddr,
+			Handler: s.entrypoint,
+		}, opts...)
+	}
+	if s.httpsAddr == "" {
+		return nil
+	}
+
+	certProvider := cfg.AutoCertProvider()
+	if certProvider == nil {
+		return gperr.New("https_addr requires autocert to be configured")
+	}
+	tlsConfig, err := newTLSConfig(s.tls, certProvider)
+	if err != nil {
+		return err
+	}
+
+	if common.ProxyHTTP3Enabled {
+		h3 := &http3.Server{
+			Addr:      s.httpsAddr,
+			Handler:   s.entrypoint,
+			TLSConfig: http3.ConfigureTLSConfig(tlsConfig.Clone()),
+		}
+		h3Opts := opts
+		switch {
+		case s.proxyProtocol && len(s.trusted) == 0:
+			logger.Warn().Msg("proxy_protocol_trusted_proxies is not set, PROXY protocol headers are not accepted on HTTP/3")
+		case s.proxyProtocol:
+			// wrappers must be set before the ACL, so the ACL checks the client address
+			h3Opts = append([]server.ServerStartOption{
+				server.WithUDPWrappers(netutils.NewProxyProtocolPacketConn(s.trusted)),
+			}, opts...)
+		}
+		server.Start(cfg.Task().Subtask(taskName("http3"), true), h3, h3Opts...)
+		s.entrypoint.SetHTTP3Server(h3)
+	}
+
+	// offer only the protocols enabled by the route
+	tlsConfig.GetConfigForClient = s.entrypoint.GetConfigForClient(tlsConfig)
+	server.Start(cfg.Task().Subtask(taskName("https"), true), &http.Server{
+		Addr:      s.httpsAddr,
+		Handler:   s.entrypoint,
+		TLSConfig: tlsConfig,
+	}, opts...)
+	return nil
+}
+
+func newTLSConfig(cfg *entrypointTypes.TLSConfig, certProvider server.CertProvider) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		GetCertificate: certProvider.GetCert,
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+	if cfg == nil {
+		return tlsConfig, nil
+	}
+	if cfg.MinVersion == "1.3" {
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+	if cfg.ClientCA != "" {
+		pem, err := os.ReadFile(cfg.ClientCA)
+		if err != nil {
+			return nil, gperr.Wrap(err, "failed to read client_ca")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, gperr.New("no certificates found in client_ca").Subject(cfg.ClientCA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
@@ -3,10 +3,12 @@ package config
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"iter"
-	"net/http"
+	"maps"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,14 +18,19 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/yusing/godoxy/agent/pkg/agent"
 	"github.com/yusing/godoxy/internal/acl"
+	"github.com/yusing/godoxy/internal/agentcerts"
+	"github.com/yusing/godoxy/internal/agentheartbeat"
+	"github.com/yusing/godoxy/internal/auth"
 	"github.com/yusing/godoxy/internal/autocert"
 	"github.com/yusing/godoxy/internal/common"
 	config "github.com/yusing/godoxy/internal/config/types"
 	"github.com/yusing/godoxy/internal/entrypoint"
+	entrypointTypes "github.com/yusing/godoxy/internal/entrypoint/types"
 	homepage "github.com/yusing/godoxy/internal/homepage/types"
 	"github.com/yusing/godoxy/internal/logging"
 	"github.com/yusing/godoxy/internal/maxmind"
 	"github.com/yusing/godoxy/internal/notif"
+	"github.com/yusing/godoxy/internal/proxmox"
 	route "github.com/yusing/godoxy/internal/route/provider"
 	"github.com/yusing/godoxy/internal/route/routes"
 	"github.com/yusing/godoxy/internal/serialization"
@@ -39,6 +46,10 @@ type state struct {
 	providers        *xsync.Map[string, types.RouteProvider]
 	autocertProvider *autocert.Provider
 	entrypoint       entrypoint.Entrypoint
+	namedEntrypoints map[string]*entrypoint.Entrypoint
+
+	// proxmox clusters that are initialized successfully
+	proxmoxReady []*proxmox.Config
 
 	task *task.Task
 
@@ -74,6 +85,8 @@ func SetState(state config.State) {
 	config.ActiveState.Store(state)
 	acl.ActiveConfig.Store(cfg.ACL)
 	entrypoint.ActiveConfig.Store(&cfg.Entrypoint)
+	// routes are checked against the entrypoints of the active config when started
+	entrypointTypes.SetNamedEntrypoints(slices.Collect(maps.Keys(cfg.Entrypoints)))
 	homepage.ActiveConfig.Store(&cfg.Homepage)
 	if autocertProvider := state.AutoCertProvider(); autocertProvider != nil {
 		autocert.ActiveProvider.Store(autocertProvider.(*autocert.Provider))
@@ -107,8 +120,10 @@ func (state *state) Init(data []byte) error {
 
 	g := gperr.NewGroup("config load error")
 	g.Go(state.initMaxMind)
-	g.Go(state.initProxmox)
-	g.Go(state.loadRouteProviders)
+	g.Go(func() error {
+		// proxmox nodes are needed by proxmox routes
+		return errors.Join(state.initProxmox(), state.loadRouteProviders())
+	})
 	g.Go(state.initAutoCert)
 
 	errs := g.Wait()
@@ -116,6 +131,8 @@ func (state *state) Init(data []byte) error {
 	errs.Add(state.initNotification())
 	errs.Add(state.initAccessLogger())
 	errs.Add(state.initEntrypoint())
+	agentcerts.ScheduleExpiryCheck(state.task)
+	agentheartbeat.Start(state.task, state.Providers.AgentHeartbeat)
 	return errs.Error()
 }
 
@@ -131,10 +148,14 @@ func (state *state) Value() *config.Config {
 	return &state.Config
 }
 
-func (state *state) EntrypointHandler() http.Handler {
+func (state *state) GetEntrypoint() *entrypoint.Entrypoint {
 	return &state.entrypoint
 }
 
+func (state *state) GetNamedEntrypoint(name string) *entrypoint.Entrypoint {
+	return state.namedEntrypoints[name]
+}
+
 // AutoCertProvider returns the autocert provider.
 //
 // If the autocert provider is not configured, it returns nil.
@@ -201,9 +222,47 @@ func (state *state) initEntrypoint() error {
 	errs := gperr.NewBuilder("entrypoint error")
 	errs.Add(state.entrypoint.SetMiddlewares(epCfg.Middlewares))
 	errs.Add(state.entrypoint.SetAccessLogger(state.task, epCfg.AccessLog))
+
+	forwardAuthDomains := slices.Clone(matchDomains)
+	state.namedEntrypoints = make(map[string]*entrypoint.Entrypoint, len(state.Entrypoints))
+	for name, cfg := range state.Entrypoints {
+		if err := state.initNamedEntrypoint(name, cfg); err != nil {
+			errs.Add(gperr.PrependSubject(name, err))
+		}
+		forwardAuthDomains = append(forwardAuthDomains, cfg.MatchDomains...)
+	}
+	auth.SetForwardAuthDomains(forwardAuthDomains)
 	return errs.Error()
 }
 
+func (state *state) initNamedEntrypoint(name string, cfg *entrypointTypes.NamedConfig) error {
+	if name == "" || name == entrypointTypes.DefaultName {
+		return gperr.New("reserved entrypoint name")
+	}
+
+	matchDomains := cfg.MatchDomains
+	if len(matchDomains) == 0 {
+		matchDomains = state.MatchDomains
+	}
+
+	ep := entrypoint.NewNamedEntrypoint(name)
+	ep.SetServeAllRoutes(cfg.ServeAllRoutes)
+	ep.SetFindRouteDomains(matchDomains)
+	ep.SetNotFoundRules(cfg.Rules.NotFound)
+
+	errs := gperr.NewBuilder()
+	errs.Add(ep.SetMiddlewares(cfg.Middlewares))
+	errs.Add(ep.SetAccessLogger(state.task, cfg.AccessLog))
+	if cfg.ACL.Valid() {
+		errs.Add(cfg.ACL.Start(state.task))
+	}
+	if err := errs.Error(); err != nil {
+		return err
+	}
+	state.namedEntrypoints[name] = &ep
+	return nil
+}
+
 func (state *state) initMaxMind() error {
 	maxmindCfg := state.Providers.MaxMind
 	if maxmindCfg != nil {
@@ -252,10 +311,13 @@ func (state *state) initProxmox() error {
 	}
 
 	errs := gperr.NewBuilder()
-	for _, cfg := range proxmoxCfg {
+	for i := range proxmoxCfg {
+		cfg := &proxmoxCfg[i]
 		if err := cfg.Init(); err != nil {
 			errs.Add(err.Subject(cfg.URL))
+			continue
 		}
+		state.proxmoxReady = append(state.proxmoxReady, cfg)
 	}
 	return errs.Error()
 }
@@ -280,7 +342,7 @@ func (state *state) loadRouteProviders() error {
 
 	agent.RemoveAllAgents()
 
-	numProviders := len(providers.Agents) + len(providers.Files) + len(providers.Docker)
+	numProviders := len(providers.Agents) + len(providers.Files) + len(providers.Docker) + len(state.proxmoxReady)
 	providersCh := make(chan types.RouteProvider, numProviders)
 
 	// start providers concurrently
@@ -302,6 +364,11 @@ func (state *state) loadRouteProviders() error {
 				errs.Add(gperr.PrependSubject(a.String(), err))
 				return
 			}
+			if agentcerts.IsRevoked(a) {
+				a.Revoke()
+				errs.Add(gperr.PrependSubject(a.String(), agent.ErrAgentRevoked))
+				return
+			}
 			agent.AddAgent(a)
 			p := route.NewAgentProvider(a)
 			providersCh <- p
@@ -325,6 +392,12 @@ func (state *state) loadRouteProviders() error {
 		})
 	}
 
+	for _, cfg := range state.proxmoxReady {
+		if cfg.RouteDiscovery != nil {
+			providersCh <- route.NewProxmoxProvider(cfg)
+		}
+	}
+
 	providersProducer.Wait()
 
 	close(providersCh)
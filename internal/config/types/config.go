@@ -7,6 +7,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/yusing/godoxy/agent/pkg/agent"
 	"github.com/yusing/godoxy/internal/acl"
+	"github.com/yusing/godoxy/internal/agentheartbeat"
 	"github.com/yusing/godoxy/internal/autocert"
 	entrypoint "github.com/yusing/godoxy/internal/entrypoint/types"
 	homepage "github.com/yusing/godoxy/internal/homepage/types"
@@ -19,21 +20,23 @@ import (
 
 type (
 	Config struct {
-		ACL             *acl.Config       `json:"acl"`
-		AutoCert        *autocert.Config  `json:"autocert"`
-		Entrypoint      entrypoint.Config `json:"entrypoint"`
-		Providers       Providers         `json:"providers"`
-		MatchDomains    []string          `json:"match_domains" validate:"domain_name"`
-		Homepage        homepage.Config   `json:"homepage"`
-		TimeoutShutdown int               `json:"timeout_shutdown" validate:"gte=0"`
+		ACL             *acl.Config                        `json:"acl"`
+		AutoCert        *autocert.Config                   `json:"autocert"`
+		Entrypoint      entrypoint.Config                  `json:"entrypoint"`
+		Entrypoints     map[string]*entrypoint.NamedConfig `json:"entrypoints"`
+		Providers       Providers                          `json:"providers"`
+		MatchDomains    []string                           `json:"match_domains" validate:"domain_name"`
+		Homepage        homepage.Config                    `json:"homepage"`
+		TimeoutShutdown int                                `json:"timeout_shutdown" validate:"gte=0"`
 	}
 	Providers struct {
-		Files        []string                    `json:"include" yaml:"include,omitempty" validate:"dive,filepath"`
-		Docker       map[string]string           `json:"docker" yaml:"docker,omitempty" validate:"non_empty_docker_keys,dive,unix_addr|url"`
-		Agents       []*agent.AgentConfig        `json:"agents" yaml:"agents,omitempty"`
-		Notification []*notif.NotificationConfig `json:"notification" yaml:"notification,omitempty"`
-		Proxmox      []proxmox.Config            `json:"proxmox" yaml:"proxmox,omitempty"`
-		MaxMind      *maxmind.Config             `json:"maxmind" yaml:"maxmind,omitempty"`
+		Files          []string                    `json:"include" yaml:"include,omitempty" validate:"dive,filepath"`
+		Docker         map[string]string           `json:"docker" yaml:"docker,omitempty" validate:"non_empty_docker_keys,dive,unix_addr|url"`
+		Agents         []*agent.AgentConfig        `json:"agents" yaml:"agents,omitempty"`
+		AgentHeartbeat agentheartbeat.Config       `json:"agent_heartbeat" yaml:"agent_heartbeat,omitempty"`
+		Notification   []*notif.NotificationConfig `json:"notification" yaml:"notification,omitempty"`
+		Proxmox        []proxmox.Config            `json:"proxmox" yaml:"proxmox,omitempty"`
+		MaxMind        *maxmind.Config             `json:"maxmind" yaml:"maxmind,omitempty"`
 	}
 )
 
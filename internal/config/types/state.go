@@ -4,8 +4,8 @@ import (
 	"context"
 	"errors"
 	"iter"
-	"net/http"
 
+	"github.com/yusing/godoxy/internal/entrypoint"
 	"github.com/yusing/godoxy/internal/types"
 	"github.com/yusing/goutils/server"
 	"github.com/yusing/goutils/synk"
@@ -21,7 +21,9 @@ type State interface {
 
 	Value() *Config
 
-	EntrypointHandler() http.Handler
+	GetEntrypoint() *entrypoint.Entrypoint
+	// GetNamedEntrypoint returns nil if the named entrypoint does not exist.
+	GetNamedEntrypoint(name string) *entrypoint.Entrypoint
 	AutoCertProvider() server.CertProvider
 
 	LoadOrStoreProvider(key string, value types.RouteProvider) (actual types.RouteProvider, loaded bool)
@@ -252,6 +252,19 @@ func loadDeleteIdlewatcherLabels(c *types.Container, helper containerHelper) {
 	// ensure it's deleted from labels
 	helper.getDeleteLabel(LabelDependsOn)
 
+	// cron expressions separated by semicolons or newlines
+	schedule := map[string]any{
+		"keep_awake":  helper.getDeleteLabel(LabelKeepAwake),
+		"force_sleep": helper.getDeleteLabel(LabelForceSleep),
+		"pre_warm":    helper.getDeleteLabel(LabelPreWarm),
+	}
+	for _, v := range schedule {
+		if v != "" {
+			cfg["schedule"] = schedule
+			break
+		}
+	}
+
 	// set only if idlewatcher is enabled
 	idleTimeout := cfg["idle_timeout"]
 	if idleTimeout != "" {
@@ -115,3 +115,25 @@ func TestImageNameParsing(t *testing.T) {
 		})
 	}
 }
+
+func TestContainerIdlewatcherSchedule(t *testing.T) {
+	c := FromDocker(&container.Summary{
+		Names: []string{"test"},
+		State: "test",
+		Labels: map[string]string{
+			"proxy.idle_timeout": "1h",
+			"proxy.keep_awake":   "0,30 9-17 * * mon-fri; * 12 * * *",
+			"proxy.pre_warm":     "45 8 * * *",
+		},
+	}, "")
+	expect.Nil(t, c.Errors)
+	expect.NotNil(t, c.IdlewatcherConfig)
+	schedule := c.IdlewatcherConfig.Schedule
+	expect.NotNil(t, schedule)
+	expect.Equal(t, len(schedule.KeepAwake), 2)
+	expect.Equal(t, schedule.KeepAwake[0].String(), "0,30 9-17 * * mon-fri")
+	expect.Equal(t, len(schedule.PreWarm), 1)
+	expect.Equal(t, len(schedule.ForceSleep), 0)
+	_, ok := c.Labels["proxy.keep_awake"]
+	expect.False(t, ok)
+}
@@ -14,5 +14,8 @@ const (
 	LabelStopSignal    = NSProxy + ".stop_signal"
 	LabelStartEndpoint = NSProxy + ".start_endpoint"
 	LabelDependsOn     = NSProxy + ".depends_on"
+	LabelKeepAwake     = NSProxy + ".keep_awake"
+	LabelForceSleep    = NSProxy + ".force_sleep"
+	LabelPreWarm       = NSProxy + ".pre_warm"
 	LabelNetwork       = NSProxy + ".network"
 )
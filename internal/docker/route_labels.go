@@ -0,0 +1,94 @@
+package docker
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/goccy/go-yaml"
+	"github.com/yusing/godoxy/internal/types"
+	gperr "github.com/yusing/goutils/errs"
+)
+
+const (
+	aliasRefPrefix    = '#'
+	aliasRefPrefixAlt = '$'
+)
+
+var ErrAliasRefIndexOutOfRange = gperr.New("index out of range")
+
+// RouteLabels returns the route config of each alias of the container parsed from its labels.
+//
+// Aliases of the container without route labels are mapped to nil. Always non-nil.
+//
+// The returned error joins the errors of all aliases.
+func RouteLabels(container *types.Container) (map[string]types.LabelMap, gperr.Error) {
+	configs := make(map[string]types.LabelMap, len(container.Aliases))
+	for _, a := range container.Aliases {
+		configs[a] = nil
+	}
+
+	var errs []error
+
+	m, err := ParseLabels(container.Labels, container.Aliases...)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for alias, entryMapAny := range m {
+		if len(alias) == 0 {
+			errs = append(errs, gperr.New("empty alias"))
+			continue
+		}
+
+		entryMap, ok := entryMapAny.(types.LabelMap)
+		if !ok {
+			// try to deserialize to map
+			entryMap = make(types.LabelMap)
+			yamlStr, ok := entryMapAny.(string)
+			if !ok {
+				// should not happen
+				panic(fmt.Errorf("invalid entry map type %T", entryMapAny))
+			}
+			if err := yaml.Unmarshal([]byte(yamlStr), &entryMap); err != nil {
+				errs = append(errs, gperr.Wrap(err).Subject(alias))
+				continue
+			}
+		}
+
+		// check if it is an alias reference
+		switch alias[0] {
+		case aliasRefPrefix, aliasRefPrefixAlt:
+			index, err := strconv.Atoi(alias[1:])
+			if err != nil {
+				errs = append(errs, err)
+				break
+			}
+			if index < 1 || index > len(container.Aliases) {
+				errs = append(errs, ErrAliasRefIndexOutOfRange.Subject(strconv.Itoa(index)))
+				break
+			}
+			alias = container.Aliases[index-1]
+		}
+
+		// an alias and its reference may both have labels
+		if existing := configs[alias]; existing != nil {
+			mergeLabelMap(existing, entryMap)
+		} else {
+			configs[alias] = entryMap
+		}
+	}
+
+	return configs, gperr.Join(errs...)
+}
+
+func mergeLabelMap(dst, src types.LabelMap) {
+	for k, v := range src {
+		srcMap, srcOk := v.(types.LabelMap)
+		dstMap, dstOk := dst[k].(types.LabelMap)
+		if srcOk && dstOk {
+			mergeLabelMap(dstMap, srcMap)
+		} else {
+			dst[k] = v
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package entrypoint
 
 import (
+	"crypto/tls"
 	"net/http"
+	"slices"
 	"strings"
 	"sync/atomic"
 
+	"github.com/quic-go/quic-go/http3"
 	"github.com/rs/zerolog/log"
 	entrypoint "github.com/yusing/godoxy/internal/entrypoint/types"
 	"github.com/yusing/godoxy/internal/logging/accesslog"
@@ -17,10 +20,13 @@ import (
 )
 
 type Entrypoint struct {
+	name            string
+	serveAllRoutes  bool
 	middleware      *middleware.Middleware
 	notFoundHandler http.Handler
 	accessLogger    accesslog.AccessLogger
 	findRouteFunc   func(host string) types.HTTPRoute
+	http3           *http3.Server
 }
 
 // nil-safe
@@ -32,11 +38,29 @@ func init() {
 }
 
 func NewEntrypoint() Entrypoint {
+	return NewNamedEntrypoint(entrypoint.DefaultName)
+}
+
+// NewNamedEntrypoint returns an entrypoint that only serves routes with name in their entrypoints.
+//
+// The default entrypoint also serves routes without entrypoints specified, see [Entrypoint.SetServeAllRoutes].
+func NewNamedEntrypoint(name string) Entrypoint {
 	return Entrypoint{
-		findRouteFunc: findRouteAnyDomain,
+		name:           name,
+		serveAllRoutes: name == entrypoint.DefaultName,
+		findRouteFunc:  findRouteAnyDomain,
 	}
 }
 
+// SetServeAllRoutes sets whether routes without entrypoints specified are served.
+func (ep *Entrypoint) SetServeAllRoutes(serveAllRoutes bool) {
+	ep.serveAllRoutes = serveAllRoutes
+}
+
+func (ep *Entrypoint) Name() string {
+	return ep.name
+}
+
 func (ep *Entrypoint) SetFindRouteDomains(domains []string) {
 	if len(domains) == 0 {
 		ep.findRouteFunc = findRouteAnyDomain
@@ -56,13 +80,13 @@ func (ep *Entrypoint) SetMiddlewares(mws []map[string]any) error {
 		return nil
 	}
 
-	mid, err := middleware.BuildMiddlewareFromChainRaw("entrypoint", mws)
+	mid, err := middleware.BuildMiddlewareFromChainRaw(ep.middlewareName(), mws)
 	if err != nil {
 		return err
 	}
 	ep.middleware = mid
 
-	log.Debug().Msg("entrypoint middleware loaded")
+	log.Debug().Str("entrypoint", ep.name).Msg("entrypoint middleware loaded")
 	return nil
 }
 
@@ -80,12 +104,51 @@ func (ep *Entrypoint) SetAccessLogger(parent task.Parent, cfg *accesslog.Request
 	if err != nil {
 		return err
 	}
-	log.Debug().Msg("entrypoint access logger created")
+	log.Debug().Str("entrypoint", ep.name).Msg("entrypoint access logger created")
 	return err
 }
 
+// SetHTTP3Server sets the HTTP/3 server to be advertised with Alt-Svc
+// for routes with h3 in their ALPN.
+func (ep *Entrypoint) SetHTTP3Server(srv *http3.Server) {
+	ep.http3 = srv
+}
+
+// GetConfigForClient returns a function for [tls.Config.GetConfigForClient]
+// that only offers the ALPN protocols enabled by the route of the server name.
+func (ep *Entrypoint) GetConfigForClient(base *tls.Config) func(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		route := ep.FindRoute(hello.ServerName)
+		if route == nil {
+			return nil, nil
+		}
+		routeProtos := route.ALPNProtos()
+		protos := slices.DeleteFunc(slices.Clone(base.NextProtos), func(proto string) bool {
+			// http/1.1 is always allowed
+			return proto != "http/1.1" && !slices.Contains(routeProtos, proto)
+		})
+		if len(protos) == len(base.NextProtos) {
+			return nil, nil
+		}
+		cfg := base.Clone()
+		cfg.NextProtos = protos
+		return cfg, nil
+	}
+}
+
 func (ep *Entrypoint) FindRoute(s string) types.HTTPRoute {
-	return ep.findRouteFunc(s)
+	r := ep.findRouteFunc(s)
+	if r == nil || ep.name == "" || r.ServesEntrypoint(ep.name, ep.serveAllRoutes) {
+		return r
+	}
+	return nil
+}
+
+func (ep *Entrypoint) middlewareName() string {
+	if ep.name == "" || ep.name == entrypoint.DefaultName {
+		return "entrypoint"
+	}
+	return "entrypoint." + ep.name
 }
 
 func (ep *Entrypoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -95,9 +158,19 @@ func (ep *Entrypoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		defer ep.accessLogger.Log(r, rec.Response())
 	}
 
-	route := ep.findRouteFunc(r.Host)
+	route := ep.FindRoute(r.Host)
 	switch {
 	case route != nil:
+		if !route.AllowsProtoMajor(r.ProtoMajor) {
+			// connections may be reused for other hosts with the same certificate,
+			// ask the client to retry with a new connection
+			http.Error(w, http.StatusText(http.StatusMisdirectedRequest), http.StatusMisdirectedRequest)
+			return
+		}
+		if ep.http3 != nil && r.ProtoMajor < 3 && route.AllowsProtoMajor(3) {
+			// fails only if the server is not listening yet
+			_ = ep.http3.SetQUICHeaders(w.Header())
+		}
 		r = routes.WithRouteContext(r, route)
 		if ep.middleware != nil {
 			ep.middleware.ServeHTTP(route.ServeHTTP, w, r)
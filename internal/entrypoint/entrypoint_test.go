@@ -1,11 +1,15 @@
 package entrypoint_test
 
 import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	. "github.com/yusing/godoxy/internal/entrypoint"
 	"github.com/yusing/godoxy/internal/route"
 	"github.com/yusing/godoxy/internal/route/routes"
+	routeTypes "github.com/yusing/godoxy/internal/route/types"
 
 	expect "github.com/yusing/goutils/testing"
 )
@@ -128,3 +132,61 @@ func TestFindRouteByDomainsExactMatch(t *testing.T) {
 
 	run(t, tests, testsNoMatch)
 }
+
+func TestFindRouteNamedEntrypoint(t *testing.T) {
+	t.Cleanup(routes.Clear)
+
+	addRoute("app1")
+	routes.HTTP.Add(&route.ReveseProxyRoute{
+		Route: &route.Route{
+			Alias:       "admin",
+			Port:        route.Port{Proxy: 80},
+			Entrypoints: []string{"vpn"},
+		},
+	})
+
+	vpn := NewNamedEntrypoint("vpn")
+	lan := NewNamedEntrypoint("lan")
+	lan.SetServeAllRoutes(true)
+
+	// routes without entrypoints are only served on entrypoints serving all routes
+	expect.NotNil(t, ep.FindRoute("app1.domain.com"))
+	expect.Nil(t, vpn.FindRoute("app1.domain.com"))
+	expect.NotNil(t, lan.FindRoute("app1.domain.com"))
+
+	expect.Nil(t, ep.FindRoute("admin.domain.com"))
+	expect.NotNil(t, vpn.FindRoute("admin.domain.com"))
+	expect.Nil(t, lan.FindRoute("admin.domain.com"))
+}
+
+func TestRouteALPN(t *testing.T) {
+	t.Cleanup(routes.Clear)
+
+	addRoute("app1")
+	routes.HTTP.Add(&route.ReveseProxyRoute{
+		Route: &route.Route{
+			Alias:      "legacy",
+			Port:       route.Port{Proxy: 80},
+			HTTPConfig: routeTypes.HTTPConfig{ALPN: []string{routeTypes.ALPNHTTP1}},
+		},
+	})
+
+	base := &tls.Config{NextProtos: []string{"h2", "http/1.1"}}
+	getConfig := ep.GetConfigForClient(base)
+
+	cfg, err := getConfig(&tls.ClientHelloInfo{ServerName: "app1.domain.com"})
+	expect.NoError(t, err)
+	expect.Nil(t, cfg) // unchanged
+
+	cfg, err = getConfig(&tls.ClientHelloInfo{ServerName: "legacy.domain.com"})
+	expect.NoError(t, err)
+	expect.Equal(t, cfg.NextProtos, []string{"http/1.1"})
+	expect.Equal(t, base.NextProtos, []string{"h2", "http/1.1"})
+
+	// h2 connections reused for a route without h2
+	req := httptest.NewRequest(http.MethodGet, "https://legacy.domain.com", nil)
+	req.ProtoMajor = 2
+	rec := httptest.NewRecorder()
+	ep.ServeHTTP(rec, req)
+	expect.Equal(t, rec.Code, http.StatusMisdirectedRequest)
+}
@@ -1,15 +1,76 @@
 package entrypoint
 
 import (
+	"slices"
+	"sync/atomic"
+
+	"github.com/yusing/godoxy/internal/acl"
 	"github.com/yusing/godoxy/internal/logging/accesslog"
+	nettypes "github.com/yusing/godoxy/internal/net/types"
 	"github.com/yusing/godoxy/internal/route/rules"
+	gperr "github.com/yusing/goutils/errs"
+)
+
+// DefaultName is the name of the entrypoint listening on HTTP_ADDR and HTTPS_ADDR.
+const DefaultName = "default"
+
+// names of the configured named entrypoints, for validating route entrypoints
+var namedEntrypoints atomic.Pointer[[]string]
+
+// SetNamedEntrypoints sets the names of the configured named entrypoints.
+func SetNamedEntrypoints(names []string) {
+	namedEntrypoints.Store(&names)
+}
+
+// IsConfigured returns whether name is the default entrypoint or a configured named entrypoint.
+func IsConfigured(name string) bool {
+	if name == DefaultName {
+		return true
+	}
+	names := namedEntrypoints.Load()
+	return names != nil && slices.Contains(*names, name)
+}
+
+type (
+	Config struct {
+		SupportProxyProtocol bool `json:"support_proxy_protocol"`
+		// proxies allowed to send PROXY protocol headers on UDP (HTTP/3), required for HTTP/3 with PROXY protocol
+		ProxyProtocolTrustedProxies []*nettypes.CIDR `json:"proxy_protocol_trusted_proxies"`
+		Rules                       struct {
+			NotFound rules.Rules `json:"not_found"`
+		} `json:"rules"`
+		Middlewares []map[string]any               `json:"middlewares"`
+		AccessLog   *accesslog.RequestLoggerConfig `json:"access_log" validate:"omitempty"`
+	}
+	// NamedConfig is an additional entrypoint with its own listeners.
+	//
+	// Only routes listing the entrypoint in their entrypoints are served,
+	// unless serve_all_routes is set.
+	NamedConfig struct {
+		Config
+
+		// also serve routes without entrypoints specified, like the default entrypoint
+		ServeAllRoutes bool `json:"serve_all_routes"`
+
+		HTTPAddr     string      `json:"http_addr"`
+		HTTPSAddr    string      `json:"https_addr"`
+		ACL          *acl.Config `json:"acl"` // default: the global acl
+		TLS          *TLSConfig  `json:"tls"`
+		MatchDomains []string    `json:"match_domains" validate:"domain_name"` // default: the global match_domains
+	}
+	TLSConfig struct {
+		MinVersion string `json:"min_version" validate:"omitempty,oneof=1.2 1.3"` // default: 1.2
+		// path to the PEM encoded CA certificates, client certificates are required and verified when set
+		ClientCA string `json:"client_ca" validate:"omitempty,file"`
+	}
 )
 
-type Config struct {
-	SupportProxyProtocol bool `json:"support_proxy_protocol"`
-	Rules                struct {
-		NotFound rules.Rules `json:"not_found"`
-	} `json:"rules"`
-	Middlewares []map[string]any               `json:"middlewares"`
-	AccessLog   *accesslog.RequestLoggerConfig `json:"access_log" validate:"omitempty"`
+func (cfg *NamedConfig) Validate() gperr.Error {
+	if cfg.HTTPAddr == "" && cfg.HTTPSAddr == "" {
+		return gperr.New("http_addr or https_addr is required")
+	}
+	if cfg.TLS != nil && cfg.HTTPSAddr == "" {
+		return gperr.New("tls requires https_addr")
+	}
+	return nil
 }
@@ -163,6 +163,15 @@ func (w *Watcher) wakeFromHTTP(rw http.ResponseWriter, r *http.Request) (shouldN
 		return false
 	}
 
+	if w.isForceSleep() {
+		msg := w.cfg.ContainerName() + " is sleeping as scheduled"
+		if next := w.nextScheduledAction(); next != "" {
+			msg += ". " + next
+		}
+		http.Error(rw, msg, http.StatusServiceUnavailable)
+		return false
+	}
+
 	accept := httputils.GetAccept(r.Header)
 	acceptHTML := (r.Method == http.MethodGet && accept.AcceptHTML() || r.RequestURI == "/" && accept.IsEmpty())
 
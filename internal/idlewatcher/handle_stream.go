@@ -39,7 +39,9 @@ func (w *Watcher) preDial(ctx context.Context, predial nettypes.HookFunc) error
 }
 
 func (w *Watcher) onRead(ctx context.Context, onRead nettypes.HookFunc) error {
-	w.resetIdleTimer()
+	if !w.isIgnoredSource(ctx) {
+		w.resetIdleTimer()
+	}
 	if onRead != nil {
 		if err := onRead(ctx); err != nil {
 			return err
@@ -49,6 +51,14 @@ func (w *Watcher) onRead(ctx context.Context, onRead nettypes.HookFunc) error {
 }
 
 func (w *Watcher) wakeFromStream(ctx context.Context) error {
+	// noise traffic passes through only if the container is ready
+	if w.isIgnoredSource(ctx) {
+		if w.ready() {
+			return nil
+		}
+		return nettypes.ErrIgnored
+	}
+
 	w.resetIdleTimer()
 
 	// pass through if container is already ready
@@ -77,3 +87,39 @@ func (w *Watcher) wakeFromStream(ctx context.Context) error {
 	w.l.Debug().Stringer("url", w.hc.URL()).Msg("container is ready, passing through")
 	return nil
 }
+
+func (w *Watcher) wakeBufferConfig() *nettypes.WakeBufferConfig {
+	cfg := w.cfg.Stream
+	if cfg == nil {
+		return nil
+	}
+	return &nettypes.WakeBufferConfig{
+		WaitForData: cfg.WakeOnData,
+		Size:        cfg.BufferSize,
+		Timeout:     cfg.BufferTimeout,
+	}
+}
+
+// isIgnoredSource returns whether the client of the stream hook is in ignore_sources.
+func (w *Watcher) isIgnoredSource(ctx context.Context) bool {
+	cfg := w.cfg.Stream
+	if cfg == nil || len(cfg.IgnoreSources) == 0 {
+		return false
+	}
+
+	var ip net.IP
+	switch addr := nettypes.RemoteAddr(ctx).(type) {
+	case *net.TCPAddr:
+		ip = addr.IP
+	case *net.UDPAddr:
+		ip = addr.IP
+	default:
+		return false
+	}
+	for _, cidr := range cfg.IgnoreSources {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
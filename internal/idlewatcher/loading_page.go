@@ -9,8 +9,9 @@ import (
 )
 
 type templateData struct {
-	Title   string
-	Message string
+	Title          string
+	Message        string
+	ScheduleDetail string
 
 	FavIconPath        string
 	LoadingPageCSSPath string
@@ -34,6 +35,7 @@ func (w *Watcher) writeLoadingPage(rw http.ResponseWriter) error {
 	data := new(templateData)
 	data.Title = w.cfg.ContainerName()
 	data.Message = msg
+	data.ScheduleDetail = w.nextScheduledAction()
 	data.FavIconPath = idlewatcher.FavIconPath
 	data.LoadingPageCSSPath = idlewatcher.LoadingPageCSSPath
 	data.LoadingPageJSPath = idlewatcher.LoadingPageJSPath
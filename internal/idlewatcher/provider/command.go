@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	idlewatcher "github.com/yusing/godoxy/internal/idlewatcher/types"
+	"github.com/yusing/godoxy/internal/watcher"
+	"github.com/yusing/godoxy/internal/watcher/events"
+	gperr "github.com/yusing/goutils/errs"
+)
+
+// commandProvider runs commands on the GoDoxy host, or on the agent host if agent is set,
+// and polls the status to generate container events.
+type commandProvider struct {
+	agent  *agent.AgentConfig
+	name   string
+	status func(ctx context.Context) (idlewatcher.ContainerStatus, error)
+}
+
+const (
+	commandStatusCheckInterval = 2 * time.Second
+	commandStatusCheckTimeout  = 5 * time.Second
+)
+
+var ErrAgentNotFound = gperr.New("agent not found")
+
+func newCommandProvider(agentAddr, name string) (*commandProvider, error) {
+	p := &commandProvider{name: name}
+	if agentAddr != "" {
+		a, ok := agent.GetAgent(agentAddr)
+		if !ok {
+			return nil, ErrAgentNotFound.Subject(agentAddr)
+		}
+		p.agent = a
+	}
+	return p, nil
+}
+
+func (p *commandProvider) run(ctx context.Context, req *agent.ExecRequest) (*agent.ExecResult, error) {
+	if p.agent != nil {
+		return p.agent.Exec(ctx, req)
+	}
+	return req.Run(ctx)
+}
+
+// runSuccess runs the command and returns an error if it exits with a non-zero code.
+func (p *commandProvider) runSuccess(ctx context.Context, req *agent.ExecRequest) error {
+	result, err := p.run(ctx, req)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("%s exited with code %d: %s", req.Command[0], result.ExitCode, strings.TrimSpace(result.Output))
+	}
+	return nil
+}
+
+func (p *commandProvider) Watch(ctx context.Context) (<-chan watcher.Event, <-chan gperr.Error) {
+	eventCh := make(chan watcher.Event)
+	errCh := make(chan gperr.Error)
+
+	go func() {
+		defer close(eventCh)
+		defer close(errCh)
+
+		ticker := time.NewTicker(commandStatusCheckInterval)
+		defer ticker.Stop()
+
+		event := watcher.Event{
+			Type:      events.EventTypeDocker,
+			ActorID:   p.name,
+			ActorName: p.name,
+		}
+		lastStatus, _ := p.checkStatus(ctx)
+		reportErr := true
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			status, err := p.checkStatus(ctx)
+			if err != nil {
+				// report once until recovered
+				if reportErr {
+					reportErr = false
+					select {
+					case errCh <- gperr.Wrap(err):
+					case <-ctx.Done():
+						return
+					}
+				}
+				continue
+			}
+			reportErr = true
+			if status == lastStatus {
+				continue
+			}
+			lastStatus = status
+
+			switch status {
+			case idlewatcher.ContainerStatusRunning:
+				event.Action = events.ActionContainerStart
+			case idlewatcher.ContainerStatusPaused:
+				event.Action = events.ActionContainerPause
+			default:
+				event.Action = events.ActionContainerStop
+			}
+			select {
+			case eventCh <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return eventCh, errCh
+}
+
+func (p *commandProvider) checkStatus(ctx context.Context) (idlewatcher.ContainerStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandStatusCheckTimeout)
+	defer cancel()
+	return p.status(ctx)
+}
+
+func (p *commandProvider) Close() {
+	// noop
+}
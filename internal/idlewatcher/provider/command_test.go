@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"path/filepath"
+	"testing"
+
+	idlewatcher "github.com/yusing/godoxy/internal/idlewatcher/types"
+	"github.com/yusing/godoxy/internal/types"
+	expect "github.com/yusing/goutils/testing"
+)
+
+func TestExecProvider(t *testing.T) {
+	cfg := &types.ExecConfig{
+		Start:  `touch "$STATE_FILE"`,
+		Stop:   `rm "$STATE_FILE"`,
+		Status: `test -f "$STATE_FILE"`,
+		Env:    map[string]string{"STATE_FILE": filepath.Join(t.TempDir(), "running")},
+	}
+	p, err := NewExecProvider("app", cfg)
+	expect.NoError(t, err)
+
+	status, err := p.ContainerStatus(t.Context())
+	expect.NoError(t, err)
+	expect.Equal(t, status, idlewatcher.ContainerStatusStopped)
+
+	expect.NoError(t, p.ContainerStart(t.Context()))
+	status, err = p.ContainerStatus(t.Context())
+	expect.NoError(t, err)
+	expect.Equal(t, status, idlewatcher.ContainerStatusRunning)
+
+	expect.NoError(t, p.ContainerStop(t.Context(), "", 0))
+	status, err = p.ContainerStatus(t.Context())
+	expect.NoError(t, err)
+	expect.Equal(t, status, idlewatcher.ContainerStatusStopped)
+
+	// state file already removed
+	expect.ErrorContains(t, p.ContainerStop(t.Context(), "", 0), "exited with code 1")
+	expect.ErrorIs(t, ErrPauseNotSupported, p.ContainerPause(t.Context()))
+}
+
+func TestExecProviderWithoutStatus(t *testing.T) {
+	p, err := NewExecProvider("app", &types.ExecConfig{Start: "true", Stop: "true"})
+	expect.NoError(t, err)
+
+	expect.NoError(t, p.ContainerStart(t.Context()))
+	status, err := p.ContainerStatus(t.Context())
+	expect.NoError(t, err)
+	expect.Equal(t, status, idlewatcher.ContainerStatusRunning)
+
+	expect.NoError(t, p.ContainerStop(t.Context(), "", 0))
+	status, err = p.ContainerStatus(t.Context())
+	expect.NoError(t, err)
+	expect.Equal(t, status, idlewatcher.ContainerStatusStopped)
+}
+
+func TestParseSystemdStatus(t *testing.T) {
+	tests := []struct {
+		output string
+		want   idlewatcher.ContainerStatus
+	}{
+		{"LoadState=loaded\nActiveState=active\nFreezerState=running\n", idlewatcher.ContainerStatusRunning},
+		{"LoadState=loaded\nActiveState=activating\nFreezerState=running\n", idlewatcher.ContainerStatusRunning},
+		{"LoadState=loaded\nActiveState=active\nFreezerState=frozen\n", idlewatcher.ContainerStatusPaused},
+		{"LoadState=loaded\nActiveState=inactive\nFreezerState=running\n", idlewatcher.ContainerStatusStopped},
+		{"LoadState=loaded\nActiveState=failed\n", idlewatcher.ContainerStatusStopped},
+	}
+	for _, tc := range tests {
+		status, err := parseSystemdStatus("app.service", tc.output)
+		expect.NoError(t, err)
+		expect.Equal(t, status, tc.want)
+	}
+
+	_, err := parseSystemdStatus("app.service", "LoadState=not-found\nActiveState=inactive\n")
+	expect.ErrorIs(t, ErrUnitNotFound, err)
+}
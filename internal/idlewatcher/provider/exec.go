@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	idlewatcher "github.com/yusing/godoxy/internal/idlewatcher/types"
+	"github.com/yusing/godoxy/internal/types"
+)
+
+type ExecProvider struct {
+	*commandProvider
+	cfg *types.ExecConfig
+
+	running atomic.Bool // used when there is no status command
+}
+
+var ErrPauseNotSupported = errors.New("pause is not supported by exec provider")
+
+func NewExecProvider(name string, cfg *types.ExecConfig) (idlewatcher.Provider, error) {
+	p := &ExecProvider{cfg: cfg}
+	cmdProvider, err := newCommandProvider(cfg.Agent, name)
+	if err != nil {
+		return nil, err
+	}
+	p.commandProvider = cmdProvider
+	p.status = p.ContainerStatus
+	return p, nil
+}
+
+func (p *ExecProvider) request(command string) *agent.ExecRequest {
+	return &agent.ExecRequest{
+		Command: []string{"sh", "-c", command},
+		Env:     p.cfg.Env,
+		Dir:     p.cfg.Dir,
+		Timeout: p.cfg.Timeout,
+	}
+}
+
+func (p *ExecProvider) ContainerPause(ctx context.Context) error {
+	return ErrPauseNotSupported
+}
+
+func (p *ExecProvider) ContainerUnpause(ctx context.Context) error {
+	return ErrPauseNotSupported
+}
+
+func (p *ExecProvider) ContainerStart(ctx context.Context) error {
+	if err := p.runSuccess(ctx, p.request(p.cfg.Start)); err != nil {
+		return err
+	}
+	p.running.Store(true)
+	return nil
+}
+
+func (p *ExecProvider) ContainerStop(ctx context.Context, _ types.ContainerSignal, _ int) error {
+	if err := p.runSuccess(ctx, p.request(p.cfg.Stop)); err != nil {
+		return err
+	}
+	p.running.Store(false)
+	return nil
+}
+
+func (p *ExecProvider) ContainerKill(ctx context.Context, _ types.ContainerSignal) error {
+	return p.ContainerStop(ctx, "", 0)
+}
+
+func (p *ExecProvider) ContainerStatus(ctx context.Context) (idlewatcher.ContainerStatus, error) {
+	if p.cfg.Status == "" {
+		if p.running.Load() {
+			return idlewatcher.ContainerStatusRunning, nil
+		}
+		return idlewatcher.ContainerStatusStopped, nil
+	}
+
+	result, err := p.run(ctx, p.request(p.cfg.Status))
+	if err != nil {
+		return idlewatcher.ContainerStatusError, err
+	}
+	if result.ExitCode == 0 {
+		return idlewatcher.ContainerStatusRunning, nil
+	}
+	return idlewatcher.ContainerStatusStopped, nil
+}
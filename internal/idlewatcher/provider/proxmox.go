@@ -16,8 +16,9 @@ import (
 type ProxmoxProvider struct {
 	*proxmox.Node
 
+	vmType  proxmox.VMType
 	vmid    int
-	lxcName string
+	vmName  string
 	running bool
 }
 
@@ -25,7 +26,7 @@ const proxmoxStateCheckInterval = 1 * time.Second
 
 var ErrNodeNotFound = gperr.New("node not found in pool")
 
-func NewProxmoxProvider(nodeName string, vmid int) (idlewatcher.Provider, error) {
+func NewProxmoxProvider(nodeName string, vmType proxmox.VMType, vmid int) (idlewatcher.Provider, error) {
 	node, ok := proxmox.Nodes.Get(nodeName)
 	if !ok {
 		return nil, ErrNodeNotFound.Subject(nodeName).
@@ -35,34 +36,64 @@ func NewProxmoxProvider(nodeName string, vmid int) (idlewatcher.Provider, error)
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	lxcName, err := node.LXCName(ctx, vmid)
+	vmName, err := node.VMName(ctx, vmType, vmid)
 	if err != nil {
 		return nil, err
 	}
-	return &ProxmoxProvider{Node: node, vmid: vmid, lxcName: lxcName}, nil
+	return &ProxmoxProvider{Node: node, vmType: vmType, vmid: vmid, vmName: vmName}, nil
 }
 
+// ContainerPause suspends the container, or hibernates the vm (suspend to disk).
 func (p *ProxmoxProvider) ContainerPause(ctx context.Context) error {
+	if p.vmType.IsQEMU() {
+		return p.QEMUAction(ctx, p.vmid, proxmox.QEMUHibernate)
+	}
 	return p.LXCAction(ctx, p.vmid, proxmox.LXCSuspend)
 }
 
 func (p *ProxmoxProvider) ContainerUnpause(ctx context.Context) error {
+	if p.vmType.IsQEMU() {
+		return p.QEMUAction(ctx, p.vmid, proxmox.QEMUResume)
+	}
 	return p.LXCAction(ctx, p.vmid, proxmox.LXCResume)
 }
 
+// ContainerStart starts the container or vm, a hibernated vm is resumed from disk.
 func (p *ProxmoxProvider) ContainerStart(ctx context.Context) error {
-	return p.LXCAction(ctx, p.vmid, proxmox.LXCStart)
+	return p.VMStart(ctx, p.vmType, p.vmid)
 }
 
 func (p *ProxmoxProvider) ContainerStop(ctx context.Context, _ types.ContainerSignal, _ int) error {
+	if p.vmType.IsQEMU() {
+		return p.QEMUAction(ctx, p.vmid, proxmox.QEMUShutdown)
+	}
 	return p.LXCAction(ctx, p.vmid, proxmox.LXCShutdown)
 }
 
 func (p *ProxmoxProvider) ContainerKill(ctx context.Context, _ types.ContainerSignal) error {
+	if p.vmType.IsQEMU() {
+		return p.QEMUAction(ctx, p.vmid, proxmox.QEMUStop)
+	}
 	return p.LXCAction(ctx, p.vmid, proxmox.LXCShutdown)
 }
 
 func (p *ProxmoxProvider) ContainerStatus(ctx context.Context) (idlewatcher.ContainerStatus, error) {
+	if p.vmType.IsQEMU() {
+		status, err := p.QEMUStatus(ctx, p.vmid)
+		if err != nil {
+			return idlewatcher.ContainerStatusError, err
+		}
+		switch status {
+		case proxmox.QEMUStatusRunning:
+			return idlewatcher.ContainerStatusRunning, nil
+		case proxmox.QEMUStatusPaused:
+			return idlewatcher.ContainerStatusPaused, nil
+		case proxmox.QEMUStatusStopped:
+			return idlewatcher.ContainerStatusStopped, nil
+		}
+		return idlewatcher.ContainerStatusError, idlewatcher.ErrUnexpectedContainerStatus.Subject(string(status))
+	}
+
 	status, err := p.LXCStatus(ctx, p.vmid)
 	if err != nil {
 		return idlewatcher.ContainerStatusError, err
@@ -76,6 +107,10 @@ func (p *ProxmoxProvider) ContainerStatus(ctx context.Context) (idlewatcher.Cont
 	return idlewatcher.ContainerStatusError, idlewatcher.ErrUnexpectedContainerStatus.Subject(string(status))
 }
 
+func (p *ProxmoxProvider) SetShutdownTimeout(ctx context.Context, timeout time.Duration) error {
+	return p.VMSetShutdownTimeout(ctx, p.vmType, p.vmid, timeout)
+}
+
 func (p *ProxmoxProvider) Watch(ctx context.Context) (<-chan watcher.Event, <-chan gperr.Error) {
 	eventCh := make(chan watcher.Event)
 	errCh := make(chan gperr.Error)
@@ -85,7 +120,7 @@ func (p *ProxmoxProvider) Watch(ctx context.Context) (<-chan watcher.Event, <-ch
 		defer close(errCh)
 
 		var err error
-		p.running, err = p.LXCIsRunning(ctx, p.vmid)
+		p.running, err = p.VMIsRunning(ctx, p.vmType, p.vmid)
 		if err != nil {
 			errCh <- gperr.Wrap(err)
 			return
@@ -97,7 +132,7 @@ func (p *ProxmoxProvider) Watch(ctx context.Context) (<-chan watcher.Event, <-ch
 		event := watcher.Event{
 			Type:      events.EventTypeDocker,
 			ActorID:   strconv.Itoa(p.vmid),
-			ActorName: p.lxcName,
+			ActorName: p.vmName,
 		}
 		for {
 			select {
@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"strings"
+
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	idlewatcher "github.com/yusing/godoxy/internal/idlewatcher/types"
+	"github.com/yusing/godoxy/internal/types"
+	gperr "github.com/yusing/goutils/errs"
+)
+
+type SystemdProvider struct {
+	*commandProvider
+	cfg *types.SystemdConfig
+}
+
+var ErrUnitNotFound = gperr.New("unit not found")
+
+func NewSystemdProvider(cfg *types.SystemdConfig) (idlewatcher.Provider, error) {
+	p := &SystemdProvider{cfg: cfg}
+	cmdProvider, err := newCommandProvider(cfg.Agent, cfg.Unit)
+	if err != nil {
+		return nil, err
+	}
+	p.commandProvider = cmdProvider
+	p.status = p.ContainerStatus
+	return p, nil
+}
+
+func (p *SystemdProvider) systemctl(args ...string) *agent.ExecRequest {
+	command := make([]string, 0, len(args)+3)
+	command = append(command, "systemctl")
+	if p.cfg.User {
+		command = append(command, "--user")
+	}
+	command = append(command, args...)
+	command = append(command, p.cfg.Unit)
+	return &agent.ExecRequest{Command: command}
+}
+
+func (p *SystemdProvider) ContainerPause(ctx context.Context) error {
+	return p.runSuccess(ctx, p.systemctl("freeze"))
+}
+
+func (p *SystemdProvider) ContainerUnpause(ctx context.Context) error {
+	return p.runSuccess(ctx, p.systemctl("thaw"))
+}
+
+func (p *SystemdProvider) ContainerStart(ctx context.Context) error {
+	return p.runSuccess(ctx, p.systemctl("start"))
+}
+
+// ContainerStop stops the unit, the signal and timeout are configured in the unit.
+func (p *SystemdProvider) ContainerStop(ctx context.Context, _ types.ContainerSignal, _ int) error {
+	return p.runSuccess(ctx, p.systemctl("stop"))
+}
+
+func (p *SystemdProvider) ContainerKill(ctx context.Context, signal types.ContainerSignal) error {
+	if signal == "" {
+		return p.runSuccess(ctx, p.systemctl("kill"))
+	}
+	return p.runSuccess(ctx, p.systemctl("kill", "--signal="+string(signal)))
+}
+
+func (p *SystemdProvider) ContainerStatus(ctx context.Context) (idlewatcher.ContainerStatus, error) {
+	req := p.systemctl("show", "--property=LoadState,ActiveState,FreezerState")
+	result, err := p.run(ctx, req)
+	if err != nil {
+		return idlewatcher.ContainerStatusError, err
+	}
+	if result.ExitCode != 0 {
+		return idlewatcher.ContainerStatusError, gperr.Errorf("systemctl exited with code %d: %s", result.ExitCode, strings.TrimSpace(result.Output))
+	}
+	return parseSystemdStatus(p.cfg.Unit, result.Output)
+}
+
+// parseSystemdStatus parses the output of `systemctl show --property=LoadState,ActiveState,FreezerState`.
+func parseSystemdStatus(unit, output string) (idlewatcher.ContainerStatus, error) {
+	props := make(map[string]string, 3)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		if k, v, ok := strings.Cut(scanner.Text(), "="); ok {
+			props[k] = v
+		}
+	}
+
+	if props["LoadState"] == "not-found" {
+		return idlewatcher.ContainerStatusError, ErrUnitNotFound.Subject(unit)
+	}
+	if props["FreezerState"] == "frozen" {
+		return idlewatcher.ContainerStatusPaused, nil
+	}
+	switch activeState := props["ActiveState"]; activeState {
+	case "active", "activating", "reloading":
+		return idlewatcher.ContainerStatusRunning, nil
+	case "inactive", "failed", "deactivating":
+		return idlewatcher.ContainerStatusStopped, nil
+	default:
+		return idlewatcher.ContainerStatusError, idlewatcher.ErrUnexpectedContainerStatus.Subject(activeState)
+	}
+}
@@ -0,0 +1,94 @@
+package idlewatcher
+
+import (
+	"errors"
+	"time"
+
+	"github.com/yusing/godoxy/internal/types"
+	gperr "github.com/yusing/goutils/errs"
+	strutils "github.com/yusing/goutils/strings"
+)
+
+var errForceSleep = errors.New("sleeping as scheduled")
+
+// schedule returns the schedule of the watcher, or nil if there is none.
+//
+// Dependencies have no schedule of their own, they are woken and stopped along with the dependent.
+func (w *Watcher) schedule() *types.IdlewatcherSchedule {
+	if w.cfg.IdleTimeout == neverTick || w.cfg.Schedule.IsEmpty() {
+		return nil
+	}
+	return w.cfg.Schedule
+}
+
+// untilNextMinute returns the duration until the start of the next minute,
+// schedules are applied at the start of each minute.
+func untilNextMinute(now time.Time) time.Duration {
+	return now.Truncate(time.Minute).Add(time.Minute).Sub(now)
+}
+
+// applySchedule updates the schedule status, and wakes or stops the container as scheduled at now.
+func (w *Watcher) applySchedule(now time.Time) {
+	s := w.schedule()
+	if s == nil {
+		w.lastScheduleStatus.Store(nil)
+		return
+	}
+	status := s.Status(now)
+	w.lastScheduleStatus.Store(status)
+
+	switch {
+	case status.ForceSleep:
+		if !w.running() {
+			return
+		}
+		w.l.Info().Msg("sleeping as scheduled")
+		if err := w.stopByMethod(); err != nil {
+			gperr.LogError("failed to stop container as scheduled", err, &w.l)
+		}
+	case status.KeepAwake || s.IsPreWarm(now):
+		if w.running() {
+			w.resetIdleTimer()
+			return
+		}
+		w.l.Info().Msg("waking as scheduled")
+		go func() {
+			if err := w.Wake(w.task.Context()); err != nil {
+				gperr.LogError("failed to wake container as scheduled", err, &w.l)
+			}
+		}()
+	}
+}
+
+func (w *Watcher) isForceSleep() bool {
+	s := w.schedule()
+	return s != nil && s.IsForceSleep(time.Now())
+}
+
+func (w *Watcher) isKeepAwake() bool {
+	s := w.schedule()
+	return s != nil && s.IsKeepAwake(time.Now())
+}
+
+// scheduleStatus returns the schedule status of the last minute, with the time of the next action formatted at now.
+func (w *Watcher) scheduleStatus() *types.IdlewatcherScheduleStatus {
+	status := w.lastScheduleStatus.Load()
+	if status == nil || status.NextAction == "" {
+		return status
+	}
+	cloned := *status
+	cloned.NextActionAtStr = strutils.FormatUnixTime(status.NextActionAt)
+	return &cloned
+}
+
+// nextScheduledAction returns a human readable description of the next scheduled action, if any.
+func (w *Watcher) nextScheduledAction() string {
+	status := w.scheduleStatus()
+	if status == nil || status.NextAction == "" {
+		return ""
+	}
+	if status.NextAction == types.IdlewatcherScheduleActionSleep {
+		return "Next scheduled sleep: " + status.NextActionAtStr
+	}
+	return "Next scheduled wake: " + status.NextActionAtStr
+}
@@ -60,6 +60,8 @@ type (
 		state     synk.Value[*containerState]
 		lastReset synk.Value[time.Time]
 
+		lastScheduleStatus synk.Value[*types.IdlewatcherScheduleStatus]
+
 		idleTicker    *time.Ticker
 		healthTicker  *time.Ticker
 		readyNotifyCh chan struct{} // notifies when container becomes ready
@@ -122,6 +124,7 @@ func NewWatcher(parent task.Parent, r types.Route, cfg *types.IdlewatcherConfig)
 		}
 		if cfg.IdleTimeout > 0 {
 			w.cfg.IdlewatcherConfigBase = cfg.IdlewatcherConfigBase
+			w.cfg.Stream = cfg.Stream
 		}
 		cfg = w.cfg
 		w.resetIdleTimer()
@@ -205,7 +208,7 @@ func NewWatcher(parent task.Parent, r types.Route, cfg *types.IdlewatcherConfig)
 			continue
 		}
 
-		if depCfg.Docker == nil && depCfg.Proxmox == nil {
+		if !depCfg.HasProvider() {
 			depCont := depRoute.ContainerInfo()
 			if depCont != nil {
 				depCfg.Docker = &types.DockerConfig{
@@ -258,8 +261,14 @@ func NewWatcher(parent task.Parent, r types.Route, cfg *types.IdlewatcherConfig)
 	case cfg.Docker != nil:
 		p, err = provider.NewDockerProvider(cfg.Docker.DockerHost, cfg.Docker.ContainerID)
 		kind = "docker"
+	case cfg.Exec != nil:
+		p, err = provider.NewExecProvider(cfg.ContainerName(), cfg.Exec)
+		kind = "exec"
+	case cfg.Systemd != nil:
+		p, err = provider.NewSystemdProvider(cfg.Systemd)
+		kind = "systemd"
 	default:
-		p, err = provider.NewProxmoxProvider(cfg.Proxmox.Node, cfg.Proxmox.VMID)
+		p, err = provider.NewProxmoxProvider(cfg.Proxmox.Node, cfg.Proxmox.VMType, cfg.Proxmox.VMID)
 		kind = "proxmox"
 	}
 	targetURL := r.TargetURL()
@@ -286,6 +295,9 @@ func NewWatcher(parent task.Parent, r types.Route, cfg *types.IdlewatcherConfig)
 		w.rp = r.ReverseProxy()
 	case types.StreamRoute:
 		w.stream = r.Stream()
+		if s, ok := w.stream.(nettypes.WakeBufferedStream); ok {
+			s.SetWakeBuffer(w.wakeBufferConfig())
+		}
 	default:
 		p.Close()
 		return nil, w.newWatcherError(gperr.Errorf("unexpected route type: %T", r))
@@ -305,7 +317,7 @@ func NewWatcher(parent task.Parent, r types.Route, cfg *types.IdlewatcherConfig)
 	switch p := p.(type) { //nolint:gocritic
 	case *provider.ProxmoxProvider:
 		shutdownTimeout := max(time.Second, cfg.StopTimeout-idleWakerCheckTimeout)
-		err = p.LXCSetShutdownTimeout(ctx, cfg.Proxmox.VMID, shutdownTimeout)
+		err = p.SetShutdownTimeout(ctx, shutdownTimeout)
 		if err != nil {
 			w.l.Warn().Err(err).Msg("failed to set shutdown timeout")
 		}
@@ -371,7 +383,13 @@ func (w *Watcher) Key() string {
 // If the container is not running, it will start it.
 // If the container is paused, it will unpause it.
 // If the container is stopped, it will do nothing.
+// If the container is scheduled to sleep, it will return an error.
 func (w *Watcher) Wake(ctx context.Context) error {
+	if w.isForceSleep() {
+		w.sendEvent(WakeEventError, w.cfg.ContainerName()+" is sleeping as scheduled", errForceSleep)
+		return w.newWatcherError(errForceSleep)
+	}
+
 	// wake dependencies first.
 	if err := w.wakeDependencies(ctx); err != nil {
 		w.sendEvent(WakeEventError, "Failed to wake dependencies", err)
@@ -558,7 +576,9 @@ func (w *Watcher) expires() time.Time {
 // or killed, the idle timer is stopped and the ContainerRunning flag is set to false.
 //
 // When the idle timer fires, the container is stopped according to the
-// stop method.
+// stop method, unless it is scheduled to keep awake.
+//
+// At the start of each minute, the container is woken or stopped as scheduled.
 //
 // it exits only if the context is canceled, the container is destroyed,
 // errors occurred on docker client, or route provider died (mainly caused by config reload).
@@ -570,6 +590,10 @@ func (w *Watcher) watchUntilDestroy() (returnCause error) {
 	defer p.Close()
 	eventCh, errCh := p.Watch(w.Task().Context())
 
+	// apply the schedule once on start, then at the start of each minute
+	scheduleTimer := time.NewTimer(0)
+	defer scheduleTimer.Stop()
+
 	for {
 		select {
 		case <-w.task.Context().Done():
@@ -621,7 +645,14 @@ func (w *Watcher) watchUntilDestroy() (returnCause error) {
 				}
 				// If not ready yet, keep checking on next tick
 			}
+		case now := <-scheduleTimer.C:
+			w.applySchedule(now)
+			scheduleTimer.Reset(untilNextMinute(time.Now()))
 		case <-w.idleTicker.C:
+			if w.isKeepAwake() {
+				w.resetIdleTimer()
+				continue
+			}
 			w.idleTicker.Stop()
 			if w.running() {
 				err := w.stopByMethod()
@@ -24,6 +24,8 @@ type (
 		Log(req *http.Request, res *http.Response)
 		LogError(req *http.Request, err error)
 		LogACL(info *maxmind.IPInfo, blocked bool)
+		LogWAF(req *http.Request, info *WAFEvent)
+		LogAudit(req *http.Request, info *AuditEvent)
 
 		Config() *Config
 
@@ -49,6 +51,8 @@ type (
 
 		RequestFormatter
 		ACLFormatter
+		WAFFormatter
+		AuditFormatter
 	}
 
 	Writer interface {
@@ -75,6 +79,36 @@ type (
 		// AppendACLLog appends a log line to line with or without a trailing newline
 		AppendACLLog(line []byte, info *maxmind.IPInfo, blocked bool) []byte
 	}
+	WAFFormatter interface {
+		// AppendWAFLog appends a log line to line with or without a trailing newline
+		AppendWAFLog(line []byte, req *http.Request, info *WAFEvent) []byte
+	}
+
+	AuditFormatter interface {
+		// AppendAuditLog appends a log line to line with or without a trailing newline
+		AppendAuditLog(line []byte, req *http.Request, info *AuditEvent) []byte
+	}
+
+	// WAFEvent is the audit record of a request matched by the waf middleware.
+	WAFEvent struct {
+		Score   int
+		Blocked bool
+		Matches []WAFMatch
+	}
+	WAFMatch struct {
+		RuleID  string
+		Target  string
+		Message string
+	}
+	// AuditEvent is the audit record of a state changing API request.
+	AuditEvent struct {
+		IP       string // client IP resolved by the API server
+		User     string
+		Role     string
+		TokenID  string
+		Status   int
+		Duration time.Duration
+	}
 )
 
 var writerLocks = xsync.NewMap[string, *sync.Mutex]()
@@ -146,6 +180,10 @@ func NewAccessLoggerWithIO(parent task.Parent, writer Writer, anyCfg AnyConfig)
 		default: // should not happen, validation has done by validate tags
 			panic("invalid access log format")
 		}
+	} else if cfg.waf != nil {
+		l.WAFFormatter = WAFLogFormatter{}
+	} else if cfg.audit != nil {
+		l.AuditFormatter = AuditLogFormatter{}
 	} else {
 		l.ACLFormatter = ACLLogFormatter{}
 	}
@@ -196,6 +234,26 @@ func (l *accessLogger) LogACL(info *maxmind.IPInfo, blocked bool) {
 	bytesPool.Put(line)
 }
 
+func (l *accessLogger) LogWAF(req *http.Request, info *WAFEvent) {
+	line := bytesPool.Get()
+	line = l.AppendWAFLog(line, req, info)
+	if line[len(line)-1] != '\n' {
+		line = append(line, '\n')
+	}
+	l.write(line)
+	bytesPool.Put(line)
+}
+
+func (l *accessLogger) LogAudit(req *http.Request, info *AuditEvent) {
+	line := bytesPool.Get()
+	line = l.AppendAuditLog(line, req, info)
+	if line[len(line)-1] != '\n' {
+		line = append(line, '\n')
+	}
+	l.write(line)
+	bytesPool.Put(line)
+}
+
 func (l *accessLogger) ShouldRotate() bool {
 	return l.supportRotate != nil && l.cfg.Retention.IsValid()
 }
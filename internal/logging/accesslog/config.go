@@ -19,6 +19,12 @@ type (
 		ConfigBase
 		LogAllowed bool `json:"log_allowed"`
 	}
+	WAFLoggerConfig struct {
+		ConfigBase
+	}
+	AuditLoggerConfig struct {
+		ConfigBase
+	}
 	RequestLoggerConfig struct {
 		ConfigBase
 		Format  Format  `json:"format" validate:"oneof=common combined json"`
@@ -27,8 +33,10 @@ type (
 	} // @name RequestLoggerConfig
 	Config struct {
 		ConfigBase
-		acl *ACLLoggerConfig
-		req *RequestLoggerConfig
+		acl   *ACLLoggerConfig
+		waf   *WAFLoggerConfig
+		audit *AuditLoggerConfig
+		req   *RequestLoggerConfig
 	}
 	AnyConfig interface {
 		ToConfig() *Config
@@ -88,6 +96,20 @@ func (cfg *ACLLoggerConfig) ToConfig() *Config {
 	}
 }
 
+func (cfg *WAFLoggerConfig) ToConfig() *Config {
+	return &Config{
+		ConfigBase: cfg.ConfigBase,
+		waf:        cfg,
+	}
+}
+
+func (cfg *AuditLoggerConfig) ToConfig() *Config {
+	return &Config{
+		ConfigBase: cfg.ConfigBase,
+		audit:      cfg,
+	}
+}
+
 func (cfg *RequestLoggerConfig) ToConfig() *Config {
 	return &Config{
 		ConfigBase: cfg.ConfigBase,
@@ -123,7 +145,24 @@ func DefaultACLLoggerConfig() *ACLLoggerConfig {
 	}
 }
 
+func DefaultWAFLoggerConfig() *WAFLoggerConfig {
+	return &WAFLoggerConfig{
+		ConfigBase: ConfigBase{
+			Retention: &Retention{Days: 30},
+		},
+	}
+}
+
+func DefaultAuditLoggerConfig() *AuditLoggerConfig {
+	return &AuditLoggerConfig{
+		ConfigBase: ConfigBase{
+			Retention: &Retention{KeepSize: 100 * megabyte},
+		},
+	}
+}
+
 func init() {
 	serialization.RegisterDefaultValueFactory(DefaultRequestLoggerConfig)
 	serialization.RegisterDefaultValueFactory(DefaultACLLoggerConfig)
+	serialization.RegisterDefaultValueFactory(DefaultWAFLoggerConfig)
 }
@@ -19,6 +19,8 @@ type (
 	CombinedFormatter struct{ CommonFormatter }
 	JSONFormatter     struct{ CommonFormatter }
 	ACLLogFormatter   struct{}
+	WAFLogFormatter   struct{}
+	AuditLogFormatter struct{}
 )
 
 const LogTimeFormat = "02/Jan/2006:15:04:05 -0700"
@@ -151,3 +153,58 @@ func (f ACLLogFormatter) AppendACLLog(line []byte, info *maxmind.IPInfo, blocked
 	event.Send()
 	return writer.Bytes()
 }
+
+func (f WAFLogFormatter) AppendWAFLog(line []byte, req *http.Request, info *WAFEvent) []byte {
+	writer := bytes.NewBuffer(line)
+	logger := zerolog.New(writer)
+	event := logger.Info().
+		Str("time", utils.TimeNow().Format(LogTimeFormat)).
+		Str("ip", clientIP(req)).
+		Str("method", req.Method).
+		Str("host", req.Host).
+		Str("uri", req.URL.RequestURI()).
+		Str("useragent", req.UserAgent()).
+		Int("score", info.Score)
+	if info.Blocked {
+		event.Str("action", "block")
+	} else {
+		event.Str("action", "log")
+	}
+	arr := zerolog.Arr()
+	for _, m := range info.Matches {
+		arr.Dict(zerolog.Dict().
+			Str("id", m.RuleID).
+			Str("target", m.Target).
+			Str("msg", m.Message))
+	}
+	event.Array("matches", arr)
+	// NOTE: zerolog will append a newline to the buffer
+	event.Send()
+	return writer.Bytes()
+}
+
+func (f AuditLogFormatter) AppendAuditLog(line []byte, req *http.Request, info *AuditEvent) []byte {
+	ip := info.IP
+	if ip == "" {
+		ip = clientIP(req)
+	}
+	writer := bytes.NewBuffer(line)
+	logger := zerolog.New(writer)
+	event := logger.Info().
+		Str("time", utils.TimeNow().Format(LogTimeFormat)).
+		Str("ip", ip).
+		Str("method", req.Method).
+		Str("path", req.URL.Path).
+		Str("query", req.URL.RawQuery).
+		Int("status", info.Status).
+		Dur("duration", info.Duration)
+	if info.User != "" {
+		event.Str("user", info.User).Str("role", info.Role)
+	}
+	if info.TokenID != "" {
+		event.Str("token_id", info.TokenID)
+	}
+	// NOTE: zerolog will append a newline to the buffer
+	event.Send()
+	return writer.Bytes()
+}
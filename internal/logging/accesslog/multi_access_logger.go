@@ -49,6 +49,18 @@ func (m *MultiAccessLogger) LogACL(info *maxmind.IPInfo, blocked bool) {
 	}
 }
 
+func (m *MultiAccessLogger) LogWAF(req *http.Request, info *WAFEvent) {
+	for _, accessLogger := range m.accessLoggers {
+		accessLogger.LogWAF(req, info)
+	}
+}
+
+func (m *MultiAccessLogger) LogAudit(req *http.Request, info *AuditEvent) {
+	for _, accessLogger := range m.accessLoggers {
+		accessLogger.LogAudit(req, info)
+	}
+}
+
 func (m *MultiAccessLogger) Flush() {
 	for _, accessLogger := range m.accessLoggers {
 		accessLogger.Flush()
@@ -17,7 +17,6 @@ import (
 )
 
 // TODO: stats of each server.
-// TODO: support weighted mode.
 type (
 	impl interface {
 		OnAddServer(srv types.LoadBalancerServer)
@@ -27,6 +26,9 @@ type (
 	customServeHTTP interface {
 		ServeHTTP(srvs types.LoadBalancerServers, rw http.ResponseWriter, r *http.Request)
 	}
+	overrider interface {
+		matchOverride(w http.ResponseWriter, r *http.Request) bool
+	}
 
 	LoadBalancer struct {
 		impl
@@ -46,6 +48,11 @@ type (
 
 const maxWeight int = 100
 
+var (
+	ErrInvalidWeight  = gperr.New("invalid weight")
+	ErrServerNotFound = gperr.New("server not found")
+)
+
 func New(cfg *types.LoadBalancerConfig) *LoadBalancer {
 	lb := &LoadBalancer{
 		LoadBalancerConfig: cfg,
@@ -89,6 +96,17 @@ func (lb *LoadBalancer) updateImpl() {
 		lb.impl = lb.newLeastConn()
 	case types.LoadbalanceModeIPHash:
 		lb.impl = lb.newIPHash()
+	case types.LoadbalanceModeWeighted:
+		lb.impl = lb.newWeighted()
+	case types.LoadbalanceModeSplit:
+		lb.impl = lb.newWeighted()
+		// the config is shared with the route, copy it before enabling sticky sessions
+		cfg := *lb.LoadBalancerConfig
+		cfg.Sticky = true
+		if cfg.StickyMaxAge == 0 {
+			cfg.StickyMaxAge = types.StickyMaxAgeDefault
+		}
+		lb.LoadBalancerConfig = &cfg
 	default: // should happen in test only
 		lb.impl = lb.newRoundRobin()
 	}
@@ -163,6 +181,55 @@ func (lb *LoadBalancer) RemoveServer(srv types.LoadBalancerServer) {
 	}
 }
 
+// SetWeights updates the weights of servers by name or key without reloading,
+// weights are then rebalanced to sum up to 100.
+//
+// Nothing is changed if any of the weights is invalid or refers to an unknown server.
+func (lb *LoadBalancer) SetWeights(weights map[string]int) gperr.Error {
+	for name, weight := range weights {
+		if weight < 0 || weight > maxWeight {
+			return ErrInvalidWeight.Subject(name).With(gperr.Errorf("must be between 0 and %d, got %d", maxWeight, weight))
+		}
+	}
+
+	lb.poolMu.Lock()
+	defer lb.poolMu.Unlock()
+
+	found := make(map[string]struct{}, len(weights))
+	for _, srv := range lb.pool.Iter {
+		for _, name := range []string{srv.Name(), srv.Key()} {
+			if _, ok := weights[name]; ok {
+				found[name] = struct{}{}
+			}
+		}
+	}
+
+	errs := gperr.NewBuilder("failed to set weights")
+	for name := range weights {
+		if _, ok := found[name]; !ok {
+			errs.Add(ErrServerNotFound.Subject(name))
+		}
+	}
+	if errs.HasError() {
+		return errs.Error()
+	}
+
+	lb.sumWeight = 0
+	for _, srv := range lb.pool.Iter {
+		for _, name := range []string{srv.Name(), srv.Key()} {
+			if weight, ok := weights[name]; ok {
+				srv.SetWeight(weight)
+				break
+			}
+		}
+		lb.sumWeight += srv.Weight()
+	}
+	lb.rebalance()
+
+	lb.l.Info().Any("weights", weights).Msg("weights updated")
+	return nil
+}
+
 func (lb *LoadBalancer) rebalance() {
 	if lb.sumWeight == maxWeight {
 		return
@@ -196,22 +263,30 @@ func (lb *LoadBalancer) rebalance() {
 		lb.sumWeight += srv.Weight()
 	}
 
+	// hand out the remainder to servers with weight, drained servers (weight 0) stay drained
 	delta := maxWeight - lb.sumWeight
-	if delta == 0 {
-		return
-	}
-	for _, srv := range lb.pool.Iter {
-		if delta == 0 {
-			break
+	for delta != 0 {
+		changed := false
+		for _, srv := range lb.pool.Iter {
+			if delta == 0 {
+				break
+			}
+			w := srv.Weight()
+			switch {
+			case delta > 0 && w > 0:
+				srv.SetWeight(w + 1)
+				lb.sumWeight++
+				delta--
+				changed = true
+			case delta < 0 && w > 1:
+				srv.SetWeight(w - 1)
+				lb.sumWeight--
+				delta++
+				changed = true
+			}
 		}
-		if delta > 0 {
-			srv.SetWeight(srv.Weight() + 1)
-			lb.sumWeight++
-			delta--
-		} else {
-			srv.SetWeight(srv.Weight() - 1)
-			lb.sumWeight--
-			delta++
+		if !changed {
+			return
 		}
 	}
 }
@@ -239,6 +314,12 @@ func (lb *LoadBalancer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// overrides take precedence over sticky sessions and the balancing mode
+	if selectedServer := getOverrideServer(rw, r, srvs); selectedServer != nil {
+		selectedServer.ServeHTTP(rw, r)
+		return
+	}
+
 	// Check for idlewatcher requests or sticky sessions
 	if lb.Sticky || isIdlewatcherRequest(r) {
 		if selectedServer := getStickyServer(r, srvs); selectedServer != nil {
@@ -354,6 +435,16 @@ func (lb *LoadBalancer) availServers() []types.LoadBalancerServer {
 	return avail
 }
 
+// getOverrideServer returns the first server whose override matches the request.
+func getOverrideServer(rw http.ResponseWriter, r *http.Request, srvs []types.LoadBalancerServer) types.LoadBalancerServer {
+	for _, srv := range srvs {
+		if o, ok := srv.(overrider); ok && o.matchOverride(rw, r) {
+			return srv
+		}
+	}
+	return nil
+}
+
 // isIdlewatcherRequest checks if this is an idlewatcher-related request
 func isIdlewatcherRequest(r *http.Request) bool {
 	// Check for explicit idlewatcher paths
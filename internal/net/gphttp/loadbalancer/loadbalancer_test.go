@@ -1,8 +1,12 @@
 package loadbalancer
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	nettypes "github.com/yusing/godoxy/internal/net/types"
+	"github.com/yusing/godoxy/internal/route/rules"
 	"github.com/yusing/godoxy/internal/types"
 	expect "github.com/yusing/goutils/testing"
 )
@@ -41,4 +45,101 @@ func TestRebalance(t *testing.T) {
 		// t.Logf("%s", U.Must(json.MarshalIndent(lb.pool, "", "  ")))
 		expect.Equal(t, lb.sumWeight, maxWeight)
 	})
+	t.Run("drained", func(t *testing.T) {
+		lb := New(new(types.LoadBalancerConfig))
+		drained := newTestServer("drained", 25, "")
+		lb.AddServer(drained)
+		lb.AddServer(newTestServer("v1", 25, ""))
+		lb.AddServer(newTestServer("v2", 25, ""))
+		lb.AddServer(newTestServer("v3", 25, ""))
+		// 90 is scaled to 33 each, the remainder must not go to the drained server
+		expect.NoError(t, lb.SetWeights(map[string]int{"drained": 0, "v1": 30, "v2": 30, "v3": 30}))
+		expect.Equal(t, lb.sumWeight, maxWeight)
+		expect.Equal(t, drained.Weight(), 0)
+	})
+}
+
+func newTestServer(name string, weight int, override string) types.LoadBalancerServer {
+	var on *rules.RuleOn
+	if override != "" {
+		on = new(rules.RuleOn)
+		if err := on.Parse(override); err != nil {
+			panic(err)
+		}
+	}
+	return NewServer(name, nettypes.MustParseURL("http://"+name), weight, on, nil, nil)
+}
+
+func TestWeighted(t *testing.T) {
+	lb := New(&types.LoadBalancerConfig{Mode: types.LoadbalanceModeWeighted})
+	v1 := newTestServer("v1", 90, "")
+	v2 := newTestServer("v2", 10, "")
+	lb.AddServer(v1)
+	lb.AddServer(v2)
+
+	counts := make(map[types.LoadBalancerServer]int)
+	srvs := types.LoadBalancerServers{v1, v2}
+	for range 100 {
+		counts[lb.ChooseServer(srvs, nil)]++
+	}
+	expect.Equal(t, counts[v1]+counts[v2], 100)
+	expect.Equal(t, counts[v1], v1.Weight())
+	expect.Equal(t, counts[v2], v2.Weight())
+}
+
+func TestSetWeights(t *testing.T) {
+	lb := New(&types.LoadBalancerConfig{Mode: types.LoadbalanceModeWeighted})
+	v1 := newTestServer("v1", 50, "")
+	v2 := newTestServer("v2", 50, "")
+	lb.AddServer(v1)
+	lb.AddServer(v2)
+
+	expect.NoError(t, lb.SetWeights(map[string]int{"v1": 80, "v2": 20}))
+	expect.Equal(t, v1.Weight(), 80)
+	expect.Equal(t, v2.Weight(), 20)
+	expect.Equal(t, lb.sumWeight, maxWeight)
+
+	expect.ErrorIs(t, ErrServerNotFound, lb.SetWeights(map[string]int{"v3": 10}))
+	expect.ErrorIs(t, ErrInvalidWeight, lb.SetWeights(map[string]int{"v1": -1}))
+
+	// unknown names must not partially apply the update
+	expect.ErrorIs(t, ErrServerNotFound, lb.SetWeights(map[string]int{"v1": 30, "v3": 70}))
+	expect.Equal(t, v1.Weight(), 80)
+	expect.Equal(t, v2.Weight(), 20)
+}
+
+func TestSplit(t *testing.T) {
+	cfg := &types.LoadBalancerConfig{Mode: types.LoadbalanceModeSplit}
+	lb := New(cfg)
+	v1 := newTestServer("v1", 90, "")
+	v2 := newTestServer("v2", 10, "")
+	lb.AddServer(v1)
+	lb.AddServer(v2)
+
+	expect.True(t, lb.Sticky)
+	expect.Equal(t, lb.StickyMaxAge, types.StickyMaxAgeDefault)
+	// the route config is left unchanged
+	expect.False(t, cfg.Sticky)
+	expect.Equal(t, cfg.StickyMaxAge, 0)
+
+	counts := make(map[types.LoadBalancerServer]int)
+	srvs := types.LoadBalancerServers{v1, v2}
+	for range 100 {
+		counts[lb.ChooseServer(srvs, nil)]++
+	}
+	// weights are rebalanced when servers are added
+	expect.Equal(t, counts[v1], v1.Weight())
+	expect.Equal(t, counts[v2], v2.Weight())
+}
+
+func TestOverride(t *testing.T) {
+	v1 := newTestServer("v1", 90, "")
+	v2 := newTestServer("v2", 10, "header X-Canary 1")
+	srvs := []types.LoadBalancerServer{v1, v2}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	expect.Nil(t, getOverrideServer(httptest.NewRecorder(), req, srvs))
+
+	req.Header.Set("X-Canary", "1")
+	expect.Equal(t, getOverrideServer(httptest.NewRecorder(), req, srvs), v2)
 }
@@ -3,9 +3,11 @@ package loadbalancer
 import (
 	"context"
 	"net/http"
+	"sync/atomic"
 
 	idlewatcher "github.com/yusing/godoxy/internal/idlewatcher/types"
 	nettypes "github.com/yusing/godoxy/internal/net/types"
+	"github.com/yusing/godoxy/internal/route/rules"
 	"github.com/yusing/godoxy/internal/types"
 	U "github.com/yusing/godoxy/internal/utils"
 )
@@ -13,30 +15,36 @@ import (
 type server struct {
 	_ U.NoCopy
 
-	name   string
-	url    *nettypes.URL
-	weight int
+	name string
+	url  *nettypes.URL
+	// weight is written by the load balancer under its pool lock
+	// and read concurrently by the balancing mode when choosing a server
+	weight atomic.Int64
+
+	// requests matching override are always sent to this server
+	override *rules.RuleOn
 
 	http.Handler `json:"-"`
 	types.HealthMonitor
 }
 
-func NewServer(name string, url *nettypes.URL, weight int, handler http.Handler, healthMon types.HealthMonitor) types.LoadBalancerServer {
+func NewServer(name string, url *nettypes.URL, weight int, override *rules.RuleOn, handler http.Handler, healthMon types.HealthMonitor) types.LoadBalancerServer {
 	srv := &server{
 		name:          name,
 		url:           url,
-		weight:        weight,
+		override:      override,
 		Handler:       handler,
 		HealthMonitor: healthMon,
 	}
+	srv.weight.Store(int64(weight))
 	return srv
 }
 
 func TestNewServer[T ~int | ~float32 | ~float64](weight T) types.LoadBalancerServer {
 	srv := &server{
-		weight: int(weight),
-		url:    nettypes.MustParseURL("http://localhost"),
+		url: nettypes.MustParseURL("http://localhost"),
 	}
+	srv.weight.Store(int64(weight))
 	return srv
 }
 
@@ -53,11 +61,15 @@ func (srv *server) Key() string {
 }
 
 func (srv *server) Weight() int {
-	return srv.weight
+	return int(srv.weight.Load())
 }
 
 func (srv *server) SetWeight(weight int) {
-	srv.weight = weight
+	srv.weight.Store(int64(weight))
+}
+
+func (srv *server) matchOverride(w http.ResponseWriter, r *http.Request) bool {
+	return srv.override != nil && srv.override.Check(w, r)
 }
 
 func (srv *server) String() string {
@@ -0,0 +1,63 @@
+package loadbalancer
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/yusing/godoxy/internal/types"
+)
+
+// weighted implements smooth weighted round robin (as in nginx),
+// each server receives requests proportional to its weight.
+type weighted struct {
+	current map[types.LoadBalancerServer]int
+	mu      sync.Mutex
+}
+
+var _ impl = (*weighted)(nil)
+
+func (*LoadBalancer) newWeighted() impl {
+	return &weighted{current: make(map[types.LoadBalancerServer]int)}
+}
+
+func (impl *weighted) OnAddServer(srv types.LoadBalancerServer) {
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+
+	impl.current[srv] = 0
+}
+
+func (impl *weighted) OnRemoveServer(srv types.LoadBalancerServer) {
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+
+	delete(impl.current, srv)
+}
+
+func (impl *weighted) ChooseServer(srvs types.LoadBalancerServers, r *http.Request) types.LoadBalancerServer {
+	if len(srvs) == 0 {
+		return nil
+	}
+
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+
+	var best types.LoadBalancerServer
+	total := 0
+	for _, srv := range srvs {
+		weight := srv.Weight()
+		if weight <= 0 {
+			continue
+		}
+		total += weight
+		impl.current[srv] += weight
+		if best == nil || impl.current[srv] > impl.current[best] {
+			best = srv
+		}
+	}
+	if best == nil { // all weights are zero
+		return nil
+	}
+	impl.current[best] -= total
+	return best
+}
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/yusing/godoxy/internal/serialization"
+	gperr "github.com/yusing/goutils/errs"
+	"golang.org/x/time/rate"
+)
+
+type (
+	// apiKey protects upstream APIs with static API keys loaded from a file.
+	//
+	// The upstream receives the key name instead of the key.
+	apiKey struct {
+		KeysFile   string           `json:"keys_file" validate:"required"`
+		Header     string           `json:"header"`      // header to read the key from
+		Query      string           `json:"query"`       // query parameter to read the key from if the header is missing
+		NameHeader string           `json:"name_header"` // header to forward the key name to the upstream
+		RateLimit  *apiKeyRateLimit `json:"rate_limit"`  // default rate limit of each key, none if unset
+
+		keys map[string]*apiKeyEntry // by sha256 hex of the key
+	}
+	apiKeysFile struct {
+		Keys []*apiKeyEntry `json:"keys"`
+	}
+	apiKeyEntry struct {
+		Name      string           `json:"name" validate:"required"`
+		Key       string           `json:"key"`        // plain text key
+		Hash      string           `json:"hash"`       // sha256 hex of the key, instead of key
+		RateLimit *apiKeyRateLimit `json:"rate_limit"` // overrides the default rate limit
+
+		limiter *rate.Limiter
+	}
+	apiKeyRateLimit struct {
+		Average int           `json:"average"`
+		Burst   int           `json:"burst"`
+		Period  time.Duration `json:"period"` // default 1s
+	}
+)
+
+var APIKey = NewMiddleware[apiKey]()
+
+// setup implements MiddlewareWithSetup.
+func (m *apiKey) setup() {
+	m.Header = "X-API-Key"
+	m.NameHeader = "X-API-Key-Name"
+}
+
+// finalize implements MiddlewareFinalizerWithError.
+func (m *apiKey) finalize() error {
+	if m.RateLimit != nil {
+		if err := m.RateLimit.validate(); err != nil {
+			return gperr.PrependSubject("rate_limit", err)
+		}
+	}
+
+	data, err := os.ReadFile(m.KeysFile)
+	if err != nil {
+		return err
+	}
+	var file apiKeysFile
+	if err := serialization.UnmarshalValidateYAML(data, &file); err != nil {
+		return gperr.PrependSubject(m.KeysFile, err)
+	}
+
+	errs := gperr.NewBuilder("invalid keys_file")
+	m.keys = make(map[string]*apiKeyEntry, len(file.Keys))
+	for _, entry := range file.Keys {
+		hash, err := entry.hash()
+		if err != nil {
+			errs.Add(gperr.PrependSubject(entry.Name, err))
+			continue
+		}
+		if _, ok := m.keys[hash]; ok {
+			errs.Add(gperr.New("duplicated key").Subject(entry.Name))
+			continue
+		}
+		rateLimit := m.RateLimit
+		if entry.RateLimit != nil {
+			if err := entry.RateLimit.validate(); err != nil {
+				errs.Add(gperr.PrependSubject(entry.Name, err))
+				continue
+			}
+			rateLimit = entry.RateLimit
+		}
+		if rateLimit != nil {
+			entry.limiter = rate.NewLimiter(rate.Limit(rateLimit.Average)*rate.Every(rateLimit.Period), rateLimit.Burst)
+		}
+		m.keys[hash] = entry
+	}
+	return errs.Error()
+}
+
+// before implements RequestModifier.
+func (m *apiKey) before(w http.ResponseWriter, r *http.Request) (proceed bool) {
+	// never trust the header from the client
+	r.Header.Del(m.NameHeader)
+
+	key := r.Header.Get(m.Header)
+	if key == "" && m.Query != "" {
+		key = r.URL.Query().Get(m.Query)
+	}
+	if key == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	hash := sha256.Sum256([]byte(key))
+	entry, ok := m.keys[hex.EncodeToString(hash[:])]
+	if !ok {
+		APIKey.LogWarn(r).Msg("invalid api key")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	if entry.limiter != nil && !entry.limiter.Allow() {
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+		return false
+	}
+
+	// the key is not forwarded to the upstream
+	r.Header.Del(m.Header)
+	if m.Query != "" {
+		if query := r.URL.Query(); query.Has(m.Query) {
+			query.Del(m.Query)
+			r.URL.RawQuery = query.Encode()
+		}
+	}
+	r.Header.Set(m.NameHeader, entry.Name)
+	return true
+}
+
+func (e *apiKeyEntry) hash() (string, error) {
+	switch {
+	case e.Key != "" && e.Hash != "":
+		return "", gperr.New("key and hash are mutually exclusive")
+	case e.Key != "":
+		hash := sha256.Sum256([]byte(e.Key))
+		return hex.EncodeToString(hash[:]), nil
+	case e.Hash != "":
+		b, err := hex.DecodeString(e.Hash)
+		if err != nil || len(b) != sha256.Size {
+			return "", gperr.New("hash is not a sha256 hex string")
+		}
+		return hex.EncodeToString(b), nil // normalize to lowercase
+	default:
+		return "", gperr.New("key or hash is required")
+	}
+}
+
+func (rl *apiKeyRateLimit) validate() error {
+	if rl.Average < 1 || rl.Burst < 1 {
+		return gperr.New("average and burst must be at least 1")
+	}
+	if rl.Period == 0 {
+		rl.Period = time.Second
+	}
+	return nil
+}
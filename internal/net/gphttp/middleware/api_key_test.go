@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nettypes "github.com/yusing/godoxy/internal/net/types"
+	expect "github.com/yusing/goutils/testing"
+)
+
+func TestAPIKey(t *testing.T) {
+	hash := sha256.Sum256([]byte("hashed-key"))
+	keysFile := filepath.Join(t.TempDir(), "api_keys.yml")
+	expect.NoError(t, os.WriteFile(keysFile, []byte(`
+keys:
+  - name: ci
+    key: plain-key
+    rate_limit:
+      average: 1
+      burst: 1
+      period: 1h
+  - name: monitoring
+    hash: `+hex.EncodeToString(hash[:])+`
+`), 0o600))
+
+	mid, err := APIKey.New(OptionsRaw{"keys_file": keysFile, "query": "api_key"})
+	expect.NoError(t, err)
+
+	request := func(t *testing.T, args *testArgs) *TestResult {
+		t.Helper()
+		result, err := newMiddlewaresTest([]*Middleware{mid}, args)
+		expect.NoError(t, err)
+		return result
+	}
+
+	t.Run("plain key", func(t *testing.T) {
+		result := request(t, &testArgs{headers: http.Header{
+			"X-Api-Key":      {"plain-key"},
+			"X-Api-Key-Name": {"admin"},
+		}})
+		expect.Equal(t, result.ResponseStatus, http.StatusOK)
+		expect.Equal(t, result.RequestHeaders.Get("X-API-Key-Name"), "ci")
+		expect.Equal(t, result.RequestHeaders.Get("X-API-Key"), "")
+	})
+	t.Run("rate limited", func(t *testing.T) {
+		result := request(t, &testArgs{headers: http.Header{"X-Api-Key": {"plain-key"}}})
+		expect.Equal(t, result.ResponseStatus, http.StatusTooManyRequests)
+	})
+	t.Run("hashed key from query", func(t *testing.T) {
+		result := request(t, &testArgs{reqURL: nettypes.MustParseURL("https://example.com/?api_key=hashed-key")})
+		expect.Equal(t, result.ResponseStatus, http.StatusOK)
+		expect.Equal(t, result.RequestHeaders.Get("X-API-Key-Name"), "monitoring")
+	})
+	t.Run("invalid key", func(t *testing.T) {
+		result := request(t, &testArgs{headers: http.Header{"X-Api-Key": {"wrong"}}})
+		expect.Equal(t, result.ResponseStatus, http.StatusUnauthorized)
+	})
+	t.Run("missing key", func(t *testing.T) {
+		result := request(t, nil)
+		expect.Equal(t, result.ResponseStatus, http.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/yusing/godoxy/internal/net/gphttp/middleware/crawler"
+	"github.com/yusing/goutils/http/httpheaders"
+)
+
+type (
+	botFilter struct {
+		AllowUserAgents []string        `json:"allow_user_agents"` // classified as good_bot
+		BlockUserAgents []string        `json:"block_user_agents"` // classified as bad_bot
+		Block           []crawler.Class `json:"block" validate:"dive,oneof=human bot good_bot bad_bot verified_crawler fake_crawler"`
+		Tarpit          []crawler.Class `json:"tarpit" validate:"dive,oneof=human bot good_bot bad_bot verified_crawler fake_crawler"`
+		Slowdown        []crawler.Class `json:"slowdown" validate:"dive,oneof=human bot good_bot bad_bot verified_crawler fake_crawler"`
+		TarpitDuration  time.Duration   `json:"tarpit_duration"`
+		SlowdownDelay   time.Duration   `json:"slowdown_delay"`
+		MaxTarpitConns  int64           `json:"max_tarpit_connections" validate:"min=1"`
+		RobotsTxt       string          `json:"robots_txt"` // appended to the upstream robots.txt, or served if upstream has none
+
+		classifier *crawler.Classifier
+		actions    map[crawler.Class]botAction
+	}
+	botAction uint8
+)
+
+const (
+	botActionNone botAction = iota
+	botActionSlowdown
+	botActionTarpit
+	botActionBlock
+)
+
+const robotsTxtPath = "/robots.txt"
+
+// number of connections currently held by tarpits, shared by all bot filters
+var tarpitConns atomic.Int64
+
+var BotFilter = NewMiddleware[botFilter]()
+
+// setup implements MiddlewareWithSetup.
+func (m *botFilter) setup() {
+	m.BlockUserAgents = crawler.DefaultBadBots
+	m.Block = []crawler.Class{crawler.ClassBadBot, crawler.ClassFakeCrawler}
+	m.TarpitDuration = 30 * time.Second
+	m.SlowdownDelay = 2 * time.Second
+	m.MaxTarpitConns = 100
+}
+
+// finalize implements MiddlewareFinalizer.
+func (m *botFilter) finalize() {
+	m.classifier = crawler.NewClassifier(m.AllowUserAgents, m.BlockUserAgents)
+	// later actions take precedence
+	m.actions = make(map[crawler.Class]botAction)
+	for _, class := range m.Slowdown {
+		m.actions[class] = botActionSlowdown
+	}
+	for _, class := range m.Tarpit {
+		m.actions[class] = botActionTarpit
+	}
+	for _, class := range m.Block {
+		m.actions[class] = botActionBlock
+	}
+}
+
+// before implements RequestModifier.
+func (m *botFilter) before(w http.ResponseWriter, r *http.Request) (proceed bool) {
+	class := m.classifier.Classify(r)
+	*r = *crawler.WithClass(r, class)
+
+	// let every bot read robots.txt
+	if m.RobotsTxt != "" && r.URL.Path == robotsTxtPath {
+		return true
+	}
+
+	switch m.actions[class] {
+	case botActionBlock:
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	case botActionTarpit:
+		m.tarpit(w, r)
+		return false
+	case botActionSlowdown:
+		select {
+		case <-r.Context().Done():
+			return false
+		case <-time.After(m.SlowdownDelay):
+		}
+	}
+	return true
+}
+
+// tarpit holds the connection and drips the response body slowly to waste the bot's resources.
+func (m *botFilter) tarpit(w http.ResponseWriter, r *http.Request) {
+	if tarpitConns.Add(1) > m.MaxTarpitConns {
+		tarpitConns.Add(-1)
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		return
+	}
+	defer tarpitConns.Add(-1)
+
+	w.Header().Set(httpheaders.HeaderContentType, "text/plain; charset=utf-8")
+	w.Header().Set("Retry-After", strconv.Itoa(int(m.TarpitDuration.Seconds())))
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	rc := http.NewResponseController(w)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	timer := time.NewTimer(m.TarpitDuration)
+	defer timer.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timer.C:
+			return
+		case <-ticker.C:
+			if _, err := w.Write([]byte{'.'}); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// modifyResponse implements ResponseModifier.
+func (m *botFilter) modifyResponse(resp *http.Response) error {
+	if m.RobotsTxt == "" || resp.Request == nil || resp.Request.URL.Path != robotsTxtPath {
+		return nil
+	}
+
+	var body []byte
+	switch {
+	case resp.StatusCode == http.StatusOK && resp.Header.Get("Content-Encoding") == "":
+		upstream, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		body = append(bytes.TrimRight(upstream, "\n"), '\n', '\n')
+		body = append(body, m.RobotsTxt...)
+	case resp.StatusCode == http.StatusNotFound:
+		_, _ = io.Copy(io.Discard, resp.Body) // drain the original body
+		resp.Body.Close()
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+		body = []byte(m.RobotsTxt)
+	default:
+		return nil
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set(httpheaders.HeaderContentLength, strconv.Itoa(len(body)))
+	resp.Header.Set(httpheaders.HeaderContentType, "text/plain; charset=utf-8")
+	resp.Header.Del("Transfer-Encoding")
+	return nil
+}
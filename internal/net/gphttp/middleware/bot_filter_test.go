@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/yusing/godoxy/internal/net/gphttp/middleware/crawler"
+	nettypes "github.com/yusing/godoxy/internal/net/types"
+	expect "github.com/yusing/goutils/testing"
+)
+
+type botFilterTestResolver struct{}
+
+func (botFilterTestResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	if addr == "192.0.2.1" { // httptest remote address
+		return []string{"crawl-192-0-2-1.googlebot.com."}, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (botFilterTestResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if host == "crawl-192-0-2-1.googlebot.com" {
+		return []net.IPAddr{{IP: net.ParseIP("192.0.2.1")}}, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func TestBotFilter(t *testing.T) {
+	crawler.DefaultResolver = botFilterTestResolver{}
+	t.Cleanup(func() {
+		crawler.DefaultResolver = net.DefaultResolver
+	})
+
+	const (
+		browserUA   = "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+		googlebotUA = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+		bingbotUA   = "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)"
+		ahrefsUA    = "Mozilla/5.0 (compatible; AhrefsBot/7.0; +http://ahrefs.com/robot/)"
+	)
+
+	// crawlers are verified in the background, verify them before classifying
+	for _, ua := range []string{googlebotUA, bingbotUA} {
+		crawler.Identify(ua).Verify(t.Context(), "192.0.2.1")
+	}
+
+	tests := []struct {
+		name   string
+		ua     string
+		opts   OptionsRaw
+		status int
+	}{
+		{name: "human", ua: browserUA, status: http.StatusOK},
+		{name: "verified crawler", ua: googlebotUA, status: http.StatusOK},
+		{name: "fake crawler", ua: bingbotUA, status: http.StatusForbidden},
+		{name: "bad bot", ua: ahrefsUA, status: http.StatusForbidden},
+		{name: "generic bot", ua: "curl/8.5.0", status: http.StatusOK},
+		{
+			name:   "allowed",
+			ua:     ahrefsUA,
+			opts:   OptionsRaw{"allow_user_agents": []string{"ahrefsbot"}},
+			status: http.StatusOK,
+		},
+		{
+			name:   "block generic bot",
+			ua:     "python-requests/2.31.0",
+			opts:   OptionsRaw{"block": []string{"bot"}},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "tarpit",
+			ua:     "curl/8.5.0",
+			opts:   OptionsRaw{"tarpit": []string{"bot"}, "tarpit_duration": "10ms"},
+			status: http.StatusTooManyRequests,
+		},
+		{
+			name:   "slowdown",
+			ua:     "curl/8.5.0",
+			opts:   OptionsRaw{"slowdown": []string{"bot"}, "slowdown_delay": "10ms"},
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := newMiddlewareTest(BotFilter, &testArgs{
+				middlewareOpt: tt.opts,
+				headers:       http.Header{"User-Agent": {tt.ua}},
+			})
+			expect.NoError(t, err)
+			expect.Equal(t, result.ResponseStatus, tt.status)
+		})
+	}
+}
+
+func TestBotFilterInvalidClass(t *testing.T) {
+	_, err := BotFilter.New(OptionsRaw{"block": []string{"robot"}})
+	expect.HasError(t, err)
+}
+
+func TestBotFilterRobotsTxt(t *testing.T) {
+	headers := testHeaders.Clone()
+	t.Cleanup(func() {
+		testHeaders = headers
+	})
+
+	const robotsTxt = "User-agent: GPTBot\nDisallow: /"
+	opts := OptionsRaw{"robots_txt": robotsTxt}
+	reqURL := nettypes.MustParseURL("https://example.com/robots.txt")
+
+	t.Run("append", func(t *testing.T) {
+		result, err := newMiddlewareTest(BotFilter, &testArgs{
+			middlewareOpt: opts,
+			reqURL:        reqURL,
+			headers:       http.Header{"User-Agent": {"Mozilla/5.0 (compatible; AhrefsBot/7.0)"}},
+			respBody:      []byte("User-agent: *\nAllow: /\n"),
+		})
+		expect.NoError(t, err)
+		expect.Equal(t, result.ResponseStatus, http.StatusOK)
+		expect.Equal(t, string(result.Data), "User-agent: *\nAllow: /\n\n"+robotsTxt)
+	})
+	t.Run("upstream not found", func(t *testing.T) {
+		result, err := newMiddlewareTest(BotFilter, &testArgs{
+			middlewareOpt: opts,
+			reqURL:        reqURL,
+			respStatus:    http.StatusNotFound,
+			respBody:      []byte("Not Found"),
+		})
+		expect.NoError(t, err)
+		expect.Equal(t, result.ResponseStatus, http.StatusOK)
+		expect.Equal(t, string(result.Data), robotsTxt)
+	})
+}
@@ -42,11 +42,17 @@ func (c *checkBypass) isEnforced(r *http.Request) bool {
 }
 
 func (c *checkBypass) before(w http.ResponseWriter, r *http.Request) (proceedNext bool) {
+	_, proceedNext = c.beforeWithContext(w, r)
+	return proceedNext
+}
+
+// beforeWithContext implements RequestContextModifier.
+func (c *checkBypass) beforeWithContext(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
 	if c.modReq == nil || (!c.isEnforced(r) && c.bypass.ShouldBypass(w, r)) {
-		return true
+		return r, true
 	}
 	log.Debug().Str("middleware", c.name).Str("url", r.Host+r.URL.Path).Msg("modifying request")
-	return c.modReq.before(w, r)
+	return runBefore(c.modReq, w, r)
 }
 
 func (c *checkBypass) modifyResponse(resp *http.Response) error {
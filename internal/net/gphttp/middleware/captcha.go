@@ -6,12 +6,55 @@ import (
 	"github.com/yusing/godoxy/internal/net/gphttp/middleware/captcha"
 )
 
-type hCaptcha struct {
-	captcha.HcaptchaProvider
-}
+type (
+	hCaptcha struct {
+		captcha.HcaptchaProvider
+	}
+	turnstile struct {
+		captcha.TurnstileProvider
+	}
+	reCaptcha struct {
+		captcha.RecaptchaProvider
+	}
+	powChallenge struct {
+		captcha.ProofOfWorkProvider
+	}
+)
+
+var (
+	HCaptcha     = NewMiddleware[hCaptcha]()
+	Turnstile    = NewMiddleware[turnstile]()
+	ReCaptcha    = NewMiddleware[reCaptcha]()
+	PoWChallenge = NewMiddleware[powChallenge]()
+)
 
 func (h *hCaptcha) before(w http.ResponseWriter, r *http.Request) (proceed bool) {
 	return captcha.PreRequest(h, w, r)
 }
 
-var HCaptcha = NewMiddleware[hCaptcha]()
+func (t *turnstile) before(w http.ResponseWriter, r *http.Request) (proceed bool) {
+	return captcha.PreRequest(t, w, r)
+}
+
+// setup implements MiddlewareWithSetup.
+func (rc *reCaptcha) setup() {
+	rc.SetDefaults()
+}
+
+func (rc *reCaptcha) before(w http.ResponseWriter, r *http.Request) (proceed bool) {
+	return captcha.PreRequest(rc, w, r)
+}
+
+// setup implements MiddlewareWithSetup.
+func (p *powChallenge) setup() {
+	p.Difficulty = captcha.PoWDefaultDifficulty
+}
+
+// finalize implements MiddlewareFinalizerWithError.
+func (p *powChallenge) finalize() error {
+	return p.Init()
+}
+
+func (p *powChallenge) before(w http.ResponseWriter, r *http.Request) (proceed bool) {
+	return captcha.PreRequest(p, w, r)
+}
@@ -1,17 +1,10 @@
 package captcha
 
 import (
-	"bytes"
-	"context"
 	"errors"
-	"net"
 	"net/http"
 	"net/url"
-	"time"
 
-	_ "embed"
-
-	"github.com/bytedance/sonic"
 	gperr "github.com/yusing/goutils/errs"
 )
 
@@ -41,36 +34,17 @@ func (p *HcaptchaProvider) Verify(r *http.Request) error {
 		return errors.New("h-captcha-response is missing")
 	}
 
-	remoteIP := r.RemoteAddr
-	if ip, _, err := net.SplitHostPort(remoteIP); err == nil {
-		remoteIP = ip
-	}
-
-	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
-	defer cancel()
 	formData := url.Values{}
 	formData.Set("secret", p.Secret)
 	formData.Set("response", response)
-	formData.Set("remoteip", remoteIP)
+	formData.Set("remoteip", remoteIP(r))
 	formData.Set("sitekey", p.SiteKey)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.hcaptcha.com/siteverify", bytes.NewBufferString(formData.Encode()))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
 	var respData struct {
 		Success bool     `json:"success"`
 		Error   []string `json:"error-codes"`
 	}
-	if err := sonic.ConfigDefault.NewDecoder(resp.Body).Decode(&respData); err != nil {
+	if err := siteVerify(r.Context(), "https://api.hcaptcha.com/siteverify", formData, &respData); err != nil {
 		return err
 	}
 
@@ -86,7 +60,7 @@ func (p *HcaptchaProvider) ScriptHTML() string {
 <script src="https://js.hcaptcha.com/1/api.js" async defer></script>`
 }
 
-func (p *HcaptchaProvider) FormHTML() string {
+func (p *HcaptchaProvider) FormHTML(*http.Request) string {
 	return `
 <div
 	class="h-captcha"
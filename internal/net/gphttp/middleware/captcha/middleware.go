@@ -18,17 +18,8 @@ var captchaPageHTML string
 var captchaPage = template.Must(template.New("captcha").Parse(captchaPageHTML))
 
 func PreRequest(p Provider, w http.ResponseWriter, r *http.Request) (proceed bool) {
-	// check session
-	sessionID, err := r.Cookie(cookieName)
-	if err == nil {
-		session, ok := CaptchaSessions.Load(sessionID.Value)
-		if ok {
-			if session.expired() {
-				CaptchaSessions.Delete(sessionID.Value)
-			} else {
-				return true
-			}
-		}
+	if hasValidSession(p, r) || p.ShouldBypass(r) {
+		return true
 	}
 
 	if !httputils.GetAccept(r.Header).AcceptHTML() {
@@ -39,9 +30,7 @@ func PreRequest(p Provider, w http.ResponseWriter, r *http.Request) (proceed boo
 	if r.Method == http.MethodPost {
 		err := p.Verify(r)
 		if err == nil {
-			session := newCaptchaSession(p)
-			CaptchaSessions.Store(session.ID, session)
-			auth.SetTokenCookie(w, r, cookieName, session.ID, p.SessionExpiry())
+			auth.SetTokenCookie(w, r, cookieName, newSession(p, r), p.SessionExpiry())
 			http.Redirect(w, r, r.URL.Path, http.StatusFound)
 			return false
 		}
@@ -52,12 +41,40 @@ func PreRequest(p Provider, w http.ResponseWriter, r *http.Request) (proceed boo
 	}
 
 	// captcha challenge
-	err = captchaPage.Execute(w, map[string]any{
+	err := captchaPage.Execute(w, map[string]any{
 		"ScriptHTML": p.ScriptHTML(),
-		"FormHTML":   p.FormHTML(),
+		"FormHTML":   p.FormHTML(r),
 	})
 	if err != nil {
 		log.Error().Err(err).Msg("failed to execute captcha page")
 	}
 	return false
 }
+
+func hasValidSession(p Provider, r *http.Request) bool {
+	cookie, err := r.Cookie(cookieName)
+	if err != nil {
+		return false
+	}
+	if sp, ok := p.(SessionProvider); ok {
+		return sp.ValidateSession(r, cookie.Value)
+	}
+	session, ok := CaptchaSessions.Load(cookie.Value)
+	if !ok {
+		return false
+	}
+	if session.expired() {
+		CaptchaSessions.Delete(cookie.Value)
+		return false
+	}
+	return true
+}
+
+func newSession(p Provider, r *http.Request) string {
+	if sp, ok := p.(SessionProvider); ok {
+		return sp.NewSession(r)
+	}
+	session := newCaptchaSession(p)
+	CaptchaSessions.Store(session.ID, session)
+	return session.ID
+}
@@ -0,0 +1,212 @@
+package captcha
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/bits"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "embed"
+
+	"github.com/puzpuzpuz/xsync/v4"
+	"github.com/yusing/godoxy/internal/utils"
+	gperr "github.com/yusing/goutils/errs"
+)
+
+// ProofOfWorkProvider is a self-hosted challenge that requires the browser
+// to find a nonce such that sha256(challenge:nonce) has at least
+// Difficulty leading zero bits.
+//
+// Challenges and sessions are HMAC signed, only solved challenges are
+// remembered until they expire so each of them can be used once.
+type ProofOfWorkProvider struct {
+	ProviderBase
+
+	Difficulty int    `json:"difficulty" validate:"min=1,max=32"`
+	Secret     string `json:"secret"` // HMAC secret, random if empty (sessions will not survive restarts)
+
+	secret []byte
+	solved *xsync.Map[string, int64] // challenge => expiry unix timestamp
+}
+
+const (
+	PoWDefaultDifficulty = 18
+	powChallengeExpiry   = 5 * time.Minute
+	powMaxSolved         = 100_000
+)
+
+var (
+	ErrInvalidChallenge  = gperr.New("invalid challenge")
+	ErrChallengeExpired  = gperr.New("challenge expired")
+	ErrChallengeUsed     = gperr.New("challenge already used")
+	ErrTooManyChallenges = gperr.New("too many challenges, try again later")
+)
+
+//go:embed pow.js
+var powJS string
+
+// Init initializes the HMAC secret.
+func (p *ProofOfWorkProvider) Init() error {
+	p.solved = xsync.NewMap[string, int64]()
+	if p.Secret != "" {
+		p.secret = []byte(p.Secret)
+		return nil
+	}
+	p.secret = make([]byte, 32)
+	_, err := rand.Read(p.secret)
+	return err
+}
+
+func (p *ProofOfWorkProvider) CSPDirectives() []string {
+	return nil
+}
+
+func (p *ProofOfWorkProvider) CSPSources() []string {
+	return nil
+}
+
+func (p *ProofOfWorkProvider) Verify(r *http.Request) error {
+	challenge := r.PostFormValue("pow-challenge")
+	nonce := r.PostFormValue("pow-nonce")
+	if challenge == "" || nonce == "" {
+		return errors.New("pow-challenge or pow-nonce is missing")
+	}
+
+	issuedAt, err := p.verifyChallenge(r, challenge)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256([]byte(challenge + ":" + nonce))
+	if leadingZeroBits(sum[:]) < p.Difficulty {
+		return ErrCaptchaVerificationFailed.Subject("insufficient work")
+	}
+	return p.markSolved(challenge, issuedAt.Add(powChallengeExpiry))
+}
+
+// markSolved records the challenge as solved until it expires,
+// it fails if the challenge was already solved.
+func (p *ProofOfWorkProvider) markSolved(challenge string, expiry time.Time) error {
+	if p.solved.Size() >= powMaxSolved {
+		now := utils.TimeNow().Unix()
+		p.solved.Range(func(k string, exp int64) bool {
+			if exp < now {
+				p.solved.Delete(k)
+			}
+			return true
+		})
+		if p.solved.Size() >= powMaxSolved {
+			return ErrTooManyChallenges
+		}
+	}
+	if _, loaded := p.solved.LoadOrStore(challenge, expiry.Unix()); loaded {
+		return ErrChallengeUsed
+	}
+	return nil
+}
+
+// newChallenge returns a challenge in the format of "<timestamp>.<random>.<signature>",
+// bound to the client IP and the difficulty.
+func (p *ProofOfWorkProvider) newChallenge(r *http.Request) string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	ts := strconv.FormatInt(utils.TimeNow().Unix(), 10)
+	random := hex.EncodeToString(buf)
+	return ts + "." + random + "." + p.sign("challenge", ts, random, remoteIP(r), strconv.Itoa(p.Difficulty))
+}
+
+func (p *ProofOfWorkProvider) verifyChallenge(r *http.Request, challenge string) (issuedAt time.Time, _ error) {
+	parts := strings.Split(challenge, ".")
+	if len(parts) != 3 {
+		return issuedAt, ErrInvalidChallenge
+	}
+	ts, random, sig := parts[0], parts[1], parts[2]
+	expected := p.sign("challenge", ts, random, remoteIP(r), strconv.Itoa(p.Difficulty))
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return issuedAt, ErrInvalidChallenge
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return issuedAt, ErrInvalidChallenge
+	}
+	issuedAt = time.Unix(unix, 0)
+	if utils.TimeNow().Sub(issuedAt) > powChallengeExpiry {
+		return issuedAt, ErrChallengeExpired
+	}
+	return issuedAt, nil
+}
+
+// NewSession implements SessionProvider.
+//
+// The session value is "<expiry>.<signature>", bound to the user agent
+// and the client network (/24 for IPv4, /64 for IPv6).
+func (p *ProofOfWorkProvider) NewSession(r *http.Request) string {
+	expiry := strconv.FormatInt(utils.TimeNow().Add(p.SessionExpiry()).Unix(), 10)
+	return expiry + "." + p.sign("session", expiry, clientNetwork(r), r.UserAgent())
+}
+
+// ValidateSession implements SessionProvider.
+func (p *ProofOfWorkProvider) ValidateSession(r *http.Request, value string) bool {
+	expiry, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	if !hmac.Equal([]byte(sig), []byte(p.sign("session", expiry, clientNetwork(r), r.UserAgent()))) {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return false
+	}
+	return utils.TimeNow().Before(time.Unix(expiresAt, 0))
+}
+
+func (p *ProofOfWorkProvider) sign(parts ...string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *ProofOfWorkProvider) ScriptHTML() string {
+	return `
+<script>` + powJS + `</script>`
+}
+
+func (p *ProofOfWorkProvider) FormHTML(r *http.Request) string {
+	return `
+<div id="pow-status">Verifying your browser...</div>
+<input type="hidden" id="pow-challenge" name="pow-challenge" value="` + p.newChallenge(r) + `" />
+<input type="hidden" id="pow-difficulty" value="` + strconv.Itoa(p.Difficulty) + `" />
+<input type="hidden" id="pow-nonce" name="pow-nonce" />`
+}
+
+// clientNetwork returns the network of the client IP, so sessions
+// survive address changes within the same network, e.g. IPv6 privacy extensions.
+func clientNetwork(r *http.Request) string {
+	ip := net.ParseIP(remoteIP(r))
+	if ip == nil {
+		return remoteIP(r)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(64, 128)).String()
+}
+
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, c := range b {
+		if c != 0 {
+			return n + bits.LeadingZeros8(c)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,107 @@
+package captcha
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	expect "github.com/yusing/goutils/testing"
+)
+
+func newPoWRequest(remoteAddr string, form url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("User-Agent", "test-agent")
+	r.RemoteAddr = remoteAddr
+	return r
+}
+
+func solve(challenge string, difficulty int) string {
+	for nonce := 0; ; nonce++ {
+		sum := sha256.Sum256([]byte(challenge + ":" + strconv.Itoa(nonce)))
+		if leadingZeroBits(sum[:]) >= difficulty {
+			return strconv.Itoa(nonce)
+		}
+	}
+}
+
+func TestProofOfWork(t *testing.T) {
+	p := &ProofOfWorkProvider{Difficulty: 8}
+	expect.NoError(t, p.Init())
+
+	challenge := p.newChallenge(newPoWRequest("10.0.0.1:1234", nil))
+
+	t.Run("valid", func(t *testing.T) {
+		r := newPoWRequest("10.0.0.1:1234", url.Values{
+			"pow-challenge": {challenge},
+			"pow-nonce":     {solve(challenge, 8)},
+		})
+		expect.NoError(t, p.Verify(r))
+	})
+	t.Run("replayed", func(t *testing.T) {
+		r := newPoWRequest("10.0.0.1:1234", url.Values{
+			"pow-challenge": {challenge},
+			"pow-nonce":     {solve(challenge, 8)},
+		})
+		expect.ErrorIs(t, ErrChallengeUsed, p.Verify(r))
+	})
+	t.Run("insufficient work", func(t *testing.T) {
+		nonce := 0
+		for {
+			sum := sha256.Sum256([]byte(challenge + ":" + strconv.Itoa(nonce)))
+			if leadingZeroBits(sum[:]) < 8 {
+				break
+			}
+			nonce++
+		}
+		r := newPoWRequest("10.0.0.1:1234", url.Values{
+			"pow-challenge": {challenge},
+			"pow-nonce":     {strconv.Itoa(nonce)},
+		})
+		expect.ErrorIs(t, ErrCaptchaVerificationFailed, p.Verify(r))
+	})
+	t.Run("different ip", func(t *testing.T) {
+		r := newPoWRequest("10.0.0.2:1234", url.Values{
+			"pow-challenge": {challenge},
+			"pow-nonce":     {solve(challenge, 8)},
+		})
+		expect.ErrorIs(t, ErrInvalidChallenge, p.Verify(r))
+	})
+	t.Run("tampered", func(t *testing.T) {
+		tampered := "1" + challenge
+		r := newPoWRequest("10.0.0.1:1234", url.Values{
+			"pow-challenge": {tampered},
+			"pow-nonce":     {solve(tampered, 8)},
+		})
+		expect.ErrorIs(t, ErrInvalidChallenge, p.Verify(r))
+	})
+}
+
+func TestProofOfWorkSession(t *testing.T) {
+	p := &ProofOfWorkProvider{Difficulty: 8}
+	expect.NoError(t, p.Init())
+
+	r := newPoWRequest("10.0.0.1:1234", nil)
+	session := p.NewSession(r)
+	expect.True(t, p.ValidateSession(r, session))
+
+	other := newPoWRequest("10.0.0.1:1234", nil)
+	other.Header.Set("User-Agent", "other-agent")
+	expect.False(t, p.ValidateSession(other, session))
+
+	// same network
+	expect.True(t, p.ValidateSession(newPoWRequest("10.0.0.2:1234", nil), session))
+	// other network
+	expect.False(t, p.ValidateSession(newPoWRequest("10.0.1.1:1234", nil), session))
+
+	expect.False(t, p.ValidateSession(r, "0."+strings.SplitN(session, ".", 2)[1]))
+
+	// sessions signed with another secret are rejected
+	p2 := &ProofOfWorkProvider{Difficulty: 8}
+	expect.NoError(t, p2.Init())
+	expect.False(t, p2.ValidateSession(r, session))
+}
@@ -12,8 +12,17 @@ type Provider interface {
 	CSPSources() []string
 	Verify(r *http.Request) error
 	SessionExpiry() time.Duration
+	ShouldBypass(r *http.Request) bool
 	ScriptHTML() string
-	FormHTML() string
+	FormHTML(r *http.Request) string
+}
+
+// SessionProvider is implemented by providers that manage
+// their own sessions instead of using the session store.
+type SessionProvider interface {
+	Provider
+	NewSession(r *http.Request) (value string)
+	ValidateSession(r *http.Request, value string) bool
 }
 
 var ErrCaptchaVerificationFailed = gperr.New("captcha verification failed")
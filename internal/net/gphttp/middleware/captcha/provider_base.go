@@ -1,9 +1,18 @@
 package captcha
 
-import "time"
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yusing/godoxy/internal/net/gphttp/middleware/crawler"
+)
 
 type ProviderBase struct {
 	Expiry time.Duration `json:"session_expiry"`
+
+	BypassUserAgents       []string `json:"bypass_user_agents"`       // user agent substrings
+	BypassVerifiedCrawlers bool     `json:"bypass_verified_crawlers"` // e.g. Googlebot, Bingbot, verified with reverse and forward DNS lookup
 }
 
 func (p *ProviderBase) SessionExpiry() time.Duration {
@@ -12,3 +21,18 @@ func (p *ProviderBase) SessionExpiry() time.Duration {
 	}
 	return p.Expiry
 }
+
+func (p *ProviderBase) ShouldBypass(r *http.Request) bool {
+	if len(p.BypassUserAgents) > 0 {
+		ua := r.UserAgent()
+		for _, uaBypass := range p.BypassUserAgents {
+			if strings.Contains(ua, uaBypass) {
+				return true
+			}
+		}
+	}
+	if p.BypassVerifiedCrawlers {
+		return crawler.FromRequest(r) != nil
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package captcha
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	gperr "github.com/yusing/goutils/errs"
+)
+
+type RecaptchaProvider struct {
+	ProviderBase
+
+	SiteKey  string           `json:"site_key" validate:"required"`
+	Secret   string           `json:"secret" validate:"required"`
+	Version  RecaptchaVersion `json:"version" validate:"omitempty,oneof=v2 v3"`
+	MinScore float64          `json:"min_score" validate:"min=0,max=1"` // v3 only
+	Action   string           `json:"action"`                           // v3 only
+}
+
+type RecaptchaVersion string
+
+const (
+	RecaptchaV2 RecaptchaVersion = "v2"
+	RecaptchaV3 RecaptchaVersion = "v3"
+)
+
+const (
+	recaptchaDefaultMinScore = 0.5
+	recaptchaDefaultAction   = "captcha"
+)
+
+var ErrRecaptchaLowScore = gperr.New("recaptcha score too low")
+
+// https://developers.google.com/recaptcha/docs/faq#im-using-content-security-policy-csp-on-my-website.-how-can-i-configure-it-to-work-with-recaptcha
+func (p *RecaptchaProvider) CSPDirectives() []string {
+	return []string{"script-src", "frame-src"}
+}
+
+// https://developers.google.com/recaptcha/docs/faq#im-using-content-security-policy-csp-on-my-website.-how-can-i-configure-it-to-work-with-recaptcha
+func (p *RecaptchaProvider) CSPSources() []string {
+	return []string{
+		"https://www.google.com/recaptcha/",
+		"https://www.gstatic.com/recaptcha/",
+	}
+}
+
+// SetDefaults sets the default version, minimum score and action.
+func (p *RecaptchaProvider) SetDefaults() {
+	p.Version = RecaptchaV2
+	p.MinScore = recaptchaDefaultMinScore
+	p.Action = recaptchaDefaultAction
+}
+
+// https://developers.google.com/recaptcha/docs/verify
+func (p *RecaptchaProvider) Verify(r *http.Request) error {
+	response := r.PostFormValue("g-recaptcha-response")
+	if response == "" {
+		return errors.New("g-recaptcha-response is missing")
+	}
+
+	formData := url.Values{}
+	formData.Set("secret", p.Secret)
+	formData.Set("response", response)
+	formData.Set("remoteip", remoteIP(r))
+
+	var respData struct {
+		Success bool     `json:"success"`
+		Score   float64  `json:"score"`
+		Action  string   `json:"action"`
+		Error   []string `json:"error-codes"`
+	}
+	if err := siteVerify(r.Context(), "https://www.google.com/recaptcha/api/siteverify", formData, &respData); err != nil {
+		return err
+	}
+
+	if !respData.Success {
+		return gperr.JoinLines(ErrCaptchaVerificationFailed, respData.Error...)
+	}
+
+	if p.Version == RecaptchaV3 {
+		if respData.Action != p.Action {
+			return ErrCaptchaVerificationFailed.Subjectf("action mismatch: %q", respData.Action)
+		}
+		if respData.Score < p.MinScore {
+			return ErrRecaptchaLowScore.Subjectf("%.1f < %.1f", respData.Score, p.MinScore)
+		}
+	}
+
+	return nil
+}
+
+func (p *RecaptchaProvider) ScriptHTML() string {
+	if p.Version == RecaptchaV3 {
+		// v3 is invisible, execute it on load and submit the token
+		return `
+<script src="https://www.google.com/recaptcha/api.js?render=` + url.QueryEscape(p.SiteKey) + `"></script>
+<script>
+	window.addEventListener("load", function () {
+		grecaptcha.ready(function () {
+			grecaptcha
+				.execute(` + jsString(p.SiteKey) + `, { action: ` + jsString(p.Action) + ` })
+				.then(function (token) {
+					document.getElementById("g-recaptcha-response").value = token;
+					onDataCallback();
+				});
+		});
+	});
+</script>`
+	}
+	return `
+<script src="https://www.google.com/recaptcha/api.js" async defer></script>`
+}
+
+func (p *RecaptchaProvider) FormHTML(*http.Request) string {
+	if p.Version == RecaptchaV3 {
+		return `
+<input type="hidden" id="g-recaptcha-response" name="g-recaptcha-response" />`
+	}
+	return `
+<div
+	class="g-recaptcha"
+	data-sitekey="` + p.SiteKey + `"
+	data-callback="onDataCallback"
+></div>`
+}
+
+// jsString returns s as a JavaScript string literal, safe to be placed in inline scripts
+// as "<", ">" and "&" are escaped by json.Marshal.
+func jsString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
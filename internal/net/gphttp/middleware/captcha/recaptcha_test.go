@@ -0,0 +1,19 @@
+package captcha
+
+import (
+	"strings"
+	"testing"
+
+	expect "github.com/yusing/goutils/testing"
+)
+
+func TestRecaptchaV3ScriptEscaped(t *testing.T) {
+	p := &RecaptchaProvider{
+		SiteKey: `key"); alert(1); ("`,
+		Version: RecaptchaV3,
+		Action:  `</script><script>alert(1)</script>`,
+	}
+	script := p.ScriptHTML()
+	expect.True(t, strings.Contains(script, `.execute("key\"); alert(1); (\"", { action: "\u003c/script\u003e\u003cscript\u003ealert(1)\u003c/script\u003e" })`))
+	expect.Equal(t, strings.Count(script, "</script>"), 2)
+}
@@ -0,0 +1,41 @@
+package captcha
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+const siteVerifyTimeout = 3 * time.Second
+
+// siteVerify posts the form to the siteverify endpoint of the provider and decodes the response into result.
+func siteVerify(ctx context.Context, endpoint string, form url.Values, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, siteVerifyTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return sonic.ConfigDefault.NewDecoder(resp.Body).Decode(result)
+}
+
+func remoteIP(r *http.Request) string {
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return ip
+	}
+	return r.RemoteAddr
+}
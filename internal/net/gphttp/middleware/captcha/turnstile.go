@@ -0,0 +1,67 @@
+package captcha
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	gperr "github.com/yusing/goutils/errs"
+)
+
+type TurnstileProvider struct {
+	ProviderBase
+
+	SiteKey string `json:"site_key" validate:"required"`
+	Secret  string `json:"secret" validate:"required"`
+}
+
+// https://developers.cloudflare.com/turnstile/reference/content-security-policy/
+func (p *TurnstileProvider) CSPDirectives() []string {
+	return []string{"script-src", "frame-src"}
+}
+
+// https://developers.cloudflare.com/turnstile/reference/content-security-policy/
+func (p *TurnstileProvider) CSPSources() []string {
+	return []string{"https://challenges.cloudflare.com"}
+}
+
+// https://developers.cloudflare.com/turnstile/get-started/server-side-validation/
+func (p *TurnstileProvider) Verify(r *http.Request) error {
+	response := r.PostFormValue("cf-turnstile-response")
+	if response == "" {
+		return errors.New("cf-turnstile-response is missing")
+	}
+
+	formData := url.Values{}
+	formData.Set("secret", p.Secret)
+	formData.Set("response", response)
+	formData.Set("remoteip", remoteIP(r))
+
+	var respData struct {
+		Success bool     `json:"success"`
+		Error   []string `json:"error-codes"`
+	}
+	if err := siteVerify(r.Context(), "https://challenges.cloudflare.com/turnstile/v0/siteverify", formData, &respData); err != nil {
+		return err
+	}
+
+	if !respData.Success {
+		return gperr.JoinLines(ErrCaptchaVerificationFailed, respData.Error...)
+	}
+
+	return nil
+}
+
+func (p *TurnstileProvider) ScriptHTML() string {
+	return `
+<script src="https://challenges.cloudflare.com/turnstile/v0/api.js" async defer></script>`
+}
+
+func (p *TurnstileProvider) FormHTML(*http.Request) string {
+	return `
+<div
+	class="cf-turnstile"
+	data-sitekey="` + p.SiteKey + `"
+	data-callback="onDataCallback"
+></div>`
+}
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yusing/godoxy/internal/route/rules"
+	gperr "github.com/yusing/goutils/errs"
+)
+
+type (
+	cors struct {
+		CORSOpts
+
+		allowAll     bool
+		matchers     []rules.Matcher
+		allowMethods string
+		allowHeaders string
+		exposeHeader string
+		maxAge       string
+	}
+	CORSOpts struct {
+		// string, glob(...) or regex(...), "*" to allow all origins.
+		//
+		// "*" cannot be used with allow_credentials.
+		AllowOrigins []string `json:"allow_origins"`
+		// empty or "*" to reflect Access-Control-Request-Method.
+		AllowMethods []string `json:"allow_methods"`
+		// empty or "*" to reflect Access-Control-Request-Headers.
+		AllowHeaders     []string      `json:"allow_headers"`
+		ExposeHeaders    []string      `json:"expose_headers"`
+		AllowCredentials bool          `json:"allow_credentials"`
+		MaxAge           time.Duration `json:"max_age"`
+	}
+)
+
+const (
+	headerOrigin                        = "Origin"
+	headerVary                          = "Vary"
+	headerAccessControlRequestMethod    = "Access-Control-Request-Method"
+	headerAccessControlRequestHeaders   = "Access-Control-Request-Headers"
+	headerAccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	headerAccessControlAllowMethods     = "Access-Control-Allow-Methods"
+	headerAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	headerAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	headerAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	headerAccessControlMaxAge           = "Access-Control-Max-Age"
+)
+
+var ErrCORSCredentialsAllowAll = gperr.New("allow_credentials requires an explicit allow_origins list")
+
+var (
+	CORS            = NewMiddleware[cors]()
+	corsOptsDefault = CORSOpts{
+		AllowOrigins: []string{"*"},
+	}
+)
+
+// setup implements MiddlewareWithSetup.
+func (m *cors) setup() {
+	m.CORSOpts = corsOptsDefault
+}
+
+// finalize implements MiddlewareFinalizerWithError.
+func (m *cors) finalize() error {
+	errs := gperr.NewBuilder("invalid allow_origins")
+	for _, origin := range m.AllowOrigins {
+		if origin == "*" {
+			m.allowAll = true
+			continue
+		}
+		matcher, err := rules.ParseMatcher(origin)
+		if err != nil {
+			errs.Add(err.Subject(origin))
+			continue
+		}
+		m.matchers = append(m.matchers, matcher)
+	}
+	if !slices.Contains(m.AllowMethods, "*") {
+		m.allowMethods = strings.Join(m.AllowMethods, ", ")
+	}
+	if !slices.Contains(m.AllowHeaders, "*") {
+		m.allowHeaders = strings.Join(m.AllowHeaders, ", ")
+	}
+	m.exposeHeader = strings.Join(m.ExposeHeaders, ", ")
+	if m.MaxAge > 0 {
+		m.maxAge = strconv.Itoa(int(m.MaxAge.Seconds()))
+	}
+	if m.allowAll && m.AllowCredentials {
+		// reflecting any origin with credentials lets every site read authenticated responses
+		return ErrCORSCredentialsAllowAll
+	}
+	return errs.Error()
+}
+
+// before implements RequestModifier.
+func (m *cors) before(w http.ResponseWriter, r *http.Request) (proceed bool) {
+	if !isPreflight(r) {
+		return true
+	}
+
+	// preflight requests are answered here and never reach the upstream
+	header := w.Header()
+	header.Add(headerVary, headerOrigin)
+	header.Add(headerVary, headerAccessControlRequestMethod)
+	header.Add(headerVary, headerAccessControlRequestHeaders)
+
+	origin := r.Header.Get(headerOrigin)
+	if !m.isOriginAllowed(origin) {
+		http.Error(w, "CORS origin not allowed", http.StatusForbidden)
+		return false
+	}
+
+	m.setAllowOrigin(header, origin)
+	if m.allowMethods != "" {
+		header.Set(headerAccessControlAllowMethods, m.allowMethods)
+	} else {
+		header.Set(headerAccessControlAllowMethods, r.Header.Get(headerAccessControlRequestMethod))
+	}
+	if m.allowHeaders != "" {
+		header.Set(headerAccessControlAllowHeaders, m.allowHeaders)
+	} else if reqHeaders := r.Header.Get(headerAccessControlRequestHeaders); reqHeaders != "" {
+		header.Set(headerAccessControlAllowHeaders, reqHeaders)
+	}
+	if m.maxAge != "" {
+		header.Set(headerAccessControlMaxAge, m.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return false
+}
+
+// modifyResponse implements ResponseModifier.
+func (m *cors) modifyResponse(resp *http.Response) error {
+	origin := resp.Request.Header.Get(headerOrigin)
+	if !m.allowAll {
+		resp.Header.Add(headerVary, headerOrigin)
+	}
+	if origin == "" {
+		return nil
+	}
+
+	// override the upstream CORS headers
+	resp.Header.Del(headerAccessControlAllowOrigin)
+	resp.Header.Del(headerAccessControlAllowCredentials)
+	resp.Header.Del(headerAccessControlExposeHeaders)
+
+	if !m.isOriginAllowed(origin) {
+		return nil
+	}
+	m.setAllowOrigin(resp.Header, origin)
+	if m.exposeHeader != "" {
+		resp.Header.Set(headerAccessControlExposeHeaders, m.exposeHeader)
+	}
+	return nil
+}
+
+func (m *cors) isOriginAllowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if m.allowAll {
+		return true
+	}
+	for _, match := range m.matchers {
+		if match(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *cors) setAllowOrigin(header http.Header, origin string) {
+	if m.allowAll {
+		header.Set(headerAccessControlAllowOrigin, "*")
+		return
+	}
+	header.Set(headerAccessControlAllowOrigin, origin)
+	if m.AllowCredentials {
+		header.Set(headerAccessControlAllowCredentials, "true")
+	}
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get(headerOrigin) != "" &&
+		r.Header.Get(headerAccessControlRequestMethod) != ""
+}
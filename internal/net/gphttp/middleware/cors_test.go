@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	expect "github.com/yusing/goutils/testing"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	opts := OptionsRaw{
+		"allow_origins":     []string{"https://example.com", "glob(https://*.example.org)"},
+		"allow_methods":     []string{"GET", "POST"},
+		"allow_credentials": true,
+		"max_age":           "10m",
+	}
+	t.Run("allowed", func(t *testing.T) {
+		result, err := newMiddlewareTest(CORS, &testArgs{
+			middlewareOpt: opts,
+			reqMethod:     http.MethodOptions,
+			headers: http.Header{
+				"Origin":                         {"https://app.example.org"},
+				"Access-Control-Request-Method":  {"POST"},
+				"Access-Control-Request-Headers": {"X-Custom"},
+			},
+		})
+		expect.NoError(t, err)
+		expect.Equal(t, result.ResponseStatus, http.StatusNoContent)
+		expect.Equal(t, result.ResponseHeaders.Get("Access-Control-Allow-Origin"), "https://app.example.org")
+		expect.Equal(t, result.ResponseHeaders.Get("Access-Control-Allow-Credentials"), "true")
+		expect.Equal(t, result.ResponseHeaders.Get("Access-Control-Allow-Methods"), "GET, POST")
+		expect.Equal(t, result.ResponseHeaders.Get("Access-Control-Allow-Headers"), "X-Custom")
+		expect.Equal(t, result.ResponseHeaders.Get("Access-Control-Max-Age"), "600")
+		expect.True(t, slices.Contains(result.ResponseHeaders.Values("Vary"), "Origin"))
+		// should not reach upstream
+		expect.Nil(t, result.RequestHeaders)
+	})
+	t.Run("disallowed", func(t *testing.T) {
+		result, err := newMiddlewareTest(CORS, &testArgs{
+			middlewareOpt: opts,
+			reqMethod:     http.MethodOptions,
+			headers: http.Header{
+				"Origin":                        {"https://evil.com"},
+				"Access-Control-Request-Method": {"POST"},
+			},
+		})
+		expect.NoError(t, err)
+		expect.Equal(t, result.ResponseStatus, http.StatusForbidden)
+		expect.Equal(t, result.ResponseHeaders.Get("Access-Control-Allow-Origin"), "")
+	})
+}
+
+func TestCORSResponse(t *testing.T) {
+	t.Run("wildcard", func(t *testing.T) {
+		result, err := newMiddlewareTest(CORS, &testArgs{
+			headers: http.Header{"Origin": {"https://example.com"}},
+		})
+		expect.NoError(t, err)
+		expect.Equal(t, result.ResponseStatus, http.StatusOK)
+		expect.Equal(t, result.ResponseHeaders.Get("Access-Control-Allow-Origin"), "*")
+	})
+	t.Run("credentials", func(t *testing.T) {
+		result, err := newMiddlewareTest(CORS, &testArgs{
+			middlewareOpt: OptionsRaw{
+				"allow_origins":     []string{"https://example.com"},
+				"allow_credentials": true,
+				"expose_headers":    []string{"X-Total-Count"},
+			},
+			headers: http.Header{"Origin": {"https://example.com"}},
+		})
+		expect.NoError(t, err)
+		expect.Equal(t, result.ResponseHeaders.Get("Access-Control-Allow-Origin"), "https://example.com")
+		expect.Equal(t, result.ResponseHeaders.Get("Access-Control-Allow-Credentials"), "true")
+		expect.Equal(t, result.ResponseHeaders.Get("Access-Control-Expose-Headers"), "X-Total-Count")
+		expect.True(t, slices.Contains(result.ResponseHeaders.Values("Vary"), "Origin"))
+	})
+	t.Run("disallowed", func(t *testing.T) {
+		result, err := newMiddlewareTest(CORS, &testArgs{
+			middlewareOpt: OptionsRaw{"allow_origins": []string{"regex(^https://([a-z]+\\.)?example\\.com$)"}},
+			headers:       http.Header{"Origin": {"https://example.org"}},
+		})
+		expect.NoError(t, err)
+		expect.Equal(t, result.ResponseStatus, http.StatusOK)
+		expect.Equal(t, result.ResponseHeaders.Get("Access-Control-Allow-Origin"), "")
+	})
+	t.Run("credentials with wildcard", func(t *testing.T) {
+		_, err := newMiddlewareTest(CORS, &testArgs{
+			middlewareOpt: OptionsRaw{"allow_credentials": true},
+		})
+		expect.ErrorIs(t, ErrCORSCredentialsAllowAll, err)
+	})
+	t.Run("invalid origin", func(t *testing.T) {
+		_, err := newMiddlewareTest(CORS, &testArgs{
+			middlewareOpt: OptionsRaw{"allow_origins": []string{"regex([)"}},
+		})
+		expect.HasError(t, err)
+	})
+}
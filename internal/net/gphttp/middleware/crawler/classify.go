@@ -0,0 +1,145 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Class is the classification of a request by its user agent and origin.
+type Class string
+
+const (
+	ClassHuman           Class = "human"
+	ClassBot             Class = "bot"              // generic automation, e.g. curl, python-requests
+	ClassGoodBot         Class = "good_bot"         // allowed by user agent
+	ClassBadBot          Class = "bad_bot"          // blocked by user agent, e.g. aggressive scrapers
+	ClassVerifiedCrawler Class = "verified_crawler" // a known crawler, verified by IP ranges or DNS
+	ClassFakeCrawler     Class = "fake_crawler"     // claims to be a known crawler but failed verification
+)
+
+var AllClasses = []Class{ClassHuman, ClassBot, ClassGoodBot, ClassBadBot, ClassVerifiedCrawler, ClassFakeCrawler}
+
+// DefaultBadBots are user agents of aggressive scrapers and SEO crawlers.
+var DefaultBadBots = []string{
+	"AhrefsBot",
+	"SemrushBot",
+	"MJ12bot",
+	"DotBot",
+	"PetalBot",
+	"Bytespider",
+	"BLEXBot",
+	"DataForSeoBot",
+	"MegaIndex",
+	"serpstatbot",
+	"SeekportBot",
+	"barkrowler",
+	"ZoominfoBot",
+	"Scrapy",
+}
+
+// genericBots are user agent substrings of generic automation tools and unknown bots.
+var genericBots = []string{
+	"bot",
+	"crawl",
+	"spider",
+	"scrape",
+	"curl/",
+	"wget/",
+	"python-requests",
+	"python-urllib",
+	"aiohttp",
+	"httpx",
+	"go-http-client",
+	"java/",
+	"okhttp",
+	"libwww-perl",
+	"axios/",
+	"node-fetch",
+	"headlesschrome",
+	"phantomjs",
+}
+
+// Classifier classifies requests with case-insensitive user agent substrings.
+type Classifier struct {
+	goodBots []string
+	badBots  []string
+}
+
+type classContextKey struct{}
+
+var DefaultClassifier = NewClassifier(nil, DefaultBadBots)
+
+func NewClassifier(goodBots, badBots []string) *Classifier {
+	return &Classifier{
+		goodBots: toLower(goodBots),
+		badBots:  toLower(badBots),
+	}
+}
+
+// Classify classifies the request, the order of precedence is
+// good bots, bad bots, known crawlers, generic bots, then human.
+//
+// Known crawlers are classified as bots until their IP verification completes.
+func (c *Classifier) Classify(r *http.Request) Class {
+	ua := r.UserAgent()
+	if ua == "" {
+		return ClassBot
+	}
+	lowerUA := strings.ToLower(ua)
+	if containsAny(lowerUA, c.goodBots) {
+		return ClassGoodBot
+	}
+	if containsAny(lowerUA, c.badBots) {
+		return ClassBadBot
+	}
+	if known := Identify(ua); known != nil {
+		verified, pending := known.VerifyAsync(remoteIP(r))
+		switch {
+		case verified:
+			return ClassVerifiedCrawler
+		case pending:
+			// not known yet, avoid blocking real crawlers before the lookup completes
+			return ClassBot
+		}
+		return ClassFakeCrawler
+	}
+	if containsAny(lowerUA, genericBots) {
+		return ClassBot
+	}
+	return ClassHuman
+}
+
+// WithClass stores the classification in the request context.
+func WithClass(r *http.Request, class Class) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), classContextKey{}, class))
+}
+
+// ClassOf returns the classification stored by WithClass,
+// or classifies the request with the DefaultClassifier.
+//
+// Route rules run before route middlewares, so they always
+// see the DefaultClassifier instead of a bot_filter's classifier.
+func ClassOf(r *http.Request) Class {
+	if class, ok := r.Context().Value(classContextKey{}).(Class); ok {
+		return class
+	}
+	return DefaultClassifier.Classify(r)
+}
+
+func toLower(list []string) []string {
+	lower := make([]string, len(list))
+	for i, s := range list {
+		lower[i] = strings.ToLower(s)
+	}
+	return lower
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package crawler
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+	"github.com/yusing/godoxy/internal/utils"
+	"github.com/yusing/goutils/synk"
+	"github.com/yusing/goutils/task"
+)
+
+// Crawler is a well-known search engine crawler that can be verified
+// with a reverse DNS lookup followed by a forward DNS lookup.
+type Crawler struct {
+	Name       string
+	UserAgents []string // user agent substrings
+	Domains    []string // reverse DNS hostname suffixes
+	RangesURL  string   // published IP ranges, checked before DNS lookups
+
+	ranges           atomic.Pointer[[]*net.IPNet]
+	rangesLastUpdate synk.Value[time.Time]
+	rangesMu         sync.Mutex
+}
+
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+type cacheEntry struct {
+	verified bool
+	expiry   time.Time
+}
+
+// https://developers.google.com/search/docs/crawling-indexing/verifying-googlebot
+// https://www.bing.com/webmasters/help/how-to-verify-bingbot-3905dc26
+var KnownCrawlers = []*Crawler{
+	{
+		Name:       "Googlebot",
+		UserAgents: []string{"Googlebot", "Google-InspectionTool", "GoogleOther", "Storebot-Google", "AdsBot-Google"},
+		Domains:    []string{".googlebot.com", ".google.com", ".googleusercontent.com"},
+		RangesURL:  "https://developers.google.com/static/search/apis/ipranges/googlebot.json",
+	},
+	{
+		Name:       "Bingbot",
+		UserAgents: []string{"bingbot", "BingPreview", "adidxbot"},
+		Domains:    []string{".search.msn.com"},
+		RangesURL:  "https://www.bing.com/toolbox/bingbot.json",
+	},
+	{
+		Name:       "DuckDuckBot",
+		UserAgents: []string{"DuckDuckBot"},
+		Domains:    []string{".duckduckgo.com"},
+	},
+	{
+		Name:       "Applebot",
+		UserAgents: []string{"Applebot"},
+		Domains:    []string{".applebot.apple.com"},
+	},
+	{
+		Name:       "YandexBot",
+		UserAgents: []string{"YandexBot", "YandexImages", "YandexMobileBot"},
+		Domains:    []string{".yandex.ru", ".yandex.net", ".yandex.com"},
+	},
+	{
+		Name:       "Baiduspider",
+		UserAgents: []string{"Baiduspider"},
+		Domains:    []string{".crawl.baidu.com", ".crawl.baidu.jp"},
+	},
+}
+
+const (
+	cacheTTL           = 6 * time.Hour
+	cacheCleanInterval = 10 * time.Minute
+	cacheMaxEntries    = 10000
+	lookupTimeout      = 3 * time.Second
+)
+
+var (
+	// DefaultResolver is used for DNS lookups, replaced in tests.
+	DefaultResolver Resolver = net.DefaultResolver
+
+	verifyCache = xsync.NewMap[string, cacheEntry]()
+	verifying   = xsync.NewMap[string, struct{}]()
+)
+
+var initCacheCleanerOnce sync.Once
+
+func initCacheCleaner() {
+	cleaner := task.RootTask("crawler_cache_cleaner", true)
+	go func() {
+		ticker := time.NewTicker(cacheCleanInterval)
+		defer ticker.Stop()
+		defer cleaner.Finish("program exit")
+
+		for {
+			select {
+			case <-ticker.C:
+				cleanExpired()
+			case <-cleaner.Context().Done():
+				return
+			}
+		}
+	}()
+}
+
+func cleanExpired() {
+	now := utils.TimeNow()
+	verifyCache.Range(func(key string, entry cacheEntry) bool {
+		if now.After(entry.expiry) {
+			verifyCache.Delete(key)
+		}
+		return true
+	})
+}
+
+// cacheResult stores the verification result, evicting an arbitrary entry when the cache is full.
+func cacheResult(key string, verified bool) {
+	initCacheCleanerOnce.Do(initCacheCleaner)
+	if verifyCache.Size() >= cacheMaxEntries {
+		verifyCache.Range(func(k string, _ cacheEntry) bool {
+			verifyCache.Delete(k)
+			return verifyCache.Size() >= cacheMaxEntries
+		})
+	}
+	verifyCache.Store(key, cacheEntry{verified: verified, expiry: utils.TimeNow().Add(cacheTTL)})
+}
+
+// Identify returns the crawler claimed by the user agent, or nil if none.
+//
+// The result is not trusted, use Verify to verify it.
+func Identify(userAgent string) *Crawler {
+	if userAgent == "" {
+		return nil
+	}
+	for _, c := range KnownCrawlers {
+		for _, ua := range c.UserAgents {
+			if strings.Contains(userAgent, ua) {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// Verify checks whether ip belongs to the crawler.
+//
+// The ip is first checked against the published IP ranges if available,
+// otherwise the reverse DNS hostname of ip must end with one of the crawler's domains,
+// and the forward DNS lookup of the hostname must resolve back to ip.
+// Results are cached up to cacheMaxEntries, expired entries are evicted periodically.
+func (c *Crawler) Verify(ctx context.Context, ip string) bool {
+	key := c.Name + ":" + ip
+	if entry, ok := verifyCache.Load(key); ok && utils.TimeNow().Before(entry.expiry) {
+		return entry.verified
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+
+	verified := c.verify(ctx, ip)
+	cacheResult(key, verified)
+	return verified
+}
+
+// VerifyAsync returns the cached verification result of ip without blocking.
+//
+// On a cache miss, ip is verified in the background and pending is true,
+// later requests see the result once the lookup completes.
+func (c *Crawler) VerifyAsync(ip string) (verified, pending bool) {
+	key := c.Name + ":" + ip
+	if entry, ok := verifyCache.Load(key); ok && utils.TimeNow().Before(entry.expiry) {
+		return entry.verified, false
+	}
+	if _, loaded := verifying.LoadOrStore(key, struct{}{}); !loaded {
+		go func() {
+			defer verifying.Delete(key)
+			c.Verify(context.Background(), ip)
+		}()
+	}
+	return false, true
+}
+
+func (c *Crawler) verify(ctx context.Context, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	if c.inRanges(parsed) {
+		return true
+	}
+	hosts, err := DefaultResolver.LookupAddr(ctx, ip)
+	if err != nil {
+		return false
+	}
+	for _, host := range hosts {
+		host = strings.TrimSuffix(host, ".")
+		if !c.matchDomain(host) {
+			continue
+		}
+		addrs, err := DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if addr.IP.Equal(parsed) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *Crawler) matchDomain(host string) bool {
+	for _, domain := range c.Domains {
+		if strings.HasSuffix(host, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// FromRequest returns the verified crawler of the request, or nil if
+// the request does not come from a verified crawler.
+//
+// It does not block on DNS lookups, a crawler is not verified
+// until the background verification of its IP completes.
+func FromRequest(r *http.Request) *Crawler {
+	c := Identify(r.UserAgent())
+	if c == nil {
+		return nil
+	}
+	if verified, _ := c.VerifyAsync(remoteIP(r)); !verified {
+		return nil
+	}
+	return c
+}
+
+func remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
@@ -0,0 +1,105 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	expect "github.com/yusing/goutils/testing"
+)
+
+type fakeResolver struct {
+	ptr   map[string][]string
+	hosts map[string][]string
+}
+
+func (f *fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	if names, ok := f.ptr[addr]; ok {
+		return names, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (f *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := f.hosts[host]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	addrs := make([]net.IPAddr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.IPAddr{IP: net.ParseIP(ip)}
+	}
+	return addrs, nil
+}
+
+func TestVerify(t *testing.T) {
+	DefaultResolver = &fakeResolver{
+		ptr: map[string][]string{
+			"66.249.66.1": {"crawl-66-249-66-1.googlebot.com."},
+			"10.0.0.1":    {"crawl-10-0-0-1.googlebot.com."}, // spoofed PTR record
+			"10.0.0.2":    {"host.example.com."},
+		},
+		hosts: map[string][]string{
+			"crawl-66-249-66-1.googlebot.com": {"66.249.66.1"},
+			"crawl-10-0-0-1.googlebot.com":    {"66.249.66.2"},
+		},
+	}
+	t.Cleanup(func() {
+		DefaultResolver = net.DefaultResolver
+		verifyCache.Clear()
+	})
+
+	googlebot := Identify("Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)")
+	expect.NotNil(t, googlebot)
+	expect.Equal(t, googlebot.Name, "Googlebot")
+
+	expect.True(t, googlebot.Verify(t.Context(), "66.249.66.1"))
+	expect.False(t, googlebot.Verify(t.Context(), "10.0.0.1"))
+	expect.False(t, googlebot.Verify(t.Context(), "10.0.0.2"))
+	expect.False(t, googlebot.Verify(t.Context(), "10.0.0.3"))
+
+	expect.Nil(t, Identify("Mozilla/5.0 (Windows NT 10.0; Win64; x64)"))
+}
+
+func TestVerifyAsync(t *testing.T) {
+	DefaultResolver = &fakeResolver{
+		ptr:   map[string][]string{"66.249.66.1": {"crawl-66-249-66-1.googlebot.com."}},
+		hosts: map[string][]string{"crawl-66-249-66-1.googlebot.com": {"66.249.66.1"}},
+	}
+	t.Cleanup(func() {
+		DefaultResolver = net.DefaultResolver
+		verifyCache.Clear()
+	})
+
+	googlebot := Identify("Googlebot/2.1")
+	verified, pending := googlebot.VerifyAsync("66.249.66.1")
+	expect.False(t, verified)
+	expect.True(t, pending)
+
+	expect.True(t, waitVerified(googlebot, "66.249.66.1"))
+}
+
+func waitVerified(c *Crawler, ip string) bool {
+	for range 100 {
+		if verified, pending := c.VerifyAsync(ip); !pending {
+			return verified
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestCacheMaxEntries(t *testing.T) {
+	t.Cleanup(verifyCache.Clear)
+
+	for i := range cacheMaxEntries + 10 {
+		cacheResult(strconv.Itoa(i), false)
+	}
+	expect.True(t, verifyCache.Size() <= cacheMaxEntries)
+
+	_, ok := verifyCache.Load(strconv.Itoa(cacheMaxEntries + 9))
+	expect.True(t, ok)
+}
@@ -0,0 +1,117 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/rs/zerolog/log"
+	"github.com/yusing/godoxy/internal/common"
+	strutils "github.com/yusing/goutils/strings"
+)
+
+const (
+	rangesUpdateInterval      = 24 * time.Hour
+	rangesUpdateRetryInterval = 10 * time.Minute
+	rangesFetchTimeout        = 5 * time.Second
+)
+
+// ipRanges returns the published IP ranges of the crawler,
+// outdated ranges are refreshed in the background. It returns nil if unavailable.
+func (c *Crawler) ipRanges() []*net.IPNet {
+	if c.RangesURL == "" || common.IsTest {
+		return nil
+	}
+
+	ranges := c.loadRanges()
+	if time.Since(c.rangesLastUpdate.Load()) < rangesUpdateInterval {
+		return ranges
+	}
+
+	if c.rangesMu.TryLock() {
+		// not being updated by another request
+		go c.updateRanges()
+	}
+	return ranges
+}
+
+// updateRanges fetches the published IP ranges, rangesMu must be held by the caller.
+func (c *Crawler) updateRanges() {
+	defer c.rangesMu.Unlock()
+
+	updated, err := fetchIPRanges(c.RangesURL)
+	if err != nil {
+		c.rangesLastUpdate.Store(time.Now().Add(rangesUpdateRetryInterval - rangesUpdateInterval))
+		log.Err(err).Str("crawler", c.Name).Msg("failed to update crawler IP ranges, retry in " + strutils.FormatDuration(rangesUpdateRetryInterval))
+		return
+	}
+	c.ranges.Store(&updated)
+	c.rangesLastUpdate.Store(time.Now())
+	log.Info().Str("crawler", c.Name).Int("count", len(updated)).Msg("crawler IP ranges updated")
+}
+
+func (c *Crawler) loadRanges() []*net.IPNet {
+	if ranges := c.ranges.Load(); ranges != nil {
+		return *ranges
+	}
+	return nil
+}
+
+func (c *Crawler) inRanges(ip net.IP) bool {
+	for _, ipnet := range c.ipRanges() {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchIPRanges fetches IP ranges in the format published by Google and Bing, i.e.
+//
+//	{"prefixes": [{"ipv4Prefix": "66.249.64.0/27"}, {"ipv6Prefix": "2001:4860:4801:10::/64"}]}
+func fetchIPRanges(endpoint string) ([]*net.IPNet, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rangesFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var data struct {
+		Prefixes []struct {
+			IPv4Prefix string `json:"ipv4Prefix"`
+			IPv6Prefix string `json:"ipv6Prefix"`
+		} `json:"prefixes"`
+	}
+	if err := sonic.ConfigDefault.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	ranges := make([]*net.IPNet, 0, len(data.Prefixes))
+	for _, prefix := range data.Prefixes {
+		cidr := prefix.IPv4Prefix
+		if cidr == "" {
+			cidr = prefix.IPv6Prefix
+		}
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix %q", cidr)
+		}
+		ranges = append(ranges, ipnet)
+	}
+	return ranges, nil
+}
@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yusing/godoxy/internal/auth"
+	gperr "github.com/yusing/goutils/errs"
+)
+
+// jwtAuth protects upstream APIs with bearer tokens issued by an identity provider.
+//
+// Verified claims are available as $jwt_claim(name) in rules when used as an entrypoint middleware.
+// Route rules run before route middlewares, so they do not see the claims of a route level jwt_auth.
+type jwtAuth struct {
+	JWKSURL    string   `json:"jwks_url" validate:"omitempty,url"`
+	PublicKeys []string `json:"public_keys"` // PEM encoded public keys or certificates, or file:// paths
+	Secret     string   `json:"secret"`      // HMAC secret
+	// allowed signing algorithms, defaults to all algorithms of the configured keys.
+	Algorithms     []string          `json:"algorithms"`
+	Issuer         string            `json:"issuer"`
+	Audience       []string          `json:"audience"` // any of
+	Leeway         time.Duration     `json:"leeway"`
+	RequiredClaims map[string]string `json:"required_claims"` // claim name -> value, empty to only require presence
+	ClaimHeaders   map[string]string `json:"claim_headers"`   // claim name -> request header name
+	TokenQuery     string            `json:"token_query"`     // query parameter to read the token from if there is no Authorization header
+	TokenCookie    string            `json:"token_cookie"`    // cookie to read the token from if there is no Authorization header
+	Realm          string            `json:"realm"`
+
+	jwks       *auth.JWKS
+	staticKeys jwt.VerificationKeySet
+	parser     *jwt.Parser
+}
+
+var (
+	hmacAlgorithms       = []string{"HS256", "HS384", "HS512"}
+	rsaAlgorithms        = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+	ecdsaAlgorithms      = []string{"ES256", "ES384", "ES512"}
+	ed25519Algorithms    = []string{"EdDSA"}
+	asymmetricAlgorithms = slices.Concat(rsaAlgorithms, ecdsaAlgorithms, ed25519Algorithms)
+)
+
+var JWTAuth = NewMiddleware[jwtAuth]()
+
+// setup implements MiddlewareWithSetup.
+func (m *jwtAuth) setup() {
+	m.Realm = "Restricted"
+}
+
+// finalize implements MiddlewareFinalizerWithError.
+func (m *jwtAuth) finalize() error {
+	if m.JWKSURL == "" && len(m.PublicKeys) == 0 && m.Secret == "" {
+		return gperr.New("jwks_url, public_keys or secret is required")
+	}
+
+	var algorithms []string
+	if m.JWKSURL != "" {
+		m.jwks = auth.GetJWKS(m.JWKSURL)
+		algorithms = append(algorithms, asymmetricAlgorithms...)
+	}
+
+	errs := gperr.NewBuilder("invalid public_keys")
+	for i, s := range m.PublicKeys {
+		key, err := auth.ParsePublicKey(s)
+		if err != nil {
+			errs.Add(gperr.Wrap(err).Subjectf("%d", i))
+			continue
+		}
+		switch key.(type) {
+		case *rsa.PublicKey:
+			algorithms = append(algorithms, rsaAlgorithms...)
+		case *ecdsa.PublicKey:
+			algorithms = append(algorithms, ecdsaAlgorithms...)
+		case ed25519.PublicKey:
+			algorithms = append(algorithms, ed25519Algorithms...)
+		default:
+			errs.Add(gperr.Errorf("unsupported key type %T", key).Subjectf("%d", i))
+			continue
+		}
+		m.staticKeys.Keys = append(m.staticKeys.Keys, key)
+	}
+	if errs.HasError() {
+		return errs.Error()
+	}
+	if m.Secret != "" {
+		m.staticKeys.Keys = append(m.staticKeys.Keys, []byte(m.Secret))
+		algorithms = append(algorithms, hmacAlgorithms...)
+	}
+
+	if len(m.Algorithms) > 0 {
+		for _, alg := range m.Algorithms {
+			if !slices.Contains(algorithms, alg) {
+				return gperr.Errorf("algorithm %q is not supported by the configured keys", alg)
+			}
+		}
+		algorithms = m.Algorithms
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(algorithms),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(m.Leeway),
+	}
+	if m.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(m.Issuer))
+	}
+	if len(m.Audience) > 0 {
+		opts = append(opts, jwt.WithAudience(m.Audience...))
+	}
+	m.parser = jwt.NewParser(opts...)
+	return nil
+}
+
+// before implements RequestModifier.
+func (m *jwtAuth) before(w http.ResponseWriter, r *http.Request) (proceed bool) {
+	_, proceed = m.beforeWithContext(w, r)
+	return proceed
+}
+
+// beforeWithContext implements RequestContextModifier.
+func (m *jwtAuth) beforeWithContext(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	// never trust claim headers from the client
+	for _, header := range m.ClaimHeaders {
+		r.Header.Del(header)
+	}
+
+	tokenString := m.tokenFromRequest(r)
+	if tokenString == "" {
+		m.unauthorized(w, "")
+		return r, false
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := m.parser.ParseWithClaims(tokenString, claims, m.keyfunc); err != nil {
+		if !errors.Is(err, jwt.ErrTokenExpired) {
+			JWTAuth.LogWarn(r).Err(err).Msg("invalid jwt")
+		}
+		m.unauthorized(w, "invalid_token")
+		return r, false
+	}
+
+	for claim, value := range m.RequiredClaims {
+		if !hasClaim(claims, claim, value) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+m.Realm+`", error="insufficient_scope"`)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return r, false
+		}
+	}
+
+	for claim, header := range m.ClaimHeaders {
+		if v, ok := claims[claim]; ok {
+			r.Header.Set(header, auth.FormatClaim(v))
+		}
+	}
+	return auth.WithClaims(r, map[string]any(claims)), true
+}
+
+func (m *jwtAuth) tokenFromRequest(r *http.Request) string {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	if m.TokenQuery != "" {
+		if token := r.URL.Query().Get(m.TokenQuery); token != "" {
+			return token
+		}
+	}
+	if m.TokenCookie != "" {
+		if cookie, err := r.Cookie(m.TokenCookie); err == nil {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// keyfunc returns the key from JWKS, falling back to the static keys.
+func (m *jwtAuth) keyfunc(token *jwt.Token) (any, error) {
+	if m.jwks == nil {
+		return m.staticKeys, nil
+	}
+	key, err := m.jwks.Keyfunc(token)
+	if err != nil {
+		if len(m.staticKeys.Keys) == 0 {
+			return nil, err
+		}
+		return m.staticKeys, nil
+	}
+	return key, nil
+}
+
+func (m *jwtAuth) unauthorized(w http.ResponseWriter, errCode string) {
+	challenge := `Bearer realm="` + m.Realm + `"`
+	if errCode != "" {
+		challenge += `, error="` + errCode + `"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// hasClaim returns whether the claim is present and equals the value,
+// or contains it if the claim is an array.
+func hasClaim(claims jwt.MapClaims, claim, value string) bool {
+	v, ok := claims[claim]
+	if !ok {
+		return false
+	}
+	if value == "" {
+		return true
+	}
+	if values, ok := v.([]any); ok {
+		return slices.ContainsFunc(values, func(elem any) bool {
+			return auth.FormatClaim(elem) == value
+		})
+	}
+	return auth.FormatClaim(v) == value
+}
@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yusing/godoxy/internal/auth"
+	"github.com/yusing/godoxy/internal/route/rules"
+	"github.com/yusing/godoxy/internal/serialization"
+	expect "github.com/yusing/goutils/testing"
+)
+
+const jwtTestSecret = "0123456789abcdef0123456789abcdef"
+
+func signJWTTest(t *testing.T, method jwt.SigningMethod, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	expect.NoError(t, err)
+	return token
+}
+
+func validJWTClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":    "https://idp.example.com",
+		"aud":    "api",
+		"sub":    "alice",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"groups": []string{"dev", "ops"},
+	}
+}
+
+func TestJWTAuth(t *testing.T) {
+	opts := OptionsRaw{
+		"secret":          jwtTestSecret,
+		"issuer":          "https://idp.example.com",
+		"audience":        []string{"api"},
+		"required_claims": map[string]string{"groups": "ops"},
+		"claim_headers":   map[string]string{"sub": "X-User", "groups": "X-Groups"},
+	}
+	request := func(t *testing.T, token string) *TestResult {
+		t.Helper()
+		headers := http.Header{"X-User": {"mallory"}}
+		if token != "" {
+			headers.Set("Authorization", "Bearer "+token)
+		}
+		result, err := newMiddlewareTest(JWTAuth, &testArgs{
+			middlewareOpt: opts,
+			headers:       headers,
+		})
+		expect.NoError(t, err)
+		return result
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		result := request(t, signJWTTest(t, jwt.SigningMethodHS256, []byte(jwtTestSecret), validJWTClaims()))
+		expect.Equal(t, result.ResponseStatus, http.StatusOK)
+		expect.Equal(t, result.RequestHeaders.Get("X-User"), "alice")
+		expect.Equal(t, result.RequestHeaders.Get("X-Groups"), "dev,ops")
+	})
+	t.Run("missing token", func(t *testing.T) {
+		result := request(t, "")
+		expect.Equal(t, result.ResponseStatus, http.StatusUnauthorized)
+		expect.Equal(t, result.ResponseHeaders.Get("WWW-Authenticate"), `Bearer realm="Restricted"`)
+	})
+	t.Run("expired", func(t *testing.T) {
+		claims := validJWTClaims()
+		claims["exp"] = time.Now().Add(-time.Minute).Unix()
+		result := request(t, signJWTTest(t, jwt.SigningMethodHS256, []byte(jwtTestSecret), claims))
+		expect.Equal(t, result.ResponseStatus, http.StatusUnauthorized)
+		expect.Equal(t, result.ResponseHeaders.Get("WWW-Authenticate"), `Bearer realm="Restricted", error="invalid_token"`)
+	})
+	t.Run("not yet valid", func(t *testing.T) {
+		claims := validJWTClaims()
+		claims["nbf"] = time.Now().Add(time.Minute).Unix()
+		result := request(t, signJWTTest(t, jwt.SigningMethodHS256, []byte(jwtTestSecret), claims))
+		expect.Equal(t, result.ResponseStatus, http.StatusUnauthorized)
+	})
+	t.Run("missing exp", func(t *testing.T) {
+		claims := validJWTClaims()
+		delete(claims, "exp")
+		result := request(t, signJWTTest(t, jwt.SigningMethodHS256, []byte(jwtTestSecret), claims))
+		expect.Equal(t, result.ResponseStatus, http.StatusUnauthorized)
+	})
+	t.Run("wrong issuer", func(t *testing.T) {
+		claims := validJWTClaims()
+		claims["iss"] = "https://evil.example.com"
+		result := request(t, signJWTTest(t, jwt.SigningMethodHS256, []byte(jwtTestSecret), claims))
+		expect.Equal(t, result.ResponseStatus, http.StatusUnauthorized)
+	})
+	t.Run("wrong audience", func(t *testing.T) {
+		claims := validJWTClaims()
+		claims["aud"] = "other"
+		result := request(t, signJWTTest(t, jwt.SigningMethodHS256, []byte(jwtTestSecret), claims))
+		expect.Equal(t, result.ResponseStatus, http.StatusUnauthorized)
+	})
+	t.Run("wrong secret", func(t *testing.T) {
+		result := request(t, signJWTTest(t, jwt.SigningMethodHS256, []byte("wrong"), validJWTClaims()))
+		expect.Equal(t, result.ResponseStatus, http.StatusUnauthorized)
+	})
+	t.Run("missing required claim", func(t *testing.T) {
+		claims := validJWTClaims()
+		claims["groups"] = []string{"dev"}
+		result := request(t, signJWTTest(t, jwt.SigningMethodHS256, []byte(jwtTestSecret), claims))
+		expect.Equal(t, result.ResponseStatus, http.StatusForbidden)
+	})
+}
+
+func TestJWTAuthPublicKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	expect.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	expect.NoError(t, err)
+	publicKeyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	t.Run("static", func(t *testing.T) {
+		result, err := newMiddlewareTest(JWTAuth, &testArgs{
+			middlewareOpt: OptionsRaw{"public_keys": []string{publicKeyPEM}},
+			headers:       http.Header{"Authorization": {"Bearer " + signJWTTest(t, jwt.SigningMethodRS256, key, validJWTClaims())}},
+		})
+		expect.NoError(t, err)
+		expect.Equal(t, result.ResponseStatus, http.StatusOK)
+	})
+
+	t.Run("algorithm confusion", func(t *testing.T) {
+		// HMAC signed with the public key must not be accepted
+		result, err := newMiddlewareTest(JWTAuth, &testArgs{
+			middlewareOpt: OptionsRaw{"public_keys": []string{publicKeyPEM}},
+			headers:       http.Header{"Authorization": {"Bearer " + signJWTTest(t, jwt.SigningMethodHS256, []byte(publicKeyPEM), validJWTClaims())}},
+		})
+		expect.NoError(t, err)
+		expect.Equal(t, result.ResponseStatus, http.StatusUnauthorized)
+	})
+
+	t.Run("jwks", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = sonic.ConfigDefault.NewEncoder(w).Encode(map[string]any{
+				"keys": []map[string]string{{
+					"kty": "RSA",
+					"kid": "test",
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				}},
+			})
+		}))
+		defer srv.Close()
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, validJWTClaims())
+		token.Header["kid"] = "test"
+		signed, err := token.SignedString(key)
+		expect.NoError(t, err)
+
+		result, gerr := newMiddlewareTest(JWTAuth, &testArgs{
+			middlewareOpt: OptionsRaw{"jwks_url": srv.URL},
+			headers:       http.Header{"Authorization": {"Bearer " + signed}},
+		})
+		expect.NoError(t, gerr)
+		expect.Equal(t, result.ResponseStatus, http.StatusOK)
+	})
+}
+
+func TestJWTAuthOptions(t *testing.T) {
+	_, err := JWTAuth.New(OptionsRaw{"issuer": "https://idp.example.com"})
+	expect.ErrorContains(t, err, "required")
+	_, err = JWTAuth.New(OptionsRaw{"secret": jwtTestSecret, "algorithms": []string{"RS256"}})
+	expect.ErrorContains(t, err, "not supported")
+}
+
+func TestJWTAuthClaimRuleVariable(t *testing.T) {
+	var ruleSet rules.Rules
+	_, err := serialization.ConvertString(`
+- name: sub
+  do: set header X-Sub $jwt_claim(sub)
+`, reflect.ValueOf(&ruleSet))
+	expect.NoError(t, err)
+
+	mid, err := BuildMiddlewareFromChainRaw("jwt", []map[string]any{{"use": "jwt_auth", "secret": jwtTestSecret}})
+	expect.NoError(t, err)
+
+	upstream := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Sub", r.Header.Get("X-Sub"))
+		w.Header().Set("X-Claim", auth.FormatClaim(auth.ClaimsOf(r)["sub"]))
+	}
+	serve := func(t *testing.T, handler http.HandlerFunc) http.Header {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		req.Header.Set("Authorization", "Bearer "+signJWTTest(t, jwt.SigningMethodHS256, []byte(jwtTestSecret), validJWTClaims()))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		expect.Equal(t, rec.Code, http.StatusOK)
+		return rec.Header()
+	}
+
+	t.Run("entrypoint middleware", func(t *testing.T) {
+		// entrypoint middlewares run before route rules
+		routeHandler := ruleSet.BuildHandler(upstream)
+		header := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			mid.ServeHTTP(routeHandler, w, r)
+		})
+		expect.Equal(t, header.Get("X-Sub"), "alice")
+		expect.Equal(t, header.Get("X-Claim"), "alice")
+	})
+	t.Run("route middleware", func(t *testing.T) {
+		// route rules run before route middlewares, claims are only visible to the upstream
+		header := serve(t, ruleSet.BuildHandler(func(w http.ResponseWriter, r *http.Request) {
+			mid.ServeHTTP(upstream, w, r)
+		}))
+		expect.Equal(t, header.Get("X-Sub"), "")
+		expect.Equal(t, header.Get("X-Claim"), "alice")
+	})
+}
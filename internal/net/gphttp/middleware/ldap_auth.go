@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/yusing/godoxy/internal/auth"
+	"github.com/yusing/godoxy/internal/common"
+	gperr "github.com/yusing/goutils/errs"
+)
+
+// ldapAuth protects the route with HTTP basic authentication against the LDAP server.
+//
+// The upstream receives Remote-User and Remote-Groups instead of the credentials.
+type ldapAuth struct {
+	Realm         string   `json:"realm"`
+	AllowedUsers  []string `json:"allowed_users"`  // defaults to GODOXY_LDAP_ALLOWED_USERS
+	AllowedGroups []string `json:"allowed_groups"` // defaults to GODOXY_LDAP_ALLOWED_GROUPS
+}
+
+var LDAPAuth = NewMiddleware[ldapAuth]()
+
+// setup implements MiddlewareWithSetup.
+func (m *ldapAuth) setup() {
+	m.Realm = "Restricted"
+}
+
+// finalize implements MiddlewareFinalizer.
+func (m *ldapAuth) finalize() error {
+	if _, err := auth.GetLDAPClient(); err != nil {
+		return gperr.Wrap(err, "ldap_auth middleware")
+	}
+	if len(m.AllowedUsers) == 0 && len(m.AllowedGroups) == 0 {
+		m.AllowedUsers = common.LDAPAllowedUsers
+		m.AllowedGroups = common.LDAPAllowedGroups
+	}
+	if len(m.AllowedUsers) == 0 && len(m.AllowedGroups) == 0 {
+		return gperr.Wrap(auth.ErrLDAPNoAllowList, "ldap_auth middleware")
+	}
+	return nil
+}
+
+// before implements RequestModifier.
+func (m *ldapAuth) before(w http.ResponseWriter, r *http.Request) (proceed bool) {
+	// never trust the header from the client
+	r.Header.Del(auth.HeaderRemoteUser)
+	r.Header.Del(auth.HeaderRemoteGroups)
+
+	user, err := auth.LDAPBasicAuth(r)
+	switch {
+	case err == nil:
+	case errors.Is(err, auth.ErrTooManyLoginAttempts):
+		http.Error(w, "too many login attempts, try again later", http.StatusTooManyRequests)
+		return false
+	case errors.Is(err, auth.ErrMissingBasicAuth):
+		m.unauthorized(w)
+		return false
+	default:
+		LDAPAuth.LogWarn(r).Err(err).Msg("ldap basic auth failed")
+		m.unauthorized(w)
+		return false
+	}
+
+	if !user.IsAllowed(m.AllowedUsers, m.AllowedGroups) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+
+	r.Header.Del("Authorization")
+	r.Header.Set(auth.HeaderRemoteUser, user.Username)
+	r.Header.Set(auth.HeaderRemoteGroups, strings.Join(user.Groups, ","))
+	return true
+}
+
+func (m *ldapAuth) unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="`+m.Realm+`", charset="UTF-8"`)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
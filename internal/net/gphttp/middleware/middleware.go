@@ -46,6 +46,12 @@ type (
 	RequestModifier interface {
 		before(w http.ResponseWriter, r *http.Request) (proceed bool)
 	}
+	// RequestContextModifier is a RequestModifier that attaches values to the request context,
+	// the returned request is passed to the next handler.
+	RequestContextModifier interface {
+		RequestModifier
+		beforeWithContext(w http.ResponseWriter, r *http.Request) (*http.Request, bool)
+	}
 	ResponseModifier             interface{ modifyResponse(r *http.Response) error }
 	MiddlewareWithSetup          interface{ setup() }
 	MiddlewareFinalizer          interface{ finalize() }
@@ -160,9 +166,18 @@ func (m *Middleware) MarshalJSON() ([]byte, error) {
 	}, "", "  ")
 }
 
+// runBefore runs the request modifier and returns the request to pass to the next handler.
+func runBefore(exec RequestModifier, w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if exec, ok := exec.(RequestContextModifier); ok {
+		return exec.beforeWithContext(w, r)
+	}
+	return r, exec.before(w, r)
+}
+
 func (m *Middleware) ModifyRequest(next http.HandlerFunc, w http.ResponseWriter, r *http.Request) {
 	if exec, ok := m.impl.(RequestModifier); ok {
-		if proceed := exec.before(w, r); !proceed {
+		var proceed bool
+		if r, proceed = runBefore(exec, w, r); !proceed {
 			return
 		}
 	}
@@ -185,7 +200,8 @@ func (m *Middleware) ModifyResponse(resp *http.Response) error {
 
 func (m *Middleware) ServeHTTP(next http.HandlerFunc, w http.ResponseWriter, r *http.Request) {
 	if exec, ok := m.impl.(RequestModifier); ok {
-		if proceed := exec.before(w, r); !proceed {
+		var proceed bool
+		if r, proceed = runBefore(exec, w, r); !proceed {
 			return
 		}
 	}
@@ -252,7 +268,7 @@ func patchReverseProxy(rp *ReverseProxy, middlewares []*Middleware) {
 	if before, ok := mid.impl.(RequestModifier); ok {
 		next := rp.HandlerFunc
 		rp.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
-			if proceed := before.before(w, r); proceed {
+			if r, proceed := runBefore(before, w, r); proceed {
 				next(w, r)
 			}
 		}
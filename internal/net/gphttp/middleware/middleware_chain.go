@@ -29,15 +29,18 @@ func NewMiddlewareChain(name string, chain []*Middleware) *Middleware {
 
 // before implements RequestModifier.
 func (m *middlewareChain) before(w http.ResponseWriter, r *http.Request) (proceedNext bool) {
-	if len(m.befores) == 0 {
-		return true
-	}
+	_, proceedNext = m.beforeWithContext(w, r)
+	return proceedNext
+}
+
+// beforeWithContext implements RequestContextModifier.
+func (m *middlewareChain) beforeWithContext(w http.ResponseWriter, r *http.Request) (_ *http.Request, proceedNext bool) {
 	for _, b := range m.befores {
-		if proceedNext = b.before(w, r); !proceedNext {
-			return false
+		if r, proceedNext = runBefore(b, w, r); !proceedNext {
+			return r, false
 		}
 	}
-	return true
+	return r, true
 }
 
 // modifyResponse implements ResponseModifier.
@@ -19,6 +19,10 @@ var allMiddlewares = map[string]*Middleware{
 
 	"oidc":        OIDC,
 	"forwardauth": ForwardAuth,
+	"ldapauth":    LDAPAuth,
+	"jwtauth":     JWTAuth,
+	"apikey":      APIKey,
+	"signedurl":   SignedURL,
 
 	"request":        ModifyRequest,
 	"modifyrequest":  ModifyRequest,
@@ -26,6 +30,7 @@ var allMiddlewares = map[string]*Middleware{
 	"modifyresponse": ModifyResponse,
 	"setxforwarded":  SetXForwarded,
 	"hidexforwarded": HideXForwarded,
+	"cors":           CORS,
 
 	"modifyhtml": ModifyHTML,
 	"themed":     Themed,
@@ -39,7 +44,13 @@ var allMiddlewares = map[string]*Middleware{
 	"cidrwhitelist": CIDRWhiteList,
 	"ratelimit":     RateLimiter,
 
-	"hcaptcha": HCaptcha,
+	"hcaptcha":     HCaptcha,
+	"turnstile":    Turnstile,
+	"recaptcha":    ReCaptcha,
+	"powchallenge": PoWChallenge,
+
+	"waf":       WAF,
+	"botfilter": BotFilter,
 }
 
 var (
@@ -12,11 +12,14 @@ import (
 )
 
 type oidcMiddleware struct {
-	AllowedUsers  []string `json:"allowed_users"`
-	AllowedGroups []string `json:"allowed_groups"`
-	ClientID      string   `json:"client_id"`
-	ClientSecret  string   `json:"client_secret"`
-	Scopes        string   `json:"scopes"`
+	IssuerURL     string            `json:"issuer_url"` // defaults to the global issuer
+	AllowedUsers  []string          `json:"allowed_users"`
+	AllowedGroups []string          `json:"allowed_groups"`
+	ClientID      string            `json:"client_id"`
+	ClientSecret  string            `json:"client_secret"`
+	Scopes        string            `json:"scopes"`
+	ClaimHeaders  map[string]string `json:"claim_headers"` // claim name -> request header name
+	CookieDomain  string            `json:"cookie_domain"`
 
 	auth *auth.OIDCProvider
 
@@ -27,8 +30,17 @@ type oidcMiddleware struct {
 var OIDC = NewMiddleware[oidcMiddleware]()
 
 func (amw *oidcMiddleware) finalize() error {
-	if !auth.IsOIDCEnabled() {
-		return gperr.New("OIDC not enabled but OIDC middleware is used")
+	if amw.IssuerURL == "" {
+		if !auth.IsOIDCEnabled() {
+			return gperr.New("OIDC not enabled but OIDC middleware is used without issuer_url")
+		}
+		return nil
+	}
+	if amw.ClientID == "" || amw.ClientSecret == "" {
+		return gperr.New("client_id and client_secret are required when issuer_url is set")
+	}
+	if len(amw.AllowedUsers) == 0 && len(amw.AllowedGroups) == 0 {
+		return gperr.New("allowed_users or allowed_groups is required when issuer_url is set")
 	}
 	return nil
 }
@@ -49,31 +61,18 @@ func (amw *oidcMiddleware) initSlow() error {
 	}
 
 	defer func() {
-		amw.isInitialized = 1
+		// retry on next request if failed, e.g. issuer discovery was unreachable
+		if amw.auth != nil {
+			atomic.StoreInt32(&amw.isInitialized, 1)
+		}
 		amw.initMu.Unlock()
 	}()
 
-	// Always start with the global OIDC provider (for issuer discovery)
-	authProvider, err := auth.NewOIDCProviderFromEnv()
+	authProvider, err := amw.newProvider()
 	if err != nil {
 		return err
 	}
 
-	// Check if custom client credentials are provided
-	if amw.ClientID != "" && amw.ClientSecret != "" {
-		// Use custom client credentials
-		customProvider, err := auth.NewOIDCProviderWithCustomClient(
-			authProvider,
-			amw.ClientID,
-			amw.ClientSecret,
-		)
-		if err != nil {
-			return err
-		}
-		authProvider = customProvider
-	}
-	// If no custom credentials, authProvider remains the global one
-
 	// Always trigger login on unknown paths.
 	// This prevents falling back to the default login page, which applies bypass rules.
 	// Without this, redirecting to the global login page could circumvent the intended route restrictions.
@@ -89,28 +88,72 @@ func (amw *oidcMiddleware) initSlow() error {
 
 	// Apply custom scopes if provided
 	if amw.Scopes != "" {
-		authProvider.SetScopes(strings.Split(amw.Scopes, ","))
+		scopes := strings.Split(amw.Scopes, ",")
+		for i, scope := range scopes {
+			scopes[i] = strings.TrimSpace(scope)
+		}
+		authProvider.SetScopes(scopes)
+	}
+
+	if amw.CookieDomain != "" {
+		authProvider.SetCookieDomain(amw.CookieDomain)
 	}
 
 	amw.auth = authProvider
 	return nil
 }
 
+func (amw *oidcMiddleware) newProvider() (*auth.OIDCProvider, error) {
+	// Route level issuer, discovery and JWKS are shared with other routes of the same issuer
+	if amw.IssuerURL != "" {
+		return auth.NewOIDCProvider(amw.IssuerURL, amw.ClientID, amw.ClientSecret, amw.AllowedUsers, amw.AllowedGroups)
+	}
+
+	// Start with the global OIDC provider (for issuer discovery)
+	authProvider, err := auth.NewOIDCProviderFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if custom client credentials are provided
+	if amw.ClientID != "" && amw.ClientSecret != "" {
+		return auth.NewOIDCProviderWithCustomClient(
+			authProvider,
+			amw.ClientID,
+			amw.ClientSecret,
+		)
+	}
+	// If no custom credentials, authProvider remains the global one
+	return authProvider, nil
+}
+
 func (amw *oidcMiddleware) before(w http.ResponseWriter, r *http.Request) (proceed bool) {
+	_, proceed = amw.beforeWithContext(w, r)
+	return proceed
+}
+
+// beforeWithContext implements RequestContextModifier.
+func (amw *oidcMiddleware) beforeWithContext(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
 	if err := amw.init(); err != nil {
 		// no need to log here, main OIDC should've already failed and logged
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return false
+		return r, false
 	}
 
 	if r.URL.Path == auth.OIDCLogoutPath {
 		amw.auth.LogoutHandler(w, r)
-		return true
+		return r, true
+	}
+
+	// never trust claim headers from the client
+	for _, header := range amw.ClaimHeaders {
+		r.Header.Del(header)
 	}
 
-	err := amw.auth.CheckToken(r)
+	_, claims, err := amw.auth.CurrentUserClaims(r)
 	if err == nil {
-		return true
+		amw.setClaimHeaders(r, claims)
+		return auth.WithClaims(r, claims), true
 	}
 
 	switch {
@@ -119,5 +162,13 @@ func (amw *oidcMiddleware) before(w http.ResponseWriter, r *http.Request) (proce
 	default:
 		auth.WriteBlockPage(w, http.StatusForbidden, err.Error(), auth.OIDCLogoutPath)
 	}
-	return false
+	return r, false
+}
+
+func (amw *oidcMiddleware) setClaimHeaders(r *http.Request, claims map[string]any) {
+	for claim, header := range amw.ClaimHeaders {
+		if v, ok := claims[claim]; ok {
+			r.Header.Set(header, auth.FormatClaim(v))
+		}
+	}
 }
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	expect "github.com/yusing/goutils/testing"
@@ -33,3 +35,53 @@ func TestOIDCMiddlewarePerRouteConfig(t *testing.T) {
 		expect.Equal(t, middleware.Scopes, "")
 	})
 }
+
+func TestOIDCMiddlewareFinalize(t *testing.T) {
+	t.Run("route level issuer", func(t *testing.T) {
+		middleware := &oidcMiddleware{
+			IssuerURL:     "https://idp.example.com",
+			ClientID:      "client-id",
+			ClientSecret:  "client-secret",
+			AllowedGroups: []string{"admins"},
+		}
+		expect.NoError(t, middleware.finalize())
+	})
+
+	t.Run("route level issuer without client", func(t *testing.T) {
+		middleware := &oidcMiddleware{
+			IssuerURL:     "https://idp.example.com",
+			AllowedGroups: []string{"admins"},
+		}
+		expect.HasError(t, middleware.finalize())
+	})
+
+	t.Run("route level issuer without allowed users or groups", func(t *testing.T) {
+		middleware := &oidcMiddleware{
+			IssuerURL:    "https://idp.example.com",
+			ClientID:     "client-id",
+			ClientSecret: "client-secret",
+		}
+		expect.HasError(t, middleware.finalize())
+	})
+}
+
+func TestOIDCMiddlewareClaimHeaders(t *testing.T) {
+	middleware := &oidcMiddleware{
+		ClaimHeaders: map[string]string{
+			"email":          "X-Auth-Email",
+			"groups":         "X-Auth-Groups",
+			"email_verified": "X-Auth-Email-Verified",
+			"missing":        "X-Auth-Missing",
+		},
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	middleware.setClaimHeaders(r, map[string]any{
+		"email":          "user@example.com",
+		"groups":         []any{"admins", "users"},
+		"email_verified": true,
+	})
+	expect.Equal(t, r.Header.Get("X-Auth-Email"), "user@example.com")
+	expect.Equal(t, r.Header.Get("X-Auth-Groups"), "admins,users")
+	expect.Equal(t, r.Header.Get("X-Auth-Email-Verified"), "true")
+	expect.Equal(t, r.Header.Get("X-Auth-Missing"), "")
+}
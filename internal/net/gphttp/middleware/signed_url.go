@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/yusing/godoxy/internal/auth"
+)
+
+// signedURL only allows requests with a valid signed URL minted by the API,
+// e.g. for sharing files of a fileserver route with people without an account.
+//
+// Subresources of the shared page are allowed by a short-lived cookie set on the first use.
+//
+// To allow signed URLs in addition to login, add `signed_url` to the bypass rules
+// of the authentication middleware instead.
+type signedURL struct{}
+
+var SignedURL = NewMiddleware[signedURL]()
+
+// before implements RequestModifier.
+func (m *signedURL) before(w http.ResponseWriter, r *http.Request) (proceed bool) {
+	err := auth.CheckSignedURL(w, r)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, auth.ErrMissingSignature), errors.Is(err, auth.ErrInvalidSignature), errors.Is(err, auth.ErrSignedURLPathMismatch):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	case errors.Is(err, auth.ErrSignedURLDisabled):
+		SignedURL.LogError(r).Err(err).Msg("signed URL check failed")
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+	default: // expired, revoked or used up
+		http.Error(w, "link has expired", http.StatusGone)
+	}
+	return false
+}
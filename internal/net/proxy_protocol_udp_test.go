@@ -0,0 +1,181 @@
+package netutils
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/pires/go-proxyproto"
+	nettypes "github.com/yusing/godoxy/internal/net/types"
+	expect "github.com/yusing/goutils/testing"
+)
+
+func TestProxyProtocolPacketConn(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	expect.NoError(t, err)
+	defer server.Close()
+	pc := NewProxyProtocolPacketConn([]*nettypes.CIDR{mustParseCIDR(t, "127.0.0.1")})(server)
+
+	lb, err := net.ListenPacket("udp", "127.0.0.1:0")
+	expect.NoError(t, err)
+	defer lb.Close()
+
+	client := &net.UDPAddr{IP: net.ParseIP("203.0.113.7"), Port: 40000}
+	header := proxyproto.HeaderProxyFromAddrs(2, client, server.LocalAddr())
+	hdr, err := header.Format()
+	expect.NoError(t, err)
+
+	buf := make([]byte, 1500)
+	read := func() (string, net.Addr) {
+		t.Helper()
+		expect.NoError(t, pc.SetReadDeadline(time.Now().Add(time.Second)))
+		n, addr, err := pc.ReadFrom(buf)
+		expect.NoError(t, err)
+		return string(buf[:n]), addr
+	}
+
+	// with header, the client address is reported
+	_, err = lb.WriteTo(append(hdr, "hello"...), server.LocalAddr())
+	expect.NoError(t, err)
+	payload, addr := read()
+	expect.Equal(t, payload, "hello")
+	expect.Equal(t, addr.String(), client.String())
+
+	// replies to the client go through the load balancer
+	_, err = pc.WriteTo([]byte("world"), addr)
+	expect.NoError(t, err)
+	expect.NoError(t, lb.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := lb.ReadFrom(buf)
+	expect.NoError(t, err)
+	expect.Equal(t, string(buf[:n]), "world")
+
+	// without header, passed through unchanged
+	_, err = lb.WriteTo([]byte("plain"), server.LocalAddr())
+	expect.NoError(t, err)
+	payload, addr = read()
+	expect.Equal(t, payload, "plain")
+	expect.Equal(t, addr.String(), lb.LocalAddr().String())
+
+	// malformed header is dropped
+	_, err = lb.WriteTo(hdr[:len(hdr)-2], server.LocalAddr())
+	expect.NoError(t, err)
+	_, err = lb.WriteTo([]byte("next"), server.LocalAddr())
+	expect.NoError(t, err)
+	payload, _ = read()
+	expect.Equal(t, payload, "next")
+}
+
+func TestProxyProtocolPacketConnUntrusted(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	expect.NoError(t, err)
+	defer server.Close()
+	pc := NewProxyProtocolPacketConn([]*nettypes.CIDR{mustParseCIDR(t, "10.0.0.0/8")})(server)
+
+	sender, err := net.ListenPacket("udp", "127.0.0.1:0")
+	expect.NoError(t, err)
+	defer sender.Close()
+
+	hdr, err := proxyproto.HeaderProxyFromAddrs(2, &net.UDPAddr{IP: net.ParseIP("203.0.113.7"), Port: 40000}, server.LocalAddr()).Format()
+	expect.NoError(t, err)
+
+	// headers from untrusted senders are dropped
+	_, err = sender.WriteTo(append(hdr, "spoofed"...), server.LocalAddr())
+	expect.NoError(t, err)
+	_, err = sender.WriteTo([]byte("plain"), server.LocalAddr())
+	expect.NoError(t, err)
+
+	buf := make([]byte, 1500)
+	expect.NoError(t, pc.SetReadDeadline(time.Now().Add(time.Second)))
+	n, addr, err := pc.ReadFrom(buf)
+	expect.NoError(t, err)
+	expect.Equal(t, string(buf[:n]), "plain")
+	expect.Equal(t, addr.String(), sender.LocalAddr().String())
+}
+
+func TestProxyProtocolPacketConnMaxPeers(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	expect.NoError(t, err)
+	defer server.Close()
+	pc := NewProxyProtocolPacketConn([]*nettypes.CIDR{mustParseCIDR(t, "127.0.0.1")})(server).(*proxyProtocolPacketConn)
+
+	lb, err := net.ListenPacket("udp", "127.0.0.1:0")
+	expect.NoError(t, err)
+	defer lb.Close()
+
+	now := time.Now().Unix()
+	pc.lastPrune.Store(now)
+	for i := range proxyProtocolMaxPeers {
+		peer := &proxyProtocolPeer{addr: lb.LocalAddr()}
+		peer.lastSeen.Store(now)
+		pc.peers.Store(netip.AddrPortFrom(netip.MustParseAddr("198.51.100.1"), uint16(i)), peer)
+	}
+
+	send := func(ip string, payload string) {
+		t.Helper()
+		hdr, err := proxyproto.HeaderProxyFromAddrs(2, &net.UDPAddr{IP: net.ParseIP(ip), Port: 40000}, server.LocalAddr()).Format()
+		expect.NoError(t, err)
+		_, err = lb.WriteTo(append(hdr, payload...), server.LocalAddr())
+		expect.NoError(t, err)
+	}
+
+	// new peers are dropped when full
+	send("203.0.113.7", "dropped")
+	send("198.51.100.1", "known") // port 40000 is already known
+	buf := make([]byte, 1500)
+	expect.NoError(t, pc.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := pc.ReadFrom(buf)
+	expect.NoError(t, err)
+	expect.Equal(t, string(buf[:n]), "known")
+	expect.Equal(t, pc.peers.Size(), proxyProtocolMaxPeers)
+
+	// expired peers are not pruned before the prune interval
+	for _, peer := range pc.peers.Range {
+		peer.lastSeen.Store(now - int64(proxyProtocolPeerTTL/time.Second) - 1)
+	}
+	send("203.0.113.7", "dropped")
+	send("198.51.100.1", "known")
+	expect.NoError(t, pc.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err = pc.ReadFrom(buf)
+	expect.NoError(t, err)
+	expect.Equal(t, string(buf[:n]), "known")
+	expect.Equal(t, pc.peers.Size(), proxyProtocolMaxPeers)
+
+	// expired peers are pruned to make room on the next prune
+	pc.lastPrune.Store(now - int64(proxyProtocolPruneInterval/time.Second))
+	for _, peer := range pc.peers.Range {
+		peer.lastSeen.Store(now - int64(proxyProtocolPeerTTL/time.Second) - 1)
+	}
+	send("203.0.113.7", "accepted")
+	expect.NoError(t, pc.SetReadDeadline(time.Now().Add(time.Second)))
+	n, addr, err := pc.ReadFrom(buf)
+	expect.NoError(t, err)
+	expect.Equal(t, string(buf[:n]), "accepted")
+	expect.Equal(t, addr.String(), "203.0.113.7:40000")
+	expect.Equal(t, pc.peers.Size(), 1)
+}
+
+func mustParseCIDR(t *testing.T, s string) *nettypes.CIDR {
+	t.Helper()
+	cidr, err := nettypes.ParseCIDR(s)
+	expect.NoError(t, err)
+	return &cidr
+}
+
+func TestParseProxyProtocolV2(t *testing.T) {
+	src := &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 1234}
+	dst := &net.UDPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443}
+	hdr, err := proxyproto.HeaderProxyFromAddrs(2, src, dst).Format()
+	expect.NoError(t, err)
+
+	addr, hdrLen, ok := parseProxyProtocolV2(hdr)
+	expect.True(t, ok)
+	expect.Equal(t, hdrLen, len(hdr))
+	expect.Equal(t, addr.String(), src.String())
+
+	// TCP headers are not accepted on datagrams
+	tcpHdr, err := proxyproto.HeaderProxyFromAddrs(2, &net.TCPAddr{IP: src.IP, Port: 1}, &net.TCPAddr{IP: dst.IP, Port: 2}).Format()
+	expect.NoError(t, err)
+	_, _, ok = parseProxyProtocolV2(tcpHdr)
+	expect.False(t, ok)
+}
@@ -2,7 +2,9 @@ package nettypes
 
 import (
 	"context"
+	"errors"
 	"net"
+	"time"
 )
 
 type Stream interface {
@@ -12,3 +14,36 @@ type Stream interface {
 }
 
 type HookFunc func(ctx context.Context) error
+
+// WakeBufferedStream is a stream that buffers the data from clients while preDial is in progress,
+// and replays it to the destination afterwards.
+type WakeBufferedStream interface {
+	Stream
+	SetWakeBuffer(cfg *WakeBufferConfig)
+}
+
+type WakeBufferConfig struct {
+	// Wait for the first bytes from the client before calling preDial (tcp only).
+	WaitForData bool
+	// Max bytes buffered per connection, 0 for default.
+	Size int
+	// Buffered data older than this is dropped instead of replayed, 0 for default.
+	// With WaitForData, connections that send nothing within this duration are closed.
+	Timeout time.Duration
+}
+
+// ErrIgnored is returned by hooks to drop the connection or datagram silently.
+var ErrIgnored = errors.New("ignored")
+
+type remoteAddrKey struct{}
+
+// WithRemoteAddr returns a context carrying the client address for hooks.
+func WithRemoteAddr(ctx context.Context, addr net.Addr) context.Context {
+	return context.WithValue(ctx, remoteAddrKey{}, addr)
+}
+
+// RemoteAddr returns the client address passed to hooks, or nil if unknown.
+func RemoteAddr(ctx context.Context) net.Addr {
+	addr, _ := ctx.Value(remoteAddrKey{}).(net.Addr)
+	return addr
+}
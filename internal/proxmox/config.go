@@ -21,9 +21,21 @@ type Config struct {
 
 	NoTLSVerify bool `json:"no_tls_verify" yaml:"no_tls_verify,omitempty"`
 
+	// RouteDiscovery creates routes from the description and tags of the guests
+	RouteDiscovery *RouteDiscoveryConfig `json:"route_discovery,omitempty" yaml:"route_discovery,omitempty"`
+
 	client *Client
 }
 
+type RouteDiscoveryConfig struct {
+	// nodes to discover guests on, default: all nodes of the cluster
+	Nodes []string `json:"nodes,omitempty" yaml:"nodes,omitempty"`
+	// interval to poll the guests for changes, default: 30s
+	Interval time.Duration `json:"interval,omitempty" yaml:"interval,omitempty" validate:"omitempty,min=1s" swaggertype:"primitive,integer"`
+} // @name ProxmoxRouteDiscoveryConfig
+
+const DefaultRouteDiscoveryInterval = 30 * time.Second
+
 func (c *Config) Client() *Client {
 	if c.client == nil {
 		panic("proxmox client accessed before init")
@@ -31,6 +43,18 @@ func (c *Config) Client() *Client {
 	return c.client
 }
 
+// DiscoveryNodes returns the names of the nodes to discover routes on.
+func (c *Config) DiscoveryNodes() []string {
+	if c.RouteDiscovery != nil && len(c.RouteDiscovery.Nodes) > 0 {
+		return c.RouteDiscovery.Nodes
+	}
+	nodes := make([]string, 0, c.Client().NumNodes())
+	for _, node := range c.Client().Cluster.Nodes {
+		nodes = append(nodes, node.Name)
+	}
+	return nodes
+}
+
 func (c *Config) Init() gperr.Error {
 	var tr *http.Transport
 	if c.NoTLSVerify {
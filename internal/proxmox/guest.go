@@ -0,0 +1,92 @@
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/luthermonson/go-proxmox"
+)
+
+// Guest is an LXC container or a QEMU VM on a node.
+type Guest struct {
+	Node        string
+	VMID        int
+	Type        VMType
+	Name        string
+	Status      string
+	Tags        []string
+	Description string
+	IPs         []net.IP // static ip addresses from the config
+}
+
+type guestListEntry struct {
+	VMID     proxmox.StringOrUint64 `json:"vmid"`
+	Name     string                 `json:"name"`
+	Status   string                 `json:"status"`
+	Template proxmox.IsTemplate     `json:"template"`
+}
+
+type guestConfig struct {
+	Description string `json:"description"`
+	Tags        string `json:"tags"`
+	// lxc
+	Net0 string `json:"net0"`
+	Net1 string `json:"net1"`
+	Net2 string `json:"net2"`
+	// qemu cloud-init
+	IPConfig0 string `json:"ipconfig0"`
+	IPConfig1 string `json:"ipconfig1"`
+	IPConfig2 string `json:"ipconfig2"`
+}
+
+func (cfg *guestConfig) ips() (res []net.IP) {
+	for _, s := range []string{cfg.Net0, cfg.Net1, cfg.Net2, cfg.IPConfig0, cfg.IPConfig1, cfg.IPConfig2} {
+		res = append(res, getIPFromNet(s)...)
+	}
+	return res
+}
+
+// Guests returns the containers and vms on the node with their config, templates are excluded.
+func (n *Node) Guests(ctx context.Context) ([]*Guest, error) {
+	var guests []*Guest
+	for _, vmType := range []VMType{VMTypeLXC, VMTypeQEMU} {
+		var list []guestListEntry
+		if err := n.client.Get(ctx, fmt.Sprintf("/nodes/%s/%s", n.name, vmType), &list); err != nil {
+			return nil, err
+		}
+		for _, entry := range list {
+			if entry.Template {
+				continue
+			}
+			vmid := int(entry.VMID)
+			var cfg guestConfig
+			if err := n.client.Get(ctx, fmt.Sprintf("/nodes/%s/%s/%d/config", n.name, vmType, vmid), &cfg); err != nil {
+				return nil, fmt.Errorf("%s: %w", vmType.GuestName(vmid), err)
+			}
+			guests = append(guests, &Guest{
+				Node:        n.name,
+				VMID:        vmid,
+				Type:        vmType,
+				Name:        entry.Name,
+				Status:      entry.Status,
+				Tags:        parseTags(cfg.Tags),
+				Description: cfg.Description,
+				IPs:         cfg.ips(),
+			})
+		}
+	}
+	return guests, nil
+}
+
+func (g *Guest) IsRunning() bool {
+	return g.Status == string(LXCStatusRunning)
+}
+
+// parseTags splits the tags of a guest, which are separated by semicolons in the config.
+func parseTags(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ';' || r == ',' || r == ' '
+	})
+}
@@ -0,0 +1,58 @@
+package proxmox
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luthermonson/go-proxmox"
+	expect "github.com/yusing/goutils/testing"
+)
+
+func TestGuests(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api2/json/nodes/pve/lxc", func(w http.ResponseWriter, r *http.Request) {
+		// vmid of lxc is a string
+		writeData(w, []map[string]any{{"vmid": "100", "name": "app", "status": "running", "tags": "godoxy;prod"}})
+	})
+	mux.HandleFunc("GET /api2/json/nodes/pve/qemu", func(w http.ResponseWriter, r *http.Request) {
+		writeData(w, []map[string]any{
+			{"vmid": 101, "name": "vm", "status": "stopped"},
+			{"vmid": 9000, "name": "template", "status": "stopped", "template": 1},
+		})
+	})
+	mux.HandleFunc("GET /api2/json/nodes/pve/lxc/100/config", func(w http.ResponseWriter, r *http.Request) {
+		writeData(w, map[string]any{
+			"tags":        "godoxy;prod",
+			"description": "```godoxy\napp:\n  port: 8080\n```\n",
+			"net0":        "name=eth0,bridge=vmbr0,ip=10.0.6.100/16,type=veth",
+		})
+	})
+	mux.HandleFunc("GET /api2/json/nodes/pve/qemu/101/config", func(w http.ResponseWriter, r *http.Request) {
+		writeData(w, map[string]any{"tags": "godoxy.port.8080", "ipconfig0": "ip=10.0.6.101/16,gw=10.0.0.1"})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	node := &Node{name: "pve", id: "node/pve", client: proxmox.NewClient(srv.URL + "/api2/json")}
+
+	guests, err := node.Guests(t.Context())
+	expect.NoError(t, err)
+	expect.Equal(t, len(guests), 2)
+
+	lxc := guests[0]
+	expect.Equal(t, lxc.VMID, 100)
+	expect.Equal(t, lxc.Type, VMTypeLXC)
+	expect.Equal(t, lxc.Node, "pve")
+	expect.True(t, lxc.IsRunning())
+	expect.Equal(t, lxc.Tags, []string{"godoxy", "prod"})
+	expect.Equal(t, lxc.Description, "```godoxy\napp:\n  port: 8080\n```\n")
+	expect.Equal(t, lxc.IPs, []net.IP{net.ParseIP("10.0.6.100")})
+
+	vm := guests[1]
+	expect.Equal(t, vm.VMID, 101)
+	expect.Equal(t, vm.Type, VMTypeQEMU)
+	expect.False(t, vm.IsRunning())
+	expect.Equal(t, vm.Tags, []string{"godoxy.port.8080"})
+	expect.Equal(t, vm.IPs, []net.IP{net.ParseIP("10.0.6.101")})
+}
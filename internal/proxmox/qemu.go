@@ -0,0 +1,207 @@
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/luthermonson/go-proxmox"
+)
+
+type (
+	QEMUAction string
+	QEMUStatus string
+
+	qemuStatusCurrent struct {
+		Name      string     `json:"name"`
+		Status    QEMUStatus `json:"status"`
+		QMPStatus string     `json:"qmpstatus"`
+	}
+)
+
+const (
+	QEMUStart     QEMUAction = "start"
+	QEMUShutdown  QEMUAction = "shutdown" // ACPI shutdown, requires support from the guest
+	QEMUStop      QEMUAction = "stop"     // immediate stop, like pulling the power cord
+	QEMUSuspend   QEMUAction = "suspend"  // suspend to RAM
+	QEMUHibernate QEMUAction = "hibernate"
+	QEMUResume    QEMUAction = "resume"
+)
+
+const (
+	QEMUStatusRunning QEMUStatus = "running"
+	QEMUStatusStopped QEMUStatus = "stopped" // also when hibernated, resumed from disk on start
+	QEMUStatusPaused  QEMUStatus = "paused"  // suspended to RAM
+)
+
+const qemuGuestAgentRetryInterval = time.Second
+
+// expectedStatus returns the status of the vm after the action is done.
+func (action QEMUAction) expectedStatus() QEMUStatus {
+	switch action {
+	case QEMUStart, QEMUResume:
+		return QEMUStatusRunning
+	case QEMUSuspend:
+		return QEMUStatusPaused
+	default:
+		return QEMUStatusStopped
+	}
+}
+
+func (n *Node) QEMUAction(ctx context.Context, vmid int, action QEMUAction) error {
+	path := fmt.Sprintf("/nodes/%s/qemu/%d/status/%s", n.name, vmid, action)
+	var params map[string]any
+	if action == QEMUHibernate {
+		path = fmt.Sprintf("/nodes/%s/qemu/%d/status/%s", n.name, vmid, QEMUSuspend)
+		params = map[string]any{"todisk": 1}
+	}
+
+	var upid proxmox.UPID
+	if err := n.client.Post(ctx, path, params, &upid); err != nil {
+		return err
+	}
+
+	task := proxmox.NewTask(upid, n.client)
+	checkTicker := time.NewTicker(proxmoxTaskCheckInterval)
+	defer checkTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-checkTicker.C:
+			if err := task.Ping(ctx); err != nil {
+				return err
+			}
+			if task.IsFailed {
+				return fmt.Errorf("qemu %s failed: %s", action, task.ExitStatus)
+			}
+			if task.IsCompleted {
+				status, err := n.QEMUStatus(ctx, vmid)
+				if err != nil {
+					return err
+				}
+				if status == action.expectedStatus() {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+func (n *Node) qemuStatusCurrent(ctx context.Context, vmid int) (*qemuStatusCurrent, error) {
+	var status qemuStatusCurrent
+	if err := n.client.Get(ctx, fmt.Sprintf("/nodes/%s/qemu/%d/status/current", n.name, vmid), &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (n *Node) QEMUName(ctx context.Context, vmid int) (string, error) {
+	status, err := n.qemuStatusCurrent(ctx, vmid)
+	if err != nil {
+		return "", err
+	}
+	return status.Name, nil
+}
+
+func (n *Node) QEMUStatus(ctx context.Context, vmid int) (QEMUStatus, error) {
+	status, err := n.qemuStatusCurrent(ctx, vmid)
+	if err != nil {
+		return "", err
+	}
+	if status.Status == QEMUStatusRunning {
+		switch status.QMPStatus {
+		case "paused", "suspended":
+			return QEMUStatusPaused, nil
+		}
+	}
+	return status.Status, nil
+}
+
+func (n *Node) QEMUIsRunning(ctx context.Context, vmid int) (bool, error) {
+	status, err := n.QEMUStatus(ctx, vmid)
+	return status == QEMUStatusRunning, err
+}
+
+func (n *Node) QEMUSetShutdownTimeout(ctx context.Context, vmid int, timeout time.Duration) error {
+	return n.client.Put(ctx, fmt.Sprintf("/nodes/%s/qemu/%d/config", n.name, vmid), map[string]interface{}{
+		"startup": fmt.Sprintf("down=%.0f", timeout.Seconds()),
+	}, nil)
+}
+
+// QEMUGetIPs returns the ip addresses of the vm
+// it first tries to get the ip addresses from the cloud-init config
+// if that fails, it gets the ip addresses from the QEMU guest agent,
+// waiting for the guest agent to start until ctx is done.
+func (n *Node) QEMUGetIPs(ctx context.Context, vmid int) ([]net.IP, error) {
+	ips, err := n.QEMUGetIPsFromConfig(ctx, vmid)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) > 0 {
+		return ips, nil
+	}
+	for {
+		ips, err = n.QEMUGetIPsFromGuestAgent(ctx, vmid)
+		if err == nil {
+			return ips, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("qemu guest agent: %w", err)
+		case <-time.After(qemuGuestAgentRetryInterval):
+		}
+	}
+}
+
+// QEMUGetIPsFromConfig returns the ip addresses of the vm from the cloud-init config
+func (n *Node) QEMUGetIPsFromConfig(ctx context.Context, vmid int) (res []net.IP, err error) {
+	type Config struct {
+		IPConfig0 string `json:"ipconfig0"`
+		IPConfig1 string `json:"ipconfig1"`
+		IPConfig2 string `json:"ipconfig2"`
+	}
+	var cfg Config
+	if err := n.client.Get(ctx, fmt.Sprintf("/nodes/%s/qemu/%d/config", n.name, vmid), &cfg); err != nil {
+		return nil, err
+	}
+
+	res = append(res, getIPFromNet(cfg.IPConfig0)...)
+	res = append(res, getIPFromNet(cfg.IPConfig1)...)
+	res = append(res, getIPFromNet(cfg.IPConfig2)...)
+	return res, nil
+}
+
+// QEMUGetIPsFromGuestAgent returns the ip addresses of the vm reported by the QEMU guest agent
+// it fails if the vm is stopped or the guest agent is not running
+func (n *Node) QEMUGetIPsFromGuestAgent(ctx context.Context, vmid int) ([]net.IP, error) {
+	type Interface struct {
+		Name        string `json:"name"`
+		IPAddresses []struct {
+			IPAddress string `json:"ip-address"`
+		} `json:"ip-addresses"`
+	}
+	var res struct {
+		Result []Interface `json:"result"`
+	}
+	if err := n.client.Get(ctx, fmt.Sprintf("/nodes/%s/qemu/%d/agent/network-get-interfaces", n.name, vmid), &res); err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0)
+	for _, iface := range res.Result {
+		if iface.Name == "lo" ||
+			strings.HasPrefix(iface.Name, "br-") ||
+			strings.HasPrefix(iface.Name, "veth") ||
+			strings.HasPrefix(iface.Name, "docker") {
+			continue
+		}
+		for _, addr := range iface.IPAddresses {
+			if ip := privateIPOrNil(net.ParseIP(addr.IPAddress)); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips, nil
+}
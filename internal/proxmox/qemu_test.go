@@ -0,0 +1,170 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luthermonson/go-proxmox"
+	expect "github.com/yusing/goutils/testing"
+)
+
+// fakeQEMU is a fake proxmox API serving a single qemu vm on node "pve".
+type fakeQEMU struct {
+	mu        sync.Mutex
+	status    QEMUStatus
+	qmpStatus string
+	ipconfig0 string
+	agentUp   bool
+	actions   []string
+	startup   string
+}
+
+const fakeUPID = "UPID:pve:00001234:00005678:6512ABCD:qmstart:100:root@pam:"
+
+func (vm *fakeQEMU) do(action string, todisk bool) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.actions = append(vm.actions, action)
+	switch action {
+	case "start", "resume":
+		vm.status, vm.qmpStatus, vm.agentUp = QEMUStatusRunning, "running", true
+	case "shutdown", "stop":
+		vm.status, vm.qmpStatus, vm.agentUp = QEMUStatusStopped, "stopped", false
+	case "suspend":
+		if todisk {
+			vm.status, vm.qmpStatus, vm.agentUp = QEMUStatusStopped, "stopped", false
+		} else {
+			vm.qmpStatus = "paused"
+		}
+	}
+}
+
+func writeData(w http.ResponseWriter, data any) {
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+func newFakeQEMUNode(t *testing.T, vm *fakeQEMU) *Node {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api2/json/nodes/pve/qemu/100/status/current", func(w http.ResponseWriter, r *http.Request) {
+		vm.mu.Lock()
+		defer vm.mu.Unlock()
+		writeData(w, map[string]any{"name": "test-vm", "status": vm.status, "qmpstatus": vm.qmpStatus})
+	})
+	mux.HandleFunc("POST /api2/json/nodes/pve/qemu/100/status/{action}", func(w http.ResponseWriter, r *http.Request) {
+		var params struct {
+			ToDisk int `json:"todisk"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&params)
+		vm.do(r.PathValue("action"), params.ToDisk == 1)
+		writeData(w, fakeUPID)
+	})
+	mux.HandleFunc("GET /api2/json/nodes/pve/tasks/{upid}/status", func(w http.ResponseWriter, r *http.Request) {
+		writeData(w, map[string]any{"upid": r.PathValue("upid"), "status": "stopped", "exitstatus": "OK"})
+	})
+	mux.HandleFunc("GET /api2/json/nodes/pve/qemu/100/config", func(w http.ResponseWriter, r *http.Request) {
+		vm.mu.Lock()
+		defer vm.mu.Unlock()
+		writeData(w, map[string]any{"name": "test-vm", "ipconfig0": vm.ipconfig0})
+	})
+	mux.HandleFunc("PUT /api2/json/nodes/pve/qemu/100/config", func(w http.ResponseWriter, r *http.Request) {
+		var params struct {
+			Startup string `json:"startup"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&params)
+		vm.mu.Lock()
+		vm.startup = params.Startup
+		vm.mu.Unlock()
+		writeData(w, nil)
+	})
+	mux.HandleFunc("GET /api2/json/nodes/pve/qemu/100/agent/network-get-interfaces", func(w http.ResponseWriter, r *http.Request) {
+		vm.mu.Lock()
+		defer vm.mu.Unlock()
+		if !vm.agentUp {
+			http.Error(w, "QEMU guest agent is not running", http.StatusInternalServerError)
+			return
+		}
+		writeData(w, map[string]any{"result": []map[string]any{
+			{"name": "lo", "ip-addresses": []map[string]any{{"ip-address": "127.0.0.1"}}},
+			{"name": "docker0", "ip-addresses": []map[string]any{{"ip-address": "172.17.0.1"}}},
+			{"name": "eth0", "ip-addresses": []map[string]any{{"ip-address": "10.0.6.100"}, {"ip-address": "8.8.8.8"}}},
+		}})
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return &Node{name: "pve", id: "node/pve", client: proxmox.NewClient(srv.URL + "/api2/json")}
+}
+
+func TestQEMUActions(t *testing.T) {
+	vm := &fakeQEMU{status: QEMUStatusStopped, qmpStatus: "stopped"}
+	node := newFakeQEMUNode(t, vm)
+	ctx := t.Context()
+
+	name, err := node.VMName(ctx, VMTypeQEMU, 100)
+	expect.NoError(t, err)
+	expect.Equal(t, name, "test-vm")
+
+	expect.NoError(t, node.VMStart(ctx, VMTypeQEMU, 100))
+	running, err := node.VMIsRunning(ctx, VMTypeQEMU, 100)
+	expect.NoError(t, err)
+	expect.True(t, running)
+
+	expect.NoError(t, node.QEMUAction(ctx, 100, QEMUSuspend))
+	status, err := node.QEMUStatus(ctx, 100)
+	expect.NoError(t, err)
+	expect.Equal(t, status, QEMUStatusPaused)
+
+	expect.NoError(t, node.QEMUAction(ctx, 100, QEMUResume))
+	expect.NoError(t, node.QEMUAction(ctx, 100, QEMUHibernate))
+	status, err = node.QEMUStatus(ctx, 100)
+	expect.NoError(t, err)
+	expect.Equal(t, status, QEMUStatusStopped)
+
+	expect.NoError(t, node.QEMUAction(ctx, 100, QEMUStart))
+	expect.NoError(t, node.QEMUAction(ctx, 100, QEMUShutdown))
+	expect.Equal(t, vm.actions, []string{"start", "suspend", "resume", "suspend", "start", "shutdown"})
+
+	expect.NoError(t, node.VMSetShutdownTimeout(ctx, VMTypeQEMU, 100, 30*time.Second))
+	expect.Equal(t, vm.startup, "down=30")
+}
+
+func TestQEMUGetIPs(t *testing.T) {
+	t.Run("cloud-init config", func(t *testing.T) {
+		vm := &fakeQEMU{status: QEMUStatusStopped, ipconfig0: "ip=10.0.6.68/16,gw=10.0.0.1"}
+		node := newFakeQEMUNode(t, vm)
+
+		ips, err := node.VMGetIPs(t.Context(), VMTypeQEMU, 100)
+		expect.NoError(t, err)
+		expect.Equal(t, ips, []net.IP{net.ParseIP("10.0.6.68")})
+	})
+
+	t.Run("guest agent", func(t *testing.T) {
+		vm := &fakeQEMU{status: QEMUStatusStopped, ipconfig0: "ip=dhcp"}
+		node := newFakeQEMUNode(t, vm)
+
+		// guest agent comes up after the vm is started
+		time.AfterFunc(100*time.Millisecond, func() { vm.do("start", false) })
+
+		ips, err := node.VMGetIPs(t.Context(), VMTypeQEMU, 100)
+		expect.NoError(t, err)
+		expect.Equal(t, len(ips), 1)
+		expect.Equal(t, ips[0].String(), "10.0.6.100")
+	})
+
+	t.Run("guest agent not running", func(t *testing.T) {
+		vm := &fakeQEMU{status: QEMUStatusStopped}
+		node := newFakeQEMUNode(t, vm)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+		defer cancel()
+		_, err := node.VMGetIPs(ctx, VMTypeQEMU, 100)
+		expect.ErrorContains(t, err, "qemu guest agent")
+	})
+}
@@ -0,0 +1,63 @@
+package proxmox
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+)
+
+// VMType is the type of a proxmox guest, either an LXC container or a QEMU VM.
+type VMType string
+
+const (
+	VMTypeLXC  VMType = "lxc"
+	VMTypeQEMU VMType = "qemu"
+)
+
+func (t VMType) IsQEMU() bool {
+	return t == VMTypeQEMU
+}
+
+// GuestName returns the display name of the guest when its name is unknown.
+func (t VMType) GuestName(vmid int) string {
+	if t.IsQEMU() {
+		return "vm-" + strconv.Itoa(vmid)
+	}
+	return "lxc-" + strconv.Itoa(vmid)
+}
+
+func (n *Node) VMName(ctx context.Context, vmType VMType, vmid int) (string, error) {
+	if vmType.IsQEMU() {
+		return n.QEMUName(ctx, vmid)
+	}
+	return n.LXCName(ctx, vmid)
+}
+
+func (n *Node) VMIsRunning(ctx context.Context, vmType VMType, vmid int) (bool, error) {
+	if vmType.IsQEMU() {
+		return n.QEMUIsRunning(ctx, vmid)
+	}
+	return n.LXCIsRunning(ctx, vmid)
+}
+
+func (n *Node) VMStart(ctx context.Context, vmType VMType, vmid int) error {
+	if vmType.IsQEMU() {
+		return n.QEMUAction(ctx, vmid, QEMUStart)
+	}
+	return n.LXCAction(ctx, vmid, LXCStart)
+}
+
+func (n *Node) VMGetIPs(ctx context.Context, vmType VMType, vmid int) ([]net.IP, error) {
+	if vmType.IsQEMU() {
+		return n.QEMUGetIPs(ctx, vmid)
+	}
+	return n.LXCGetIPs(ctx, vmid)
+}
+
+func (n *Node) VMSetShutdownTimeout(ctx context.Context, vmType VMType, vmid int, timeout time.Duration) error {
+	if vmType.IsQEMU() {
+		return n.QEMUSetShutdownTimeout(ctx, vmid, timeout)
+	}
+	return n.LXCSetShutdownTimeout(ctx, vmid, timeout)
+}
@@ -1,9 +1,16 @@
 package provider
 
 import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
 	"github.com/rs/zerolog"
 	"github.com/yusing/godoxy/agent/pkg/agent"
+	"github.com/yusing/godoxy/agent/pkg/discovery"
 	"github.com/yusing/godoxy/internal/route"
+	"github.com/yusing/godoxy/internal/serialization"
 	"github.com/yusing/godoxy/internal/watcher"
 	gperr "github.com/yusing/goutils/errs"
 )
@@ -11,14 +18,19 @@ import (
 type AgentProvider struct {
 	*agent.AgentConfig
 	docker ProviderImpl
+
+	// last route list pushed by the agent, nil if discovery is not enabled on the agent
+	update atomic.Pointer[discovery.Update]
 }
 
+const agentFetchRoutesTimeout = 5 * time.Second
+
 func (p *AgentProvider) ShortName() string {
 	return p.AgentConfig.Name
 }
 
 func (p *AgentProvider) NewWatcher() watcher.Watcher {
-	return p.docker.NewWatcher()
+	return &agentWatcher{p}
 }
 
 func (p *AgentProvider) IsExplicitOnly() bool {
@@ -26,7 +38,68 @@ func (p *AgentProvider) IsExplicitOnly() bool {
 }
 
 func (p *AgentProvider) loadRoutesImpl() (route.Routes, gperr.Error) {
-	return p.docker.loadRoutesImpl()
+	update := p.update.Load()
+	if update == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), agentFetchRoutesTimeout)
+		defer cancel()
+
+		var err error
+		update, err = discovery.Fetch(ctx, p.AgentConfig)
+		if errors.Is(err, discovery.ErrDisabled) {
+			return p.docker.loadRoutesImpl()
+		}
+		if err != nil {
+			return nil, gperr.Wrap(err)
+		}
+	}
+	return p.routesFromUpdate(update)
+}
+
+// routesFromUpdate creates routes from the route list discovered by the agent.
+func (p *AgentProvider) routesFromUpdate(update *discovery.Update) (route.Routes, gperr.Error) {
+	errs := gperr.NewBuilder("")
+	if update.Error != "" {
+		errs.Adds(update.Error)
+	}
+
+	routes := make(route.Routes, len(update.Routes))
+	for alias, r := range update.Routes {
+		cfg, err := filterRemoteRouteConfig(r.Config)
+		if err != nil {
+			errs.Add(err.Subject(alias))
+			if cfg == nil {
+				continue
+			}
+		}
+
+		if r.Container == nil {
+			// routes from route files on the agent
+			cfg["agent"] = p.Addr
+			fileRoute := &route.Route{Alias: alias}
+			if err := serialization.MapUnmarshalValidate(cfg, fileRoute); err != nil {
+				errs.Add(err.Subject(alias).Subject(r.File))
+				continue
+			}
+			routes[alias] = fileRoute
+			continue
+		}
+
+		container := r.Container
+		if !container.IsExplicit && p.IsExplicitOnly() {
+			continue
+		}
+		container.Agent = p.AgentConfig
+		container.DockerHost = p.FakeDockerHost()
+		if idw := container.IdlewatcherConfig; idw != nil && idw.Docker != nil {
+			idw.Docker.DockerHost = container.DockerHost
+		}
+		containerRoute, err := newContainerRoute(alias, container, cfg)
+		if err != nil {
+			errs.Add(err.Subject(container.ContainerName))
+		}
+		routes[alias] = containerRoute
+	}
+	return routes, errs.Error()
 }
 
 func (p *AgentProvider) Logger() *zerolog.Logger {
@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+	"github.com/yusing/godoxy/agent/pkg/agent"
+	"github.com/yusing/godoxy/agent/pkg/discovery"
+	D "github.com/yusing/godoxy/internal/docker"
+	expect "github.com/yusing/goutils/testing"
+)
+
+func TestAgentDiscoveredRoutes(t *testing.T) {
+	// discovered on the agent
+	cont := D.FromDocker(&container.Summary{
+		ID:     "test",
+		Names:  []string{"/app"},
+		State:  "running",
+		Labels: map[string]string{"proxy.app.port": "8080", "com.docker.compose.project": "apps"},
+		Mounts: []container.MountPoint{{Source: "/srv/app", Destination: "/data"}},
+	}, client.DefaultDockerHost)
+	configs, err := D.RouteLabels(cont)
+	expect.NoError(t, err)
+
+	update := &discovery.Update{Routes: map[string]*discovery.Route{
+		"app":  {Container: cont, Config: configs["app"]},
+		"file": {File: "routes.yml", Config: map[string]any{"host": "10.0.0.2", "port": "8081"}},
+	}}
+	data, jsonErr := json.Marshal(update)
+	expect.NoError(t, jsonErr)
+
+	// received by the main instance
+	update = new(discovery.Update)
+	expect.NoError(t, json.Unmarshal(data, update))
+
+	testAgent, ok := agent.GetAgent("test-agent")
+	expect.True(t, ok)
+	p := NewAgentProvider(testAgent).ProviderImpl.(*AgentProvider)
+
+	routes, err := p.routesFromUpdate(update)
+	expect.NoError(t, err)
+	expect.Equal(t, len(routes), 2)
+
+	app := routes["app"]
+	expect.Equal(t, app.Container.Agent, testAgent)
+	expect.Equal(t, app.Container.DockerHost, testAgent.FakeDockerHost())
+	expect.Equal(t, app.Container.Mounts.Get("/srv/app"), "/data")
+	expect.Equal(t, D.DockerComposeProject(app.Container), "apps")
+	expect.Equal(t, app.Port.Proxy, 8080)
+
+	file := routes["file"]
+	expect.True(t, file.Container == nil)
+	expect.Equal(t, file.GetAgent(), testAgent)
+	expect.Equal(t, file.Port.Proxy, 8081)
+}
+
+func TestAgentRouteFieldsFiltered(t *testing.T) {
+	update := &discovery.Update{Routes: map[string]*discovery.Route{
+		"file": {File: "routes.yml", Config: map[string]any{
+			"host":       "10.0.0.2",
+			"port":       "8081",
+			"agent":      "other-agent",
+			"rule_file":  "/etc/passwd",
+			"access_log": map[string]any{"path": "/etc/cron.d/godoxy"},
+			"middlewares": map[string]any{
+				"api_key":        map[string]any{"keys_file": "/etc/shadow"},
+				"jwt_auth":       map[string]any{"public_keys": []any{"file:///etc/ssl/private/key.pem"}},
+				"themed":         map[string]any{"css": "file:///etc/passwd"},
+				"waf":            map[string]any{"log": map[string]any{"path": "/etc/cron.d/godoxy"}},
+				"cidr_whitelist": map[string]any{"allow": []any{"10.0.0.0/8"}},
+			},
+		}},
+		"static": {File: "routes.yml", Config: map[string]any{"scheme": "fileserver", "root": "/"}},
+	}}
+
+	testAgent, ok := agent.GetAgent("test-agent")
+	expect.True(t, ok)
+	p := NewAgentProvider(testAgent).ProviderImpl.(*AgentProvider)
+
+	routes, err := p.routesFromUpdate(update)
+	expect.ErrorIs(t, ErrRemoteRouteField, err)
+	expect.ErrorIs(t, ErrRemoteRouteMiddleware, err)
+	expect.ErrorIs(t, ErrRemoteRouteFileServer, err)
+	expect.Equal(t, len(routes), 1)
+
+	file := routes["file"]
+	expect.Equal(t, file.GetAgent(), testAgent)
+	expect.Equal(t, file.RuleFile, "")
+	expect.True(t, file.AccessLog == nil)
+	expect.Equal(t, len(file.Middlewares), 1)
+	_, ok = file.Middlewares["cidr_whitelist"]
+	expect.True(t, ok)
+	expect.Equal(t, file.Port.Proxy, 8081)
+}
+
+func TestAgentRouteCommandIdlewatcherRejected(t *testing.T) {
+	update := &discovery.Update{Routes: map[string]*discovery.Route{
+		"app": {File: "routes.yml", Config: map[string]any{
+			"host": "10.0.0.2",
+			"port": "8081",
+			"idlewatcher": map[string]any{
+				"idle_timeout": "1h",
+				"exec":         map[string]any{"start": "touch /tmp/pwned", "stop": "true"},
+			},
+		}},
+	}}
+
+	testAgent, ok := agent.GetAgent("test-agent")
+	expect.True(t, ok)
+	p := NewAgentProvider(testAgent)
+	p.ProviderImpl.(*AgentProvider).update.Store(update)
+
+	routes, err := p.loadRoutes()
+	expect.ErrorIs(t, ErrCommandIdlewatcher, err)
+	expect.Equal(t, len(routes), 0)
+}
@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/yusing/godoxy/agent/pkg/discovery"
+	"github.com/yusing/godoxy/internal/watcher"
+	"github.com/yusing/godoxy/internal/watcher/events"
+	gperr "github.com/yusing/goutils/errs"
+)
+
+// agentWatcher receives the routes pushed by the agent,
+// or watches the docker socket of the agent if route discovery is not enabled on it.
+type agentWatcher struct {
+	p *AgentProvider
+}
+
+const agentWatcherRetryInterval = 3 * time.Second
+
+var agentReloadTrigger = watcher.Event{
+	Type:            events.EventTypeDocker,
+	Action:          events.ActionForceReload,
+	ActorAttributes: map[string]string{},
+}
+
+func (w *agentWatcher) Events(ctx context.Context) (<-chan watcher.Event, <-chan gperr.Error) {
+	eventCh := make(chan watcher.Event)
+	errCh := make(chan gperr.Error)
+
+	go func() {
+		defer close(eventCh)
+		defer close(errCh)
+
+		reportErr := true
+		for {
+			updates, err := discovery.Watch(ctx, w.p.AgentConfig)
+			switch {
+			case errors.Is(err, discovery.ErrDisabled):
+				w.p.update.Store(nil)
+				w.forward(ctx, w.p.docker.NewWatcher(), eventCh, errCh)
+				return
+			case err != nil:
+				// report once until reconnected
+				if reportErr {
+					reportErr = false
+					select {
+					case errCh <- gperr.Wrap(err, "agent route discovery"):
+					case <-ctx.Done():
+						return
+					}
+				}
+			default:
+				reportErr = true
+				w.receive(ctx, updates, eventCh)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(agentWatcherRetryInterval):
+			}
+		}
+	}()
+
+	return eventCh, errCh
+}
+
+// receive caches the route lists pushed by the agent and sends their events until disconnected.
+//
+// Routes are kept when disconnected, the agent is unreachable meanwhile anyway.
+func (w *agentWatcher) receive(ctx context.Context, updates <-chan *discovery.Update, eventCh chan<- watcher.Event) {
+	first := true
+	for update := range updates {
+		w.p.update.Store(update)
+
+		evs := update.Events
+		// the route list may have changed while disconnected
+		if first || len(evs) == 0 {
+			evs = []watcher.Event{agentReloadTrigger}
+		}
+		first = false
+
+		for _, ev := range evs {
+			select {
+			case eventCh <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (w *agentWatcher) forward(ctx context.Context, wt watcher.Watcher, eventCh chan<- watcher.Event, errCh chan<- gperr.Error) {
+	srcEventCh, srcErrCh := wt.Events(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-srcEventCh:
+			if !ok {
+				return
+			}
+			select {
+			case eventCh <- ev:
+			case <-ctx.Done():
+				return
+			}
+		case err, ok := <-srcErrCh:
+			if !ok {
+				return
+			}
+			select {
+			case errCh <- err:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -2,11 +2,8 @@ package provider
 
 import (
 	"context"
-	"fmt"
-	"strconv"
 	"time"
 
-	"github.com/goccy/go-yaml"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/yusing/godoxy/internal/docker"
@@ -22,12 +19,7 @@ type DockerProvider struct {
 	l                zerolog.Logger
 }
 
-const (
-	aliasRefPrefix    = '#'
-	aliasRefPrefixAlt = '$'
-)
-
-var ErrAliasRefIndexOutOfRange = gperr.New("index out of range")
+var ErrAliasRefIndexOutOfRange = docker.ErrAliasRefIndexOutOfRange
 
 func DockerProviderImpl(name, dockerHost string) ProviderImpl {
 	return &DockerProvider{
@@ -116,79 +108,34 @@ func (p *DockerProvider) routesFromContainerLabels(container *types.Container) (
 		return make(route.Routes, 0), nil
 	}
 
-	routes := make(route.Routes, len(container.Aliases))
-
-	// init entries map for all aliases
-	for _, a := range container.Aliases {
-		routes[a] = &route.Route{
-			Alias: a,
-			Metadata: route.Metadata{
-				Container: container,
-			},
-		}
-	}
-
 	errs := gperr.NewBuilder("label errors")
 
-	m, err := docker.ParseLabels(container.Labels, container.Aliases...)
+	configs, err := docker.RouteLabels(container)
 	errs.Add(err)
 
-	for alias, entryMapAny := range m {
-		if len(alias) == 0 {
-			errs.Adds("empty alias")
-			continue
-		}
-
-		entryMap, ok := entryMapAny.(types.LabelMap)
-		if !ok {
-			// try to deserialize to map
-			entryMap = make(types.LabelMap)
-			yamlStr, ok := entryMapAny.(string)
-			if !ok {
-				// should not happen
-				panic(fmt.Errorf("invalid entry map type %T", entryMapAny))
-			}
-			if err := yaml.Unmarshal([]byte(yamlStr), &entryMap); err != nil {
-				errs.Add(gperr.Wrap(err).Subject(alias))
-				continue
-			}
-		}
-
-		// check if it is an alias reference
-		switch alias[0] {
-		case aliasRefPrefix, aliasRefPrefixAlt:
-			index, err := strconv.Atoi(alias[1:])
-			if err != nil {
-				errs.Add(err)
-				break
-			}
-			if index < 1 || index > len(container.Aliases) {
-				errs.Add(ErrAliasRefIndexOutOfRange.Subject(strconv.Itoa(index)))
-				break
-			}
-			alias = container.Aliases[index-1]
-		}
-
-		// init entry if not exist
-		r, ok := routes[alias]
-		if !ok {
-			r = &route.Route{
-				Alias: alias,
-				Metadata: route.Metadata{
-					Container: container,
-				},
-			}
-			routes[alias] = r
-		}
-
-		// deserialize map into entry object
-		err := serialization.MapUnmarshalValidate(entryMap, r)
-		if err != nil {
-			errs.Add(err.Subject(alias))
-		} else {
-			routes[alias] = r
-		}
+	routes := make(route.Routes, len(configs))
+	for alias, cfg := range configs {
+		r, err := newContainerRoute(alias, container, cfg)
+		errs.Add(err)
+		routes[alias] = r
 	}
-
 	return routes, errs.Error()
 }
+
+// newContainerRoute creates the route of a container alias from its label config, which may be nil.
+func newContainerRoute(alias string, container *types.Container, cfg types.LabelMap) (*route.Route, gperr.Error) {
+	r := &route.Route{
+		Alias: alias,
+		Metadata: route.Metadata{
+			Container: container,
+		},
+	}
+	if cfg == nil {
+		return r, nil
+	}
+	// deserialize map into entry object
+	if err := serialization.MapUnmarshalValidate(cfg, r); err != nil {
+		return r, err.Subject(alias)
+	}
+	return r, nil
+}
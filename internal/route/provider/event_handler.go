@@ -1,6 +1,8 @@
 package provider
 
 import (
+	"strconv"
+
 	"github.com/yusing/godoxy/internal/route"
 	provider "github.com/yusing/godoxy/internal/route/provider/types"
 	"github.com/yusing/godoxy/internal/watcher"
@@ -69,8 +71,15 @@ func (handler *EventHandler) matchAny(events []watcher.Event, route *route.Route
 func (handler *EventHandler) match(event watcher.Event, route *route.Route) bool {
 	switch handler.provider.GetType() {
 	case provider.ProviderTypeDocker, provider.ProviderTypeAgent:
+		// routes from route files on the agent
+		if route.Container == nil {
+			return event.Type == eventsPkg.EventTypeFile
+		}
 		return route.Container.ContainerID == event.ActorID ||
 			route.Container.ContainerName == event.ActorName
+	case provider.ProviderTypeProxmox:
+		idw := route.Idlewatcher
+		return idw != nil && idw.Proxmox != nil && strconv.Itoa(idw.Proxmox.VMID) == event.ActorID
 	case provider.ProviderTypeFile:
 		return true
 	}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"maps"
+	"net/url"
 	"path"
 	"sync"
 	"time"
@@ -13,6 +14,7 @@ import (
 	"github.com/yusing/godoxy/agent/pkg/agent"
 	"github.com/yusing/godoxy/internal/common"
 	"github.com/yusing/godoxy/internal/docker"
+	"github.com/yusing/godoxy/internal/proxmox"
 	"github.com/yusing/godoxy/internal/route"
 	provider "github.com/yusing/godoxy/internal/route/provider/types"
 	"github.com/yusing/godoxy/internal/types"
@@ -47,7 +49,11 @@ const (
 	providerEventFlushInterval = 300 * time.Millisecond
 )
 
-var ErrEmptyProviderName = errors.New("empty provider name")
+var (
+	ErrEmptyProviderName = errors.New("empty provider name")
+	// exec and systemd run commands on the host, only route files are trusted to configure them.
+	ErrCommandIdlewatcher = gperr.New("exec and systemd idlewatcher are only allowed in route files")
+)
 
 var _ types.RouteProvider = (*Provider)(nil)
 
@@ -91,6 +97,24 @@ func NewAgentProvider(cfg *agent.AgentConfig) *Provider {
 	return p
 }
 
+func NewProxmoxProvider(cfg *proxmox.Config) *Provider {
+	name := cfg.Client().Name()
+	if name == "" { // standalone node
+		if u, err := url.Parse(cfg.URL); err == nil {
+			name = u.Hostname()
+		}
+	}
+	interval := proxmox.DefaultRouteDiscoveryInterval
+	if cfg.RouteDiscovery.Interval > 0 {
+		interval = cfg.RouteDiscovery.Interval
+	}
+
+	p := newProvider(provider.ProviderTypeProxmox)
+	p.ProviderImpl = ProxmoxProviderImpl(name, cfg.DiscoveryNodes(), interval)
+	p.watcher = p.NewWatcher()
+	return p
+}
+
 func (p *Provider) GetType() provider.Type {
 	return p.t
 }
@@ -178,7 +202,7 @@ func (p *Provider) FindService(project, service string) (types.Route, bool) {
 	routes := p.lockCloneRoutes()
 	for _, r := range routes {
 		cont := r.ContainerInfo()
-		if docker.DockerComposeProject(cont) != project {
+		if cont == nil || docker.DockerComposeProject(cont) != project {
 			continue
 		}
 		if docker.DockerComposeService(cont) == service {
@@ -213,6 +237,11 @@ func (p *Provider) loadRoutes() (routes route.Routes, err gperr.Error) {
 			delete(routes, alias)
 			continue
 		}
+		if idw := r.Idlewatcher; idw != nil && (idw.Exec != nil || idw.Systemd != nil) && p.GetType() != provider.ProviderTypeFile {
+			errs.Add(ErrCommandIdlewatcher.Subject(alias))
+			delete(routes, alias)
+			continue
+		}
 		r.FinalizeHomepageConfig()
 	}
 	return routes, errs.Error()
@@ -0,0 +1,300 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/yusing/godoxy/internal/proxmox"
+	"github.com/yusing/godoxy/internal/route"
+	"github.com/yusing/godoxy/internal/serialization"
+	"github.com/yusing/godoxy/internal/types"
+	"github.com/yusing/godoxy/internal/watcher"
+	gperr "github.com/yusing/goutils/errs"
+)
+
+type ProxmoxProvider struct {
+	name     string
+	nodes    []string
+	interval time.Duration
+	l        zerolog.Logger
+
+	// last guest list polled by the watcher
+	guests atomic.Pointer[[]*proxmox.Guest]
+}
+
+const (
+	proxmoxFetchGuestsTimeout = 10 * time.Second
+
+	// tag namespace of the route config in guest tags, e.g. godoxy.port=8080
+	proxmoxTagNamespace = "godoxy"
+	// info string of the fenced code block with the route config in guest descriptions
+	proxmoxDescriptionBlock = "```godoxy"
+)
+
+var ErrInvalidProxmoxTag = gperr.New("invalid tag, expect godoxy.<key>=<value>")
+
+func ProxmoxProviderImpl(name string, nodes []string, interval time.Duration) ProviderImpl {
+	return &ProxmoxProvider{
+		name:     name,
+		nodes:    nodes,
+		interval: interval,
+		l:        log.With().Str("type", "proxmox").Str("name", name).Logger(),
+	}
+}
+
+func (p *ProxmoxProvider) String() string {
+	return "proxmox@" + p.name
+}
+
+func (p *ProxmoxProvider) ShortName() string {
+	return p.name
+}
+
+func (p *ProxmoxProvider) IsExplicitOnly() bool {
+	return false
+}
+
+func (p *ProxmoxProvider) Logger() *zerolog.Logger {
+	return &p.l
+}
+
+func (p *ProxmoxProvider) NewWatcher() watcher.Watcher {
+	return &proxmoxWatcher{p}
+}
+
+func (p *ProxmoxProvider) loadRoutesImpl() (route.Routes, gperr.Error) {
+	errs := gperr.NewBuilder("")
+
+	guests := p.guests.Load()
+	if guests == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), proxmoxFetchGuestsTimeout)
+		defer cancel()
+
+		list, err := p.fetchGuests(ctx, nil)
+		if err != nil && len(list) == 0 {
+			return nil, err
+		}
+		errs.Add(err)
+		guests = &list
+		p.guests.Store(guests)
+	}
+
+	routes := make(route.Routes)
+	owners := make(map[string]*proxmox.Guest)
+	for _, g := range *guests {
+		newRoutes, err := routesFromGuest(g)
+		if err != nil {
+			errs.Add(err.Subject(guestName(g)))
+		}
+		for alias, r := range newRoutes {
+			if conflict, ok := owners[alias]; ok {
+				errs.Add(gperr.Multiline().
+					Addf("route with alias %s already exists", alias).
+					Addf("guest %s", guestName(g)).
+					Addf("conflicting guest %s", guestName(conflict)))
+				continue
+			}
+			owners[alias] = g
+			routes[alias] = r
+		}
+	}
+	return routes, errs.Error()
+}
+
+// fetchGuests lists the guests on the nodes.
+//
+// Guests of nodes that fail to respond are taken from prev,
+// so their routes are kept while the node is temporarily unreachable.
+func (p *ProxmoxProvider) fetchGuests(ctx context.Context, prev []*proxmox.Guest) ([]*proxmox.Guest, gperr.Error) {
+	errs := gperr.NewBuilder("failed to list proxmox guests")
+	var guests []*proxmox.Guest
+	for _, name := range p.nodes {
+		node, ok := proxmox.Nodes.Get(name)
+		if !ok {
+			errs.Add(gperr.Errorf("proxmox node %s not found in pool", name))
+			continue
+		}
+		nodeGuests, err := node.Guests(ctx)
+		if err != nil {
+			errs.Add(gperr.PrependSubject(name, err))
+			for _, g := range prev {
+				if g.Node == name {
+					guests = append(guests, g)
+				}
+			}
+			continue
+		}
+		guests = append(guests, nodeGuests...)
+	}
+	return guests, errs.Error()
+}
+
+func guestName(g *proxmox.Guest) string {
+	if g.Name != "" {
+		return g.Name
+	}
+	return g.Type.GuestName(g.VMID)
+}
+
+// routesFromGuest creates the routes of a guest from its description and tags.
+//
+// Guests without route config, and stopped guests without idlewatcher are skipped.
+func routesFromGuest(g *proxmox.Guest) (route.Routes, gperr.Error) {
+	errs := gperr.NewBuilder("route config errors")
+
+	configs, err := routeConfigsFromDescription(g.Description)
+	errs.Add(err)
+	if configs == nil {
+		configs = make(map[string]types.LabelMap)
+	}
+
+	alias := guestName(g)
+	tagCfg := configs[alias]
+	if tagCfg == nil {
+		tagCfg = make(types.LabelMap)
+	}
+	found, err := applyTags(tagCfg, g.Tags)
+	errs.Add(err)
+	if found {
+		configs[alias] = tagCfg
+	}
+
+	routes := make(route.Routes, len(configs))
+	for alias, cfg := range configs {
+		cfg, err := filterRemoteRouteConfig(cfg)
+		if err != nil {
+			errs.Add(err.Subject(alias))
+			if cfg == nil {
+				continue
+			}
+		}
+		if err := setGuestIdlewatcher(cfg, g); err != nil {
+			errs.Add(err.Subject(alias))
+			continue
+		}
+		// use the static ip without waking up the stopped guest,
+		// running guests are checked for reachable ip addresses on validation
+		if _, ok := cfg["host"]; !ok && !g.IsRunning() && len(g.IPs) > 0 {
+			cfg["host"] = g.IPs[0].String()
+		}
+
+		r := &route.Route{Alias: alias}
+		if err := serialization.MapUnmarshalValidate(cfg, r); err != nil {
+			errs.Add(err.Subject(alias))
+			continue
+		}
+		if !g.IsRunning() && r.Idlewatcher.IdleTimeout <= 0 {
+			continue
+		}
+		routes[alias] = r
+	}
+	return routes, errs.Error()
+}
+
+// routeConfigsFromDescription parses the route config in the fenced code block of a guest description, e.g.
+//
+//	```godoxy
+//	app:
+//	  port: 8080
+//	```
+func routeConfigsFromDescription(desc string) (map[string]types.LabelMap, gperr.Error) {
+	desc = strings.ReplaceAll(desc, "\r\n", "\n")
+	_, block, ok := strings.Cut(desc, proxmoxDescriptionBlock+"\n")
+	if !ok {
+		return nil, nil
+	}
+	block, _, ok = strings.Cut(block, "```")
+	if !ok {
+		return nil, gperr.New("unterminated godoxy block in description")
+	}
+
+	var configs map[string]types.LabelMap
+	if err := yaml.Unmarshal([]byte(block), &configs); err != nil {
+		return nil, gperr.Wrap(err, "invalid godoxy block in description")
+	}
+	return configs, nil
+}
+
+// applyTags sets the route config of the godoxy tags of a guest, e.g.
+//
+//   - godoxy: route with the default config
+//   - godoxy.port=8080
+//   - godoxy.healthcheck.path=/health
+//   - godoxy.port.8080: the last part is the value, as proxmox does not allow "=" in tags
+//
+// It returns whether any godoxy tag is found.
+func applyTags(cfg types.LabelMap, tags []string) (found bool, _ gperr.Error) {
+	errs := gperr.NewBuilder("tag errors")
+	for _, tag := range tags {
+		key, ok := strings.CutPrefix(tag, proxmoxTagNamespace)
+		if !ok {
+			continue
+		}
+		if key == "" {
+			found = true
+			continue
+		}
+		key, ok = strings.CutPrefix(key, ".")
+		if !ok {
+			continue
+		}
+		found = true
+
+		key, value, ok := strings.Cut(key, "=")
+		if !ok {
+			i := strings.LastIndexByte(key, '.')
+			if i == -1 {
+				errs.Add(ErrInvalidProxmoxTag.Subject(tag))
+				continue
+			}
+			key, value = key[:i], key[i+1:]
+		}
+		if err := setNested(cfg, strings.Split(key, "."), value); err != nil {
+			errs.Add(err.Subject(tag))
+		}
+	}
+	return found, errs.Error()
+}
+
+func setNested(m types.LabelMap, keys []string, value string) gperr.Error {
+	for _, k := range keys[:len(keys)-1] {
+		switch v := m[k].(type) {
+		case nil:
+			next := make(types.LabelMap)
+			m[k] = next
+			m = next
+		case types.LabelMap:
+			m = v
+		default:
+			return gperr.Errorf("expect mapping, got %T", v)
+		}
+	}
+	m[keys[len(keys)-1]] = value
+	return nil
+}
+
+// setGuestIdlewatcher sets the guest as the idlewatcher provider of the route,
+// it only takes effect when idle_timeout is set in the route config.
+func setGuestIdlewatcher(cfg types.LabelMap, g *proxmox.Guest) gperr.Error {
+	var idw types.LabelMap
+	switch v := cfg["idlewatcher"].(type) {
+	case nil:
+		idw = make(types.LabelMap)
+		cfg["idlewatcher"] = idw
+	case types.LabelMap:
+		idw = v
+	default:
+		return gperr.Errorf("idlewatcher: expect mapping, got %T", v)
+	}
+	idw["proxmox"] = types.LabelMap{
+		"node":    g.Node,
+		"vmid":    g.VMID,
+		"vm_type": string(g.Type),
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package provider
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/yusing/godoxy/internal/proxmox"
+	"github.com/yusing/godoxy/internal/types"
+	"github.com/yusing/godoxy/internal/watcher/events"
+	expect "github.com/yusing/goutils/testing"
+)
+
+func TestProxmoxRoutesFromGuest(t *testing.T) {
+	g := &proxmox.Guest{
+		Node:   "pve",
+		VMID:   100,
+		Type:   proxmox.VMTypeLXC,
+		Name:   "app",
+		Status: "running",
+		Tags:   []string{"prod", "godoxy.host=10.0.6.100", "godoxy.port=8080", "godoxy.healthcheck.path=/health", "godoxy.scheme.https"},
+		Description: "# App\r\n" +
+			"```godoxy\r\n" +
+			"app-admin:\r\n" +
+			"  host: 10.0.6.100\r\n" +
+			"  port: 9000\r\n" +
+			"  idlewatcher:\r\n" +
+			"    idle_timeout: 1h\r\n" +
+			"```\r\n",
+	}
+
+	routes, err := routesFromGuest(g)
+	expect.NoError(t, err)
+	expect.Equal(t, len(routes), 2)
+
+	app := routes["app"]
+	expect.Equal(t, app.Host, "10.0.6.100")
+	expect.Equal(t, app.Port.Proxy, 8080)
+	expect.Equal(t, app.Scheme.String(), "https")
+	expect.Equal(t, app.HealthCheck.Path, "/health")
+	expect.Equal(t, *app.Idlewatcher.Proxmox, types.ProxmoxConfig{Node: "pve", VMID: 100, VMType: proxmox.VMTypeLXC})
+	expect.Equal(t, app.Idlewatcher.IdleTimeout, 0)
+
+	admin := routes["app-admin"]
+	expect.Equal(t, admin.Port.Proxy, 9000)
+	expect.Equal(t, admin.Idlewatcher.IdleTimeout, time.Hour)
+	expect.Equal(t, admin.Idlewatcher.Proxmox.VMID, 100)
+}
+
+func TestProxmoxRoutesFromStoppedGuest(t *testing.T) {
+	g := &proxmox.Guest{
+		Node:   "pve",
+		VMID:   101,
+		Type:   proxmox.VMTypeQEMU,
+		Name:   "vm",
+		Status: "stopped",
+		Tags:   []string{"godoxy.port=8080"},
+		IPs:    []net.IP{net.ParseIP("10.0.6.101")},
+	}
+
+	// not routed without idlewatcher
+	routes, err := routesFromGuest(g)
+	expect.NoError(t, err)
+	expect.Equal(t, len(routes), 0)
+
+	g.Tags = append(g.Tags, "godoxy.idlewatcher.idle_timeout=30m")
+	routes, err = routesFromGuest(g)
+	expect.NoError(t, err)
+	expect.Equal(t, len(routes), 1)
+	// static ip is used without waking up the guest
+	expect.Equal(t, routes["vm"].Host, "10.0.6.101")
+	expect.Equal(t, routes["vm"].Idlewatcher.Proxmox.VMType, proxmox.VMTypeQEMU)
+}
+
+func TestProxmoxRoutesFromGuestErrors(t *testing.T) {
+	routes, err := routesFromGuest(&proxmox.Guest{Name: "none", Status: "running", Tags: []string{"prod", "godoxyfoo"}})
+	expect.NoError(t, err)
+	expect.Equal(t, len(routes), 0)
+
+	_, err = routesFromGuest(&proxmox.Guest{Name: "bad", Status: "running", Tags: []string{"godoxy.port"}})
+	expect.ErrorIs(t, ErrInvalidProxmoxTag, err)
+
+	_, err = routesFromGuest(&proxmox.Guest{Name: "bad", Status: "running", Description: "```godoxy\napp:\n  port: 80\n"})
+	expect.ErrorContains(t, err, "unterminated")
+
+	routes, err = routesFromGuest(&proxmox.Guest{
+		Node:   "pve",
+		VMID:   100,
+		Type:   proxmox.VMTypeLXC,
+		Name:   "app",
+		Status: "running",
+		Tags:   []string{"godoxy.host=10.0.6.100", "godoxy.port=8080", "godoxy.rule_file=/etc/passwd", "godoxy.middlewares.api_key.keys_file=/etc/shadow"},
+		Description: "```godoxy\n" +
+			"app-admin:\n" +
+			"  host: 10.0.6.100\n" +
+			"  port: 9000\n" +
+			"  access_log:\n" +
+			"    path: /etc/cron.d/godoxy\n" +
+			"app-files:\n" +
+			"  scheme: fileserver\n" +
+			"  root: /\n" +
+			"```\n",
+	})
+	expect.ErrorIs(t, ErrRemoteRouteField, err)
+	expect.ErrorIs(t, ErrRemoteRouteMiddleware, err)
+	expect.ErrorIs(t, ErrRemoteRouteFileServer, err)
+	expect.Equal(t, len(routes), 2)
+	expect.Equal(t, routes["app"].RuleFile, "")
+	expect.Equal(t, len(routes["app"].Middlewares), 0)
+	expect.True(t, routes["app-admin"].AccessLog == nil)
+}
+
+func TestProxmoxGuestEvents(t *testing.T) {
+	prev := []*proxmox.Guest{
+		{VMID: 100, Name: "app", Status: "running"},
+		{VMID: 101, Name: "db", Status: "running"},
+		{VMID: 102, Name: "old", Status: "running"},
+		{VMID: 103, Name: "web", Status: "running", Tags: []string{"godoxy"}},
+	}
+	cur := []*proxmox.Guest{
+		{VMID: 100, Name: "app", Status: "running"},
+		{VMID: 101, Name: "db", Status: "stopped"},
+		{VMID: 103, Name: "web", Status: "running", Tags: []string{"godoxy.port=8080"}},
+		{VMID: 104, Name: "new", Status: "running"},
+	}
+
+	evs := guestEvents(prev, cur)
+	expect.Equal(t, len(evs), 4)
+	actions := make(map[string]events.Action, len(evs))
+	for _, ev := range evs {
+		expect.Equal(t, ev.Type, events.EventTypeProxmox)
+		actions[ev.ActorID] = ev.Action
+	}
+	expect.Equal(t, actions, map[string]events.Action{
+		"101": events.ActionContainerStop,
+		"102": events.ActionContainerDestroy,
+		"103": events.ActionContainerCreate,
+		"104": events.ActionContainerCreate,
+	})
+}
@@ -0,0 +1,114 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/yusing/godoxy/internal/proxmox"
+	"github.com/yusing/godoxy/internal/watcher"
+	"github.com/yusing/godoxy/internal/watcher/events"
+	gperr "github.com/yusing/goutils/errs"
+)
+
+// proxmoxWatcher polls the guests on the nodes and sends an event for each changed guest.
+type proxmoxWatcher struct {
+	p *ProxmoxProvider
+}
+
+func (w *proxmoxWatcher) Events(ctx context.Context) (<-chan watcher.Event, <-chan gperr.Error) {
+	eventCh := make(chan watcher.Event)
+	errCh := make(chan gperr.Error)
+
+	go func() {
+		defer close(eventCh)
+		defer close(errCh)
+
+		ticker := time.NewTicker(w.p.interval)
+		defer ticker.Stop()
+
+		reportErr := true
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			var prev []*proxmox.Guest
+			if guests := w.p.guests.Load(); guests != nil {
+				prev = *guests
+			}
+
+			fetchCtx, cancel := context.WithTimeout(ctx, proxmoxFetchGuestsTimeout)
+			guests, err := w.p.fetchGuests(fetchCtx, prev)
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+			w.p.guests.Store(&guests)
+
+			if err == nil {
+				reportErr = true
+			} else if reportErr {
+				// report once until recovered
+				reportErr = false
+				select {
+				case errCh <- err:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			for _, ev := range guestEvents(prev, guests) {
+				select {
+				case eventCh <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return eventCh, errCh
+}
+
+// guestEvents returns the events of guests that are created, removed or changed between the polls.
+func guestEvents(prev, cur []*proxmox.Guest) []watcher.Event {
+	prevByID := make(map[int]*proxmox.Guest, len(prev))
+	for _, g := range prev {
+		prevByID[g.VMID] = g
+	}
+
+	var evs []watcher.Event
+	for _, g := range cur {
+		old, ok := prevByID[g.VMID]
+		delete(prevByID, g.VMID)
+		switch {
+		case !ok:
+			evs = append(evs, guestEvent(g, events.ActionContainerCreate))
+		case reflect.DeepEqual(old, g):
+		case old.Status != g.Status && g.IsRunning():
+			evs = append(evs, guestEvent(g, events.ActionContainerStart))
+		case old.Status != g.Status:
+			evs = append(evs, guestEvent(g, events.ActionContainerStop))
+		default:
+			// config changed, like a recreated container
+			evs = append(evs, guestEvent(g, events.ActionContainerCreate))
+		}
+	}
+	for _, g := range prevByID {
+		evs = append(evs, guestEvent(g, events.ActionContainerDestroy))
+	}
+	return evs
+}
+
+func guestEvent(g *proxmox.Guest, action events.Action) watcher.Event {
+	return watcher.Event{
+		Type:      events.EventTypeProxmox,
+		ActorID:   strconv.Itoa(g.VMID),
+		ActorName: guestName(g),
+		Action:    action,
+	}
+}
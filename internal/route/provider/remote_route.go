@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"strings"
+
+	"github.com/yusing/godoxy/internal/types"
+	gperr "github.com/yusing/goutils/errs"
+	strutils "github.com/yusing/goutils/strings"
+)
+
+// remoteRouteFields are the route fields allowed in route configs from other hosts,
+// i.e. pushed by agents and in proxmox guest descriptions and tags.
+//
+// Fields that refer to files or commands on this host (root, rule_file, access_log,
+// ssl certificates, rules with serve/exec commands) or that change where the route
+// is served (agent, entrypoints) are dropped.
+var remoteRouteFields = map[string]struct{}{
+	"alias":                   {},
+	"scheme":                  {},
+	"host":                    {},
+	"port":                    {},
+	"path_patterns":           {},
+	"healthcheck":             {},
+	"load_balance":            {},
+	"middlewares":             {},
+	"homepage":                {},
+	"idlewatcher":             {},
+	"no_tls_verify":           {},
+	"response_header_timeout": {},
+	"disable_compression":     {},
+	"ssl_server_name":         {},
+	"ssl_protocols":           {},
+	"alpn":                    {},
+}
+
+// remoteRouteMiddlewares are the middlewares allowed in route configs from other hosts,
+// keyed by lowercase name without underscores like middleware.Get.
+//
+// Middlewares with options that read or write files on this host (api_key, jwt_auth,
+// themed, waf) are dropped. Middlewares defined in compose files on this host
+// (name@file) are allowed.
+var remoteRouteMiddlewares = map[string]struct{}{
+	"redirecthttp":     {},
+	"oidc":             {},
+	"forwardauth":      {},
+	"ldapauth":         {},
+	"signedurl":        {},
+	"request":          {},
+	"modifyrequest":    {},
+	"response":         {},
+	"modifyresponse":   {},
+	"setxforwarded":    {},
+	"hidexforwarded":   {},
+	"cors":             {},
+	"modifyhtml":       {},
+	"errorpage":        {},
+	"customerrorpage":  {},
+	"realip":           {},
+	"cloudflarerealip": {},
+	"cidrwhitelist":    {},
+	"ratelimit":        {},
+	"hcaptcha":         {},
+	"turnstile":        {},
+	"recaptcha":        {},
+	"powchallenge":     {},
+	"botfilter":        {},
+}
+
+var (
+	ErrRemoteRouteField      = gperr.New("field is not allowed on agent and proxmox routes")
+	ErrRemoteRouteMiddleware = gperr.New("middleware is not allowed on agent and proxmox routes")
+	ErrRemoteRouteFileServer = gperr.New("fileserver routes are not allowed on agent and proxmox routes")
+)
+
+// filterRemoteRouteConfig returns a copy of cfg with only the fields in remoteRouteFields
+// and the middlewares in remoteRouteMiddlewares, along with an error listing the dropped ones.
+func filterRemoteRouteConfig(cfg types.LabelMap) (types.LabelMap, gperr.Error) {
+	if scheme, ok := cfg["scheme"].(string); ok && scheme == "fileserver" {
+		return nil, ErrRemoteRouteFileServer
+	}
+	errs := gperr.NewBuilder("")
+	filtered := make(types.LabelMap, len(cfg))
+	for k, v := range cfg {
+		if _, ok := remoteRouteFields[k]; !ok {
+			errs.Add(ErrRemoteRouteField.Subject(k))
+			continue
+		}
+		if k == "middlewares" {
+			if middlewares, ok := v.(map[string]any); ok {
+				v = filterRemoteMiddlewares(middlewares, &errs)
+			}
+		}
+		filtered[k] = v
+	}
+	return filtered, errs.Error()
+}
+
+func filterRemoteMiddlewares(middlewares map[string]any, errs *gperr.Builder) map[string]any {
+	filtered := make(map[string]any, len(middlewares))
+	for name, opts := range middlewares {
+		if _, ok := remoteRouteMiddlewares[strutils.ToLowerNoSnake(name)]; !ok && !strings.HasSuffix(name, "@file") {
+			errs.Add(ErrRemoteRouteMiddleware.Subject(name))
+			continue
+		}
+		filtered[name] = opts
+	}
+	return filtered
+}
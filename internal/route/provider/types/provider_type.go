@@ -3,7 +3,8 @@ package provider
 type Type string //	@name	ProviderType
 
 const (
-	ProviderTypeDocker Type = "docker"
-	ProviderTypeFile   Type = "file"
-	ProviderTypeAgent  Type = "agent"
+	ProviderTypeDocker  Type = "docker"
+	ProviderTypeFile    Type = "file"
+	ProviderTypeAgent   Type = "agent"
+	ProviderTypeProxmox Type = "proxmox"
 )
@@ -13,6 +13,8 @@ import (
 	"github.com/yusing/godoxy/internal/net/gphttp/middleware"
 	nettypes "github.com/yusing/godoxy/internal/net/types"
 	"github.com/yusing/godoxy/internal/route/routes"
+	"github.com/yusing/godoxy/internal/route/rules"
+	route "github.com/yusing/godoxy/internal/route/types"
 	"github.com/yusing/godoxy/internal/types"
 	"github.com/yusing/godoxy/internal/watcher/health/monitor"
 	gperr "github.com/yusing/goutils/errs"
@@ -25,6 +27,7 @@ type ReveseProxyRoute struct {
 	*Route
 
 	loadBalancer *loadbalancer.LoadBalancer
+	lbOverride   *rules.RuleOn
 	handler      http.Handler
 	rp           *reverseproxy.ReverseProxy
 }
@@ -74,7 +77,7 @@ func NewReverseProxyRoute(base *Route) (*ReveseProxyRoute, gperr.Error) {
 			HTTPConfig: httpConfig,
 		}
 		setHeaderFunc := cfg.SetAgentProxyConfigHeaders
-		if !a.Version.IsOlderThan(version.New(0, 18, 6)) {
+		if !a.GetVersion().IsOlderThan(version.New(0, 18, 6)) {
 			setHeaderFunc = cfg.SetAgentProxyConfigHeadersLegacy
 		}
 
@@ -89,6 +92,13 @@ func NewReverseProxyRoute(base *Route) (*ReveseProxyRoute, gperr.Error) {
 		Route: base,
 		rp:    rp,
 	}
+
+	if base.UseLoadBalance() && base.LoadBalance.Override != "" {
+		r.lbOverride = new(rules.RuleOn)
+		if err := r.lbOverride.Parse(base.LoadBalance.Override); err != nil {
+			return nil, gperr.Wrap(err).Subject("load_balance.override")
+		}
+	}
 	return r, nil
 }
 
@@ -179,8 +189,10 @@ func (r *ReveseProxyRoute) addToLoadBalancer(parent task.Parent) {
 		_ = lb.Start(parent) // always return nil
 		linked = &ReveseProxyRoute{
 			Route: &Route{
-				Alias:    cfg.Link,
-				Homepage: r.Homepage,
+				Alias:       cfg.Link,
+				Homepage:    r.Homepage,
+				Entrypoints: r.Entrypoints,
+				HTTPConfig:  route.HTTPConfig{ALPN: r.ALPN},
 			},
 			loadBalancer: lb,
 			handler:      lb,
@@ -194,7 +206,7 @@ func (r *ReveseProxyRoute) addToLoadBalancer(parent task.Parent) {
 	}
 	r.loadBalancer = lb
 
-	server := loadbalancer.NewServer(r.task.Name(), r.ProxyURL, r.LoadBalance.Weight, r.handler, r.HealthMon)
+	server := loadbalancer.NewServer(r.task.Name(), r.ProxyURL, r.LoadBalance.Weight, r.lbOverride, r.handler, r.HealthMon)
 	lb.AddServer(server)
 	r.task.OnCancel("lb_remove_server", func() {
 		lb.RemoveServer(server)
@@ -8,6 +8,7 @@ import (
 	"os"
 	"reflect"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,6 +17,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/yusing/godoxy/agent/pkg/agent"
 	"github.com/yusing/godoxy/internal/docker"
+	entrypoint "github.com/yusing/godoxy/internal/entrypoint/types"
 	"github.com/yusing/godoxy/internal/homepage"
 	homepagecfg "github.com/yusing/godoxy/internal/homepage/types"
 	netutils "github.com/yusing/godoxy/internal/net"
@@ -55,6 +57,7 @@ type (
 		Homepage     *homepage.ItemConfig           `json:"homepage"`
 		AccessLog    *accesslog.RequestLoggerConfig `json:"access_log,omitempty" extensions:"x-nullable"`
 		Agent        string                         `json:"agent,omitempty"`
+		Entrypoints  []string                       `json:"entrypoints,omitempty" extensions:"x-nullable"` // entrypoints to be served on, all if empty
 
 		Idlewatcher *types.IdlewatcherConfig `json:"idlewatcher,omitempty" extensions:"x-nullable"`
 
@@ -170,51 +173,57 @@ func (r *Route) validate() gperr.Error {
 
 	if r.Idlewatcher != nil && r.Idlewatcher.Proxmox != nil {
 		node := r.Idlewatcher.Proxmox.Node
+		vmType := r.Idlewatcher.Proxmox.VMType
 		vmid := r.Idlewatcher.Proxmox.VMID
 		if node == "" {
 			return gperr.Errorf("node (proxmox node name) is required")
 		}
 		if vmid <= 0 {
-			return gperr.Errorf("vmid (lxc id) is required")
+			return gperr.Errorf("vmid (lxc or vm id) is required")
 		}
 		if r.Host == DefaultHost {
 			containerName := r.Idlewatcher.ContainerName()
 			// get ip addresses of the vmid
 			node, ok := proxmox.Nodes.Get(node)
 			if !ok {
-				return gperr.Errorf("proxmox node %s not found in pool", node)
+				return gperr.Errorf("proxmox node %s not found in pool", r.Idlewatcher.Proxmox.Node)
 			}
 
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-
-			ips, err := node.LXCGetIPs(ctx, vmid)
-			if err != nil {
-				return gperr.Errorf("failed to get ip addresses of vmid %d: %w", vmid, err)
-			}
-
-			if len(ips) == 0 {
-				return gperr.Multiline().
-					Addf("no ip addresses found for %s", containerName).
-					Adds("make sure you have set static ip address for container instead of dhcp").
-					Subject(containerName)
+			// vms may take a while to boot and start the guest agent
+			timeout := 5 * time.Second
+			if vmType.IsQEMU() {
+				timeout = max(r.Idlewatcher.WakeTimeout, types.ContainerWakeTimeoutDefault)
 			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
 
 			l := log.With().Str("container", containerName).Logger()
 
 			l.Info().Msg("checking if container is running")
-			running, err := node.LXCIsRunning(ctx, vmid)
+			running, err := node.VMIsRunning(ctx, vmType, vmid)
 			if err != nil {
 				return gperr.New("failed to check container state").With(err)
 			}
 
 			if !running {
 				l.Info().Msg("starting container")
-				if err := node.LXCAction(ctx, vmid, proxmox.LXCStart); err != nil {
+				if err := node.VMStart(ctx, vmType, vmid); err != nil {
 					return gperr.New("failed to start container").With(err)
 				}
 			}
 
+			ips, err := node.VMGetIPs(ctx, vmType, vmid)
+			if err != nil {
+				return gperr.Errorf("failed to get ip addresses of vmid %d: %w", vmid, err)
+			}
+
+			if len(ips) == 0 {
+				return gperr.Multiline().
+					Addf("no ip addresses found for %s", containerName).
+					Adds("make sure you have set static ip address for container instead of dhcp, or installed qemu-guest-agent for vms").
+					Subject(containerName)
+			}
+
 			l.Info().Msgf("finding reachable ip addresses")
 			errs := gperr.NewBuilder("failed to find reachable ip addresses")
 			for _, ip := range ips {
@@ -239,6 +248,18 @@ func (r *Route) validate() gperr.Error {
 		r.Idlewatcher = r.Container.IdlewatcherConfig
 	}
 
+	// commands of agent routes run on the agent host
+	if idw := r.Idlewatcher; idw != nil {
+		if a := r.GetAgent(); a != nil {
+			switch {
+			case idw.Exec != nil:
+				idw.Exec.Agent = a.Addr
+			case idw.Systemd != nil:
+				idw.Systemd.Agent = a.Addr
+			}
+		}
+	}
+
 	// return error if route is localhost:<godoxy_port> but route is not agent
 	if !r.IsAgent() {
 		switch r.Host {
@@ -384,6 +405,10 @@ func (r *Route) start(parent task.Parent) gperr.Error {
 		}
 	}
 
+	if err := r.checkEntrypoints(); err != nil {
+		return err
+	}
+
 	if cont := r.ContainerInfo(); cont != nil {
 		docker.SetDockerHostByContainerID(cont.ContainerID, cont.DockerHost)
 	}
@@ -394,6 +419,19 @@ func (r *Route) start(parent task.Parent) gperr.Error {
 	return nil
 }
 
+// checkEntrypoints returns an error if the route lists an entrypoint that is not configured.
+//
+// It is checked on start rather than validation, as the entrypoints of a reloaded config
+// are only known after the config is committed.
+func (r *Route) checkEntrypoints() gperr.Error {
+	for _, name := range r.Entrypoints {
+		if !entrypoint.IsConfigured(name) {
+			return gperr.Errorf("entrypoint %s not found", name)
+		}
+	}
+	return nil
+}
+
 func (r *Route) Finish(reason any) {
 	if cont := r.ContainerInfo(); cont != nil {
 		docker.DeleteDockerHostByContainerID(cont.ContainerID)
@@ -698,6 +736,17 @@ func (r *Route) UseAccessLog() bool {
 	return r.AccessLog != nil
 }
 
+// ServesEntrypoint returns whether the route is served on the entrypoint.
+//
+// Routes without entrypoints are only served on entrypoints that serve all routes,
+// i.e. the default entrypoint and named entrypoints with serve_all_routes.
+func (r *Route) ServesEntrypoint(name string, serveAllRoutes bool) bool {
+	if len(r.Entrypoints) == 0 {
+		return serveAllRoutes
+	}
+	return slices.Contains(r.Entrypoints, name)
+}
+
 func (r *Route) Finalize() {
 	r.Alias = strings.ToLower(strings.TrimSpace(r.Alias))
 	r.Host = strings.ToLower(strings.TrimSpace(r.Host))
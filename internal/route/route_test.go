@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/yusing/godoxy/internal/common"
+	entrypoint "github.com/yusing/godoxy/internal/entrypoint/types"
 	route "github.com/yusing/godoxy/internal/route/types"
 	"github.com/yusing/godoxy/internal/types"
 	expect "github.com/yusing/goutils/testing"
@@ -135,6 +136,28 @@ func TestRouteValidate(t *testing.T) {
 	})
 }
 
+func TestRouteEntrypoints(t *testing.T) {
+	entrypoint.SetNamedEntrypoints([]string{"vpn"})
+	t.Cleanup(func() { entrypoint.SetNamedEntrypoints(nil) })
+
+	newRoute := func(entrypoints ...string) *Route {
+		return &Route{
+			Alias:       "test",
+			Scheme:      route.SchemeHTTP,
+			Host:        "example.com",
+			Port:        route.Port{Proxy: 80},
+			Entrypoints: entrypoints,
+		}
+	}
+
+	expect.NoError(t, newRoute().checkEntrypoints())
+	expect.NoError(t, newRoute("vpn", entrypoint.DefaultName).checkEntrypoints())
+
+	err := newRoute("vpn", "lan").checkEntrypoints()
+	expect.HasError(t, err, "unknown entrypoints should be rejected")
+	expect.ErrorContains(t, err, "entrypoint lan not found")
+}
+
 func TestPreferredPort(t *testing.T) {
 	ports := types.PortMapping{
 		22:   {PrivatePort: 22},
@@ -2,6 +2,7 @@ package rules
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/rs/zerolog"
 	"github.com/yusing/godoxy/internal/auth"
+	"github.com/yusing/godoxy/internal/common"
 	"github.com/yusing/godoxy/internal/logging"
 	gphttp "github.com/yusing/godoxy/internal/net/gphttp"
 	nettypes "github.com/yusing/godoxy/internal/net/types"
@@ -211,23 +213,57 @@ var commands = map[string]struct {
 			description: makeLines(
 				"Require HTTP basic authentication for incoming requests, e.g.:",
 				helpExample(CommandRequireBasicAuth, "Restricted Area"),
+				"With `ldap`, valid LDAP credentials of the allowed users and groups",
+				"(GODOXY_LDAP_ALLOWED_USERS and GODOXY_LDAP_ALLOWED_GROUPS) are accepted and the request proceeds, e.g.:",
+				helpExample(CommandRequireBasicAuth, "Restricted Area", "ldap"),
 			),
 			args: map[string]string{
-				"realm": "the authentication realm",
+				"realm":     "the authentication realm",
+				"[backend]": "the credentials backend, only `ldap` is supported",
 			},
 		},
 		validate: func(args []string) (any, gperr.Error) {
-			if len(args) == 1 {
-				return args[0], nil
+			switch len(args) {
+			case 1:
+				return &Tuple[string, bool]{args[0], false}, nil
+			case 2:
+				if args[1] != "ldap" {
+					return nil, ErrInvalidArguments.Subject(args[1])
+				}
+				if !auth.IsLDAPEnabled() {
+					return nil, ErrInvalidArguments.With(auth.ErrLDAPNotConfigured)
+				}
+				if len(common.LDAPAllowedUsers) == 0 && len(common.LDAPAllowedGroups) == 0 {
+					return nil, ErrInvalidArguments.With(auth.ErrLDAPNoAllowList)
+				}
+				return &Tuple[string, bool]{args[0], true}, nil
 			}
-			return nil, ErrExpectOneArg
+			return nil, ErrExpectOneOrTwoArgs
 		},
 		build: func(args any) CommandHandler {
-			realm := args.(string)
-			return TerminatingCommand(func(w http.ResponseWriter, r *http.Request) error {
-				w.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`"`)
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return nil
+			realm, withLDAP := args.(*Tuple[string, bool]).Unpack()
+			if !withLDAP {
+				return TerminatingCommand(func(w http.ResponseWriter, r *http.Request) error {
+					w.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`"`)
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+					return nil
+				})
+			}
+			return NonTerminatingCommand(func(w http.ResponseWriter, r *http.Request) error {
+				user, err := auth.LDAPBasicAuth(r)
+				switch {
+				case err == nil:
+					if user.IsAllowed(common.LDAPAllowedUsers, common.LDAPAllowedGroups) {
+						return nil
+					}
+					http.Error(w, "Forbidden", http.StatusForbidden)
+				case errors.Is(err, auth.ErrTooManyLoginAttempts):
+					http.Error(w, "too many login attempts, try again later", http.StatusTooManyRequests)
+				default:
+					w.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`"`)
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				}
+				return errTerminated
 			})
 		},
 	},
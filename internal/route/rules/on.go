@@ -6,6 +6,8 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/yusing/godoxy/internal/auth"
+	"github.com/yusing/godoxy/internal/net/gphttp/middleware/crawler"
 	"github.com/yusing/godoxy/internal/route/routes"
 	gperr "github.com/yusing/goutils/errs"
 )
@@ -36,6 +38,8 @@ const (
 	OnRemote    = "remote"
 	OnBasicAuth = "basic_auth"
 	OnRoute     = "route"
+	OnBotClass  = "bot_class"
+	OnSignedURL = "signed_url"
 
 	// on response
 	OnResponseHeader = "resp_header"
@@ -355,6 +359,50 @@ var checkers = map[string]struct {
 			}
 		},
 	},
+	OnBotClass: {
+		help: Help{
+			command: OnBotClass,
+			description: makeLines(
+				"Classification of the request by user agent and verified crawler detection, e.g.:",
+				helpExample(OnBotClass, "verified_crawler"),
+				helpExample(OnBotClass, "fake_crawler"),
+				"Rules run before route middlewares, so the default classifier is used,",
+				"allow_user_agents and block_user_agents of a route bot_filter do not apply.",
+				"Known crawlers are classified as bot until their IP verification completes.",
+			),
+			args: map[string]string{
+				"class": "one of human, bot, good_bot, bad_bot, verified_crawler, fake_crawler",
+			},
+		},
+		validate: validateBotClass,
+		builder: func(args any) CheckFunc {
+			class := args.(crawler.Class)
+			return func(_ http.ResponseWriter, r *http.Request) bool {
+				return crawler.ClassOf(r) == class
+			}
+		},
+	},
+	OnSignedURL: {
+		help: Help{
+			command: OnSignedURL,
+			description: makeLines(
+				"Request has a valid signed URL minted by the API, e.g.:",
+				helpExample(OnSignedURL),
+			),
+			args: map[string]string{},
+		},
+		validate: func(args []string) (any, gperr.Error) {
+			if len(args) != 0 {
+				return nil, ErrExpectNoArg
+			}
+			return nil, nil
+		},
+		builder: func(args any) CheckFunc {
+			return func(w http.ResponseWriter, r *http.Request) bool {
+				return auth.CheckSignedURL(w, r) == nil
+			}
+		},
+	},
 	OnStatus: {
 		isResponseChecker: true,
 		help: Help{
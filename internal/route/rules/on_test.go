@@ -251,6 +251,38 @@ func TestOnCorrectness(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			name:    "bot_class_human",
+			checker: "bot_class human",
+			input: &http.Request{
+				Header: http.Header{"User-Agent": {"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"}},
+			},
+			want: true,
+		},
+		{
+			name:    "bot_class_bad_bot",
+			checker: "bot_class bad_bot",
+			input: &http.Request{
+				Header: http.Header{"User-Agent": {"Mozilla/5.0 (compatible; AhrefsBot/7.0; +http://ahrefs.com/robot/)"}},
+			},
+			want: true,
+		},
+		{
+			name:    "bot_class_generic_bot",
+			checker: "bot_class bot",
+			input: &http.Request{
+				Header: http.Header{"User-Agent": {"curl/8.5.0"}},
+			},
+			want: true,
+		},
+		{
+			name:    "bot_class_negated",
+			checker: "!bot_class human",
+			input: &http.Request{
+				Header: http.Header{"User-Agent": {"python-requests/2.31.0"}},
+			},
+			want: true,
+		},
 		{
 			name:    "regex_match",
 			checker: `host regex(example\w+\.com)`,
@@ -6,10 +6,12 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/rs/zerolog"
+	"github.com/yusing/godoxy/internal/net/gphttp/middleware/crawler"
 	nettypes "github.com/yusing/godoxy/internal/net/types"
 	gperr "github.com/yusing/goutils/errs"
 	httputils "github.com/yusing/goutils/http"
@@ -198,6 +200,18 @@ func validateMethod(args []string) (any, gperr.Error) {
 	return method, nil
 }
 
+// validateBotClass returns crawler.Class with the class validated.
+func validateBotClass(args []string) (any, gperr.Error) {
+	if len(args) != 1 {
+		return nil, ErrExpectOneArg
+	}
+	class := crawler.Class(args[0])
+	if !slices.Contains(crawler.AllClasses, class) {
+		return nil, ErrInvalidArguments.Subject(args[0])
+	}
+	return class, nil
+}
+
 func validateStatusCode(status string) (int, error) {
 	statusCode, err := strconv.Atoi(status)
 	if err != nil {
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+
+	"github.com/yusing/godoxy/internal/auth"
 )
 
 var (
@@ -12,6 +14,7 @@ var (
 	VarQuery          = "arg"
 	VarForm           = "form"
 	VarPostForm       = "postform"
+	VarJWTClaim       = "jwt_claim"
 )
 
 type dynamicVarGetter func(args []string, w *ResponseModifier, req *http.Request) (string, error)
@@ -62,6 +65,15 @@ var dynamicVarSubsMap = map[string]dynamicVarGetter{
 		}
 		return getValueByKeyAtIndex(req.PostForm, key, index)
 	},
+	VarJWTClaim: func(args []string, w *ResponseModifier, req *http.Request) (string, error) {
+		if len(args) != 1 {
+			return "", ErrExpectOneArg
+		}
+		// claims verified by jwt_auth or oidc middleware, empty if none.
+		// Only entrypoint middlewares run before route rules.
+		claim, _ := auth.ClaimOf(req, args[0])
+		return claim, nil
+	},
 }
 
 func getValueByKeyAtIndex[Values http.Header | url.Values](values Values, key string, index int) (string, error) {
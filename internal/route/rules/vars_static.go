@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/yusing/godoxy/internal/net/gphttp/middleware/crawler"
 	"github.com/yusing/godoxy/internal/route/routes"
 )
 
@@ -24,6 +25,7 @@ const (
 	VarRemoteHost         = "remote_host"
 	VarRemotePort         = "remote_port"
 	VarRemoteAddr         = "remote_addr"
+	VarBotClass           = "bot_class"
 
 	VarUpstreamName   = "upstream_name"
 	VarUpstreamScheme = "upstream_scheme"
@@ -78,6 +80,7 @@ var staticReqVarSubsMap = map[string]reqVarGetter{
 		return ""
 	},
 	VarRemoteAddr:     func(req *http.Request) string { return req.RemoteAddr },
+	VarBotClass:       func(req *http.Request) string { return string(crawler.ClassOf(req)) },
 	VarUpstreamName:   routes.TryGetUpstreamName,
 	VarUpstreamScheme: routes.TryGetUpstreamScheme,
 	VarUpstreamHost:   routes.TryGetUpstreamHost,
@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/yusing/godoxy/internal/auth"
 )
 
 func TestExtractArgs(t *testing.T) {
@@ -648,6 +649,37 @@ func TestExpandVars_NoRemotePort(t *testing.T) {
 	})
 }
 
+func TestExpandVars_JWTClaim(t *testing.T) {
+	testRequest := auth.WithClaims(httptest.NewRequest("GET", "/", nil), map[string]any{
+		"sub":          "alice",
+		"groups":       []any{"dev", "ops"},
+		"realm_access": map[string]any{"roles": []any{"admin"}},
+	})
+	testResponseModifier := NewResponseModifier(httptest.NewRecorder())
+
+	tests := map[string]string{
+		"$jwt_claim(sub)":                "alice",
+		"$jwt_claim(groups)":             "dev,ops",
+		"$jwt_claim(realm_access.roles)": "admin",
+		"$jwt_claim(nonexistent)":        "",
+	}
+	for input, expected := range tests {
+		t.Run(input, func(t *testing.T) {
+			var out strings.Builder
+			err := ExpandVars(testResponseModifier, testRequest, input, &out)
+			require.NoError(t, err)
+			require.Equal(t, expected, out.String())
+		})
+	}
+
+	t.Run("without claims", func(t *testing.T) {
+		var out strings.Builder
+		err := ExpandVars(testResponseModifier, httptest.NewRequest("GET", "/", nil), "$jwt_claim(sub)", &out)
+		require.NoError(t, err)
+		require.Equal(t, "", out.String())
+	})
+}
+
 func TestExpandVars_WhitespaceHandling(t *testing.T) {
 	testRequest := httptest.NewRequest("GET", "/test", nil)
 	testResponseModifier := NewResponseModifier(httptest.NewRecorder())
@@ -2,10 +2,12 @@ package stream
 
 import (
 	"context"
+	"errors"
 	"net"
 
 	"github.com/pires/go-proxyproto"
 	"github.com/rs/zerolog"
+	"github.com/yusing/godoxy/agent/pkg/agent"
 	"github.com/yusing/godoxy/internal/acl"
 	"github.com/yusing/godoxy/internal/entrypoint"
 	nettypes "github.com/yusing/godoxy/internal/net/types"
@@ -17,23 +19,32 @@ type TCPTCPStream struct {
 	listener net.Listener
 	laddr    *net.TCPAddr
 	dst      *net.TCPAddr
+	dstAddr  string
+	agent    *agent.AgentConfig // relay connections through the agent if set
 
 	preDial nettypes.HookFunc
 	onRead  nettypes.HookFunc
+	wakeBuffer
 
 	closed atomic.Bool
 }
 
-func NewTCPTCPStream(listenAddr, dstAddr string) (nettypes.Stream, error) {
-	dst, err := net.ResolveTCPAddr("tcp", dstAddr)
-	if err != nil {
-		return nil, err
-	}
+var _ nettypes.WakeBufferedStream = (*TCPTCPStream)(nil)
+
+func NewTCPTCPStream(listenAddr, dstAddr string, agent *agent.AgentConfig) (nettypes.Stream, error) {
 	laddr, err := net.ResolveTCPAddr("tcp", listenAddr)
 	if err != nil {
 		return nil, err
 	}
-	return &TCPTCPStream{laddr: laddr, dst: dst}, nil
+	s := &TCPTCPStream{laddr: laddr, dstAddr: dstAddr, agent: agent}
+	// dstAddr may only be resolvable on the agent network
+	if agent == nil {
+		s.dst, err = net.ResolveTCPAddr("tcp", dstAddr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
 }
 
 func (s *TCPTCPStream) ListenAndServe(ctx context.Context, preDial, onRead nettypes.HookFunc) {
@@ -78,6 +89,11 @@ func (s *TCPTCPStream) MarshalZerologObject(e *zerolog.Event) {
 	}
 	if s.dst != nil {
 		e.Str("dst", s.dst.String())
+	} else {
+		e.Str("dst", s.dstAddr)
+	}
+	if s.agent != nil {
+		e.Str("agent", s.agent.Name)
 	}
 }
 
@@ -99,8 +115,9 @@ func (s *TCPTCPStream) listen(ctx context.Context) {
 				logErr(s, err, "failed to accept connection")
 				continue
 			}
-			if s.onRead != nil {
-				if err := s.onRead(ctx); err != nil {
+			// with WaitForData, connections without data are not counted as activity
+			if s.onRead != nil && !s.config().WaitForData {
+				if err := s.onRead(nettypes.WithRemoteAddr(ctx, conn.RemoteAddr())); err != nil {
 					logErr(s, err, "failed to on read")
 					continue
 				}
@@ -113,11 +130,13 @@ func (s *TCPTCPStream) listen(ctx context.Context) {
 func (s *TCPTCPStream) handle(ctx context.Context, conn net.Conn) {
 	defer conn.Close()
 
+	ctx = nettypes.WithRemoteAddr(ctx, conn.RemoteAddr())
+
+	var buffered []byte
 	if s.preDial != nil {
-		if err := s.preDial(ctx); err != nil {
-			if !s.closed.Load() {
-				logErr(s, err, "failed to pre-dial")
-			}
+		var ok bool
+		buffered, ok = s.preDialBuffered(ctx, conn)
+		if !ok {
 			return
 		}
 	}
@@ -126,7 +145,7 @@ func (s *TCPTCPStream) handle(ctx context.Context, conn net.Conn) {
 		return
 	}
 
-	dstConn, err := net.DialTCP("tcp", nil, s.dst)
+	dstConn, err := s.dial(ctx)
 	if err != nil {
 		if !s.closed.Load() {
 			logErr(s, err, "failed to dial destination")
@@ -139,8 +158,17 @@ func (s *TCPTCPStream) handle(ctx context.Context, conn net.Conn) {
 		return
 	}
 
+	// replay the data received during pre-dial
+	if len(buffered) > 0 {
+		if _, err := dstConn.Write(buffered); err != nil {
+			logErrf(s, err, "failed to write %d buffered bytes to destination", len(buffered))
+			return
+		}
+		logDebugf(s, "replayed %d buffered bytes from %s", len(buffered), conn.RemoteAddr())
+	}
+
 	src := conn
-	dst := net.Conn(dstConn)
+	dst := dstConn
 	if s.onRead != nil {
 		src = &wrapperConn{
 			Conn:   conn,
@@ -160,6 +188,58 @@ func (s *TCPTCPStream) handle(ctx context.Context, conn net.Conn) {
 	}
 }
 
+// preDialBuffered calls preDial while buffering the data from the client, and returns the buffered data.
+//
+// It returns false if the connection should be closed.
+func (s *TCPTCPStream) preDialBuffered(ctx context.Context, conn net.Conn) ([]byte, bool) {
+	cfg := s.config()
+	buf := newTCPWakeBuffer(conn, cfg.Size)
+
+	if cfg.WaitForData {
+		if err := buf.readFirst(cfg.Timeout); len(buf.buf) == 0 {
+			logDebugf(s, "no data from %s before pre-dial: %v", conn.RemoteAddr(), err)
+			return nil, false
+		}
+	}
+	if buf.err == nil {
+		buf.start()
+	} else {
+		close(buf.done)
+	}
+
+	err := s.preDial(ctx)
+	data, readErr := buf.stop()
+	if err != nil {
+		switch {
+		case errors.Is(err, nettypes.ErrIgnored):
+			logDebugf(s, "ignored connection from %s", conn.RemoteAddr())
+		case !s.closed.Load():
+			logErr(s, err, "failed to pre-dial")
+		}
+		return nil, false
+	}
+	if len(data) == 0 && readErr != nil {
+		// client is gone during pre-dial
+		return nil, false
+	}
+	if buf.expired(cfg.Timeout) {
+		logDebugf(s, "dropped connection from %s, buffered data expired", conn.RemoteAddr())
+		return nil, false
+	}
+	return data, true
+}
+
+func (s *TCPTCPStream) dial(ctx context.Context) (net.Conn, error) {
+	if s.agent != nil {
+		return s.agent.DialStream(ctx, "tcp", s.dstAddr)
+	}
+	conn, err := net.DialTCP("tcp", nil, s.dst)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
 type wrapperConn struct {
 	net.Conn
 	ctx    context.Context
@@ -3,6 +3,7 @@ package stream
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"maps"
 	"net"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/rs/zerolog"
+	"github.com/yusing/godoxy/agent/pkg/agent"
 	"github.com/yusing/godoxy/internal/acl"
 	nettypes "github.com/yusing/godoxy/internal/net/types"
 	"github.com/yusing/goutils/synk"
@@ -20,11 +22,14 @@ type UDPUDPStream struct {
 	name     string
 	listener net.PacketConn
 
-	laddr *net.UDPAddr
-	dst   *net.UDPAddr
+	laddr   *net.UDPAddr
+	dst     *net.UDPAddr
+	dstAddr string
+	agent   *agent.AgentConfig // relay datagrams through the agent if set
 
 	preDial nettypes.HookFunc
 	onRead  nettypes.HookFunc
+	wakeBuffer
 
 	cleanUpTicker *time.Ticker
 
@@ -35,13 +40,21 @@ type UDPUDPStream struct {
 
 type udpUDPConn struct {
 	srcAddr  *net.UDPAddr
-	dstConn  *net.UDPConn
+	dstConn  net.Conn
 	listener net.PacketConn
 	lastUsed atomic.Time
 	closed   atomic.Bool
 	mu       sync.Mutex
+
+	// datagrams received before connected to the destination
+	connected   bool
+	pending     []udpPendingDatagram
+	pendingSize int
+	maxPending  int
 }
 
+var _ nettypes.WakeBufferedStream = (*UDPUDPStream)(nil)
+
 const (
 	udpBufferSize      = 16 * 1024
 	udpIdleTimeout     = 5 * time.Minute // Longer timeout for game sessions
@@ -51,20 +64,25 @@ const (
 
 var bufPool = synk.GetSizedBytesPool()
 
-func NewUDPUDPStream(listenAddr, dstAddr string) (nettypes.Stream, error) {
-	dst, err := net.ResolveUDPAddr("udp", dstAddr)
-	if err != nil {
-		return nil, err
-	}
+func NewUDPUDPStream(listenAddr, dstAddr string, agent *agent.AgentConfig) (nettypes.Stream, error) {
 	laddr, err := net.ResolveUDPAddr("udp", listenAddr)
 	if err != nil {
 		return nil, err
 	}
-	return &UDPUDPStream{
-		laddr: laddr,
-		dst:   dst,
-		conns: make(map[string]*udpUDPConn),
-	}, nil
+	s := &UDPUDPStream{
+		laddr:   laddr,
+		dstAddr: dstAddr,
+		agent:   agent,
+		conns:   make(map[string]*udpUDPConn),
+	}
+	// dstAddr may only be resolvable on the agent network
+	if agent == nil {
+		s.dst, err = net.ResolveUDPAddr("udp", dstAddr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
 }
 
 func (s *UDPUDPStream) ListenAndServe(ctx context.Context, preDial, onRead nettypes.HookFunc) {
@@ -119,6 +137,11 @@ func (s *UDPUDPStream) MarshalZerologObject(e *zerolog.Event) {
 	}
 	if s.dst != nil {
 		e.Str("dst", s.dst.String())
+	} else {
+		e.Str("dst", s.dstAddr)
+	}
+	if s.agent != nil {
+		e.Str("agent", s.agent.Name)
 	}
 }
 
@@ -149,75 +172,108 @@ func (s *UDPUDPStream) listen(ctx context.Context) {
 			logDebugf(s, "read %d bytes from %s", n, srcAddr)
 
 			if s.onRead != nil {
-				if err := s.onRead(ctx); err != nil {
+				if err := s.onRead(nettypes.WithRemoteAddr(ctx, srcAddr)); err != nil {
 					logErr(s, err, "failed to on read")
 					continue
 				}
 			}
 
-			// Get or create connection, passing the initial data
-			go s.getOrCreateConnection(ctx, srcAddrUDP, bytes.Clone(buf[:n]))
+			// Get or create connection, passing the data
+			s.getOrCreateConnection(ctx, srcAddrUDP, bytes.Clone(buf[:n]))
 		}
 	}
 }
 
-func (s *UDPUDPStream) getOrCreateConnection(ctx context.Context, srcAddr *net.UDPAddr, initialData []byte) {
+func (s *UDPUDPStream) getOrCreateConnection(ctx context.Context, srcAddr *net.UDPAddr, data []byte) {
 	key := srcAddr.String()
 
 	s.mu.Lock()
-	if conn, ok := s.conns[key]; ok {
-		s.mu.Unlock()
-		// Forward packet for existing connection
-		go conn.forwardToDestination(initialData)
-		return
+	conn, ok := s.conns[key]
+	if !ok {
+		// buffer the datagrams until connected, pre-dial may take a while
+		conn = &udpUDPConn{
+			srcAddr:    srcAddr,
+			listener:   s.listener,
+			maxPending: s.config().Size,
+		}
+		conn.lastUsed.Store(time.Now())
+		s.conns[key] = conn
 	}
+	s.mu.Unlock()
 
-	defer s.mu.Unlock()
-	// Create new connection with initial data
-	conn, ok := s.createConnection(ctx, srcAddr, initialData)
-	if ok && !conn.closed.Load() {
-		s.conns[key] = conn
+	if conn.buffer(data) {
+		if !ok {
+			go s.connect(ctx, key, conn)
+		}
+		return
 	}
+	// Forward packet for existing connection
+	go conn.forwardToDestination(data)
 }
 
-func (s *UDPUDPStream) createConnection(ctx context.Context, srcAddr *net.UDPAddr, initialData []byte) (*udpUDPConn, bool) {
+// connect connects to the destination and replays the buffered datagrams.
+func (s *UDPUDPStream) connect(ctx context.Context, key string, conn *udpUDPConn) {
+	ctx = nettypes.WithRemoteAddr(ctx, conn.srcAddr)
+
 	// Apply pre-dial if configured
 	if s.preDial != nil {
 		if err := s.preDial(ctx); err != nil {
-			logErr(s, err, "failed to pre-dial")
-			return nil, false
+			if errors.Is(err, nettypes.ErrIgnored) {
+				logDebugf(s, "ignored datagrams from %s", conn.srcAddr)
+			} else {
+				logErr(s, err, "failed to pre-dial")
+			}
+			s.removeConnection(key, conn)
+			return
 		}
 	}
 
 	// Create UDP connection to destination
-	dstConn, err := net.DialUDP("udp", nil, s.dst)
+	dstConn, err := s.dial(ctx)
 	if err != nil {
 		logErr(s, err, "failed to dial dst")
-		return nil, false
-	}
-
-	conn := &udpUDPConn{
-		srcAddr:  srcAddr,
-		dstConn:  dstConn,
-		listener: s.listener,
+		s.removeConnection(key, conn)
+		return
 	}
-	conn.lastUsed.Store(time.Now())
 
-	// Send initial data before starting response handler
-	if !conn.forwardToDestination(initialData) {
+	// Send buffered data before starting response handler
+	if !conn.connect(dstConn, s.config().Timeout) {
 		dstConn.Close()
-		return nil, false
+		s.removeConnection(key, conn)
+		return
 	}
 
-	// Start response handler after initial data is sent
+	// Start response handler after buffered data is sent
 	go conn.handleResponses(ctx)
 
-	logDebugf(s, "created new connection from %s", srcAddr.String())
-	return conn, true
+	logDebugf(s, "created new connection from %s", conn.srcAddr.String())
+}
+
+func (s *UDPUDPStream) removeConnection(key string, conn *udpUDPConn) {
+	s.mu.Lock()
+	if s.conns[key] == conn {
+		delete(s.conns, key)
+	}
+	s.mu.Unlock()
+	conn.Close()
+}
+
+func (s *UDPUDPStream) dial(ctx context.Context) (net.Conn, error) {
+	if s.agent != nil {
+		return s.agent.DialStream(ctx, "udp", s.dstAddr)
+	}
+	conn, err := net.DialUDP("udp", nil, s.dst)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
 }
 
 func (conn *udpUDPConn) MarshalZerologObject(e *zerolog.Event) {
-	e.Stringer("src", conn.srcAddr).Stringer("dst", conn.dstConn.RemoteAddr())
+	e.Stringer("src", conn.srcAddr)
+	if dstConn := conn.dstConn; dstConn != nil {
+		e.Stringer("dst", dstConn.RemoteAddr())
+	}
 }
 
 func (conn *udpUDPConn) handleResponses(ctx context.Context) {
@@ -295,6 +351,50 @@ func (s *UDPUDPStream) cleanUp(ctx context.Context) {
 	}
 }
 
+// buffer buffers the datagram if not yet connected, datagrams exceeding the buffer size are dropped.
+func (conn *udpUDPConn) buffer(data []byte) (buffered bool) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	if conn.connected {
+		return false
+	}
+	if conn.pendingSize+len(data) > conn.maxPending {
+		logDebugf(conn, "buffer full, dropped %d bytes", len(data))
+		return true
+	}
+	conn.pending = append(conn.pending, udpPendingDatagram{data: data, receivedAt: time.Now()})
+	conn.pendingSize += len(data)
+	return true
+}
+
+// connect sets the destination and replays the buffered datagrams, expired datagrams are dropped.
+func (conn *udpUDPConn) connect(dstConn net.Conn, timeout time.Duration) bool {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	if conn.closed.Load() {
+		return false
+	}
+
+	conn.dstConn = dstConn
+	conn.connected = true
+	pending := conn.pending
+	conn.pending = nil
+	conn.pendingSize = 0
+
+	for _, datagram := range pending {
+		if time.Since(datagram.receivedAt) > timeout {
+			logDebugf(conn, "dropped expired datagram, %d bytes", len(datagram.data))
+			continue
+		}
+		if !conn.write(datagram.data) {
+			return false
+		}
+	}
+	return true
+}
+
 func (conn *udpUDPConn) forwardToDestination(data []byte) bool {
 	conn.mu.Lock()
 	defer conn.mu.Unlock()
@@ -303,6 +403,10 @@ func (conn *udpUDPConn) forwardToDestination(data []byte) bool {
 		return false
 	}
 
+	return conn.write(data)
+}
+
+func (conn *udpUDPConn) write(data []byte) bool {
 	_, err := conn.dstConn.Write(data)
 	if err != nil {
 		logErrf(conn, err, "failed to write %d bytes to dst", len(data))
@@ -328,6 +432,8 @@ func (conn *udpUDPConn) Close() {
 
 	conn.closed.Store(true)
 
-	conn.dstConn.Close()
-	conn.dstConn = nil
+	if conn.dstConn != nil {
+		conn.dstConn.Close()
+		conn.dstConn = nil
+	}
 }
@@ -0,0 +1,122 @@
+package stream
+
+import (
+	"errors"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	nettypes "github.com/yusing/godoxy/internal/net/types"
+)
+
+const (
+	wakeBufferSizeDefault    = 64 * 1024
+	wakeBufferTimeoutDefault = 30 * time.Second
+	wakeBufferReadSize       = 16 * 1024
+)
+
+type wakeBuffer struct {
+	cfg nettypes.WakeBufferConfig
+	mu  sync.RWMutex
+}
+
+func (b *wakeBuffer) SetWakeBuffer(cfg *nettypes.WakeBufferConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if cfg == nil {
+		b.cfg = nettypes.WakeBufferConfig{}
+	} else {
+		b.cfg = *cfg
+	}
+}
+
+func (b *wakeBuffer) config() nettypes.WakeBufferConfig {
+	b.mu.RLock()
+	cfg := b.cfg
+	b.mu.RUnlock()
+	if cfg.Size <= 0 {
+		cfg.Size = wakeBufferSizeDefault
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = wakeBufferTimeoutDefault
+	}
+	return cfg
+}
+
+// tcpWakeBuffer reads from the client in background while the destination is waking up,
+// so the client is not left without reads during long wakes.
+//
+// Reading stops when the buffer is full, the rest is left to the socket buffers.
+type tcpWakeBuffer struct {
+	conn  net.Conn
+	size  int
+	buf   []byte
+	since time.Time // when the first bytes are received
+	err   error     // last read error
+	done  chan struct{}
+}
+
+func newTCPWakeBuffer(conn net.Conn, size int) *tcpWakeBuffer {
+	return &tcpWakeBuffer{
+		conn: conn,
+		size: size,
+		done: make(chan struct{}),
+	}
+}
+
+// readFirst waits for the first bytes from the client until timeout.
+func (b *tcpWakeBuffer) readFirst(timeout time.Duration) error {
+	_ = b.conn.SetReadDeadline(time.Now().Add(timeout))
+	defer b.conn.SetReadDeadline(time.Time{}) //nolint:errcheck
+	b.readOnce()
+	return b.err
+}
+
+func (b *tcpWakeBuffer) readOnce() {
+	b.buf = slices.Grow(b.buf, min(wakeBufferReadSize, b.size-len(b.buf)))
+	n, err := b.conn.Read(b.buf[len(b.buf):min(cap(b.buf), b.size)])
+	if n > 0 {
+		if b.since.IsZero() {
+			b.since = time.Now()
+		}
+		b.buf = b.buf[:len(b.buf)+n]
+	}
+	if err != nil {
+		b.err = err
+	}
+}
+
+// start reads from the client in background until stop is called, the buffer is full, or an error occurred.
+func (b *tcpWakeBuffer) start() {
+	go func() {
+		defer close(b.done)
+		for b.err == nil && len(b.buf) < b.size {
+			b.readOnce()
+		}
+	}()
+}
+
+// stop stops reading and returns the buffered bytes.
+//
+// The error is nil if reading is stopped by stop or the buffer is full.
+func (b *tcpWakeBuffer) stop() ([]byte, error) {
+	_ = b.conn.SetReadDeadline(time.Now())
+	<-b.done
+	_ = b.conn.SetReadDeadline(time.Time{})
+	if errors.Is(b.err, os.ErrDeadlineExceeded) {
+		b.err = nil
+	}
+	return b.buf, b.err
+}
+
+// expired returns whether the buffered bytes are older than timeout.
+func (b *tcpWakeBuffer) expired(timeout time.Duration) bool {
+	return !b.since.IsZero() && time.Since(b.since) > timeout
+}
+
+type udpPendingDatagram struct {
+	data       []byte
+	receivedAt time.Time
+}
@@ -0,0 +1,191 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	nettypes "github.com/yusing/godoxy/internal/net/types"
+	expect "github.com/yusing/goutils/testing"
+)
+
+// blockingPreDial returns a preDial hook that blocks until release is called.
+func blockingPreDial() (preDial nettypes.HookFunc, calls *atomic.Int32, release func()) {
+	calls = new(atomic.Int32)
+	ch := make(chan struct{})
+	preDial = func(ctx context.Context) error {
+		calls.Add(1)
+		select {
+		case <-ch:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return preDial, calls, func() { close(ch) }
+}
+
+func TestTCPWakeBufferReplay(t *testing.T) {
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	expect.NoError(t, err)
+	t.Cleanup(func() { backend.Close() })
+
+	s, err := NewTCPTCPStream("127.0.0.1:0", backend.Addr().String(), nil)
+	expect.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	preDial, calls, release := blockingPreDial()
+	s.ListenAndServe(t.Context(), preDial, nil)
+
+	client, err := net.Dial("tcp", s.LocalAddr().String())
+	expect.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	// client speaks first while the destination is waking
+	_, err = client.Write([]byte("hello "))
+	expect.NoError(t, err)
+	_, err = client.Write([]byte("world"))
+	expect.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+	expect.Equal(t, calls.Load(), 1)
+	release()
+
+	conn, err := backend.Accept()
+	expect.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	buf := make([]byte, len("hello world"))
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = io.ReadFull(conn, buf)
+	expect.NoError(t, err)
+	expect.Equal(t, string(buf), "hello world")
+
+	// the rest is piped as usual
+	_, err = conn.Write([]byte("hi"))
+	expect.NoError(t, err)
+	_ = client.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = io.ReadFull(client, buf[:2])
+	expect.NoError(t, err)
+	expect.Equal(t, string(buf[:2]), "hi")
+}
+
+func TestTCPWakeBufferWaitForData(t *testing.T) {
+	s, err := NewTCPTCPStream("127.0.0.1:0", "127.0.0.1:1", nil)
+	expect.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	var calls atomic.Int32
+	s.(nettypes.WakeBufferedStream).SetWakeBuffer(&nettypes.WakeBufferConfig{
+		WaitForData: true,
+		Timeout:     100 * time.Millisecond,
+	})
+	s.ListenAndServe(t.Context(), func(ctx context.Context) error {
+		calls.Add(1)
+		return nettypes.ErrIgnored
+	}, nil)
+
+	// connections without data do not trigger pre-dial, and are closed after the timeout
+	client, err := net.Dial("tcp", s.LocalAddr().String())
+	expect.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	_ = client.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = client.Read(make([]byte, 1))
+	expect.ErrorIs(t, io.EOF, err)
+	expect.Equal(t, calls.Load(), 0)
+
+	client2, err := net.Dial("tcp", s.LocalAddr().String())
+	expect.NoError(t, err)
+	t.Cleanup(func() { client2.Close() })
+	_, err = client2.Write([]byte("hello"))
+	expect.NoError(t, err)
+
+	_ = client2.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = client2.Read(make([]byte, 1))
+	expect.ErrorIs(t, io.EOF, err)
+	expect.Equal(t, calls.Load(), 1)
+}
+
+func TestUDPWakeBufferReplay(t *testing.T) {
+	backend, err := net.ListenPacket("udp", "127.0.0.1:0")
+	expect.NoError(t, err)
+	t.Cleanup(func() { backend.Close() })
+
+	s, err := NewUDPUDPStream("127.0.0.1:0", backend.LocalAddr().String(), nil)
+	expect.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	s.(nettypes.WakeBufferedStream).SetWakeBuffer(&nettypes.WakeBufferConfig{Size: 8})
+
+	preDial, calls, release := blockingPreDial()
+	s.ListenAndServe(t.Context(), preDial, nil)
+
+	client, err := net.Dial("udp", s.LocalAddr().String())
+	expect.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	for _, msg := range []string{"one", "two", "three"} {
+		_, err = client.Write([]byte(msg))
+		expect.NoError(t, err)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	expect.Equal(t, calls.Load(), 1)
+	release()
+
+	// "three" exceeds the buffer size and is dropped
+	buf := make([]byte, udpBufferSize)
+	for _, want := range []string{"one", "two"} {
+		_ = backend.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := backend.ReadFrom(buf)
+		expect.NoError(t, err)
+		expect.Equal(t, string(buf[:n]), want)
+	}
+
+	// later datagrams are forwarded directly
+	_, err = client.Write([]byte("four"))
+	expect.NoError(t, err)
+	_ = backend.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := backend.ReadFrom(buf)
+	expect.NoError(t, err)
+	expect.Equal(t, string(buf[:n]), "four")
+}
+
+func TestUDPWakeBufferIgnored(t *testing.T) {
+	backend, err := net.ListenPacket("udp", "127.0.0.1:0")
+	expect.NoError(t, err)
+	t.Cleanup(func() { backend.Close() })
+
+	s, err := NewUDPUDPStream("127.0.0.1:0", backend.LocalAddr().String(), nil)
+	expect.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	var remote atomic.Value
+	s.ListenAndServe(t.Context(), func(ctx context.Context) error {
+		remote.Store(nettypes.RemoteAddr(ctx).String())
+		return nettypes.ErrIgnored
+	}, nil)
+
+	client, err := net.Dial("udp", s.LocalAddr().String())
+	expect.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	_, err = client.Write([]byte("probe"))
+	expect.NoError(t, err)
+
+	_ = backend.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, _, err = backend.ReadFrom(make([]byte, udpBufferSize))
+	expect.ErrorIs(t, os.ErrDeadlineExceeded, err)
+	expect.Equal(t, remote.Load().(string), client.LocalAddr().String())
+
+	udp := s.(*UDPUDPStream)
+	udp.mu.Lock()
+	expect.Equal(t, len(udp.conns), 0)
+	udp.mu.Unlock()
+}
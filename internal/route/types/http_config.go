@@ -5,6 +5,7 @@ import (
 	"crypto/x509"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -22,6 +23,38 @@ type HTTPConfig struct {
 	SSLCertificate        string   `json:"ssl_certificate,omitempty"`         // Path to client certificate
 	SSLCertificateKey     string   `json:"ssl_certificate_key,omitempty"`     // Path to client certificate key
 	SSLProtocols          []string `json:"ssl_protocols,omitempty"`           // Allowed TLS protocols
+
+	// Protocols offered to clients, HTTP/1.1 is always allowed.
+	// HTTP/3 is only advertised for routes with h3 when HTTP3_ENABLED is set.
+	ALPN []string `json:"alpn,omitempty" validate:"dive,oneof=h3 h2 http/1.1"` // default: h2, http/1.1
+}
+
+const (
+	ALPNHTTP3 = "h3"
+	ALPNHTTP2 = "h2"
+	ALPNHTTP1 = "http/1.1"
+)
+
+var defaultALPN = []string{ALPNHTTP2, ALPNHTTP1}
+
+// ALPNProtos returns the protocols offered to clients.
+func (cfg *HTTPConfig) ALPNProtos() []string {
+	if len(cfg.ALPN) == 0 {
+		return defaultALPN
+	}
+	return cfg.ALPN
+}
+
+// AllowsProtoMajor returns whether requests of the HTTP major version are allowed.
+func (cfg *HTTPConfig) AllowsProtoMajor(major int) bool {
+	switch major {
+	case 3:
+		return slices.Contains(cfg.ALPNProtos(), ALPNHTTP3)
+	case 2:
+		return slices.Contains(cfg.ALPNProtos(), ALPNHTTP2)
+	default:
+		return true
+	}
 }
 
 // BuildTLSConfig creates a TLS configuration based on the HTTP config options.
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"maps"
+
 	"github.com/bytedance/sonic"
 	"github.com/moby/moby/api/types/container"
 	"github.com/yusing/ds/ordered"
@@ -74,3 +76,35 @@ func (e *ContainerError) MarshalJSON() ([]byte, error) {
 	err := e.errs.Error().(gperr.PlainError)
 	return sonic.Marshal(string(err.Plain()))
 }
+
+func (e *ContainerError) UnmarshalJSON(data []byte) error {
+	var msg string
+	if err := sonic.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	e.errs.Adds(msg)
+	return nil
+}
+
+// UnmarshalJSON decodes a container marshaled by an agent with local route discovery.
+//
+// Labels are restored from the actual labels.
+func (c *Container) UnmarshalJSON(data []byte) error {
+	type container Container
+	var v struct {
+		*container
+		Mounts map[string]string `json:"mounts,omitempty"`
+	}
+	v.container = (*container)(c)
+	if err := sonic.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if len(v.Mounts) > 0 {
+		c.Mounts = ordered.NewMap[string, string](ordered.WithCapacity(len(v.Mounts)))
+		for src, dst := range v.Mounts {
+			c.Mounts.Set(src, dst)
+		}
+	}
+	c.Labels = maps.Clone(c.ActualLabels)
+	return nil
+}
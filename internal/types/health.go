@@ -59,6 +59,8 @@ type (
 		Detail      string             `json:"detail"`
 		URL         string             `json:"url"`
 		Extra       *HealthExtra       `json:"extra,omitempty" extensions:"x-nullable"`
+		// idlewatcher schedule of the route, if any
+		Schedule *IdlewatcherScheduleStatus `json:"schedule,omitempty" extensions:"x-nullable"`
 	} // @name HealthJSON
 
 	HealthJSONRepr struct {
@@ -72,6 +74,7 @@ type (
 		Detail   string
 		URL      *url.URL
 		Extra    *HealthExtra
+		Schedule *IdlewatcherScheduleStatus
 	}
 
 	HealthExtra struct {
@@ -87,13 +90,15 @@ const (
 	StatusStarting
 	StatusUnhealthy
 	StatusError
+	StatusAgentUnreachable
 
-	StatusUnknownStr   = "unknown"
-	StatusHealthyStr   = "healthy"
-	StatusNappingStr   = "napping"
-	StatusStartingStr  = "starting"
-	StatusUnhealthyStr = "unhealthy"
-	StatusErrorStr     = "error"
+	StatusUnknownStr          = "unknown"
+	StatusHealthyStr          = "healthy"
+	StatusNappingStr          = "napping"
+	StatusStartingStr         = "starting"
+	StatusUnhealthyStr        = "unhealthy"
+	StatusErrorStr            = "error"
+	StatusAgentUnreachableStr = "agent_unreachable"
 
 	NumStatuses int = iota - 1
 
@@ -102,11 +107,12 @@ const (
 )
 
 var (
-	StatusHealthyStr2   = strconv.Itoa(int(StatusHealthy))
-	StatusNappingStr2   = strconv.Itoa(int(StatusNapping))
-	StatusStartingStr2  = strconv.Itoa(int(StatusStarting))
-	StatusUnhealthyStr2 = strconv.Itoa(int(StatusUnhealthy))
-	StatusErrorStr2     = strconv.Itoa(int(StatusError))
+	StatusHealthyStr2          = strconv.Itoa(int(StatusHealthy))
+	StatusNappingStr2          = strconv.Itoa(int(StatusNapping))
+	StatusStartingStr2         = strconv.Itoa(int(StatusStarting))
+	StatusUnhealthyStr2        = strconv.Itoa(int(StatusUnhealthy))
+	StatusErrorStr2            = strconv.Itoa(int(StatusError))
+	StatusAgentUnreachableStr2 = strconv.Itoa(int(StatusAgentUnreachable))
 )
 
 func NewHealthStatusFromString(s string) HealthStatus {
@@ -121,6 +127,8 @@ func NewHealthStatusFromString(s string) HealthStatus {
 		return StatusStarting
 	case StatusErrorStr, StatusErrorStr2:
 		return StatusError
+	case StatusAgentUnreachableStr, StatusAgentUnreachableStr2:
+		return StatusAgentUnreachable
 	default:
 		return StatusUnknown
 	}
@@ -138,6 +146,8 @@ func (s HealthStatus) String() string {
 		return StatusStartingStr
 	case StatusError:
 		return StatusErrorStr
+	case StatusAgentUnreachable:
+		return StatusAgentUnreachableStr
 	default:
 		return StatusUnknownStr
 	}
@@ -192,5 +202,6 @@ func (jsonRepr *HealthJSONRepr) MarshalJSON() ([]byte, error) {
 		Detail:      jsonRepr.Detail,
 		URL:         url,
 		Extra:       jsonRepr.Extra,
+		Schedule:    jsonRepr.Schedule,
 	})
 }
@@ -2,37 +2,81 @@ package types
 
 import (
 	"net/url"
+	"path"
 	"strconv"
 	"strings"
 	"time"
 
+	nettypes "github.com/yusing/godoxy/internal/net/types"
+	"github.com/yusing/godoxy/internal/proxmox"
+	"github.com/yusing/godoxy/internal/utils/cron"
 	gperr "github.com/yusing/goutils/errs"
+	strutils "github.com/yusing/goutils/strings"
 )
 
 type (
 	IdlewatcherProviderConfig struct {
 		Proxmox *ProxmoxConfig `json:"proxmox,omitempty"`
 		Docker  *DockerConfig  `json:"docker,omitempty"`
+		Exec    *ExecConfig    `json:"exec,omitempty"`
+		Systemd *SystemdConfig `json:"systemd,omitempty"`
 	} // @name IdlewatcherProviderConfig
 	IdlewatcherConfigBase struct {
 		// 0: no idle watcher.
 		// Positive: idle watcher with idle timeout.
 		// Negative: idle watcher as a dependency.	IdleTimeout time.Duration `json:"idle_timeout" json_ext:"duration"`
-		IdleTimeout time.Duration       `json:"idle_timeout"`
-		WakeTimeout time.Duration       `json:"wake_timeout"`
-		StopTimeout time.Duration       `json:"stop_timeout"`
-		StopMethod  ContainerStopMethod `json:"stop_method"`
-		StopSignal  ContainerSignal     `json:"stop_signal,omitempty"`
+		IdleTimeout time.Duration        `json:"idle_timeout"`
+		WakeTimeout time.Duration        `json:"wake_timeout"`
+		StopTimeout time.Duration        `json:"stop_timeout"`
+		StopMethod  ContainerStopMethod  `json:"stop_method"`
+		StopSignal  ContainerSignal      `json:"stop_signal,omitempty"`
+		Schedule    *IdlewatcherSchedule `json:"schedule,omitempty" extensions:"x-nullable"`
 	} // @name IdlewatcherConfigBase
-	IdlewatcherConfig struct {
+	// IdlewatcherSchedule wakes or stops the container at times given by cron expressions, e.g. "* 9-17 * * mon-fri".
+	// Expressions are matched in the local timezone of GoDoxy, set with the TZ environment variable.
+	IdlewatcherSchedule struct {
+		// keep the container awake while matched, the idle timeout is ignored
+		KeepAwake cron.Schedules `json:"keep_awake,omitempty" swaggertype:"array,string"`
+		// keep the container stopped while matched, wake requests are refused. Takes precedence over keep_awake and pre_warm
+		ForceSleep cron.Schedules `json:"force_sleep,omitempty" swaggertype:"array,string"`
+		// wake the container at the matched minutes, it goes to sleep after the idle timeout as usual
+		PreWarm cron.Schedules `json:"pre_warm,omitempty" swaggertype:"array,string"`
+	} // @name IdlewatcherSchedule
+	IdlewatcherScheduleStatus struct {
+		KeepAwake       bool                      `json:"keepAwake"`
+		ForceSleep      bool                      `json:"forceSleep"`
+		NextAction      IdlewatcherScheduleAction `json:"nextAction,omitempty"`
+		NextActionAt    int64                     `json:"nextActionAt,omitempty"`
+		NextActionAtStr string                    `json:"nextActionAtStr,omitempty"`
+	} // @name IdlewatcherScheduleStatus
+	IdlewatcherScheduleAction string // @name IdlewatcherScheduleAction
+	IdlewatcherConfig         struct {
 		IdlewatcherProviderConfig
 		IdlewatcherConfigBase
 
-		StartEndpoint string   `json:"start_endpoint,omitempty"` // Optional path that must be hit to start container
-		DependsOn     []string `json:"depends_on,omitempty"`
+		StartEndpoint string                   `json:"start_endpoint,omitempty"` // Optional path that must be hit to start container
+		DependsOn     []string                 `json:"depends_on,omitempty"`
+		Stream        *IdlewatcherStreamConfig `json:"stream,omitempty" extensions:"x-nullable"` // stream routes only
 
 		valErr gperr.Error
 	} // @name IdlewatcherConfig
+	// IdlewatcherStreamConfig controls how stream routes wake the container.
+	//
+	// Data received while waking is buffered and replayed once the container is ready.
+	IdlewatcherStreamConfig struct {
+		// wake on the first bytes from the client instead of on connect (tcp only),
+		// so connections without data like port scans and tcp probes don't wake the container.
+		// Do not set it for protocols where the server speaks first, e.g. SSH, SMTP and MySQL.
+		WakeOnData bool `json:"wake_on_data,omitempty"`
+		// max bytes buffered per connection while waking, default 64KiB
+		BufferSize int `json:"buffer_size,omitempty" validate:"gte=0"`
+		// buffered data older than this is dropped instead of replayed, default 30s.
+		// With wake_on_data, connections without data within this duration are closed.
+		BufferTimeout time.Duration `json:"buffer_timeout,omitempty" swaggertype:"primitive,integer"`
+		// traffic from these sources, e.g. uptime monitors, neither wakes the container nor resets the idle timer.
+		// It is dropped while the container is not ready.
+		IgnoreSources []*nettypes.CIDR `json:"ignore_sources,omitempty" swaggertype:"array,string"`
+	} // @name IdlewatcherStreamConfig
 	ContainerStopMethod string // @name ContainerStopMethod
 	ContainerSignal     string // @name ContainerSignal
 
@@ -42,9 +86,30 @@ type (
 		ContainerName string `json:"container_name" validate:"required"`
 	} // @name DockerConfig
 	ProxmoxConfig struct {
-		Node string `json:"node" validate:"required"`
-		VMID int    `json:"vmid" validate:"required"`
+		Node   string         `json:"node" validate:"required"`
+		VMID   int            `json:"vmid" validate:"required"`
+		VMType proxmox.VMType `json:"vm_type,omitempty" validate:"omitempty,oneof=lxc qemu" swaggertype:"string" enums:"lxc,qemu"` // default: lxc
 	} // @name ProxmoxConfig
+	// ExecConfig runs shell commands on the GoDoxy host, or the agent host for agent routes.
+	ExecConfig struct {
+		Start string `json:"start" validate:"required"` // must return once started
+		Stop  string `json:"stop" validate:"required"`
+		// exit code 0: running, otherwise stopped.
+		// If not set, the service is considered running after start and stopped after stop.
+		Status  string            `json:"status,omitempty"`
+		Timeout time.Duration     `json:"timeout,omitempty" swaggertype:"primitive,integer"` // of each command, default: 1m
+		Env     map[string]string `json:"env,omitempty"`
+		Dir     string            `json:"dir,omitempty"`
+
+		Agent string `json:"-"` // agent address, set from the route
+	} // @name ExecConfig
+	// SystemdConfig manages a systemd unit on the GoDoxy host, or the agent host for agent routes.
+	SystemdConfig struct {
+		Unit string `json:"unit" validate:"required"`
+		User bool   `json:"user,omitempty"` // systemctl --user
+
+		Agent string `json:"-"` // agent address, set from the route
+	} // @name SystemdConfig
 )
 
 const (
@@ -54,20 +119,104 @@ const (
 	ContainerStopMethodPause ContainerStopMethod = "pause"
 	ContainerStopMethodStop  ContainerStopMethod = "stop"
 	ContainerStopMethodKill  ContainerStopMethod = "kill"
+
+	IdlewatcherScheduleActionWake    IdlewatcherScheduleAction = "wake"
+	IdlewatcherScheduleActionSleep   IdlewatcherScheduleAction = "sleep"
+	IdlewatcherScheduleActionPreWarm IdlewatcherScheduleAction = "pre_warm"
 )
 
+// idlewatcherScheduleLookahead limits the search of the next keep_awake and force_sleep window.
+const idlewatcherScheduleLookahead = 32 * 24 * time.Hour
+
+func (c *IdlewatcherProviderConfig) HasProvider() bool {
+	return c.Docker != nil || c.Proxmox != nil || c.Exec != nil || c.Systemd != nil
+}
+
 func (c *IdlewatcherConfig) Key() string {
-	if c.Docker != nil {
+	switch {
+	case c.Docker != nil:
 		return c.Docker.ContainerID
+	case c.Exec != nil:
+		return "exec:" + c.Exec.Agent + ":" + c.Exec.Start
+	case c.Systemd != nil:
+		return "systemd:" + c.Systemd.Agent + ":" + c.Systemd.Unit
 	}
 	return c.Proxmox.Node + ":" + strconv.Itoa(c.Proxmox.VMID)
 }
 
 func (c *IdlewatcherConfig) ContainerName() string {
-	if c.Docker != nil {
+	switch {
+	case c.Docker != nil:
 		return c.Docker.ContainerName
+	case c.Exec != nil:
+		// name of the executable
+		if fields := strings.Fields(c.Exec.Start); len(fields) > 0 {
+			return path.Base(fields[0])
+		}
+		return "exec"
+	case c.Systemd != nil:
+		return c.Systemd.Unit
 	}
-	return "lxc-" + strconv.Itoa(c.Proxmox.VMID)
+	return c.Proxmox.VMType.GuestName(c.Proxmox.VMID)
+}
+
+func (s *IdlewatcherSchedule) IsEmpty() bool {
+	return s == nil || len(s.KeepAwake) == 0 && len(s.ForceSleep) == 0 && len(s.PreWarm) == 0
+}
+
+// IsKeepAwake returns whether the container should be kept awake at t.
+func (s *IdlewatcherSchedule) IsKeepAwake(t time.Time) bool {
+	return !s.IsForceSleep(t) && s.KeepAwake.Match(t)
+}
+
+// IsForceSleep returns whether the container should be stopped at t.
+func (s *IdlewatcherSchedule) IsForceSleep(t time.Time) bool {
+	return s.ForceSleep.Match(t)
+}
+
+// IsPreWarm returns whether the container should be woken at t.
+func (s *IdlewatcherSchedule) IsPreWarm(t time.Time) bool {
+	return !s.IsForceSleep(t) && s.PreWarm.Match(t)
+}
+
+// Status returns the state of the schedule at now and the next scheduled action after now.
+//
+// Wake and pre_warm actions that fall into a force_sleep window are skipped.
+func (s *IdlewatcherSchedule) Status(now time.Time) *IdlewatcherScheduleStatus {
+	status := &IdlewatcherScheduleStatus{
+		KeepAwake:  s.IsKeepAwake(now),
+		ForceSleep: s.IsForceSleep(now),
+	}
+
+	var nextAt time.Time
+	setNext := func(action IdlewatcherScheduleAction, t time.Time) {
+		if t.IsZero() || !nextAt.IsZero() && !t.Before(nextAt) {
+			return
+		}
+		status.NextAction = action
+		nextAt = t
+	}
+	setNext(IdlewatcherScheduleActionSleep, s.ForceSleep.NextStart(now, idlewatcherScheduleLookahead))
+	setNext(IdlewatcherScheduleActionWake, s.nextOutsideForceSleep(now, func(t time.Time) time.Time {
+		return s.KeepAwake.NextStart(t, idlewatcherScheduleLookahead)
+	}))
+	setNext(IdlewatcherScheduleActionPreWarm, s.nextOutsideForceSleep(now, s.PreWarm.Next))
+
+	if !nextAt.IsZero() {
+		status.NextActionAt = nextAt.Unix()
+		status.NextActionAtStr = strutils.FormatTime(nextAt)
+	}
+	return status
+}
+
+func (s *IdlewatcherSchedule) nextOutsideForceSleep(now time.Time, next func(time.Time) time.Time) time.Time {
+	end := now.Add(idlewatcherScheduleLookahead)
+	for t := next(now); !t.IsZero() && t.Before(end); t = next(t) {
+		if !s.IsForceSleep(t) {
+			return t
+		}
+	}
+	return time.Time{}
 }
 
 func (c *IdlewatcherConfig) Validate() gperr.Error {
@@ -92,7 +241,7 @@ func (c *IdlewatcherConfig) ValErr() gperr.Error {
 }
 
 func (c *IdlewatcherConfig) validateProvider() error {
-	if c.Docker == nil && c.Proxmox == nil {
+	if !c.HasProvider() {
 		return gperr.New("missing idlewatcher provider config")
 	}
 	return nil
@@ -113,7 +262,12 @@ func (c *IdlewatcherConfig) validateStopMethod() error {
 	case "":
 		c.StopMethod = ContainerStopMethodStop
 		return nil
-	case ContainerStopMethodPause, ContainerStopMethodStop, ContainerStopMethodKill:
+	case ContainerStopMethodPause:
+		if c.Exec != nil {
+			return gperr.New("stop method pause is not supported by exec provider")
+		}
+		return nil
+	case ContainerStopMethodStop, ContainerStopMethodKill:
 		return nil
 	default:
 		return gperr.New("invalid stop method").Subject(string(c.StopMethod))
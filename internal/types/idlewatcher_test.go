@@ -2,6 +2,7 @@ package types
 
 import (
 	"testing"
+	"time"
 
 	expect "github.com/yusing/goutils/testing"
 )
@@ -47,3 +48,44 @@ func TestValidateStartEndpoint(t *testing.T) {
 		})
 	}
 }
+
+func TestIdlewatcherScheduleStatus(t *testing.T) {
+	s := new(IdlewatcherSchedule)
+	expect.NoError(t, s.KeepAwake.Parse("* 9-17 * * mon-fri"))
+	expect.NoError(t, s.ForceSleep.Parse("* 0-5 * * *"))
+	expect.NoError(t, s.PreWarm.Parse("45 8 * * *;30 3 * * *"))
+
+	at := func(s string) time.Time {
+		t, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+
+	// monday, pre_warm before keep_awake
+	status := s.Status(at("2026-10-19 08:00"))
+	expect.False(t, status.KeepAwake)
+	expect.False(t, status.ForceSleep)
+	expect.Equal(t, status.NextAction, IdlewatcherScheduleActionPreWarm)
+	expect.Equal(t, status.NextActionAt, at("2026-10-19 08:45").Unix())
+
+	status = s.Status(at("2026-10-19 08:50"))
+	expect.Equal(t, status.NextAction, IdlewatcherScheduleActionWake)
+	expect.Equal(t, status.NextActionAt, at("2026-10-19 09:00").Unix())
+
+	status = s.Status(at("2026-10-19 12:00"))
+	expect.True(t, status.KeepAwake)
+	expect.Equal(t, status.NextAction, IdlewatcherScheduleActionSleep)
+	expect.Equal(t, status.NextActionAt, at("2026-10-20 00:00").Unix())
+
+	// pre_warm during force_sleep is skipped
+	status = s.Status(at("2026-10-20 01:00"))
+	expect.True(t, status.ForceSleep)
+	expect.False(t, s.IsPreWarm(at("2026-10-20 03:30")))
+	expect.Equal(t, status.NextAction, IdlewatcherScheduleActionPreWarm)
+	expect.Equal(t, status.NextActionAt, at("2026-10-20 08:45").Unix())
+
+	expect.True(t, (*IdlewatcherSchedule)(nil).IsEmpty())
+	expect.False(t, s.IsEmpty())
+}
@@ -16,6 +16,11 @@ type (
 		Sticky       bool             `json:"sticky"`
 		StickyMaxAge time.Duration    `json:"sticky_max_age"`
 		Options      map[string]any   `json:"options,omitempty"`
+		// Override is a rule `on` expression, requests matching it
+		// are always sent to this server regardless of mode and weight.
+		//
+		// e.g. `header X-Canary 1 | cookie canary 1`
+		Override string `json:"override,omitempty"`
 	} // @name LoadBalancerConfig
 	LoadBalancerMode   string // @name LoadBalancerMode
 	LoadBalancerServer interface {
@@ -36,6 +41,10 @@ const (
 	LoadbalanceModeRoundRobin LoadBalancerMode = "roundrobin"
 	LoadbalanceModeLeastConn  LoadBalancerMode = "leastconn"
 	LoadbalanceModeIPHash     LoadBalancerMode = "iphash"
+	LoadbalanceModeWeighted   LoadBalancerMode = "weighted"
+	// LoadbalanceModeSplit splits traffic by weight like [LoadbalanceModeWeighted],
+	// and always pins each client to its assigned server with a sticky cookie.
+	LoadbalanceModeSplit LoadBalancerMode = "split"
 )
 
 const StickyMaxAgeDefault = 1 * time.Hour
@@ -53,6 +62,12 @@ func (mode *LoadBalancerMode) ValidateUpdate() bool {
 	case string(LoadbalanceModeIPHash):
 		*mode = LoadbalanceModeIPHash
 		return true
+	case string(LoadbalanceModeWeighted):
+		*mode = LoadbalanceModeWeighted
+		return true
+	case string(LoadbalanceModeSplit):
+		*mode = LoadbalanceModeSplit
+		return true
 	}
 	*mode = LoadbalanceModeRoundRobin
 	return false
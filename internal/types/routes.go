@@ -43,10 +43,14 @@ type (
 		UseIdleWatcher() bool
 		UseHealthCheck() bool
 		UseAccessLog() bool
+		ServesEntrypoint(name string, serveAllRoutes bool) bool
 	}
 	HTTPRoute interface {
 		Route
 		http.Handler
+
+		ALPNProtos() []string
+		AllowsProtoMajor(major int) bool
 	}
 	ReverseProxyRoute interface {
 		HTTPRoute
@@ -10,6 +10,8 @@ type (
 		NumNapping   uint16 `json:"napping"`
 		NumError     uint16 `json:"error"`
 		NumUnknown   uint16 `json:"unknown"`
+		// routes of offline agents
+		NumAgentUnreachable uint16 `json:"agent_unreachable"`
 	} //	@name	RouteStats
 	ProviderStats struct {
 		Total   uint16        `json:"total"`
@@ -35,6 +37,8 @@ func (stats *RouteStats) Add(r Route) {
 		stats.NumNapping++
 	case StatusError:
 		stats.NumError++
+	case StatusAgentUnreachable:
+		stats.NumAgentUnreachable++
 	default:
 		stats.NumUnknown++
 	}
@@ -47,4 +51,5 @@ func (stats *RouteStats) AddOther(other RouteStats) {
 	stats.NumNapping += other.NumNapping
 	stats.NumError += other.NumError
 	stats.NumUnknown += other.NumUnknown
+	stats.NumAgentUnreachable += other.NumAgentUnreachable
 }
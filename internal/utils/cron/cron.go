@@ -0,0 +1,322 @@
+// Package cron parses cron expressions of 5 fields: minute, hour, day of month, month and day of week.
+//
+// Each field is either "*", a value, a range "a-b", a step "*/n" or "a-b/n", or a comma separated list of them.
+// Months and days of week can also be given by name, e.g. "jan" and "mon".
+//
+// Times are matched in their own location, callers passing time.Now() get the process local timezone (TZ).
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Schedule struct {
+	expr string
+
+	minute, hour, dom, month, dow uint64
+	// day of month and day of week are matched with OR when both are restricted, like in crontab.
+	// Fields starting with "*", e.g. "*/2", are not restricted.
+	domStar, dowStar bool
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    []string // names of values from min
+}
+
+var fields = [5]field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	// 7 is also sunday
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+const (
+	allMinutes = 1<<60 - 1
+	allHours   = 1<<24 - 1
+)
+
+// maxSearchYears limits the search of Next for expressions that never match, e.g. "0 0 30 2 *".
+const maxSearchYears = 5
+
+var ErrInvalidExpression = errors.New("invalid cron expression, expect 5 fields: minute hour day_of_month month day_of_week")
+
+func Parse(expr string) (*Schedule, error) {
+	s := new(Schedule)
+	if err := s.Parse(expr); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Parse implements strutils.Parser.
+func (s *Schedule) Parse(expr string) error {
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return fmt.Errorf("%w: %q", ErrInvalidExpression, expr)
+	}
+
+	var bitsets [5]uint64
+	for i, part := range parts {
+		b, err := fields[i].parse(strings.ToLower(part))
+		if err != nil {
+			return fmt.Errorf("%s: %w", fields[i].name, err)
+		}
+		bitsets[i] = b
+	}
+
+	*s = Schedule{
+		expr:    strings.Join(parts, " "),
+		minute:  bitsets[0],
+		hour:    bitsets[1],
+		dom:     bitsets[2],
+		month:   bitsets[3],
+		dow:     bitsets[4],
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+	}
+	// sunday is both 0 and 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return nil
+}
+
+func (f *field) parse(s string) (uint64, error) {
+	var b uint64
+	for item := range strings.SplitSeq(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+		}
+
+		var start, end int
+		switch {
+		case rng == "*":
+			start, end = f.min, f.max
+		case strings.Contains(rng, "-"):
+			lo, hi, _ := strings.Cut(rng, "-")
+			var err error
+			if start, err = f.value(lo); err != nil {
+				return 0, err
+			}
+			if end, err = f.value(hi); err != nil {
+				return 0, err
+			}
+			// sunday as the end of a range of days of week, e.g. "mon-sun"
+			if end == 0 && start > end && f.max == 7 {
+				end = 7
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		default:
+			var err error
+			if start, err = f.value(rng); err != nil {
+				return 0, err
+			}
+			end = start
+			if hasStep { // "n/step" is "n-max/step"
+				end = f.max
+			}
+		}
+		for v := start; v <= end; v += step {
+			b |= 1 << v
+		}
+	}
+	return b, nil
+}
+
+func (f *field) value(s string) (int, error) {
+	for i, name := range f.names {
+		if s == name {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+func has(b uint64, v int) bool {
+	return b&(1<<v) != 0
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := has(s.dom, t.Day())
+	dowMatch := has(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Match returns whether the minute of t matches the schedule.
+func (s *Schedule) Match(t time.Time) bool {
+	return has(s.minute, t.Minute()) &&
+		has(s.hour, t.Hour()) &&
+		has(s.month, int(t.Month())) &&
+		s.dayMatches(t)
+}
+
+// Next returns the first matching minute after t, or zero time if there is none in the next few years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	loc := t.Location()
+	yearLimit := t.Year() + maxSearchYears
+
+	for t.Year() <= yearLimit {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !has(s.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if !has(s.minute, t.Minute()) {
+			// jump to the next matching minute of the hour, or the next hour
+			if next := s.minute >> (t.Minute() + 1); next != 0 {
+				t = t.Add(time.Duration(bits.TrailingZeros64(next)+1) * time.Minute)
+			} else {
+				t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			}
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// NextStart returns the start of the next window after t,
+// i.e. the next matching minute that is not preceded by a matching minute.
+//
+// It returns zero time if there is none within limit, e.g. "* * * * *" has no start.
+func (s *Schedule) NextStart(t time.Time, limit time.Duration) time.Time {
+	end := t.Add(limit)
+	for t.Before(end) {
+		t = s.Next(t)
+		if t.IsZero() || t.After(end) {
+			return time.Time{}
+		}
+		if !s.Match(t.Add(-time.Minute)) {
+			return t
+		}
+		// inside a window, skip to its end
+		t = s.nextMismatch(t, end)
+	}
+	return time.Time{}
+}
+
+// nextMismatch returns the first minute from t that does not match, or end if all minutes before it match.
+//
+// Matching runs are skipped a field at a time: minutes within the hour, hours within the day, then whole days.
+func (s *Schedule) nextMismatch(t, end time.Time) time.Time {
+	loc := t.Location()
+	for s.Match(t) && t.Before(end) {
+		switch {
+		case s.minute != allMinutes:
+			t = t.Add(time.Duration(bits.TrailingZeros64(^(s.minute >> t.Minute()))) * time.Minute)
+		case s.hour != allHours:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+bits.TrailingZeros64(^(s.hour>>t.Hour())), 0, 0, 0, loc)
+		default:
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		}
+	}
+	return t
+}
+
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+func (s *Schedule) MarshalText() ([]byte, error) {
+	return []byte(s.expr), nil
+}
+
+func (s *Schedule) UnmarshalText(data []byte) error {
+	return s.Parse(string(data))
+}
+
+// Schedules matches when any of the schedules matches.
+type Schedules []*Schedule
+
+// Parse implements strutils.Parser.
+//
+// Expressions are separated by semicolons or newlines, as commas are part of cron expressions.
+func (ss *Schedules) Parse(v string) error {
+	exprs := strings.FieldsFunc(v, func(r rune) bool {
+		return r == ';' || r == '\n'
+	})
+	res := make(Schedules, 0, len(exprs))
+	for _, expr := range exprs {
+		// yaml list item
+		expr = strings.TrimPrefix(strings.TrimSpace(expr), "- ")
+		if expr == "" {
+			continue
+		}
+		s, err := Parse(expr)
+		if err != nil {
+			return err
+		}
+		res = append(res, s)
+	}
+	*ss = res
+	return nil
+}
+
+func (ss Schedules) Match(t time.Time) bool {
+	for _, s := range ss {
+		if s.Match(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the earliest matching minute after t, or zero time if there is none.
+func (ss Schedules) Next(t time.Time) (next time.Time) {
+	for _, s := range ss {
+		next = earliest(next, s.Next(t))
+	}
+	return next
+}
+
+// NextStart returns the earliest start of a window after t, see [Schedule.NextStart].
+//
+// Overlapping windows of different schedules are not merged.
+func (ss Schedules) NextStart(t time.Time, limit time.Duration) (next time.Time) {
+	for _, s := range ss {
+		next = earliest(next, s.NextStart(t, limit))
+	}
+	return next
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,136 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	expect "github.com/yusing/goutils/testing"
+)
+
+func date(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParse(t *testing.T) {
+	for _, expr := range []string{
+		"* * * * *",
+		"0 9 * * 1-5",
+		"*/15 8-18 * * mon-fri",
+		"0,30 22 1 jan,jul sun",
+		"5/10 * * * 7",
+		"0 9 * * mon-sun",
+	} {
+		s, err := Parse(expr)
+		expect.NoError(t, err)
+		expect.Equal(t, s.String(), expr)
+	}
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"* * * * tue-mon",
+		"*/0 * * * *",
+		"* * * foo *",
+	} {
+		_, err := Parse(expr)
+		expect.True(t, err != nil)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	s, err := Parse("*/15 8-17 * * mon-fri")
+	expect.NoError(t, err)
+
+	expect.True(t, s.Match(date("2026-10-19 08:00")))  // monday
+	expect.True(t, s.Match(date("2026-10-23 17:45")))  // friday
+	expect.False(t, s.Match(date("2026-10-19 08:10"))) // minute
+	expect.False(t, s.Match(date("2026-10-19 18:00"))) // hour
+	expect.False(t, s.Match(date("2026-10-18 08:00"))) // sunday
+
+	// day of month or day of week when both are restricted
+	s, err = Parse("0 0 1 * 0")
+	expect.NoError(t, err)
+	expect.True(t, s.Match(date("2026-10-01 00:00"))) // thursday
+	expect.True(t, s.Match(date("2026-10-18 00:00"))) // sunday
+	expect.False(t, s.Match(date("2026-10-19 00:00")))
+
+	// day of month with a step is not restricted
+	s, err = Parse("0 0 */2 * mon")
+	expect.NoError(t, err)
+	expect.True(t, s.Match(date("2026-10-19 00:00")))  // monday, odd day
+	expect.False(t, s.Match(date("2026-10-20 00:00"))) // tuesday, even day
+	expect.False(t, s.Match(date("2026-10-21 00:00"))) // wednesday, odd day
+
+	// sunday as 7
+	s, err = Parse("0 0 * * 7")
+	expect.NoError(t, err)
+	expect.True(t, s.Match(date("2026-10-18 00:00")))
+
+	// sunday as the end of a range
+	s, err = Parse("0 0 * * sat-sun")
+	expect.NoError(t, err)
+	expect.True(t, s.Match(date("2026-10-17 00:00")))
+	expect.True(t, s.Match(date("2026-10-18 00:00")))
+	expect.False(t, s.Match(date("2026-10-19 00:00")))
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"* * * * *", "2026-10-19 08:00", "2026-10-19 08:01"},
+		{"45 7 * * 1-5", "2026-10-19 08:00", "2026-10-20 07:45"},
+		{"45 7 * * 1-5", "2026-10-23 08:00", "2026-10-26 07:45"},
+		{"*/20 9 * * *", "2026-10-19 09:21", "2026-10-19 09:40"},
+		{"0 0 1 jan *", "2026-10-19 09:21", "2027-01-01 00:00"},
+		{"0 0 29 2 *", "2026-10-19 09:21", "2028-02-29 00:00"},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		expect.NoError(t, err)
+		expect.Equal(t, s.Next(date(tt.from)), date(tt.want))
+	}
+
+	s, err := Parse("0 0 30 2 *")
+	expect.NoError(t, err)
+	expect.True(t, s.Next(date("2026-10-19 09:21")).IsZero())
+}
+
+func TestNextStart(t *testing.T) {
+	s, err := Parse("* 8-17 * * 1-5")
+	expect.NoError(t, err)
+
+	// inside a window
+	expect.Equal(t, s.NextStart(date("2026-10-19 09:00"), 7*24*time.Hour), date("2026-10-20 08:00"))
+	// before a window
+	expect.Equal(t, s.NextStart(date("2026-10-19 07:00"), 7*24*time.Hour), date("2026-10-19 08:00"))
+	// weekend
+	expect.Equal(t, s.NextStart(date("2026-10-23 18:00"), 7*24*time.Hour), date("2026-10-26 08:00"))
+
+	// windows spanning midnight and whole days
+	s, err = Parse("* 22-23,0-5 * * *")
+	expect.NoError(t, err)
+	expect.Equal(t, s.NextStart(date("2026-10-19 23:00"), 7*24*time.Hour), date("2026-10-20 22:00"))
+	s, err = Parse("* * * * sat,sun")
+	expect.NoError(t, err)
+	expect.Equal(t, s.NextStart(date("2026-10-17 12:00"), 14*24*time.Hour), date("2026-10-24 00:00"))
+	s, err = Parse("0-29 9 * * *")
+	expect.NoError(t, err)
+	expect.Equal(t, s.NextStart(date("2026-10-19 09:10"), 7*24*time.Hour), date("2026-10-20 09:00"))
+
+	s, err = Parse("* * * * *")
+	expect.NoError(t, err)
+	expect.True(t, s.NextStart(date("2026-10-19 09:00"), 24*time.Hour).IsZero())
+}
@@ -9,8 +9,8 @@ import (
 type (
 	Event struct {
 		Type            EventType
-		ActorName       string            // docker: container name, file: relative file path
-		ActorID         string            // docker: container id, file: empty
+		ActorName       string            // docker: container name, file: relative file path, proxmox: guest name
+		ActorID         string            // docker: container id, file: empty, proxmox: vmid
 		ActorAttributes map[string]string // docker: container labels, file: empty
 		Action          Action
 	}
@@ -41,8 +41,9 @@ const (
 )
 
 const (
-	EventTypeDocker EventType = "docker"
-	EventTypeFile   EventType = "file"
+	EventTypeDocker  EventType = "docker"
+	EventTypeFile    EventType = "file"
+	EventTypeProxmox EventType = "proxmox"
 )
 
 var DockerEventMap = map[dockerEvents.Action]Action{
@@ -10,7 +10,6 @@ import (
 
 type (
 	AgentProxiedMonitor struct {
-		agent *agentPkg.AgentConfig
 		query synk.Value[string]
 		*monitor
 	}
@@ -46,10 +45,9 @@ func (target *AgentCheckHealthTarget) displayURL() *url.URL {
 }
 
 func NewAgentProxiedMonitor(agent *agentPkg.AgentConfig, config *types.HealthCheckConfig, target *AgentCheckHealthTarget) *AgentProxiedMonitor {
-	mon := &AgentProxiedMonitor{
-		agent: agent,
-	}
+	mon := new(AgentProxiedMonitor)
 	mon.monitor = newMonitor(target.displayURL(), config, mon.CheckHealth)
+	mon.agent = agent
 	mon.query.Store(target.buildQuery())
 	return mon
 }
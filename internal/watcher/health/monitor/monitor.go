@@ -10,6 +10,8 @@ import (
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	agentPkg "github.com/yusing/godoxy/agent/pkg/agent"
+	"github.com/yusing/godoxy/internal/agentheartbeat"
 	"github.com/yusing/godoxy/internal/common"
 	"github.com/yusing/godoxy/internal/docker"
 	"github.com/yusing/godoxy/internal/notif"
@@ -35,6 +37,8 @@ type (
 
 		isZeroPort bool
 
+		agent *agentPkg.AgentConfig // agent of the route, nil if not proxied through an agent
+
 		notifyFunc           notif.NotifyFunc
 		numConsecFailures    atomic.Int64
 		downNotificationSent atomic.Bool
@@ -66,7 +70,9 @@ func NewMonitor(r types.Route) types.HealthMonCheck {
 			return mon
 		}
 		r.Task().OnCancel("close_docker_client", client.Close)
-		return NewDockerHealthMonitor(client, cont.ContainerID, r.Name(), r.HealthCheckConfig(), mon)
+		dockerMon := NewDockerHealthMonitor(client, cont.ContainerID, r.Name(), r.HealthCheckConfig(), mon)
+		dockerMon.agent = r.GetAgent()
+		return dockerMon
 	}
 	return mon
 }
@@ -254,6 +260,11 @@ func (mon *monitor) MarshalJSON() ([]byte, error) {
 
 func (mon *monitor) checkUpdateHealth() error {
 	logger := log.With().Str("name", mon.Name()).Logger()
+	if mon.agent != nil && agentheartbeat.RoutesUnreachable(mon.agent) {
+		mon.setAgentUnreachable()
+		return nil
+	}
+
 	result, err := mon.checkHealth()
 
 	var lastStatus types.HealthStatus
@@ -272,7 +283,10 @@ func (mon *monitor) checkUpdateHealth() error {
 	// change of status
 	if result.Healthy != (lastStatus == types.StatusHealthy) {
 		if result.Healthy {
-			mon.notifyServiceUp(&logger, &result)
+			// routes coming back with their agent are covered by the agent notification
+			if lastStatus != types.StatusAgentUnreachable || mon.downNotificationSent.Load() {
+				mon.notifyServiceUp(&logger, &result)
+			}
 			mon.numConsecFailures.Store(0)
 			mon.downNotificationSent.Store(false) // Reset notification state when service comes back up
 		} else if mon.config.Retries < 0 {
@@ -294,6 +308,14 @@ func (mon *monitor) checkUpdateHealth() error {
 	return err
 }
 
+// setAgentUnreachable marks the service as "agent unreachable" instead of checking it,
+// the agent going offline is notified by the agent heartbeat instead.
+func (mon *monitor) setAgentUnreachable() {
+	mon.status.Store(types.StatusAgentUnreachable)
+	mon.lastResult.Store(types.HealthCheckResult{Healthy: false, Detail: "agent unreachable"})
+	mon.numConsecFailures.Store(0)
+}
+
 func (mon *monitor) notifyServiceUp(logger *zerolog.Logger, result *types.HealthCheckResult) {
 	logger.Info().Msg("service is up")
 	extras := mon.buildNotificationExtras(result)
@@ -8,6 +8,8 @@ import (
 
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
+	agentPkg "github.com/yusing/godoxy/agent/pkg/agent"
+	"github.com/yusing/godoxy/internal/agentheartbeat"
 	"github.com/yusing/godoxy/internal/notif"
 	"github.com/yusing/godoxy/internal/types"
 	"github.com/yusing/goutils/task"
@@ -310,3 +312,32 @@ func TestImmediateUpNotification(t *testing.T) {
 	require.Equal(t, 0, down)
 	require.Equal(t, "up", last)
 }
+
+func TestAgentUnreachable(t *testing.T) {
+	parent := task.RootTask("test", false)
+	t.Cleanup(func() { parent.Finish(nil) })
+	agentheartbeat.Start(parent, agentheartbeat.Config{Interval: time.Hour, MarkRoutesUnreachable: true})
+
+	mon, tracker := createTestMonitor(&types.HealthCheckConfig{
+		Interval: 50 * time.Millisecond,
+		Timeout:  50 * time.Millisecond,
+		Retries:  -1,
+	}, func() (types.HealthCheckResult, error) {
+		return types.HealthCheckResult{Healthy: true}, nil
+	})
+	mon.agent = &agentPkg.AgentConfig{Addr: "test-agent"}
+
+	mon.agent.SetStatus(&agentPkg.AgentStatus{Online: false})
+	require.NoError(t, mon.checkUpdateHealth())
+	require.Equal(t, types.StatusAgentUnreachable, mon.Status())
+	require.Equal(t, "agent unreachable", mon.Detail())
+
+	// back with the agent, covered by the agent notification
+	mon.agent.SetStatus(&agentPkg.AgentStatus{Online: true})
+	require.NoError(t, mon.checkUpdateHealth())
+	require.Equal(t, types.StatusHealthy, mon.Status())
+
+	up, down, _ := tracker.getStats()
+	require.Equal(t, 0, up)
+	require.Equal(t, 0, down)
+}